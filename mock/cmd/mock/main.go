@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -20,11 +23,19 @@ type Product struct {
 }
 
 func main() {
+	addr := ":9090"
+	log.Printf("Mock REST server listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, newRouter()))
+}
+
+// newRouter builds the mock API; split out of main so tests can serve it directly.
+func newRouter() http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	r.Use(faultInjection)
 
 	// Simple mock API under /api
 	r.Get("/api/orders/{orderId}", func(w http.ResponseWriter, r *http.Request) {
@@ -39,7 +50,85 @@ func main() {
 		_ = json.NewEncoder(w).Encode(Product{ID: id, Name: "Sample"})
 	})
 
-	addr := ":9090"
-	log.Printf("Mock REST server listening on %s", addr)
-	log.Fatal(http.ListenAndServe(addr, r))
+	return r
+}
+
+// faultInjection lets integration tests exercise gateway error handling via query params:
+//   - ?delay=2s       sleep before responding
+//   - ?status=503     respond with the given status
+//   - ?malformed=true respond with invalid JSON
+//   - ?size=1mb       respond with a large JSON body (supports b, kb, mb)
+//
+// Without any of these the wrapped handler runs unchanged.
+func faultInjection(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if d := q.Get("delay"); d != "" {
+			dur, err := time.ParseDuration(d)
+			if err != nil {
+				http.Error(w, "invalid delay", http.StatusBadRequest)
+				return
+			}
+			select {
+			case <-time.After(dur):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		status := http.StatusOK
+		if s := q.Get("status"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 100 || n > 599 {
+				http.Error(w, "invalid status", http.StatusBadRequest)
+				return
+			}
+			status = n
+		}
+		if q.Get("malformed") == "true" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"id": "truncated", "amount": `))
+			return
+		}
+		if s := q.Get("size"); s != "" {
+			n, err := parseSize(s)
+			if err != nil {
+				http.Error(w, "invalid size", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(map[string]string{"data": strings.Repeat("x", n)})
+			return
+		}
+		if status != http.StatusOK {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": http.StatusText(status)})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maxInjectedSize caps ?size so a typo cannot exhaust memory.
+const maxInjectedSize = 64 << 20
+
+// parseSize accepts sizes like "512", "64kb" or "1mb".
+func parseSize(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	mult := 1
+	switch {
+	case strings.HasSuffix(s, "mb"):
+		mult, s = 1<<20, strings.TrimSuffix(s, "mb")
+	case strings.HasSuffix(s, "kb"):
+		mult, s = 1<<10, strings.TrimSuffix(s, "kb")
+	case strings.HasSuffix(s, "b"):
+		s = strings.TrimSuffix(s, "b")
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n*mult > maxInjectedSize {
+		return 0, strconv.ErrSyntax
+	}
+	return n * mult, nil
 }
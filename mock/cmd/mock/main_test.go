@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func serve(t *testing.T, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestHappyPath(t *testing.T) {
+	rec := serve(t, "/api/orders/42")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var o Order
+	if err := json.Unmarshal(rec.Body.Bytes(), &o); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if o.ID != "42" || o.Amount != 100 {
+		t.Fatalf("order = %+v", o)
+	}
+}
+
+func TestFaultStatus(t *testing.T) {
+	rec := serve(t, "/api/orders/42?status=503")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["error"] != "Service Unavailable" {
+		t.Fatalf("body = %q (%v)", rec.Body.String(), err)
+	}
+	if rec := serve(t, "/api/orders/42?status=abc"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid status: got %d, want 400", rec.Code)
+	}
+}
+
+func TestFaultDelay(t *testing.T) {
+	start := time.Now()
+	rec := serve(t, "/api/orders/42?delay=50ms")
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("responded after %v, want >= 50ms", elapsed)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if rec := serve(t, "/api/orders/42?delay=soon"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid delay: got %d, want 400", rec.Code)
+	}
+}
+
+func TestFaultDelayHonoursCancellation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/42?delay=10s", nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		newRouter().ServeHTTP(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("delay ignored request cancellation")
+	}
+}
+
+func TestFaultMalformed(t *testing.T) {
+	rec := serve(t, "/api/orders/42?malformed=true")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if json.Valid(rec.Body.Bytes()) {
+		t.Fatalf("body %q is valid JSON", rec.Body.String())
+	}
+	if rec := serve(t, "/api/orders/42?malformed=true&status=502"); rec.Code != http.StatusBadGateway || json.Valid(rec.Body.Bytes()) {
+		t.Fatalf("malformed with status: got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestFaultSize(t *testing.T) {
+	rec := serve(t, "/api/products/p1?size=1mb")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body["data"]) != 1<<20 {
+		t.Fatalf("data length = %d, want %d", len(body["data"]), 1<<20)
+	}
+	if rec := serve(t, "/api/products/p1?size=1gb"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid size: got %d, want 400", rec.Code)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int{"512": 512, "512b": 512, "64kb": 64 << 10, "1MB": 1 << 20}
+	for in, want := range cases {
+		got, err := parseSize(in)
+		if err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "-1", "x", "65mb"} {
+		if _, err := parseSize(in); err == nil {
+			t.Errorf("parseSize(%q) succeeded", in)
+		}
+	}
+}
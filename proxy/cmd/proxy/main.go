@@ -2,9 +2,14 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -13,15 +18,25 @@ import (
 
 	"gateway/proxy/internal/auth"
 	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/engine"
+	"gateway/proxy/internal/events"
+	"gateway/proxy/internal/filesource"
 	"gateway/proxy/internal/handlers"
+	"gateway/proxy/internal/logging"
+	"gateway/proxy/internal/metrics"
 	"gateway/proxy/internal/session"
 	"gateway/proxy/internal/store"
 )
 
 func main() {
+	// Structured JSON logs; the standard log package is routed through the same handler
+	logger := logging.NewLogger(getEnv("LOG_LEVEL", "info"))
+	slog.SetDefault(logger)
+
 	// Configuration (MVP: simple env + in-memory)
 	resourceAudience := getEnv("GATEWAY_RESOURCE_AUDIENCE", "https://gateway.local/proxy")
-	httpAddr := getEnv("HTTP_ADDR", ":8080")
+	// Bind to loopback by default; containers set HTTP_ADDR=:8080 explicitly
+	httpAddr := getEnv("HTTP_ADDR", "127.0.0.1:8080")
 
 	// Choose store backend: Postgres if DATABASE_URL is set, else in-memory
 	var backend store.Store
@@ -41,7 +56,10 @@ func main() {
 		} else {
 			log.Printf("warning: could not read schema file: %v", err)
 		}
-		backend = store.NewPostgresStore(db, resourceAudience)
+		pg := store.NewPostgresStore(db, resourceAudience)
+		// Tenant, server and tool reads are cached per process; 0 disables the cache
+		pg.SetLookupCache(getEnvDuration("STORE_CACHE_TTL", 10*time.Second), getEnvInt("STORE_CACHE_MAX_ENTRIES", 10000))
+		backend = pg
 		log.Printf("Using Postgres store")
 	} else {
 		mem := store.NewMemoryStore(resourceAudience)
@@ -52,19 +70,164 @@ func main() {
 
 	// JWT validator factory (per-tenant issuers)
 	validator := auth.NewJWTValidator(backend)
+	validator.SetFetchTimeout(getEnvDuration("JWKS_FETCH_TIMEOUT", 5*time.Second))
+	validator.SetDiscoveryTTL(getEnvDuration("OIDC_DISCOVERY_TTL", time.Hour))
 	if os.Getenv("UNPROTECTED") == "1" || os.Getenv("UNPROTECTED") == "true" {
 		config.Unprotected = true
 	}
+	if v := os.Getenv("STRICT_TOOL_ARGS"); v == "1" || v == "true" {
+		config.StrictToolArgs = true
+	}
+	if v := os.Getenv("STRICT_PARAMS"); v == "1" || v == "true" {
+		config.StrictParams = true
+	}
+	if v := os.Getenv("KEEP_UNRESOLVED_PLACEHOLDERS"); v == "1" || v == "true" {
+		config.KeepUnresolvedPlaceholders = true
+	}
+	if v := os.Getenv("SESSION_COOKIE"); v == "1" || v == "true" {
+		config.SessionCookie = true
+	}
+	config.SessionCookieSameSite = getEnv("SESSION_COOKIE_SAMESITE", config.SessionCookieSameSite)
+	if v := os.Getenv("AUDIT_TOOL_CALLS"); v == "1" || v == "true" {
+		config.AuditToolCalls = true
+	}
+	config.BatchMaxParallel = getEnvInt("MCP_BATCH_MAX_PARALLEL", config.BatchMaxParallel)
+	config.BatchMaxSize = getEnvInt("MCP_BATCH_MAX_SIZE", config.BatchMaxSize)
+	if os.Getenv("ERROR_VERBOSITY") == config.ErrorVerbosityDebug {
+		config.ErrorVerbosity = config.ErrorVerbosityDebug
+	}
+	switch v := os.Getenv("UPSTREAM_EMPTY_RESPONSE"); v {
+	case config.EmptyResponseOK, config.EmptyResponseText:
+		config.EmptyResponse = v
+	case "", config.EmptyResponseNull:
+	default:
+		log.Printf("ignoring UPSTREAM_EMPTY_RESPONSE=%q; expected null, ok or text", v)
+	}
+	if v := os.Getenv("ALLOWED_ORIGINS"); v != "" {
+		config.AllowedOrigins = splitCSV(v)
+	}
+	if v := os.Getenv("ALLOWED_HOSTS"); v != "" {
+		config.AllowedHosts = splitCSV(v)
+	}
+	config.UpstreamUserAgent = getEnv("UPSTREAM_USER_AGENT", config.UpstreamUserAgent)
+	if v := os.Getenv("UPSTREAM_DEFAULT_HEADERS"); v != "" {
+		if err := json.Unmarshal([]byte(v), &config.UpstreamDefaultHeaders); err != nil {
+			log.Fatalf("invalid UPSTREAM_DEFAULT_HEADERS (want a JSON object of strings): %v", err)
+		}
+	}
+	config.DefaultEgressAllowlist = splitCSV(os.Getenv("DEFAULT_EGRESS_ALLOWLIST"))
+	if len(config.DefaultEgressAllowlist) == 0 {
+		log.Printf("warning: DEFAULT_EGRESS_ALLOWLIST is empty; tenants without an egressAllowlist cannot call any upstream")
+	}
+	config.MaxUpstreamResponseBytes = getEnvInt("UPSTREAM_MAX_RESPONSE_BYTES", config.MaxUpstreamResponseBytes)
 
 	// Session manager (e.g., 30 minutes idle TTL)
 	sessionManager := session.NewManager(30 * time.Minute)
+	// Optional per-tenant cap; SESSION_LIMIT_POLICY=evict drops the least recently used session instead of rejecting
+	sessionPolicy := session.LimitReject
+	if getEnv("SESSION_LIMIT_POLICY", "reject") == "evict" {
+		sessionPolicy = session.LimitEvictLRU
+	}
+	sessionManager.SetTenantLimit(getEnvInt("SESSION_MAX_PER_TENANT", 0), sessionPolicy)
+	sessionManager.StartSweeper(time.Minute)
+	defer sessionManager.Close()
+
+	// Shared upstream transport so tool calls reuse pooled connections
+	transportOpts := engine.DefaultTransportOptions()
+	transportOpts.MaxIdleConns = getEnvInt("UPSTREAM_MAX_IDLE_CONNS", transportOpts.MaxIdleConns)
+	transportOpts.MaxIdleConnsPerHost = getEnvInt("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", transportOpts.MaxIdleConnsPerHost)
+	transportOpts.IdleConnTimeout = getEnvDuration("UPSTREAM_IDLE_CONN_TIMEOUT", transportOpts.IdleConnTimeout)
+	if v := os.Getenv("UPSTREAM_SSRF_GUARD"); v == "1" || v == "true" {
+		transportOpts.SSRFGuard = true
+		for _, c := range splitCSV(os.Getenv("UPSTREAM_SSRF_ALLOWED_CIDRS")) {
+			prefix, err := netip.ParsePrefix(c)
+			if err != nil {
+				log.Fatalf("invalid UPSTREAM_SSRF_ALLOWED_CIDRS entry %q: %v", c, err)
+			}
+			transportOpts.SSRFAllowed = append(transportOpts.SSRFAllowed, prefix)
+		}
+	}
+	clients := engine.NewClientFactory(transportOpts)
+
+	// Response cache for read-only tools that opt in via mapping.cacheTTLSeconds
+	responseCache := engine.NewMemoryCache(getEnvInt("UPSTREAM_CACHE_MAX_ENTRIES", 10000))
+	balancer := engine.NewBalancer()
+	// Active probes for servers that configure healthCheck; results feed /readyz and fail-fast calls
+	var healthChecker *engine.HealthChecker
+	if hs, ok := backend.(engine.HealthStore); ok {
+		healthChecker = engine.NewHealthChecker(hs, clients.Client(0), balancer)
+		healthChecker.Start()
+		defer healthChecker.Close()
+	}
+	// Replays results for tools/call retries carrying _meta.idempotencyKey
+	idempotency := engine.NewIdempotency(getEnvInt("IDEMPOTENCY_MAX_ENTRIES", 10000), getEnvDuration("IDEMPOTENCY_TTL", 10*time.Minute))
+	// Fans out server-initiated notifications (e.g. tools/list_changed) to session SSE streams
+	bus := events.NewBus()
+	// A stream whose client falls this many notifications behind is closed as a slow consumer
+	bus.SetStreamBuffer(getEnvInt("SSE_STREAM_BUFFER", 64))
+	config.SSEMaxStreamsPerTenant = getEnvInt("SSE_MAX_STREAMS_PER_TENANT", config.SSEMaxStreamsPerTenant)
+	config.SSEWriteTimeout = getEnvDuration("SSE_WRITE_TIMEOUT", config.SSEWriteTimeout)
+	config.WSMaxMessageBytes = int64(getEnvInt("WS_MAX_MESSAGE_BYTES", int(config.WSMaxMessageBytes)))
+	config.WSIdleTimeout = getEnvDuration("WS_IDLE_TIMEOUT", config.WSIdleTimeout)
+	config.MetricsMaxToolLabels = getEnvInt("METRICS_MAX_TOOL_LABELS", config.MetricsMaxToolLabels)
+	// Child processes for servers with backend "stdio"
+	stdio := engine.NewStdioBridge()
+	defer stdio.Close()
+
+	// Optional GitOps-style definitions: servers and tools loaded from TOOLS_DIR and re-applied on change
+	var definitions *filesource.Loader
+	if dir := os.Getenv("TOOLS_DIR"); dir != "" {
+		fs, ok := backend.(filesource.Store)
+		if !ok {
+			log.Fatalf("TOOLS_DIR is not supported by this store backend")
+		}
+		definitions = filesource.NewLoader(dir, fs, bus, filesource.Precedence(getEnv("TOOLS_DIR_PRECEDENCE", "file")))
+		if err := definitions.Load(); err != nil {
+			log.Fatalf("loading TOOLS_DIR: %v", err)
+		}
+		if err := definitions.Watch(); err != nil {
+			log.Fatalf("watching TOOLS_DIR: %v", err)
+		}
+		defer definitions.Close()
+	}
+
+	// Catch dangling references and missing settings now rather than as runtime 401/404s
+	validatable, _ := backend.(interface {
+		Validate() ([]store.ConfigProblem, error)
+	})
+	if validatable != nil {
+		problems, err := validatable.Validate()
+		if err != nil {
+			log.Fatalf("validating store configuration: %v", err)
+		}
+		for _, p := range problems {
+			log.Printf("config problem: %s", p)
+		}
+		if len(problems) > 0 && getEnv("STORE_VALIDATION", "warn") == "fail" {
+			log.Fatalf("store configuration has %d problems (STORE_VALIDATION=fail)", len(problems))
+		}
+	}
 
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(logging.Middleware(logger))
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(30 * time.Second))
+	r.Use(skipEventStreams(middleware.Timeout(30 * time.Second)))
+
+	// Root aggregate protected resource metadata, for clients probing the root first
+	if agg, ok := backend.(interface {
+		ResourceAudience() string
+		AllAuthorizationServerRefs() []store.AuthorizationServerRef
+	}); ok {
+		r.Get("/.well-known/oauth-protected-resource", handlers.RootProtectedResourceMetadataHandler(agg))
+	}
+
+	// Prometheus text-format metrics
+	r.Get("/metrics", metrics.Handler())
+	if healthChecker != nil {
+		r.Get("/readyz", handlers.ReadyzHandler(healthChecker))
+	}
 
 	// Server-level protected resource metadata (RFC9728)
 	r.Get("/proxy/{server}/.well-known/oauth-protected-resource", handlers.ProtectedResourceMetadataHandler(backend))
@@ -72,14 +235,48 @@ func main() {
 	// Control plane APIs: protect with admin token if provided
 	if pg, ok := backend.(*store.PostgresStore); ok {
 		var cs handlers.ControlStore = pg
-		adminToken := os.Getenv("ADMIN_TOKEN")
+		if definitions != nil {
+			cs = definitions.Guard(cs)
+		}
+		if healthChecker != nil {
+			cs = handlers.ReloadHealthOnWrite(cs, healthChecker)
+		}
+		// ADMIN_TOKEN may list several comma-separated tokens to allow rotation; tokens
+		// added or revoked through the API are kept in the store
+		adminTokens, err := auth.LoadAdminTokens(pg, strings.Split(os.Getenv("ADMIN_TOKEN"), ","))
+		if err != nil {
+			log.Fatalf("load admin tokens: %v", err)
+		}
 		mux := chi.NewRouter()
 		mux.Post("/api/tenants", handlers.UpsertTenantHandler(cs))
+		mux.Get("/api/tenants/{slug}/export", handlers.ExportTenantHandler(cs))
+		mux.Post("/api/import", handlers.ImportHandler(cs, bus))
 		mux.Post("/api/servers", handlers.UpsertServerHandler(cs))
+		mux.Get("/api/servers", handlers.ListServersHandler(pg))
+		mux.Post("/api/servers/{server}/bundle", handlers.UpsertServerBundleHandler(cs, bus))
 		mux.Post("/api/servers/{server}/openapi", handlers.UploadOpenAPIHandler(cs))
-		mux.Post("/api/servers/{server}/tools", handlers.UpsertToolsHandler(cs))
-		if adminToken != "" {
-			r.Mount("/", auth.AdminTokenMiddleware(adminToken)(mux))
+		mux.Post("/api/servers/{server}/openapi/fetch", handlers.FetchOpenAPIHandler(cs, clients, bus))
+		mux.Post("/api/servers/{server}/openapi/validate", handlers.ValidateOpenAPIHandler(cs))
+		mux.Post("/api/servers/{server}/openapi/generate", handlers.GenerateToolsHandler(cs))
+		mux.Post("/api/servers/{server}/tools", handlers.UpsertToolsHandler(cs, bus))
+		mux.Get("/api/servers/{server}/tools", handlers.GetToolsHandler(cs))
+		mux.Get("/api/servers/{server}/mappings", handlers.ValidateMappingsHandler(cs))
+		mux.Patch("/api/servers/{server}/tools/enabled", handlers.SetToolsEnabledHandler(cs, bus))
+		mux.Post("/api/servers/{server}/tools/{tool}/test", handlers.TestToolHandler(cs, clients))
+		mux.Post("/api/api-keys", handlers.CreateAPIKeyHandler(cs))
+		mux.Delete("/api/api-keys/{id}", handlers.RevokeAPIKeyHandler(cs))
+		mux.Post("/api/jwks/refresh", handlers.RefreshJWKSHandler(validator))
+		mux.Post("/api/tenants/{slug}/issuers/refresh", handlers.RefreshIssuersHandler(cs, validator))
+		mux.Post("/api/issuers/test", handlers.TestIssuerHandler(validator))
+		mux.Get("/api/cache/stats", handlers.CacheStatsHandler(responseCache))
+		mux.Get("/api/validate", handlers.ValidateConfigHandler(pg))
+		mux.Get("/api/sessions", handlers.ListSessionsHandler(sessionManager))
+		mux.Delete("/api/sessions/{id}", handlers.TerminateSessionHandler(sessionManager))
+		mux.Get("/api/admin-tokens", handlers.ListAdminTokensHandler(adminTokens))
+		mux.Post("/api/admin-tokens", handlers.AddAdminTokenHandler(adminTokens))
+		mux.Delete("/api/admin-tokens/{id}", handlers.RevokeAdminTokenHandler(adminTokens))
+		if adminTokens.Len() > 0 {
+			r.Mount("/", auth.AdminTokenMiddleware(adminTokens)(mux))
 		} else {
 			// If no token provided, leave open only when UNPROTECTED=1
 			if config.Unprotected {
@@ -90,16 +287,68 @@ func main() {
 		}
 	}
 
-	// Single MCP endpoint (POST JSON-RPC) and session DELETE per spec option
-	r.With(auth.JWTAuthMiddleware(validator)).Post("/proxy/{server}/mcp", handlers.MCPEndpointHandler(backend, sessionManager))
-	r.With(auth.JWTAuthMiddleware(validator)).Delete("/proxy/{server}/mcp", handlers.MCPSessionDeleteHandler(sessionManager))
+	// MCP middleware chain: Origin/Host guard, then API keys (when the backend supports them), then JWT
+	// The server may also come from the X-Gateway-Server header (see /mcp below), so it is
+	// resolved before anything reads the {server} param
+	mcpAuth := []func(http.Handler) http.Handler{handlers.ServerFromHeaderMiddleware, auth.OriginHostMiddleware()}
+	if ks, ok := backend.(auth.APIKeyStore); ok {
+		mcpAuth = append(mcpAuth, auth.APIKeyAuthMiddleware(ks))
+	}
+	mcpAuth = append(mcpAuth, auth.JWTAuthMiddleware(validator))
+
+	// Single MCP endpoint (POST JSON-RPC), GET SSE stream for notifications, and session DELETE per spec option
+	// The stable /mcp path is for clients that select the server via X-Gateway-Server. Each
+	// path first gets MCPMethodHandler for every method, which the routes below override:
+	// OPTIONS and other methods are answered with Allow, before authentication.
+	mcpEndpoint := handlers.MCPEndpointHandler(backend, sessionManager, bus, clients, responseCache, balancer, stdio, idempotency)
+	for _, path := range []string{"/proxy/{server}/mcp", "/mcp"} {
+		r.Handle(path, handlers.MCPMethodHandler())
+		r.With(mcpAuth...).Post(path, mcpEndpoint)
+		r.With(mcpAuth...).Get(path, handlers.MCPStreamHandler(sessionManager, bus))
+		r.With(mcpAuth...).Delete(path, handlers.MCPSessionDeleteHandler(sessionManager))
+	}
+	// WebSocket transport: the same JSON-RPC dispatch with notifications pushed inline
+	r.With(mcpAuth...).Get("/proxy/{server}/ws", handlers.MCPWebSocketHandler(mcpEndpoint, sessionManager, bus))
 
-	log.Printf("MCP proxy listening on %s (audience=%s)", httpAddr, resourceAudience)
-	if err := http.ListenAndServe(httpAddr, r); err != nil {
+	srv := newHTTPServer(httpAddr, r)
+	log.Printf("MCP proxy listening on %s (audience=%s, allowed hosts=%s)", httpAddr, resourceAudience, strings.Join(config.AllowedHosts, ","))
+	if !config.Unprotected && !auth.LoopbackListener(httpAddr) && auth.LoopbackHostsOnly(config.AllowedHosts) {
+		log.Printf("warning: listening on %s but ALLOWED_HOSTS only accepts loopback names; MCP requests for any other Host get 403 forbidden host. Set ALLOWED_HOSTS to the host names clients use", httpAddr)
+	}
+	if err := srv.ListenAndServe(); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// newHTTPServer builds the listener with explicit timeouts to guard against slow clients.
+// WriteTimeout stays above the 30s handler timeout; long-lived streaming routes should
+// extend their own deadline via http.ResponseController rather than raising it globally.
+func newHTTPServer(addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: getEnvDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		ReadTimeout:       getEnvDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+		WriteTimeout:      getEnvDuration("HTTP_WRITE_TIMEOUT", 60*time.Second),
+		IdleTimeout:       getEnvDuration("HTTP_IDLE_TIMEOUT", 120*time.Second),
+	}
+}
+
+// skipEventStreams applies mw to every request except SSE streams and WebSocket upgrades,
+// which are long-lived by design.
+func skipEventStreams(mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet && (strings.Contains(r.Header.Get("Accept"), "text/event-stream") || strings.EqualFold(r.Header.Get("Upgrade"), "websocket")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
+
 func getEnv(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -107,6 +356,36 @@ func getEnv(key, def string) string {
 	return def
 }
 
+func splitCSV(v string) []string {
+	out := []string{}
+	for _, part := range strings.Split(v, ",") {
+		if p := strings.TrimSpace(part); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func getEnvInt(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+		log.Printf("warning: invalid %s=%q, using default %d", key, v, def)
+	}
+	return def
+}
+
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+		log.Printf("warning: invalid %s=%q, using default %s", key, v, def)
+	}
+	return def
+}
+
 func seedDemo(s *store.MemoryStore) {
 	// Demo tenant
 	_ = s.UpsertTenant(store.Tenant{
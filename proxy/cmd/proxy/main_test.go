@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPServerTimeouts(t *testing.T) {
+	srv := newHTTPServer("127.0.0.1:0", http.NotFoundHandler())
+	for name, got := range map[string]time.Duration{
+		"ReadHeaderTimeout": srv.ReadHeaderTimeout,
+		"ReadTimeout":       srv.ReadTimeout,
+		"WriteTimeout":      srv.WriteTimeout,
+		"IdleTimeout":       srv.IdleTimeout,
+	} {
+		if got <= 0 {
+			t.Errorf("%s = %v, want a positive default", name, got)
+		}
+	}
+
+	t.Setenv("HTTP_READ_TIMEOUT", "3s")
+	t.Setenv("HTTP_IDLE_TIMEOUT", "not-a-duration")
+	srv = newHTTPServer("127.0.0.1:0", http.NotFoundHandler())
+	if srv.ReadTimeout != 3*time.Second {
+		t.Errorf("ReadTimeout = %v, want 3s from HTTP_READ_TIMEOUT", srv.ReadTimeout)
+	}
+	if srv.IdleTimeout != 120*time.Second {
+		t.Errorf("IdleTimeout = %v, want the default for an invalid value", srv.IdleTimeout)
+	}
+}
+
+func TestSkipEventStreams(t *testing.T) {
+	reject := func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "timed out", http.StatusServiceUnavailable)
+		})
+	}
+	h := skipEventStreams(reject)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	for _, tc := range []struct {
+		method, header, value string
+		want                  int
+	}{
+		{http.MethodGet, "Accept", "text/event-stream", http.StatusNoContent},
+		{http.MethodGet, "Upgrade", "websocket", http.StatusNoContent},
+		{http.MethodGet, "Accept", "application/json", http.StatusServiceUnavailable},
+		{http.MethodPost, "Accept", "text/event-stream", http.StatusServiceUnavailable},
+	} {
+		req := httptest.NewRequest(tc.method, "/proxy/orders/mcp", nil)
+		req.Header.Set(tc.header, tc.value)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s with %s: %s: status %d, want %d", tc.method, tc.header, tc.value, rec.Code, tc.want)
+		}
+	}
+}
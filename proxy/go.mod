@@ -10,3 +10,13 @@ require (
 require github.com/golang-jwt/jwt/v4 v4.4.2
 
 require github.com/lib/pq v1.10.9
+
+require gopkg.in/yaml.v3 v3.0.1
+
+require github.com/fsnotify/fsnotify v1.7.0
+
+require github.com/gorilla/websocket v1.5.3
+
+require golang.org/x/sys v0.4.0 // indirect
+
+require github.com/DATA-DOG/go-sqlmock v1.5.2
@@ -1,14 +1,185 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gateway/proxy/internal/store"
 )
 
-// AdminTokenMiddleware protects control-plane routes using a shared token.
+// AdminTokenInfo is the public view of an admin token; the secret is never exposed.
+type AdminTokenInfo struct {
+	ID        string    `json:"id"`
+	Label     string    `json:"label"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type adminToken struct {
+	AdminTokenInfo
+	// hash is the hex SHA-256 of the secret; the secret itself is never kept
+	hash string
+}
+
+// AdminTokenStore persists admin tokens so tokens added or revoked through the API
+// survive restarts.
+type AdminTokenStore interface {
+	CreateAdminToken(store.AdminToken) error
+	ListAdminTokens() ([]store.AdminToken, error)
+	RevokeAdminToken(id string) error
+}
+
+// AdminTokens holds the set of currently valid control-plane tokens so they can be
+// rotated at runtime (add the new one, switch clients, revoke the old one).
+type AdminTokens struct {
+	mu     sync.RWMutex
+	tokens map[string]*adminToken
+	// store is nil for a set that lives only in memory
+	store AdminTokenStore
+}
+
+var ErrLastAdminToken = errors.New("cannot revoke the last admin token")
+
+// NewAdminTokens seeds an in-memory set with bootstrap secrets (e.g. from ADMIN_TOKEN).
+func NewAdminTokens(secrets []string) *AdminTokens {
+	a := &AdminTokens{tokens: make(map[string]*adminToken)}
+	for _, t := range bootstrapTokens(secrets) {
+		a.tokens[t.ID] = t
+	}
+	return a
+}
+
+// LoadAdminTokens seeds the set from s and the bootstrap secrets, and persists later
+// additions and revocations to s. A bootstrap secret is recorded in s the first time it is
+// seen, so revoking it through the API keeps it revoked while it is still listed in
+// ADMIN_TOKEN.
+func LoadAdminTokens(s AdminTokenStore, secrets []string) (*AdminTokens, error) {
+	stored, err := s.ListAdminTokens()
+	if err != nil {
+		return nil, err
+	}
+	a := &AdminTokens{tokens: make(map[string]*adminToken), store: s}
+	known := make(map[string]bool, len(stored))
+	for _, t := range stored {
+		known[t.TokenHash] = true
+		if !t.Revoked {
+			a.tokens[t.ID] = &adminToken{AdminTokenInfo: AdminTokenInfo{ID: t.ID, Label: t.Label, CreatedAt: time.UnixMilli(t.CreatedUnixMilli)}, hash: t.TokenHash}
+		}
+	}
+	for _, t := range bootstrapTokens(secrets) {
+		if known[t.hash] {
+			continue
+		}
+		if err := s.CreateAdminToken(t.record()); err != nil {
+			return nil, err
+		}
+		a.tokens[t.ID] = t
+	}
+	return a, nil
+}
+
+// bootstrapTokens turns ADMIN_TOKEN entries into tokens, skipping blank ones. Their ids
+// derive from the secret so an entry keeps its id across restarts.
+func bootstrapTokens(secrets []string) []*adminToken {
+	var out []*adminToken
+	for i, s := range secrets {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		hash := HashAPIKey(s)
+		out = append(out, &adminToken{AdminTokenInfo: AdminTokenInfo{ID: hash[:16], Label: "env-" + strconv.Itoa(i+1), CreatedAt: time.Now()}, hash: hash})
+	}
+	return out
+}
+
+func (t *adminToken) record() store.AdminToken {
+	return store.AdminToken{ID: t.ID, Label: t.Label, TokenHash: t.hash, CreatedUnixMilli: t.CreatedAt.UnixMilli()}
+}
+
+// Len returns the number of active tokens.
+func (a *AdminTokens) Len() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.tokens)
+}
+
+// Add generates a new token and returns its metadata together with the secret,
+// which is only ever shown once.
+func (a *AdminTokens) Add(label string) (AdminTokenInfo, string, error) {
+	id := randomHex(8)
+	secret := randomHex(32)
+	t := &adminToken{AdminTokenInfo: AdminTokenInfo{ID: id, Label: label, CreatedAt: time.Now()}, hash: HashAPIKey(secret)}
+	if a.store != nil {
+		if err := a.store.CreateAdminToken(t.record()); err != nil {
+			return AdminTokenInfo{}, "", err
+		}
+	}
+	a.mu.Lock()
+	a.tokens[id] = t
+	a.mu.Unlock()
+	return t.AdminTokenInfo, secret, nil
+}
+
+// Revoke removes a token by id. It refuses to remove the last token so the
+// control plane cannot be locked out.
+func (a *AdminTokens) Revoke(id string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.tokens[id]; !ok {
+		return false, nil
+	}
+	if len(a.tokens) == 1 {
+		return true, ErrLastAdminToken
+	}
+	if a.store != nil {
+		if err := a.store.RevokeAdminToken(id); err != nil {
+			return true, err
+		}
+	}
+	delete(a.tokens, id)
+	return true, nil
+}
+
+// List returns token metadata ordered by creation time.
+func (a *AdminTokens) List() []AdminTokenInfo {
+	a.mu.RLock()
+	out := make([]AdminTokenInfo, 0, len(a.tokens))
+	for _, t := range a.tokens {
+		out = append(out, t.AdminTokenInfo)
+	}
+	a.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// Valid reports whether token matches any active secret. Every secret is compared
+// in constant time and the loop never exits early.
+func (a *AdminTokens) Valid(token string) bool {
+	if token == "" {
+		return false
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	match := 0
+	for _, t := range a.tokens {
+		match |= tokensEqual(token, t.hash)
+	}
+	return match == 1
+}
+
+// AdminTokenMiddleware protects control-plane routes using the shared token set.
 // The client must send either:
 // - Header: X-Admin-Token: <token>
 // - or Authorization: Bearer <token>
-func AdminTokenMiddleware(expected string) func(http.Handler) http.Handler {
+func AdminTokenMiddleware(tokens *AdminTokens) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			token := r.Header.Get("X-Admin-Token")
@@ -17,7 +188,7 @@ func AdminTokenMiddleware(expected string) func(http.Handler) http.Handler {
 					token = authz[7:]
 				}
 			}
-			if token == "" || token != expected {
+			if !tokens.Valid(token) {
 				http.Error(w, "forbidden", http.StatusForbidden)
 				return
 			}
@@ -25,3 +196,15 @@ func AdminTokenMiddleware(expected string) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// tokensEqual compares a secret with a stored hash in constant time. The given secret is
+// hashed first so the comparison length is fixed and does not leak the token's length.
+func tokensEqual(given, expectedHash string) int {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(given)), []byte(expectedHash))
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+func TestAdminTokenMiddlewareHeaders(t *testing.T) {
+	h := AdminTokenMiddleware(NewAdminTokens([]string{"s3cret"}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	for _, tc := range []struct {
+		name, header, value string
+		want                int
+	}{
+		{"X-Admin-Token", "X-Admin-Token", "s3cret", http.StatusNoContent},
+		{"bearer", "Authorization", "Bearer s3cret", http.StatusNoContent},
+		{"wrong token", "X-Admin-Token", "s3creT", http.StatusForbidden},
+		{"prefix", "X-Admin-Token", "s3cre", http.StatusForbidden},
+		{"longer", "X-Admin-Token", "s3cret-and-more", http.StatusForbidden},
+		{"basic scheme", "Authorization", "Basic s3cret", http.StatusForbidden},
+		{"missing", "X-Other", "s3cret", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/servers", nil)
+		req.Header.Set(tc.header, tc.value)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestTokensEqualIsFixedLength(t *testing.T) {
+	// The given secret is hashed, so inputs of any length compare as equal-length digests
+	for _, tc := range []struct {
+		given, expected string
+		want            int
+	}{
+		{"s3cret", "s3cret", 1},
+		{"s3cret", "s3creT", 0},
+		{"", "s3cret", 0},
+		{"s3cret", "", 0},
+		{"short", "a much longer expected secret", 0},
+	} {
+		if got := tokensEqual(tc.given, HashAPIKey(tc.expected)); got != tc.want {
+			t.Errorf("tokensEqual(%q, %q) = %d, want %d", tc.given, tc.expected, got, tc.want)
+		}
+	}
+}
+
+func TestAdminTokensValid(t *testing.T) {
+	tokens := NewAdminTokens([]string{"one", " two ", ""})
+	if tokens.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2 (blank entries skipped, spaces trimmed)", tokens.Len())
+	}
+	for token, want := range map[string]bool{"one": true, "two": true, " two ": false, "": false, "three": false} {
+		if got := tokens.Valid(token); got != want {
+			t.Errorf("Valid(%q) = %v, want %v", token, got, want)
+		}
+	}
+	if NewAdminTokens(nil).Valid("") {
+		t.Error("an empty token set accepted an empty token")
+	}
+}
+
+func TestAdminTokensPersistAcrossRestarts(t *testing.T) {
+	s := store.NewMemoryStore("")
+	env := []string{"old-secret", "second-secret"}
+	tokens, err := LoadAdminTokens(s, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, secret, err := tokens.Add("ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var oldID string
+	for _, ti := range tokens.List() {
+		if ti.Label == "env-1" {
+			oldID = ti.ID
+		}
+	}
+	if _, err := tokens.Revoke(oldID); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restart with the same ADMIN_TOKEN keeps the revocation and the added token
+	reloaded, err := LoadAdminTokens(s, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for token, want := range map[string]bool{"old-secret": false, "second-secret": true, secret: true} {
+		if got := reloaded.Valid(token); got != want {
+			t.Errorf("after restart Valid(%q) = %v, want %v", token, got, want)
+		}
+	}
+	if reloaded.Len() != 2 {
+		t.Fatalf("after restart Len() = %d, want 2", reloaded.Len())
+	}
+	ids := map[string]bool{}
+	for _, ti := range reloaded.List() {
+		ids[ti.ID] = true
+	}
+	if !ids[info.ID] {
+		t.Fatalf("added token %s missing after restart: %v", info.ID, reloaded.List())
+	}
+	stored, _ := s.ListAdminTokens()
+	for _, st := range stored {
+		if st.TokenHash == secret || st.TokenHash == "second-secret" {
+			t.Fatalf("store holds a plaintext secret: %+v", st)
+		}
+	}
+}
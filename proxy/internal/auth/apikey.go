@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"gateway/proxy/internal/store"
+)
+
+// APIKeyStore is the lookup needed to authenticate API keys.
+type APIKeyStore interface {
+	GetAPIKeyByHash(hash string) (store.APIKey, error)
+	GetServer(slug string) (store.Server, error)
+}
+
+// HashAPIKey returns the at-rest representation of an API key secret.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewAPIKey generates a key id (UUID v4) and a random secret.
+func NewAPIKey() (id, secret string) {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	id = fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+	return id, "mcpk_" + randomHex(32)
+}
+
+// APIKeyAuthMiddleware accepts `Authorization: ApiKey <key>` or `X-API-Key: <key>` as an
+// alternative to JWT. On success it attaches synthesized claims (including `scope`) so the
+// downstream JWT middleware and scope checks treat the request as authenticated.
+// Requests without an API key pass through untouched.
+func APIKeyAuthMiddleware(s APIKeyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("X-API-Key")
+			if authz := r.Header.Get("Authorization"); key == "" && strings.HasPrefix(authz, "ApiKey ") {
+				key = strings.TrimPrefix(authz, "ApiKey ")
+			}
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			k, err := s.GetAPIKeyByHash(HashAPIKey(key))
+			if err != nil || k.Revoked {
+				http.Error(w, "invalid api key", http.StatusUnauthorized)
+				return
+			}
+			serverSlug := chi.URLParam(r, "server")
+			srv, err := s.GetServer(serverSlug)
+			if err != nil || srv.TenantSlug != k.TenantSlug || !k.AllowsServer(serverSlug) {
+				http.Error(w, "api key not allowed for this server", http.StatusForbidden)
+				return
+			}
+			claims := map[string]interface{}{
+				"sub":         "apikey:" + k.ID,
+				"scope":       strings.Join(k.Scopes, " "),
+				"tenant":      k.TenantSlug,
+				"auth_method": "api_key",
+			}
+			next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+		})
+	}
+}
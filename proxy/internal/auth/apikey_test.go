@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"gateway/proxy/internal/store"
+)
+
+// newKeyStore extends newTestStore with a second acme server, a server of another tenant
+// and an API key for acme restricted to orders; it returns the key id and secret.
+func newKeyStore(t *testing.T) (*store.MemoryStore, string, string) {
+	t.Helper()
+	s := newTestStore(t)
+	if err := s.UpsertServer(store.Server{Slug: "billing", TenantSlug: "acme", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertTenant(store.Tenant{Slug: "globex", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertServer(store.Server{Slug: "globex-orders", TenantSlug: "globex", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	id, secret := NewAPIKey()
+	key := store.APIKey{ID: id, TenantSlug: "acme", KeyHash: HashAPIKey(secret), Servers: []string{"orders"}, Scopes: []string{"orders:read", "orders:write"}}
+	if err := s.CreateAPIKey(key); err != nil {
+		t.Fatal(err)
+	}
+	return s, id, secret
+}
+
+// serveKeyed sends a request for server through the API key and JWT middlewares; the final
+// handler echoes the synthesized scope claim.
+func serveKeyed(s *store.MemoryStore, server string, header http.Header) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.With(APIKeyAuthMiddleware(s), JWTAuthMiddleware(NewJWTValidator(s))).Post("/proxy/{server}/mcp", func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := ClaimsFromContext(r.Context())
+		scope, _ := claims["scope"].(string)
+		_, _ = w.Write([]byte(scope))
+	})
+	req := httptest.NewRequest(http.MethodPost, "/proxy/"+server+"/mcp", nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIKeyGrantsScopes(t *testing.T) {
+	s, _, secret := newKeyStore(t)
+	for name, h := range map[string]http.Header{
+		"authorization": {"Authorization": {"ApiKey " + secret}},
+		"x-api-key":     {"X-Api-Key": {secret}},
+	} {
+		rec := serveKeyed(s, "orders", h)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d (%s)", name, rec.Code, rec.Body)
+		}
+		if got := rec.Body.String(); got != "orders:read orders:write" {
+			t.Fatalf("%s: scope = %q", name, got)
+		}
+	}
+}
+
+func TestAPIKeyHashedAtRest(t *testing.T) {
+	s, _, secret := newKeyStore(t)
+	k, err := s.GetAPIKeyByHash(HashAPIKey(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.KeyHash == secret || strings.Contains(k.KeyHash, secret) {
+		t.Fatalf("stored key hash %q contains the secret", k.KeyHash)
+	}
+}
+
+func TestAPIKeyRejected(t *testing.T) {
+	s, id, secret := newKeyStore(t)
+	cases := []struct {
+		name, server, key string
+		want              int
+	}{
+		{"unknown key", "orders", "mcpk_nope", http.StatusUnauthorized},
+		{"server not in allowlist", "billing", secret, http.StatusForbidden},
+		{"other tenant's server", "globex-orders", secret, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		if rec := serveKeyed(s, tc.server, http.Header{"X-Api-Key": {tc.key}}); rec.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+
+	if err := s.RevokeAPIKey(id); err != nil {
+		t.Fatal(err)
+	}
+	if rec := serveKeyed(s, "orders", http.Header{"X-Api-Key": {secret}}); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked key: status = %d, want 401", rec.Code)
+	}
+}
+
+func TestNoAPIKeyFallsThroughToJWT(t *testing.T) {
+	s, _, _ := newKeyStore(t)
+	if rec := serveKeyed(s, "orders", nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401 from the JWT middleware", rec.Code)
+	}
+}
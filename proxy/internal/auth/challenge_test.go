@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUnauthorizedPointsAtResourceMetadata(t *testing.T) {
+	iss := newTestIssuer(t)
+	v := NewJWTValidator(newTestStore(t, iss.URL))
+
+	rec := serveMCP(JWTAuthMiddleware(v), "orders", nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("got %d, want 401", rec.Code)
+	}
+	challenge := rec.Header().Get("WWW-Authenticate")
+	for _, want := range []string{
+		`Bearer `,
+		`error="invalid_token"`,
+		`resource_metadata="http://example.com/proxy/orders/.well-known/oauth-protected-resource"`,
+		`error_description="missing bearer token"`,
+	} {
+		if !strings.Contains(challenge, want) {
+			t.Errorf("WWW-Authenticate %q lacks %s", challenge, want)
+		}
+	}
+
+	forged := iss.token(t, newRSAKey(t), nil)
+	rec = serveMCP(JWTAuthMiddleware(v), "orders", bearer(forged))
+	if challenge := rec.Header().Get("WWW-Authenticate"); !strings.Contains(challenge, `error_description="token validation failed"`) {
+		t.Errorf("invalid token challenge %q", challenge)
+	}
+}
+
+func TestResourceMetadataHonorsForwardedProto(t *testing.T) {
+	iss := newTestIssuer(t)
+	v := NewJWTValidator(newTestStore(t, iss.URL))
+	rec := serveMCP(JWTAuthMiddleware(v), "orders", http.Header{"X-Forwarded-Proto": {"https"}})
+	want := `resource_metadata="https://example.com/proxy/orders/.well-known/oauth-protected-resource"`
+	if challenge := rec.Header().Get("WWW-Authenticate"); !strings.Contains(challenge, want) {
+		t.Fatalf("WWW-Authenticate %q lacks %s", challenge, want)
+	}
+}
+
+func TestInsufficientScopeChallenge(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "http://gw.example.com/proxy/orders/mcp", nil)
+	got := InsufficientScopeChallenge(req, []string{"orders:read", "orders:write"})
+	if !strings.Contains(got, `error="insufficient_scope"`) || !strings.Contains(got, `scope="orders:read orders:write"`) {
+		t.Fatalf("challenge %q", got)
+	}
+}
@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	keyfunc "github.com/MicahParks/keyfunc"
+)
+
+// defaultDiscoveryTTL is how long a discovered issuer metadata document is used before
+// it is fetched again.
+const defaultDiscoveryTTL = time.Hour
+
+// maxDiscoveryBytes bounds an OIDC discovery document.
+const maxDiscoveryBytes = 1 << 20
+
+// issuerMetadata is the part of an issuer's OIDC discovery document the gateway uses.
+// Issuers without discovery get the /.well-known/jwks.json fallback.
+type issuerMetadata struct {
+	JWKSURI    string
+	Discovered bool
+	FetchedAt  time.Time
+}
+
+// discovery caches issuer metadata with a TTL. An expired entry keeps being served while
+// one caller refreshes it in the background; only the first lookup of an issuer waits.
+type discovery struct {
+	client *http.Client
+	ttl    time.Duration
+
+	mu         sync.Mutex
+	entries    map[string]issuerMetadata
+	refreshing map[string]chan struct{}
+}
+
+func newDiscovery(client *http.Client, ttl time.Duration) *discovery {
+	return &discovery{client: client, ttl: ttl, entries: map[string]issuerMetadata{}, refreshing: map[string]chan struct{}{}}
+}
+
+// lookup returns the issuer's metadata, discovering it on first use and refreshing it in
+// the background once older than the TTL.
+func (d *discovery) lookup(issuer string) issuerMetadata {
+	d.mu.Lock()
+	md, ok := d.entries[issuer]
+	if ok && time.Since(md.FetchedAt) < d.ttl {
+		d.mu.Unlock()
+		return md
+	}
+	done, inflight := d.refreshing[issuer]
+	if !inflight {
+		done = make(chan struct{})
+		d.refreshing[issuer] = done
+	}
+	d.mu.Unlock()
+
+	if !inflight {
+		if ok {
+			go d.refresh(issuer, done)
+			return md
+		}
+		d.refresh(issuer, done)
+	} else if ok {
+		return md
+	}
+	<-done
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.entries[issuer]
+}
+
+// refresh discovers issuer and stores the result. A failed discovery keeps a previously
+// discovered document, and otherwise records the fallback JWKS URI, so either way the
+// issuer is not fetched again before the TTL.
+func (d *discovery) refresh(issuer string, done chan struct{}) (issuerMetadata, error) {
+	jwksURI, err := d.fetch(issuer)
+	d.mu.Lock()
+	prev, hadPrev := d.entries[issuer]
+	md := issuerMetadata{JWKSURI: jwksURI, Discovered: true, FetchedAt: time.Now()}
+	if err != nil {
+		log.Printf("oidc discovery failed for issuer %s: %v", issuer, err)
+		md = issuerMetadata{JWKSURI: jwksURIForIssuer(issuer), FetchedAt: time.Now()}
+		if hadPrev {
+			md.JWKSURI, md.Discovered = prev.JWKSURI, prev.Discovered
+		}
+	}
+	d.entries[issuer] = md
+	if done != nil {
+		delete(d.refreshing, issuer)
+		close(done)
+	}
+	d.mu.Unlock()
+	return md, err
+}
+
+// cached returns the issuer's metadata without fetching.
+func (d *discovery) cached(issuer string) (issuerMetadata, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	md, ok := d.entries[issuer]
+	return md, ok
+}
+
+func (d *discovery) fetch(issuer string) (string, error) {
+	resp, err := d.client.Get(strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDiscoveryBytes)).Decode(&doc); err != nil {
+		return "", err
+	}
+	// OIDC Discovery 1.0 §4.3: the document must name the issuer it was fetched for
+	if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(issuer, "/") {
+		return "", fmt.Errorf("document issuer %q does not match", doc.Issuer)
+	}
+	if doc.JWKSURI == "" {
+		return "", errors.New("document has no jwks_uri")
+	}
+	return doc.JWKSURI, nil
+}
+
+// IssuerRefresh is the outcome of re-discovering one issuer.
+type IssuerRefresh struct {
+	Issuer     string `json:"issuer"`
+	JWKSURI    string `json:"jwksUri"`
+	Discovered bool   `json:"discovered"`
+	Error      string `json:"error,omitempty"`
+}
+
+// RefreshIssuers re-discovers the given issuers now, regardless of TTL. Validations keep
+// using the previous metadata until each new document is in place; key sets of a
+// replaced jwks_uri are dropped so the next validation fetches from the new one.
+func (v *JWTValidator) RefreshIssuers(issuers []string) []IssuerRefresh {
+	out := make([]IssuerRefresh, len(issuers))
+	var wg sync.WaitGroup
+	for i, issuer := range issuers {
+		wg.Add(1)
+		go func(i int, issuer string) {
+			defer wg.Done()
+			prev, hadPrev := v.discovery.cached(issuer)
+			md, err := v.discovery.refresh(issuer, nil)
+			if hadPrev && prev.JWKSURI != md.JWKSURI {
+				v.dropJWKS(prev.JWKSURI)
+			}
+			out[i] = IssuerRefresh{Issuer: issuer, JWKSURI: md.JWKSURI, Discovered: md.Discovered}
+			if err != nil {
+				out[i].Error = err.Error()
+			}
+		}(i, issuer)
+	}
+	wg.Wait()
+	return out
+}
+
+// IssuerReport is the outcome of TestIssuer. OK means the gateway could validate tokens
+// from the issuer with at least one key.
+type IssuerReport struct {
+	Issuer         string   `json:"issuer"`
+	OK             bool     `json:"ok"`
+	Discovered     bool     `json:"discovered"`
+	DiscoveryError string   `json:"discoveryError,omitempty"`
+	JWKSURI        string   `json:"jwksUri"`
+	JWKSReachable  bool     `json:"jwksReachable"`
+	JWKSError      string   `json:"jwksError,omitempty"`
+	KeyCount       int      `json:"keyCount"`
+	UsableKeys     int      `json:"usableKeys"`
+	Algorithms     []string `json:"algorithms"`
+	KeyTypes       []string `json:"keyTypes"`
+}
+
+// TestIssuer runs discovery and fetches the key set of issuer the way token validation
+// does, without touching the caches. Failures are reported, not returned.
+func (v *JWTValidator) TestIssuer(issuer string) IssuerReport {
+	rep := IssuerReport{Issuer: issuer, Algorithms: []string{}, KeyTypes: []string{}}
+	jwksURI, err := v.discovery.fetch(issuer)
+	if err != nil {
+		rep.DiscoveryError = err.Error()
+		jwksURI = jwksURIForIssuer(issuer)
+	} else {
+		rep.Discovered = true
+	}
+	rep.JWKSURI = jwksURI
+
+	resp, err := v.discovery.client.Get(jwksURI)
+	if err != nil {
+		rep.JWKSError = err.Error()
+		return rep
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		rep.JWKSError = fmt.Sprintf("status %d", resp.StatusCode)
+		return rep
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDiscoveryBytes))
+	if err != nil {
+		rep.JWKSError = err.Error()
+		return rep
+	}
+	rep.JWKSReachable = true
+	var doc struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Alg string `json:"alg"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		rep.JWKSError = "invalid JWKS: " + err.Error()
+		return rep
+	}
+	rep.KeyCount = len(doc.Keys)
+	algs, types := map[string]bool{}, map[string]bool{}
+	for _, k := range doc.Keys {
+		if k.Alg != "" && !algs[k.Alg] {
+			algs[k.Alg] = true
+			rep.Algorithms = append(rep.Algorithms, k.Alg)
+		}
+		if k.Kty != "" && !types[k.Kty] {
+			types[k.Kty] = true
+			rep.KeyTypes = append(rep.KeyTypes, k.Kty)
+		}
+	}
+	// Keys the validator cannot use (e.g. without a kid) are skipped, as in validation
+	jwks, err := keyfunc.NewJSON(body)
+	if err != nil {
+		rep.JWKSError = "invalid JWKS: " + err.Error()
+		return rep
+	}
+	rep.UsableKeys = jwks.Len()
+	if rep.UsableKeys == 0 {
+		rep.JWKSError = "no usable keys"
+	}
+	rep.OK = rep.UsableKeys > 0
+	return rep
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// discoveryIssuer publishes an OIDC discovery document whose jwks_uri names /keys/a or
+// /keys/b. Each path serves a different key under the same kid, so a token signed with
+// keyB only validates once the gateway has picked up /keys/b.
+type discoveryIssuer struct {
+	*testIssuer
+	keyB          *testIssuer
+	jwksPath      atomic.Value
+	discoveryHits atomic.Int32
+	discoveryDown atomic.Bool
+}
+
+func newDiscoveryIssuer(t *testing.T) *discoveryIssuer {
+	t.Helper()
+	iss := &discoveryIssuer{testIssuer: &testIssuer{key: newRSAKey(t), kid: "k1"}, keyB: &testIssuer{key: newRSAKey(t), kid: "k1"}}
+	iss.jwksPath.Store("/keys/a")
+	iss.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			iss.discoveryHits.Add(1)
+			if iss.discoveryDown.Load() {
+				http.Error(w, "down", http.StatusServiceUnavailable)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"issuer": iss.URL, "jwks_uri": iss.URL + iss.jwksPath.Load().(string)})
+		case "/keys/a":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{rsaJWK("k1", &iss.key.PublicKey)}})
+		case "/keys/b":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{rsaJWK("k1", &iss.keyB.key.PublicKey)}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(iss.Close)
+	iss.keyB.Server = iss.Server
+	return iss
+}
+
+func TestDiscoveryTTLRediscovers(t *testing.T) {
+	iss := newDiscoveryIssuer(t)
+	v := NewJWTValidator(newTestStore(t, iss.URL))
+	v.SetDiscoveryTTL(50 * time.Millisecond)
+	tokenA, tokenB := iss.token(t, iss.key, nil), iss.keyB.token(t, iss.keyB.key, nil)
+
+	if rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(tokenA)); rec.Code != http.StatusOK {
+		t.Fatalf("token from the discovered key set: got %d", rec.Code)
+	}
+	iss.jwksPath.Store("/keys/b")
+	if rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(tokenB)); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("before the TTL: got %d, want the cached jwks_uri still in use", rec.Code)
+	}
+
+	// An expired document keeps serving while it is re-fetched in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for serveMCP(JWTAuthMiddleware(v), "orders", bearer(tokenB)).Code != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatalf("jwks_uri not re-discovered after the TTL (%d discovery fetches)", iss.discoveryHits.Load())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if iss.discoveryHits.Load() < 2 {
+		t.Fatalf("%d discovery fetches, want a re-fetch", iss.discoveryHits.Load())
+	}
+}
+
+func TestRefreshIssuersPicksUpNewJWKSURI(t *testing.T) {
+	iss, plain := newDiscoveryIssuer(t), newTestIssuer(t)
+	v := NewJWTValidator(newTestStore(t, iss.URL, plain.URL))
+	tokenA, tokenB := iss.token(t, iss.key, nil), iss.keyB.token(t, iss.keyB.key, nil)
+	if rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(tokenA)); rec.Code != http.StatusOK {
+		t.Fatalf("got %d", rec.Code)
+	}
+	iss.jwksPath.Store("/keys/b")
+
+	got := v.RefreshIssuers([]string{iss.URL, plain.URL})
+	if len(got) != 2 || got[0].JWKSURI != iss.URL+"/keys/b" || !got[0].Discovered || got[0].Error != "" {
+		t.Fatalf("refresh %+v", got)
+	}
+	// An issuer without discovery falls back to the well-known key set and says why
+	if got[1].Discovered || got[1].JWKSURI != plain.URL+"/.well-known/jwks.json" || got[1].Error == "" {
+		t.Fatalf("fallback issuer %+v", got[1])
+	}
+	if rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(tokenB)); rec.Code != http.StatusOK {
+		t.Fatalf("token from the new key set: got %d", rec.Code)
+	}
+	if rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(tokenA)); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("token from the replaced key set: got %d, want 401", rec.Code)
+	}
+
+	// A failed re-discovery keeps the previous document
+	iss.discoveryDown.Store(true)
+	got = v.RefreshIssuers([]string{iss.URL})
+	if got[0].JWKSURI != iss.URL+"/keys/b" || !got[0].Discovered || !strings.Contains(got[0].Error, "503") {
+		t.Fatalf("failed refresh %+v", got[0])
+	}
+	if rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(tokenB)); rec.Code != http.StatusOK {
+		t.Fatalf("after a failed refresh: got %d", rec.Code)
+	}
+}
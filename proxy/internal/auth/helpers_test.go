@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v4"
+
+	"gateway/proxy/internal/store"
+)
+
+// testIssuer is an identity provider without OIDC discovery: it serves its key set at the
+// fallback /.well-known/jwks.json and signs tokens with it.
+type testIssuer struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	kid      string
+	jwksHits atomic.Int32
+	jwksDown atomic.Bool
+	// jwksDelay holds key set responses back, in nanoseconds
+	jwksDelay atomic.Int64
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	iss := &testIssuer{key: newRSAKey(t), kid: "k1"}
+	iss.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/jwks.json" {
+			http.NotFound(w, r)
+			return
+		}
+		iss.jwksHits.Add(1)
+		time.Sleep(time.Duration(iss.jwksDelay.Load()))
+		if iss.jwksDown.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{rsaJWK(iss.kid, &iss.key.PublicKey)}})
+	}))
+	t.Cleanup(iss.Close)
+	return iss
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func rsaJWK(kid string, pub *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"alg": "RS256",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+// token signs claims with key under the issuer's kid; iss, aud and exp default to the
+// issuer, "https://api.example.com" and an hour from now.
+func (iss *testIssuer) token(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+	c := jwt.MapClaims{"iss": iss.URL, "aud": "https://api.example.com", "sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+	for k, v := range claims {
+		c[k] = v
+	}
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+	tok.Header["kid"] = iss.kid
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// newTestStore holds tenant acme with server orders accepting tokens from issuers.
+func newTestStore(t *testing.T, issuers ...string) *store.MemoryStore {
+	t.Helper()
+	s := store.NewMemoryStore("https://api.example.com")
+	if err := s.UpsertTenant(store.Tenant{Slug: "acme", Enabled: true, AllowedIssuers: issuers}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertServer(store.Server{Slug: "orders", TenantSlug: "acme", Enabled: true, Audience: "https://api.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// serveMCP sends a POST to /proxy/{server}/mcp through mw and returns the recorder; the
+// final handler answers 200 with the authenticated subject.
+func serveMCP(mw func(http.Handler) http.Handler, server string, header http.Header) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.With(mw).Post("/proxy/{server}/mcp", func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := ClaimsFromContext(r.Context())
+		sub, _ := claims["sub"].(string)
+		_, _ = w.Write([]byte(sub))
+	})
+	req := httptest.NewRequest(http.MethodPost, "/proxy/"+server+"/mcp", nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
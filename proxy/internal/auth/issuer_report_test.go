@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTestIssuerReachable(t *testing.T) {
+	iss, plain := newDiscoveryIssuer(t), newTestIssuer(t)
+	v := NewJWTValidator(newTestStore(t))
+
+	rep := v.TestIssuer(iss.URL)
+	if !rep.OK || !rep.Discovered || rep.DiscoveryError != "" || rep.JWKSURI != iss.URL+"/keys/a" || !rep.JWKSReachable {
+		t.Fatalf("report %+v", rep)
+	}
+	if rep.KeyCount != 1 || rep.UsableKeys != 1 || strings.Join(rep.Algorithms, ",") != "RS256" || strings.Join(rep.KeyTypes, ",") != "RSA" {
+		t.Fatalf("keys in report %+v", rep)
+	}
+
+	// Without discovery the well-known key set is tried, as validation does
+	rep = v.TestIssuer(plain.URL)
+	if !rep.OK || rep.Discovered || rep.DiscoveryError == "" || rep.JWKSURI != plain.URL+"/.well-known/jwks.json" {
+		t.Fatalf("fallback report %+v", rep)
+	}
+}
+
+func TestTestIssuerUnreachable(t *testing.T) {
+	iss := newTestIssuer(t)
+	iss.Close()
+	rep := NewJWTValidator(newTestStore(t)).TestIssuer(iss.URL)
+	if rep.OK || rep.Discovered || rep.DiscoveryError == "" || rep.JWKSReachable || rep.JWKSError == "" {
+		t.Fatalf("report %+v", rep)
+	}
+	if rep.Algorithms == nil || rep.KeyTypes == nil {
+		t.Fatalf("report %+v, want empty lists rather than null", rep)
+	}
+}
+
+func TestTestIssuerUnusableKeys(t *testing.T) {
+	cases := []struct {
+		name, body, wantErr string
+		keyCount            int
+	}{
+		{"invalid json", `{"keys":`, "invalid JWKS", 0},
+		{"unsupported key type", `{"keys":[{"kty":"XYZ","kid":"k1","alg":"XYZ1"}]}`, "no usable keys", 1},
+	}
+	for _, tc := range cases {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/.well-known/jwks.json" {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write([]byte(tc.body))
+		}))
+		rep := NewJWTValidator(newTestStore(t)).TestIssuer(ts.URL)
+		ts.Close()
+		if rep.OK || !rep.JWKSReachable || rep.KeyCount != tc.keyCount || !strings.Contains(rep.JWKSError, tc.wantErr) {
+			t.Errorf("%s: report %+v", tc.name, rep)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	keyfunc "github.com/MicahParks/keyfunc"
@@ -11,111 +15,253 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 
 	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/logging"
+	"gateway/proxy/internal/metrics"
 	"gateway/proxy/internal/store"
 )
 
+// jwksFetchErrors counts failed JWKS fetches, both the first fetch and background refreshes.
+var jwksFetchErrors = metrics.NewCounterVec("jwks_fetch_errors_total", "Failed JWKS fetches by issuer.", "issuer")
+
+// defaultJWKSFetchTimeout bounds a JWKS fetch so an unreachable issuer cannot stall requests.
+const defaultJWKSFetchTimeout = 5 * time.Second
+
 type JWTValidator struct {
-	store store.Store
+	store        store.Store
+	fetchTimeout time.Duration
 	// MVP: simple per-issuer JWKS cache
-	cache map[string]*keyfunc.JWKS
+	mu       sync.RWMutex
+	cache    map[string]*keyfunc.JWKS
+	inflight map[string]*jwksFetch
+	// failed holds recent fetch failures by jwks_uri, so requests during an IdP outage
+	// fail fast instead of each fetching again
+	failed map[string]jwksFailure
+	// discovery resolves each issuer's jwks_uri from its OIDC metadata
+	discovery *discovery
+}
+
+// jwksFailure is a failed fetch, reported without fetching again until retryAt.
+type jwksFailure struct {
+	err     error
+	retryAt time.Time
+}
+
+// jwksFetch lets concurrent first-time lookups of the same JWKS share one network fetch.
+type jwksFetch struct {
+	done chan struct{}
+	jwks *keyfunc.JWKS
+	err  error
 }
 
 func NewJWTValidator(s store.Store) *JWTValidator {
-	return &JWTValidator{store: s, cache: make(map[string]*keyfunc.JWKS)}
+	return &JWTValidator{
+		store:        s,
+		fetchTimeout: defaultJWKSFetchTimeout,
+		cache:        make(map[string]*keyfunc.JWKS),
+		inflight:     make(map[string]*jwksFetch),
+		failed:       make(map[string]jwksFailure),
+		discovery:    newDiscovery(&http.Client{Timeout: defaultJWKSFetchTimeout}, defaultDiscoveryTTL),
+	}
 }
 
-func (v *JWTValidator) getJWKS(jwksURI string) (*keyfunc.JWKS, error) {
+// SetFetchTimeout bounds each JWKS and discovery fetch (connect through body); d <= 0
+// keeps the default.
+func (v *JWTValidator) SetFetchTimeout(d time.Duration) {
+	if d > 0 {
+		v.fetchTimeout = d
+		v.discovery.client = &http.Client{Timeout: d}
+	}
+}
+
+// SetDiscoveryTTL sets how long issuer metadata is used before it is re-discovered;
+// d <= 0 keeps the default.
+func (v *JWTValidator) SetDiscoveryTTL(d time.Duration) {
+	if d > 0 {
+		v.discovery.ttl = d
+	}
+}
+
+// jwksFailureTTL is how long a failed JWKS fetch is reported without fetching again; it
+// matches the Retry-After sent to clients.
+const jwksFailureTTL = jwksRetryAfterSeconds * time.Second
+
+// getJWKS returns the issuer's key set. A failed first fetch is remembered for
+// jwksFailureTTL, after which the next request for the issuer fetches again.
+func (v *JWTValidator) getJWKS(issuer string) (*keyfunc.JWKS, error) {
+	jwksURI := v.discovery.lookup(issuer).JWKSURI
+	v.mu.RLock()
+	jwks, ok := v.cache[jwksURI]
+	v.mu.RUnlock()
+	if ok {
+		return jwks, nil
+	}
+
+	// Double-check under the write lock, then either join an in-flight fetch or start one
+	v.mu.Lock()
 	if jwks, ok := v.cache[jwksURI]; ok {
+		v.mu.Unlock()
 		return jwks, nil
 	}
-	jwks, err := keyfunc.Get(jwksURI, keyfunc.Options{RefreshErrorHandler: func(err error) {
-		// noop for MVP
-	}, RefreshInterval: time.Minute * 5})
-	if err != nil {
-		return nil, err
+	if failure, ok := v.failed[jwksURI]; ok && time.Now().Before(failure.retryAt) {
+		v.mu.Unlock()
+		return nil, failure.err
+	}
+	if f, ok := v.inflight[jwksURI]; ok {
+		v.mu.Unlock()
+		<-f.done
+		return f.jwks, f.err
+	}
+	f := &jwksFetch{done: make(chan struct{})}
+	v.inflight[jwksURI] = f
+	v.mu.Unlock()
+
+	f.jwks, f.err = keyfunc.Get(jwksURI, keyfunc.Options{
+		Client:         &http.Client{Timeout: v.fetchTimeout},
+		RefreshTimeout: v.fetchTimeout,
+		// Background refresh failures keep serving the previous keys
+		RefreshErrorHandler: func(err error) {
+			jwksFetchErrors.Inc(issuer)
+			slog.Warn("jwks refresh failed", "issuer", issuer, "err", err)
+		},
+		RefreshInterval: time.Minute * 5,
+	})
+	if f.err != nil {
+		jwksFetchErrors.Inc(issuer)
+		slog.Warn("jwks fetch failed", "issuer", issuer, "err", f.err)
+	}
+
+	v.mu.Lock()
+	if f.err == nil {
+		v.cache[jwksURI] = f.jwks
+		delete(v.failed, jwksURI)
+	} else {
+		v.failed[jwksURI] = jwksFailure{err: f.err, retryAt: time.Now().Add(jwksFailureTTL)}
+	}
+	delete(v.inflight, jwksURI)
+	v.mu.Unlock()
+	close(f.done)
+	return f.jwks, f.err
+}
+
+// RefreshJWKS drops cached key sets, and remembered fetch failures, so the next validation
+// re-fetches them. An empty issuer clears every entry. It returns the number of key sets
+// removed.
+func (v *JWTValidator) RefreshJWKS(issuer string) int {
+	target := jwksURIForIssuer(issuer)
+	if md, ok := v.discovery.cached(issuer); ok {
+		target = md.JWKSURI
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for uri := range v.failed {
+		if issuer == "" || uri == target {
+			delete(v.failed, uri)
+		}
+	}
+	removed := 0
+	for uri, jwks := range v.cache {
+		if issuer != "" && uri != target {
+			continue
+		}
+		jwks.EndBackground()
+		delete(v.cache, uri)
+		removed++
+	}
+	return removed
+}
+
+// jwksRetryAfterSeconds is the Retry-After sent when the issuer's key set could not be fetched.
+const jwksRetryAfterSeconds = 5
+
+// tokenIssuer returns the token's unverified iss claim if it is one of issuers. Only that
+// issuer's key set is then fetched; the signature check proves the claim.
+func tokenIssuer(tokenString string, issuers []string) (string, bool) {
+	unverified := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, unverified); err != nil {
+		return "", false
+	}
+	iss, _ := unverified["iss"].(string)
+	for _, issuer := range issuers {
+		if issuer == iss {
+			return iss, true
+		}
+	}
+	return "", false
+}
+
+// dropJWKS removes the cached key set fetched from jwksURI, if any.
+func (v *JWTValidator) dropJWKS(jwksURI string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if jwks, ok := v.cache[jwksURI]; ok {
+		jwks.EndBackground()
+		delete(v.cache, jwksURI)
 	}
-	v.cache[jwksURI] = jwks
-	return jwks, nil
+	delete(v.failed, jwksURI)
+}
+
+// jwksURIForIssuer is the key set location used when an issuer publishes no OIDC
+// discovery document.
+func jwksURIForIssuer(issuer string) string {
+	return fmt.Sprintf("%s/.well-known/jwks.json", strings.TrimSuffix(issuer, "/"))
 }
 
 func JWTAuthMiddleware(validator *JWTValidator) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			serverSlug := chi.URLParam(r, "server")
-			srv, err := validator.store.GetServer(serverSlug)
+			srv, tenant, err := validator.store.GetServerWithTenant(serverSlug)
+			if errors.Is(err, store.ErrTenantNotFound) {
+				http.Error(w, "tenant not found or disabled", http.StatusUnauthorized)
+				return
+			}
 			if err != nil || !srv.Enabled {
 				http.Error(w, "server not found or disabled", http.StatusUnauthorized)
 				return
 			}
-			tenant, err := validator.store.GetTenant(srv.TenantSlug)
-			if err != nil || !tenant.Enabled {
+			if !tenant.Enabled {
 				http.Error(w, "tenant not found or disabled", http.StatusUnauthorized)
 				return
 			}
+			logging.SetTenant(r.Context(), tenant.Slug)
 
 			if config.Unprotected {
 				// Skip auth entirely in unprotected mode
 				next.ServeHTTP(w, r)
 				return
 			}
+			if _, ok := ClaimsFromContext(r.Context()); ok {
+				// Already authenticated upstream (e.g. API key)
+				next.ServeHTTP(w, r)
+				return
+			}
 
 			authz := r.Header.Get("Authorization")
 			if !strings.HasPrefix(authz, "Bearer ") {
-				unauthorizedWithWWWAuthenticate(w)
+				unauthorizedWithWWWAuthenticate(w, r, "missing bearer token")
 				return
 			}
 			tokenString := strings.TrimPrefix(authz, "Bearer ")
 
-			// MVP: assume issuer has well-known JWKS endpoint
-			// In a real system we'd store jwks_uri per issuer; here we try "/.well-known/jwks.json"
-			var claims jwt.MapClaims
 			issuers := tenant.AllowedIssuers
 			if len(srv.AllowedIssuers) > 0 {
 				issuers = srv.AllowedIssuers
 			}
-			for _, issuer := range issuers {
-				jwksURI := fmt.Sprintf("%s/.well-known/jwks.json", strings.TrimSuffix(issuer, "/"))
-				jwks, err := validator.getJWKS(jwksURI)
-				if err != nil {
-					continue
-				}
-				token, err := jwt.ParseWithClaims(tokenString, jwt.MapClaims{}, jwks.Keyfunc)
-				if err != nil {
-					continue
-				}
-				if !token.Valid {
-					continue
-				}
-				if c, ok := token.Claims.(jwt.MapClaims); ok {
-					// check issuer and audience
-					if c["iss"] != issuer {
-						continue
-					}
-					switch aud := c["aud"].(type) {
-					case string:
-						if aud != srv.Audience {
-							continue
-						}
-					case []interface{}:
-						matched := false
-						for _, a := range aud {
-							if as, ok := a.(string); ok && as == srv.Audience {
-								matched = true
-								break
-							}
-						}
-						if !matched {
-							continue
-						}
-					default:
-						continue
-					}
-					claims = c
-				}
-				break
+			issuer, ok := tokenIssuer(tokenString, issuers)
+			if !ok {
+				unauthorizedWithWWWAuthenticate(w, r, "token validation failed")
+				return
+			}
+			jwks, err := validator.getJWKS(issuer)
+			if err != nil {
+				// The token may well be valid; the gateway could not get the keys to check it
+				w.Header().Set("Retry-After", strconv.Itoa(jwksRetryAfterSeconds))
+				http.Error(w, "identity provider unavailable", http.StatusServiceUnavailable)
+				return
 			}
+			claims := verifiedClaims(tokenString, jwks, issuer, srv)
 			if claims == nil {
-				unauthorizedWithWWWAuthenticate(w)
+				unauthorizedWithWWWAuthenticate(w, r, "token validation failed")
 				return
 			}
 
@@ -126,8 +272,56 @@ func JWTAuthMiddleware(validator *JWTValidator) func(http.Handler) http.Handler
 	}
 }
 
-func unauthorizedWithWWWAuthenticate(w http.ResponseWriter) {
-	// Minimal WWW-Authenticate header referencing protected resource metadata
-	w.Header().Set("WWW-Authenticate", "Bearer realm=\"MCP Proxy\", error=\"invalid_token\"")
+// verifiedClaims returns the token's claims if its signature verifies against jwks and it
+// names issuer and one of the server's audiences, else nil.
+func verifiedClaims(tokenString string, jwks *keyfunc.JWKS, issuer string, srv store.Server) jwt.MapClaims {
+	token, err := jwt.ParseWithClaims(tokenString, jwt.MapClaims{}, jwks.Keyfunc)
+	if err != nil || !token.Valid {
+		return nil
+	}
+	c, ok := token.Claims.(jwt.MapClaims)
+	if !ok || c["iss"] != issuer {
+		return nil
+	}
+	switch aud := c["aud"].(type) {
+	case string:
+		if srv.AcceptsAudience(aud) {
+			return c
+		}
+	case []interface{}:
+		for _, a := range aud {
+			if as, ok := a.(string); ok && srv.AcceptsAudience(as) {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+func unauthorizedWithWWWAuthenticate(w http.ResponseWriter, r *http.Request, description string) {
+	// Per RFC 9728, point clients at the per-server protected resource metadata
+	header := fmt.Sprintf("Bearer realm=\"MCP Proxy\", error=\"invalid_token\", resource_metadata=\"%s\"", resourceMetadataURL(r))
+	if description != "" {
+		header += fmt.Sprintf(", error_description=\"%s\"", strings.ReplaceAll(description, "\"", "'"))
+	}
+	w.Header().Set("WWW-Authenticate", header)
 	http.Error(w, "unauthorized", http.StatusUnauthorized)
 }
+
+// InsufficientScopeChallenge is the WWW-Authenticate value (RFC 6750 §3.1) telling a
+// client which scopes to request for the server addressed by r.
+func InsufficientScopeChallenge(r *http.Request, scopes []string) string {
+	return fmt.Sprintf("Bearer realm=\"MCP Proxy\", error=\"insufficient_scope\", scope=\"%s\", resource_metadata=\"%s\"", strings.Join(scopes, " "), resourceMetadataURL(r))
+}
+
+// resourceMetadataURL builds the absolute well-known URL for the server addressed by r.
+func resourceMetadataURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return fmt.Sprintf("%s://%s/proxy/%s/.well-known/oauth-protected-resource", scheme, r.Host, chi.URLParam(r, "server"))
+}
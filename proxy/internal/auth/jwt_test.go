@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v4"
+
+	"gateway/proxy/internal/store"
+)
+
+func TestJWTAuthAcceptsValidToken(t *testing.T) {
+	iss := newTestIssuer(t)
+	v := NewJWTValidator(newTestStore(t, iss.URL))
+	rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(iss.token(t, iss.key, nil)))
+	if rec.Code != http.StatusOK || rec.Body.String() != "alice" {
+		t.Fatalf("got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestJWTAuthUnreachableJWKSIs503(t *testing.T) {
+	iss := newTestIssuer(t)
+	iss.jwksDown.Store(true)
+	v := NewJWTValidator(newTestStore(t, iss.URL))
+	token := iss.token(t, iss.key, nil)
+
+	rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(token))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got %d, want 503", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "5" {
+		t.Fatalf("Retry-After %q", got)
+	}
+	hits := iss.jwksHits.Load()
+	if hits == 0 {
+		t.Fatal("key set was never fetched")
+	}
+	// The failure is remembered: a second request fails fast without another fetch
+	if rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(token)); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("second request got %d", rec.Code)
+	}
+	if iss.jwksHits.Load() != hits {
+		t.Fatalf("key set fetched again within the failure window (%d -> %d)", hits, iss.jwksHits.Load())
+	}
+	// An operator refresh forgets the failure
+	iss.jwksDown.Store(false)
+	v.RefreshJWKS(iss.URL)
+	if rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(token)); rec.Code != http.StatusOK {
+		t.Fatalf("after refresh got %d", rec.Code)
+	}
+}
+
+func TestJWTAuthBadSignatureIs401(t *testing.T) {
+	iss := newTestIssuer(t)
+	v := NewJWTValidator(newTestStore(t, iss.URL))
+	forged := iss.token(t, newRSAKey(t), nil)
+	rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(forged))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("got %d, want 401", rec.Code)
+	}
+	if rec.Header().Get("WWW-Authenticate") == "" {
+		t.Fatal("missing WWW-Authenticate")
+	}
+}
+
+func TestJWTAuthOnlyFetchesTokenIssuer(t *testing.T) {
+	good, down := newTestIssuer(t), newTestIssuer(t)
+	down.jwksDown.Store(true)
+	v := NewJWTValidator(newTestStore(t, down.URL, good.URL))
+	rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(good.token(t, good.key, nil)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d", rec.Code)
+	}
+	if down.jwksHits.Load() != 0 {
+		t.Fatal("fetched the key set of an issuer the token does not name")
+	}
+	// A token from an issuer that is not allowed is rejected without fetching anything
+	other := newTestIssuer(t)
+	rec = serveMCP(JWTAuthMiddleware(v), "orders", bearer(other.token(t, other.key, nil)))
+	if rec.Code != http.StatusUnauthorized || other.jwksHits.Load() != 0 || down.jwksHits.Load() != 0 {
+		t.Fatalf("got %d, fetches %d/%d", rec.Code, other.jwksHits.Load(), down.jwksHits.Load())
+	}
+}
+
+func TestJWTAuthRejectsWrongAudience(t *testing.T) {
+	iss := newTestIssuer(t)
+	v := NewJWTValidator(newTestStore(t, iss.URL))
+	rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(iss.token(t, iss.key, jwt.MapClaims{"aud": "https://other.example.com"})))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("got %d, want 401", rec.Code)
+	}
+}
+
+func TestJWTAuthServerWithoutTenant(t *testing.T) {
+	iss := newTestIssuer(t)
+	s := newTestStore(t, iss.URL)
+	if err := s.UpsertServer(store.Server{Slug: "orphan", TenantSlug: "ghost", Enabled: true, Audience: "https://api.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	tenant, _ := s.GetTenant("acme")
+	tenant.Enabled = false
+	if err := s.UpsertTenant(tenant); err != nil {
+		t.Fatal(err)
+	}
+	token := iss.token(t, iss.key, nil)
+	for _, server := range []string{"orphan", "orders"} {
+		rec := serveMCP(JWTAuthMiddleware(NewJWTValidator(s)), server, bearer(token))
+		if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "tenant not found or disabled") {
+			t.Fatalf("%s: got %d %q", server, rec.Code, rec.Body.String())
+		}
+	}
+	if rec := serveMCP(JWTAuthMiddleware(NewJWTValidator(s)), "nope", bearer(token)); !strings.Contains(rec.Body.String(), "server not found") {
+		t.Fatalf("unknown server: got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestJWTAuthServerIssuersOverrideTenant(t *testing.T) {
+	tenantIss, serverIss := newTestIssuer(t), newTestIssuer(t)
+	s := newTestStore(t, tenantIss.URL)
+	if err := s.UpsertServer(store.Server{Slug: "billing", TenantSlug: "acme", Enabled: true, Audience: "https://api.example.com", AllowedIssuers: []string{serverIss.URL}}); err != nil {
+		t.Fatal(err)
+	}
+	v := NewJWTValidator(s)
+	tenantToken, serverToken := tenantIss.token(t, tenantIss.key, nil), serverIss.token(t, serverIss.key, nil)
+
+	cases := []struct {
+		server, token string
+		want          int
+	}{
+		{"orders", tenantToken, http.StatusOK},
+		{"orders", serverToken, http.StatusUnauthorized},
+		{"billing", serverToken, http.StatusOK},
+		{"billing", tenantToken, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		if rec := serveMCP(JWTAuthMiddleware(v), tc.server, bearer(tc.token)); rec.Code != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.server, rec.Code, tc.want)
+		}
+	}
+}
+
+// TestJWTAuthReadsIssuersFromPostgres checks that issuers stored in Postgres reach the
+// validator: the tenant list applies unless the server row overrides it.
+func TestJWTAuthReadsIssuersFromPostgres(t *testing.T) {
+	tenantIss, serverIss := newTestIssuer(t), newTestIssuer(t)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	v := NewJWTValidator(store.NewPostgresStore(db, "https://api.example.com"))
+
+	cases := []struct {
+		server, serverIssuers, token string
+		want                         int
+	}{
+		{"orders", `[]`, tenantIss.token(t, tenantIss.key, nil), http.StatusOK},
+		{"orders", `[]`, serverIss.token(t, serverIss.key, nil), http.StatusUnauthorized},
+		{"billing", `["` + serverIss.URL + `"]`, serverIss.token(t, serverIss.key, nil), http.StatusOK},
+		{"billing", `["` + serverIss.URL + `"]`, tenantIss.token(t, tenantIss.key, nil), http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		mock.ExpectQuery(`from servers s\s+join tenants t on t\.id = s\.tenant_id\s+where s\.slug=\$1`).
+			WithArgs(tc.server).
+			WillReturnRows(pgServerWithTenant(tc.server, tc.serverIssuers, `["`+tenantIss.URL+`"]`))
+		if rec := serveMCP(JWTAuthMiddleware(v), tc.server, bearer(tc.token)); rec.Code != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.server, rec.Code, tc.want)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pgServerWithTenant is the row GetServerWithTenant scans: the server columns, then the
+// tenant columns, with everything but the issuer lists at their defaults.
+func pgServerWithTenant(slug, serverIssuersJSON, tenantIssuersJSON string) *sqlmock.Rows {
+	values := []driver.Value{slug, "acme", slug, "https://api.example.com", true, "", "", "", "", nil,
+		serverIssuersJSON, "[]", "failover", "[]", "{}", "", "http", "[]", "{}", "[]", "{}", nil, "[]", "[]", nil, "[]", "", "[]",
+		"acme", "Acme", true, "[]", tenantIssuersJSON, "[]"}
+	cols := make([]string, len(values))
+	for i := range cols {
+		cols[i] = "c" + strconv.Itoa(i)
+	}
+	return sqlmock.NewRows(cols).AddRow(values...)
+}
+
+func TestJWKSFetchTimesOut(t *testing.T) {
+	iss := newTestIssuer(t)
+	iss.jwksDelay.Store(int64(2 * time.Second))
+	v := NewJWTValidator(newTestStore(t, iss.URL))
+	v.SetFetchTimeout(200 * time.Millisecond)
+	before := jwksFetchErrors.Value(iss.URL)
+
+	start := time.Now()
+	rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(iss.token(t, iss.key, nil)))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("request took %v with a 200ms fetch timeout", elapsed)
+	}
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got %d, want 503", rec.Code)
+	}
+	if got := jwksFetchErrors.Value(iss.URL); got != before+1 {
+		t.Fatalf("jwks_fetch_errors_total %v, want %v", got, before+1)
+	}
+}
+
+func TestJWKSFetchErrorMetric(t *testing.T) {
+	iss := newTestIssuer(t)
+	iss.jwksDown.Store(true)
+	v := NewJWTValidator(newTestStore(t, iss.URL))
+	token := iss.token(t, iss.key, nil)
+
+	serveMCP(JWTAuthMiddleware(v), "orders", bearer(token))
+	if got := jwksFetchErrors.Value(iss.URL); got != 1 {
+		t.Fatalf("jwks_fetch_errors_total %v after a failed fetch, want 1", got)
+	}
+	// A successful fetch adds nothing
+	iss.jwksDown.Store(false)
+	v.RefreshJWKS(iss.URL)
+	if rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(token)); rec.Code != http.StatusOK {
+		t.Fatalf("got %d", rec.Code)
+	}
+	if got := jwksFetchErrors.Value(iss.URL); got != 1 {
+		t.Fatalf("jwks_fetch_errors_total %v after a good fetch, want 1", got)
+	}
+}
+
+func TestJWTAuthAcceptsSecondaryAudience(t *testing.T) {
+	iss := newTestIssuer(t)
+	s := newTestStore(t, iss.URL)
+	if err := s.UpsertServer(store.Server{Slug: "orders", TenantSlug: "acme", Enabled: true, Audience: "https://api.example.com", Audiences: []string{"https://orders.example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	v := NewJWTValidator(s)
+
+	cases := []struct {
+		name string
+		aud  interface{}
+		want int
+	}{
+		{"primary", "https://api.example.com", http.StatusOK},
+		{"secondary", "https://orders.example.com", http.StatusOK},
+		{"secondary in an array", []string{"https://other.example.com", "https://orders.example.com"}, http.StatusOK},
+		{"unknown", "https://other.example.com", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(iss.token(t, iss.key, jwt.MapClaims{"aud": tc.aud})))
+		if rec.Code != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"gateway/proxy/internal/config"
+)
+
+// OriginHostMiddleware protects MCP routes against DNS rebinding by validating both the
+// Host header and, when present, the Origin header. Checks are skipped in Unprotected mode.
+// Requests without an Origin (non-browser clients) are accepted; browsers always send one.
+func OriginHostMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.Unprotected {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !hostAllowed(r.Host, config.AllowedHosts) {
+				http.Error(w, "forbidden host", http.StatusForbidden)
+				return
+			}
+			if origin := r.Header.Get("Origin"); origin != "" && len(config.AllowedOrigins) > 0 {
+				allowed := false
+				for _, o := range config.AllowedOrigins {
+					if o == origin {
+						allowed = true
+						break
+					}
+				}
+				if !allowed {
+					http.Error(w, "forbidden origin", http.StatusForbidden)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func hostAllowed(hostport string, allowed []string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	for _, a := range allowed {
+		if strings.EqualFold(host, strings.Trim(a, "[]")) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoopbackListener reports whether addr (host:port) only accepts loopback connections.
+// An empty host, as in ":8080", listens on every interface.
+func LoopbackListener(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return isLoopbackHost(host)
+}
+
+// LoopbackHostsOnly reports whether every entry of hosts names the local machine, so
+// requests addressed to the gateway under any other name are refused.
+func LoopbackHostsOnly(hosts []string) bool {
+	for _, h := range hosts {
+		if !isLoopbackHost(h) {
+			return false
+		}
+	}
+	return true
+}
+
+func isLoopbackHost(host string) bool {
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
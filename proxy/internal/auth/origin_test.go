@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gateway/proxy/internal/config"
+)
+
+func serveOriginHost(host, origin string) int {
+	h := OriginHostMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/proxy/orders/mcp", nil)
+	req.Host = host
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func withHostConfig(t *testing.T, hosts, origins []string) {
+	t.Helper()
+	prevHosts, prevOrigins, prevUnprotected := config.AllowedHosts, config.AllowedOrigins, config.Unprotected
+	t.Cleanup(func() {
+		config.AllowedHosts, config.AllowedOrigins, config.Unprotected = prevHosts, prevOrigins, prevUnprotected
+	})
+	config.AllowedHosts, config.AllowedOrigins, config.Unprotected = hosts, origins, false
+}
+
+func TestOriginHostLoopbackDefault(t *testing.T) {
+	withHostConfig(t, []string{"localhost", "127.0.0.1", "::1"}, nil)
+	for _, host := range []string{"localhost:8080", "127.0.0.1:8080", "[::1]:8080", "LOCALHOST"} {
+		if code := serveOriginHost(host, ""); code != http.StatusNoContent {
+			t.Errorf("Host %s: status %d, want 204", host, code)
+		}
+	}
+	if code := serveOriginHost("gateway.example.com", ""); code != http.StatusForbidden {
+		t.Errorf("non-loopback Host: status %d, want 403", code)
+	}
+}
+
+func TestOriginHostAllowedAndSpoofed(t *testing.T) {
+	withHostConfig(t, []string{"gateway.example.com"}, []string{"https://app.example.com"})
+	if code := serveOriginHost("gateway.example.com:443", "https://app.example.com"); code != http.StatusNoContent {
+		t.Errorf("allowed host and origin: status %d, want 204", code)
+	}
+	if code := serveOriginHost("evil.example.net", "https://app.example.com"); code != http.StatusForbidden {
+		t.Errorf("spoofed host: status %d, want 403", code)
+	}
+	if code := serveOriginHost("gateway.example.com", "https://evil.example.net"); code != http.StatusForbidden {
+		t.Errorf("foreign origin: status %d, want 403", code)
+	}
+	if code := serveOriginHost("gateway.example.com", ""); code != http.StatusNoContent {
+		t.Errorf("no origin: status %d, want 204", code)
+	}
+}
+
+func TestOriginHostUnprotected(t *testing.T) {
+	withHostConfig(t, []string{"localhost"}, nil)
+	config.Unprotected = true
+	if code := serveOriginHost("evil.example.net", ""); code != http.StatusNoContent {
+		t.Errorf("unprotected: status %d, want 204", code)
+	}
+}
+
+func TestLoopbackListenerAndHosts(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:8080": true,
+		"[::1]:8080":     true,
+		"localhost:8080": true,
+		":8080":          false,
+		"0.0.0.0:8080":   false,
+		"10.0.0.5:8080":  false,
+	} {
+		if got := LoopbackListener(addr); got != want {
+			t.Errorf("LoopbackListener(%q) = %v, want %v", addr, got, want)
+		}
+	}
+	if !LoopbackHostsOnly([]string{"localhost", "127.0.0.1", "::1"}) {
+		t.Error("default hosts should be loopback only")
+	}
+	if LoopbackHostsOnly([]string{"localhost", "gateway.example.com"}) {
+		t.Error("a named host is not loopback")
+	}
+}
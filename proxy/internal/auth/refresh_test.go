@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestRefreshJWKSRefetches(t *testing.T) {
+	iss, other := newTestIssuer(t), newTestIssuer(t)
+	v := NewJWTValidator(newTestStore(t, iss.URL, other.URL))
+	token, otherToken := iss.token(t, iss.key, nil), other.token(t, other.key, nil)
+	for _, tok := range []string{token, otherToken} {
+		if rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(tok)); rec.Code != http.StatusOK {
+			t.Fatalf("got %d", rec.Code)
+		}
+	}
+	hits, otherHits := iss.jwksHits.Load(), other.jwksHits.Load()
+
+	// Cached: no fetch
+	serveMCP(JWTAuthMiddleware(v), "orders", bearer(token))
+	if iss.jwksHits.Load() != hits {
+		t.Fatal("cached key set was fetched again")
+	}
+
+	if n := v.RefreshJWKS(iss.URL); n != 1 {
+		t.Fatalf("RefreshJWKS(issuer) cleared %d, want 1", n)
+	}
+	for _, tok := range []string{token, otherToken} {
+		if rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(tok)); rec.Code != http.StatusOK {
+			t.Fatalf("after refresh got %d", rec.Code)
+		}
+	}
+	if iss.jwksHits.Load() == hits {
+		t.Fatal("refresh did not cause a re-fetch")
+	}
+	if other.jwksHits.Load() != otherHits {
+		t.Fatal("scoped refresh re-fetched another issuer's key set")
+	}
+
+	if n := v.RefreshJWKS(""); n != 2 {
+		t.Fatalf("RefreshJWKS(all) cleared %d, want 2", n)
+	}
+}
+
+func TestRefreshJWKSConcurrent(t *testing.T) {
+	iss := newTestIssuer(t)
+	v := NewJWTValidator(newTestStore(t, iss.URL))
+	token := iss.token(t, iss.key, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				v.RefreshJWKS("")
+				v.RefreshJWKS(iss.URL)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(token)); rec.Code != http.StatusOK {
+					t.Errorf("got %d", rec.Code)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentValidationFetchesEachKeySetOnce(t *testing.T) {
+	issuers := []*testIssuer{newTestIssuer(t), newTestIssuer(t), newTestIssuer(t)}
+	urls := make([]string, len(issuers))
+	tokens := make([]string, len(issuers))
+	for i, iss := range issuers {
+		urls[i] = iss.URL
+		tokens[i] = iss.token(t, iss.key, nil)
+	}
+	v := NewJWTValidator(newTestStore(t, urls...))
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 60; i++ {
+		wg.Add(1)
+		go func(token string) {
+			defer wg.Done()
+			<-start
+			if rec := serveMCP(JWTAuthMiddleware(v), "orders", bearer(token)); rec.Code != http.StatusOK {
+				t.Errorf("got %d", rec.Code)
+			}
+		}(tokens[i%len(tokens)])
+	}
+	close(start)
+	wg.Wait()
+
+	for i, iss := range issuers {
+		if n := iss.jwksHits.Load(); n != 1 {
+			t.Errorf("issuer %d: key set fetched %d times, want 1", i, n)
+		}
+	}
+}
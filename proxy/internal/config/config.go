@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 // Unprotected, when true, disables JWT authentication and scope checks for local/dev.
 // NEVER enable in production.
 var Unprotected bool = false
@@ -8,6 +10,114 @@ var Unprotected bool = false
 // and deny if not matched in protected mode.
 var AllowedOrigins []string
 
+// AllowedHosts lists accepted Host header values (port ignored) for MCP requests in protected
+// mode, guarding against DNS rebinding. Defaults to loopback only.
+var AllowedHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// DefaultEgressAllowlist applies to tenants whose own egress allowlist is empty. Empty
+// means such tenants cannot reach any upstream.
+var DefaultEgressAllowlist []string
+
+// Version identifies the gateway build, e.g. in the default upstream User-Agent.
+const Version = "0.1.0"
+
+// UpstreamUserAgent is sent on upstream calls unless a tool's mapping sets User-Agent.
+var UpstreamUserAgent = "mcp-gateway/" + Version
+
+// MaxUpstreamResponseBytes bounds a buffered upstream response body, after decompression,
+// so a small compressed body cannot expand without limit in memory. Streamed tool
+// results are relayed as they arrive and are not bounded.
+var MaxUpstreamResponseBytes = 16 << 20
+
+// UpstreamDefaultHeaders are sent on every upstream call; a tool's mapping headers win.
+var UpstreamDefaultHeaders map[string]string
+
+// StrictToolArgs, when true, rejects unknown tools/call arguments for tools whose input schema
+// sets additionalProperties:false instead of silently stripping them.
+var StrictToolArgs bool = false
+
+// StrictParams, when true, rejects initialize and tools/call requests whose params carry
+// top-level fields the gateway does not know, e.g. a misspelled "arguments", instead of
+// ignoring them.
+var StrictParams bool = false
+
+// KeepUnresolvedPlaceholders, when true, leaves {{claim}} placeholders in server instructions
+// literally if the claim is absent instead of rendering them blank.
+var KeepUnresolvedPlaceholders bool = false
+
+// SessionCookie, when true, also issues the MCP session id as a Secure, HttpOnly cookie on
+// initialize and accepts it when the Mcp-Session-Id header is absent. The header wins.
+var SessionCookie bool = false
+
+// SessionCookieSameSite is the session cookie's SameSite attribute: "strict", "lax" or "none".
+var SessionCookieSameSite = "strict"
+
+// WSMaxMessageBytes bounds one message read from an MCP WebSocket; a larger message
+// closes the socket.
+var WSMaxMessageBytes int64 = 1 << 20
+
+// WSIdleTimeout closes an MCP WebSocket that sends nothing, not even a pong, for this
+// long. The gateway pings at half this interval, so live clients never hit it.
+var WSIdleTimeout = 60 * time.Second
+
+// SSEWriteTimeout bounds each write to an SSE stream, a WebSocket or a streamed tool
+// result, so a client that stops reading releases its stream instead of holding it open.
+var SSEWriteTimeout = 10 * time.Second
+
+// AuditToolCalls, when true, logs every tools/call with its arguments at info level;
+// otherwise the same entry is only emitted at debug level. Sensitive values are redacted.
+var AuditToolCalls bool = false
+
+// BatchMaxParallel bounds how many entries of one JSON-RPC batch run at the same time.
+var BatchMaxParallel = 4
+
+// BatchMaxSize bounds the number of entries in one JSON-RPC batch; larger batches are
+// rejected whole. Zero or less disables the limit.
+var BatchMaxSize = 50
+
+// SSEMaxStreamsPerTenant caps concurrently open SSE streams per tenant; further GETs get
+// 429. Zero or less disables the limit.
+var SSEMaxStreamsPerTenant = 0
+
+// MetricsMaxToolLabels caps the distinct server and tool pairs that get their own tool
+// label on per-tool metrics; calls to tools first seen after that are counted under
+// "__other__". Zero or less disables the cap.
+var MetricsMaxToolLabels = 1000
+
+// Error verbosity levels for JSON-RPC -32000 errors.
+const (
+	ErrorVerbosityProduction = "production"
+	ErrorVerbosityDebug      = "debug"
+)
+
+// ErrorVerbosity controls whether -32000 errors carry the underlying error (debug) or only
+// a generic message and a correlation id, with the detail logged server-side (production).
+var ErrorVerbosity = ErrorVerbosityProduction
+
+// How tools/call reports an upstream 204 or empty body.
+const (
+	EmptyResponseNull = "null"
+	EmptyResponseOK   = "ok"
+	EmptyResponseText = "text"
+)
+
+// EmptyResponse selects the data of a tools/call result whose upstream sent no body:
+// null, {"ok": true, "status": <code>}, or the former {"text": ""}.
+var EmptyResponse = EmptyResponseNull
+
 // Supported protocol versions (latest + fallback)
 const MCPProtocolVersionLatest = "2025-06-18"
 const MCPProtocolVersionFallback = "2025-03-26"
+
+// SupportedProtocolVersions lists the versions initialize will agree to, newest first.
+var SupportedProtocolVersions = []string{MCPProtocolVersionLatest, MCPProtocolVersionFallback}
+
+// IsSupportedProtocolVersion reports whether v is one of SupportedProtocolVersions.
+func IsSupportedProtocolVersion(v string) bool {
+	for _, s := range SupportedProtocolVersions {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"gateway/proxy/internal/store"
+)
+
+// Load-balancing strategies for servers with several upstream base URLs.
+const (
+	StrategyFailover   = "failover"
+	StrategyRoundRobin = "round-robin"
+	StrategyWeighted   = "weighted"
+)
+
+const (
+	// unhealthyAfter consecutive 5xx/connection failures take an upstream out of rotation
+	unhealthyAfter = 3
+	// unhealthyCooldown is how long an unhealthy upstream stays out before being retried
+	unhealthyCooldown = 30 * time.Second
+)
+
+type upstreamHealth struct {
+	failures  int
+	downUntil time.Time
+}
+
+// rotation is a server's smooth weighted round-robin state: each pick adds every weight to
+// its upstream's current value and takes the largest, which then gives back the total. It
+// spreads heavier upstreams evenly instead of sending them runs of consecutive calls.
+type rotation struct {
+	weights []int
+	current []int
+}
+
+// Balancer orders upstream candidates per call according to the server's strategy and
+// tracks passive health, plus the active health state a HealthChecker records per server.
+// It is safe for concurrent use.
+type Balancer struct {
+	mu        sync.Mutex
+	rotations map[string]*rotation
+	health    map[string]*upstreamHealth
+	down      map[string]bool
+}
+
+func NewBalancer() *Balancer {
+	return &Balancer{rotations: make(map[string]*rotation), health: make(map[string]*upstreamHealth), down: make(map[string]bool)}
+}
+
+// ServerDown reports whether the server's last health check round failed.
+func (b *Balancer) ServerDown(serverSlug string) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.down[serverSlug]
+}
+
+func (b *Balancer) setServerDown(serverSlug string, down bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if down {
+		b.down[serverSlug] = true
+	} else {
+		delete(b.down, serverSlug)
+	}
+}
+
+// failFast reports whether a call to srv should be rejected without trying its upstreams.
+func (b *Balancer) failFast(srv store.Server) bool {
+	return srv.HealthCheck != nil && srv.HealthCheck.FailFast && b.ServerDown(srv.Slug)
+}
+
+// Order returns the upstream bases to try for one call. The selected upstream comes first
+// and the remaining ones follow as failover candidates; unhealthy upstreams are moved to
+// the end so they are only tried when nothing else is left.
+func (b *Balancer) Order(srv store.Server) []string {
+	bases := srv.UpstreamBases()
+	if len(bases) <= 1 || b == nil {
+		return bases
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var ordered []string
+	switch srv.LoadBalancing {
+	case StrategyRoundRobin, StrategyWeighted:
+		first := b.rotationLocked(srv, len(bases)).next()
+		ordered = append(ordered, bases[first])
+		for i := 1; i < len(bases); i++ {
+			ordered = append(ordered, bases[(first+i)%len(bases)])
+		}
+	default:
+		ordered = append(ordered, bases...)
+	}
+
+	now := time.Now()
+	healthy := make([]string, 0, len(ordered))
+	unhealthy := []string{}
+	for _, base := range ordered {
+		if h, ok := b.health[base]; ok && now.Before(h.downUntil) {
+			unhealthy = append(unhealthy, base)
+			continue
+		}
+		healthy = append(healthy, base)
+	}
+	return append(healthy, unhealthy...)
+}
+
+// rotationLocked returns the server's rotation over n upstreams, starting a new one when
+// the upstreams or their weights changed. Round-robin weighs every upstream 1.
+func (b *Balancer) rotationLocked(srv store.Server, n int) *rotation {
+	weights := make([]int, n)
+	for i := range weights {
+		weights[i] = 1
+		if srv.LoadBalancing == StrategyWeighted && i < len(srv.UpstreamWeights) && srv.UpstreamWeights[i] > 0 {
+			weights[i] = srv.UpstreamWeights[i]
+		}
+	}
+	r := b.rotations[srv.Slug]
+	if r == nil || !slices.Equal(r.weights, weights) {
+		r = &rotation{weights: weights, current: make([]int, n)}
+		b.rotations[srv.Slug] = r
+	}
+	return r
+}
+
+// next returns the index of the upstream that takes this call.
+func (r *rotation) next() int {
+	best, total := 0, 0
+	for i, w := range r.weights {
+		r.current[i] += w
+		total += w
+		if r.current[i] > r.current[best] {
+			best = i
+		}
+	}
+	r.current[best] -= total
+	return best
+}
+
+// Report records the outcome of a call to an upstream base URL.
+func (b *Balancer) Report(base string, ok bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h, exists := b.health[base]
+	if ok {
+		if exists {
+			delete(b.health, base)
+		}
+		return
+	}
+	if !exists {
+		h = &upstreamHealth{}
+		b.health[base] = h
+	}
+	h.failures++
+	if h.failures >= unhealthyAfter {
+		h.downUntil = time.Now().Add(unhealthyCooldown)
+		h.failures = 0
+	}
+}
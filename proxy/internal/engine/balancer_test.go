@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+func balancedServer(strategy string, weights ...int) store.Server {
+	return store.Server{
+		Slug:             "orders",
+		UpstreamBaseURLs: []string{"http://a", "http://b", "http://c"},
+		LoadBalancing:    strategy,
+		UpstreamWeights:  weights,
+	}
+}
+
+func countFirst(lb *Balancer, srv store.Server, calls int) map[string]int {
+	counts := map[string]int{}
+	for i := 0; i < calls; i++ {
+		counts[lb.Order(srv)[0]]++
+	}
+	return counts
+}
+
+func TestBalancerRoundRobinIsEven(t *testing.T) {
+	lb := NewBalancer()
+	srv := balancedServer(StrategyRoundRobin)
+	counts := countFirst(lb, srv, 300)
+	for _, base := range srv.UpstreamBaseURLs {
+		if counts[base] != 100 {
+			t.Fatalf("distribution %v, want 100 each", counts)
+		}
+	}
+	if order := lb.Order(srv); len(order) != 3 || order[1] == order[0] || order[2] == order[0] {
+		t.Fatalf("order %v should list every upstream once", order)
+	}
+}
+
+func TestBalancerWeightedIsSmooth(t *testing.T) {
+	lb := NewBalancer()
+	srv := balancedServer(StrategyWeighted, 5, 1, 1)
+	var picks []string
+	for i := 0; i < 7; i++ {
+		picks = append(picks, lb.Order(srv)[0])
+	}
+	// Smooth weighted round-robin interleaves the lighter upstreams instead of running a
+	// five times in a row
+	want := []string{"http://a", "http://a", "http://b", "http://a", "http://c", "http://a", "http://a"}
+	for i := range want {
+		if picks[i] != want[i] {
+			t.Fatalf("picks %v, want %v", picks, want)
+		}
+	}
+	counts := countFirst(lb, srv, 700)
+	if counts["http://a"] != 500 || counts["http://b"] != 100 || counts["http://c"] != 100 {
+		t.Fatalf("distribution %v, want 500/100/100", counts)
+	}
+}
+
+func TestBalancerWeightChangeRestartsRotation(t *testing.T) {
+	lb := NewBalancer()
+	countFirst(lb, balancedServer(StrategyWeighted, 5, 1, 1), 3)
+	counts := countFirst(lb, balancedServer(StrategyWeighted, 1, 1, 2), 400)
+	if counts["http://a"] != 100 || counts["http://b"] != 100 || counts["http://c"] != 200 {
+		t.Fatalf("distribution %v after weight change, want 100/100/200", counts)
+	}
+}
+
+func TestBalancerSkipsUnhealthy(t *testing.T) {
+	lb := NewBalancer()
+	srv := balancedServer(StrategyRoundRobin)
+	for i := 0; i < unhealthyAfter; i++ {
+		lb.Report("http://b", false)
+	}
+	counts := countFirst(lb, srv, 300)
+	if counts["http://b"] != 0 || counts["http://a"]+counts["http://c"] != 300 {
+		t.Fatalf("distribution %v, unhealthy upstream should not be picked first", counts)
+	}
+	if order := lb.Order(srv); order[len(order)-1] != "http://b" {
+		t.Fatalf("order %v, unhealthy upstream should be last", order)
+	}
+	lb.Report("http://b", true)
+	if counts := countFirst(lb, srv, 300); counts["http://b"] != 100 {
+		t.Fatalf("distribution %v after recovery, want 100 for b", counts)
+	}
+}
+
+func TestBalancerFailoverKeepsOrder(t *testing.T) {
+	lb := NewBalancer()
+	srv := balancedServer("")
+	for i := 0; i < 3; i++ {
+		if order := lb.Order(srv); order[0] != "http://a" || order[2] != "http://c" {
+			t.Fatalf("failover order %v", order)
+		}
+	}
+}
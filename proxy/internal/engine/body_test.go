@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+// capturedRequest is what an upstream saw: the media type and the decoded body fields,
+// flattened the way form encodings flatten them.
+type capturedRequest struct {
+	mediaType string
+	fields    map[string]string
+}
+
+// captureUpstream decodes each request body according to its content type.
+func captureUpstream(t *testing.T) (chan capturedRequest, store.Server, store.Tenant) {
+	t.Helper()
+	got := make(chan capturedRequest, 1)
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fields := map[string]string{}
+		switch mt {
+		case "application/json":
+			var body map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for k, v := range body {
+				fields[k] = formValue(v)
+			}
+		case "application/x-www-form-urlencoded":
+			if err := r.ParseForm(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for k := range r.PostForm {
+				fields[k] = r.PostForm.Get(k)
+			}
+		case "multipart/form-data":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for k, v := range r.MultipartForm.Value {
+				fields[k] = v[0]
+			}
+		}
+		got <- capturedRequest{mediaType: mt, fields: fields}
+	})
+	return got, srv, tenant
+}
+
+func TestBodyEncodings(t *testing.T) {
+	args := map[string]interface{}{"amount": 12, "note": "rush & gift"}
+	body := map[string]interface{}{"amount": "{{amount}}", "note": "{{note}}", "meta": map[string]interface{}{"source": "mcp"}}
+	want := map[string]string{"amount": "12", "note": "rush & gift", "meta": `{"source":"mcp"}`}
+	cases := []struct{ encoding, mediaType string }{
+		{"", "application/json"},
+		{"json", "application/json"},
+		{"form", "application/x-www-form-urlencoded"},
+		{"multipart", "multipart/form-data"},
+	}
+	for _, tc := range cases {
+		got, srv, tenant := captureUpstream(t)
+		tool := store.Tool{Name: "create_order", Mapping: store.RequestTemplate{Method: http.MethodPost, Path: "/orders", Body: body, BodyEncoding: tc.encoding}}
+		res, err := Execute(context.Background(), http.DefaultClient, srv, tenant, tool, args)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.encoding, err)
+		}
+		if res.UpstreamStatus != http.StatusOK {
+			t.Fatalf("%q: upstream status %d: %s", tc.encoding, res.UpstreamStatus, res.UpstreamBody)
+		}
+		req := <-got
+		if req.mediaType != tc.mediaType {
+			t.Errorf("%q: content type %q, want %q", tc.encoding, req.mediaType, tc.mediaType)
+		}
+		if len(req.fields) != len(want) {
+			t.Errorf("%q: fields %v, want %v", tc.encoding, req.fields, want)
+		}
+		for k, v := range want {
+			if req.fields[k] != v {
+				t.Errorf("%q: field %s = %q, want %q", tc.encoding, k, req.fields[k], v)
+			}
+		}
+	}
+}
+
+func TestUnsupportedBodyEncoding(t *testing.T) {
+	got, srv, tenant := captureUpstream(t)
+	tool := store.Tool{Name: "create_order", Mapping: store.RequestTemplate{Method: http.MethodPost, Path: "/orders", Body: map[string]interface{}{"a": "b"}, BodyEncoding: "xml"}}
+	if _, err := Execute(context.Background(), http.DefaultClient, srv, tenant, tool, nil); err == nil {
+		t.Fatal("Execute succeeded with an unsupported encoding")
+	}
+	select {
+	case <-got:
+		t.Fatal("upstream was called")
+	default:
+	}
+}
@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gateway/proxy/internal/store"
+)
+
+// Cache stores upstream results for read-only tools. Implementations must be safe
+// for concurrent use.
+type Cache interface {
+	Get(key string) (*ExecuteResult, bool)
+	Set(key string, res *ExecuteResult, ttl time.Duration)
+}
+
+// CacheStats reports hit/miss counters for metrics.
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+type cacheEntry struct {
+	res     *ExecuteResult
+	expires time.Time
+}
+
+// MemoryCache is a bounded in-process Cache with per-entry TTLs.
+type MemoryCache struct {
+	mu         sync.Mutex
+	entries    map[string]cacheEntry
+	maxEntries int
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+}
+
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{entries: make(map[string]cacheEntry), maxEntries: maxEntries}
+}
+
+func (c *MemoryCache) Get(key string) (*ExecuteResult, bool) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok && time.Now().After(e.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.mu.Unlock()
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return e.res, true
+}
+
+func (c *MemoryCache) Set(key string, res *ExecuteResult, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		// Still full: drop an arbitrary entry to stay bounded
+		for k := range c.entries {
+			if len(c.entries) < c.maxEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cacheEntry{res: res, expires: time.Now().Add(ttl)}
+}
+
+func (c *MemoryCache) Stats() CacheStats {
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// CacheKey identifies a call by server, tool and arguments. Arguments are normalized
+// through JSON encoding, which sorts map keys.
+func CacheKey(serverSlug, toolName string, args map[string]interface{}) string {
+	argsJSON, _ := json.Marshal(args)
+	sum := sha256.Sum256([]byte(serverSlug + "\x00" + toolName + "\x00" + string(argsJSON)))
+	return hex.EncodeToString(sum[:])
+}
+
+// ExecuteCached serves GET tools with a CacheTTLSeconds from cache when possible and
+// stores successful (2xx) responses. Identical concurrent misses of those tools share one
+// upstream request, even when cache is nil. Other tools go straight to ExecuteBalanced and
+// are never deduplicated.
+func ExecuteCached(ctx context.Context, cache Cache, lb *Balancer, httpClient *http.Client, srv store.Server, tenant store.Tenant, tool store.Tool, args map[string]interface{}) (*ExecuteResult, error) {
+	ttl := time.Duration(tool.Mapping.CacheTTLSeconds) * time.Second
+	if ttl <= 0 || !strings.EqualFold(tool.Mapping.Method, http.MethodGet) {
+		return ExecuteBalanced(ctx, lb, httpClient, srv, tenant, tool, args)
+	}
+	// Responses may depend on forwarded identity, so callers with different claims never share entries
+	scope := srv.Slug
+	if forwarded := claimHeaders(srv.ClaimHeaderMappings, claimsFrom(ctx)); len(forwarded) > 0 {
+		b, _ := json.Marshal(forwarded)
+		scope += "\x00" + string(b)
+	}
+	key := CacheKey(scope, tool.Name, args)
+	if cache != nil {
+		if res, ok := cache.Get(key); ok {
+			return res, nil
+		}
+	}
+	res, shared, err := cacheMisses.do(ctx, key, func(ctx context.Context) (*ExecuteResult, error) {
+		res, err := ExecuteBalanced(ctx, lb, httpClient, srv, tenant, tool, args)
+		if err == nil && cache != nil && res.UpstreamStatus >= 200 && res.UpstreamStatus < 300 {
+			cache.Set(key, res, ttl)
+		}
+		return res, err
+	})
+	if shared {
+		upstreamDeduplicated.Inc(srv.Slug)
+	}
+	return res, err
+}
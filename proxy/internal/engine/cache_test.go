@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gateway/proxy/internal/store"
+)
+
+// countingUpstream answers 200 with the request path, or 500 on /fail,
+// and counts requests.
+func countingUpstream(t *testing.T) (*atomic.Int32, store.Server, store.Tenant) {
+	t.Helper()
+	var hits atomic.Int32
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/fail" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	})
+	return &hits, srv, tenant
+}
+
+func cachedTool(method, path string) store.Tool {
+	tool := testTool("get_order", path)
+	tool.Mapping.Method = method
+	tool.Mapping.CacheTTLSeconds = 60
+	return tool
+}
+
+func TestCacheHitSkipsUpstream(t *testing.T) {
+	hits, srv, tenant := countingUpstream(t)
+	cache := NewMemoryCache(100)
+	tool := cachedTool(http.MethodGet, "/orders/{{id}}")
+	args := map[string]interface{}{"id": "42"}
+
+	first, err := ExecuteCached(context.Background(), cache, NewBalancer(), http.DefaultClient, srv, tenant, tool, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ExecuteCached(context.Background(), cache, NewBalancer(), http.DefaultClient, srv, tenant, tool, map[string]interface{}{"id": "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Fatalf("upstream hits = %d, want 1", n)
+	}
+	if string(first.UpstreamBody) != string(second.UpstreamBody) {
+		t.Fatalf("cached body %q, want %q", second.UpstreamBody, first.UpstreamBody)
+	}
+	if st := cache.Stats(); st.Hits != 1 || st.Misses != 1 {
+		t.Fatalf("stats = %+v, want 1 hit and 1 miss", st)
+	}
+}
+
+func TestCacheKeyedByArguments(t *testing.T) {
+	hits, srv, tenant := countingUpstream(t)
+	cache := NewMemoryCache(100)
+	tool := cachedTool(http.MethodGet, "/orders/{{id}}")
+
+	for _, id := range []string{"1", "2", "1", "2"} {
+		res, err := ExecuteCached(context.Background(), cache, NewBalancer(), http.DefaultClient, srv, tenant, tool, map[string]interface{}{"id": id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"path":"/orders/` + id + `"}`; string(res.UpstreamBody) != want {
+			t.Fatalf("id %s: body %q, want %q", id, res.UpstreamBody, want)
+		}
+	}
+	if n := hits.Load(); n != 2 {
+		t.Fatalf("upstream hits = %d, want 2", n)
+	}
+	if CacheKey("orders", "t", map[string]interface{}{"a": 1, "b": 2}) != CacheKey("orders", "t", map[string]interface{}{"b": 2, "a": 1}) {
+		t.Fatal("argument order changed the cache key")
+	}
+}
+
+func TestCacheOnlyStoresSuccessfulGets(t *testing.T) {
+	cases := map[string]store.Tool{
+		"non-GET":      cachedTool(http.MethodPost, "/orders"),
+		"error status": cachedTool(http.MethodGet, "/fail"),
+		"no TTL":       testTool("get_order", "/orders"),
+	}
+	for name, tool := range cases {
+		hits, srv, tenant := countingUpstream(t)
+		cache := NewMemoryCache(100)
+		for i := 0; i < 2; i++ {
+			if _, err := ExecuteCached(context.Background(), cache, NewBalancer(), http.DefaultClient, srv, tenant, tool, nil); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+		if n := hits.Load(); n != 2 {
+			t.Errorf("%s: upstream hits = %d, want 2", name, n)
+		}
+	}
+}
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	cache := NewMemoryCache(2)
+	cache.Set("a", &ExecuteResult{UpstreamStatus: 200}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.Get("a"); ok {
+		t.Fatal("expired entry returned")
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		cache.Set(k, &ExecuteResult{UpstreamStatus: 200}, time.Minute)
+	}
+	if n := len(cache.entries); n > 2 {
+		t.Fatalf("cache holds %d entries, want at most 2", n)
+	}
+}
@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// blockingUpstream holds every request until its context ends and reports that on aborted.
+func blockingUpstream() (chan struct{}, chan struct{}, http.HandlerFunc) {
+	started, aborted := make(chan struct{}, 1), make(chan struct{}, 1)
+	return started, aborted, func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		select {
+		case <-r.Context().Done():
+			aborted <- struct{}{}
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
+
+func TestCancelAbortsUpstreamCall(t *testing.T) {
+	started, aborted, h := blockingUpstream()
+	_, srv, tenant := testUpstream(t, h)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	begin := time.Now()
+	_, err := ExecuteBalanced(ctx, NewBalancer(), http.DefaultClient, srv, tenant, testTool("t", "/slow"), nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if d := time.Since(begin); d > 2*time.Second {
+		t.Fatalf("call returned after %v", d)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream request was not aborted")
+	}
+}
+
+func TestDeadlineBoundsUpstreamCall(t *testing.T) {
+	_, aborted, h := blockingUpstream()
+	_, srv, tenant := testUpstream(t, h)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := ExecuteBalanced(ctx, NewBalancer(), http.DefaultClient, srv, tenant, testTool("t", "/slow"), nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream request was not aborted")
+	}
+}
+
+func TestCancelDoesNotFailOver(t *testing.T) {
+	started, _, h := blockingUpstream()
+	ts, srv, tenant := testUpstream(t, h)
+	srv.UpstreamBaseURL, srv.UpstreamBaseURLs = "", []string{ts.URL, ts.URL + "/"}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, _ = ExecuteBalanced(ctx, NewBalancer(), http.DefaultClient, srv, tenant, testTool("t", "/slow"), nil)
+	select {
+	case <-started:
+		t.Fatal("cancelled call failed over to the next upstream")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+type claimsKey struct{}
+
+// WithClaims returns a context whose upstream calls forward the given token claims as
+// headers, per the server's ClaimHeaderMappings.
+func WithClaims(ctx context.Context, claims map[string]interface{}) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+func claimsFrom(ctx context.Context) map[string]interface{} {
+	claims, _ := ctx.Value(claimsKey{}).(map[string]interface{})
+	return claims
+}
+
+// claimHeaders resolves mappings (claim name -> header name) against claims. Only string
+// and number claims are forwarded; missing, empty and other claim types are skipped.
+func claimHeaders(mappings map[string]string, claims map[string]interface{}) map[string]string {
+	if len(mappings) == 0 || len(claims) == 0 {
+		return nil
+	}
+	out := map[string]string{}
+	for claim, header := range mappings {
+		var v string
+		switch c := claims[claim].(type) {
+		case string:
+			v = c
+		case float64:
+			v = strconv.FormatFloat(c, 'f', -1, 64)
+		case json.Number:
+			v = c.String()
+		case int:
+			v = strconv.Itoa(c)
+		case int64:
+			v = strconv.FormatInt(c, 10)
+		}
+		if v != "" && header != "" {
+			out[header] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestClaimHeadersReachUpstream(t *testing.T) {
+	var got http.Header
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		_, _ = w.Write([]byte(`{}`))
+	})
+	srv.ClaimHeaderMappings = map[string]string{
+		"sub":       "X-User-Id",
+		"tenant_id": "X-Tenant",
+		"level":     "X-Level",
+		"email":     "X-Email",
+		"groups":    "X-Groups",
+		"admin":     "X-Admin",
+	}
+	tool := testTool("t", "/x")
+	// A tool header cannot spoof the caller's identity
+	tool.Mapping.Headers = map[string]string{"X-User-Id": "{{user}}"}
+	ctx := WithClaims(context.Background(), map[string]interface{}{
+		"sub":       "alice",
+		"tenant_id": "acme",
+		"level":     float64(3),
+		"groups":    []interface{}{"a", "b"},
+		"admin":     true,
+	})
+
+	if _, err := ExecuteBalanced(ctx, NewBalancer(), http.DefaultClient, srv, tenant, tool, map[string]interface{}{"user": "mallory"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("X-User-Id") != "alice" || got.Get("X-Tenant") != "acme" || got.Get("X-Level") != "3" {
+		t.Fatalf("identity headers %v", got)
+	}
+	for _, h := range []string{"X-Email", "X-Groups", "X-Admin", "Authorization"} {
+		if _, ok := got[h]; ok {
+			t.Errorf("%s sent: %q", h, got.Get(h))
+		}
+	}
+}
+
+func TestClaimHeaders(t *testing.T) {
+	mappings := map[string]string{"sub": "X-User-Id", "empty": "X-Empty", "unmapped": ""}
+	got := claimHeaders(mappings, map[string]interface{}{"sub": "alice", "empty": "", "unmapped": "x"})
+	if len(got) != 1 || got["X-User-Id"] != "alice" {
+		t.Fatalf("headers %v", got)
+	}
+	if got := claimHeaders(mappings, nil); got != nil {
+		t.Fatalf("no claims: %v", got)
+	}
+}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"gateway/proxy/internal/store"
+)
+
+// Default upstream timeouts; a server's Timeouts may override each stage.
+const (
+	defaultConnectTimeout      = 10 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+	// DefaultCallTimeout caps a whole tool call when the server sets no TotalMs
+	DefaultCallTimeout = 20 * time.Second
+)
+
+// TransportOptions controls connection pooling for upstream calls.
+type TransportOptions struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	// SSRFGuard refuses connections to private, loopback and link-local addresses after DNS
+	// resolution, except those inside SSRFAllowed. Environment proxies are ignored when set,
+	// since the guard would otherwise only see the proxy's address.
+	SSRFGuard   bool
+	SSRFAllowed []netip.Prefix
+}
+
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// ClientFactory hands out HTTP clients that share a single pooled transport,
+// so repeated tool calls to the same upstream reuse connections.
+type ClientFactory struct {
+	opts      TransportOptions
+	transport *http.Transport
+
+	// Transports for servers with their own connect, TLS or header timeouts, shared by
+	// servers with the same settings
+	mu        sync.Mutex
+	byTimeout map[stageTimeouts]*http.Transport
+}
+
+// stageTimeouts are the per-connection timeouts a transport is built with.
+type stageTimeouts struct {
+	connect, tlsHandshake, responseHeader time.Duration
+}
+
+func NewClientFactory(opts TransportOptions) *ClientFactory {
+	f := &ClientFactory{opts: opts, byTimeout: make(map[stageTimeouts]*http.Transport)}
+	f.transport = f.newTransport(stageTimeouts{connect: defaultConnectTimeout, tlsHandshake: defaultTLSHandshakeTimeout})
+	return f
+}
+
+func (f *ClientFactory) newTransport(st stageTimeouts) *http.Transport {
+	dialer := &net.Dialer{Timeout: st.connect, KeepAlive: 30 * time.Second}
+	proxy := http.ProxyFromEnvironment
+	if f.opts.SSRFGuard {
+		dialer.Control = ssrfControl(f.opts.SSRFAllowed)
+		proxy = nil
+	}
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          f.opts.MaxIdleConns,
+		MaxIdleConnsPerHost:   f.opts.MaxIdleConnsPerHost,
+		IdleConnTimeout:       f.opts.IdleConnTimeout,
+		TLSHandshakeTimeout:   st.tlsHandshake,
+		ResponseHeaderTimeout: st.responseHeader,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// ServerClient returns a client for srv's upstreams, using a transport built with the
+// server's connect, TLS handshake and response header timeouts when it sets any. The
+// total budget is not part of the client; see CallTimeout.
+func (f *ClientFactory) ServerClient(srv store.Server) *http.Client {
+	to := srv.Timeouts
+	if to == nil || (to.ConnectMs <= 0 && to.TLSHandshakeMs <= 0 && to.ResponseHeaderMs <= 0) {
+		return f.Client(0)
+	}
+	st := stageTimeouts{
+		connect:        msOr(to.ConnectMs, defaultConnectTimeout),
+		tlsHandshake:   msOr(to.TLSHandshakeMs, defaultTLSHandshakeTimeout),
+		responseHeader: msOr(to.ResponseHeaderMs, 0),
+	}
+	f.mu.Lock()
+	t, ok := f.byTimeout[st]
+	if !ok {
+		t = f.newTransport(st)
+		f.byTimeout[st] = t
+	}
+	f.mu.Unlock()
+	return &http.Client{Transport: t}
+}
+
+// CallTimeout is the budget for one tool call on srv: its TotalMs, or DefaultCallTimeout.
+func CallTimeout(srv store.Server) time.Duration {
+	if srv.Timeouts != nil {
+		return msOr(srv.Timeouts.TotalMs, DefaultCallTimeout)
+	}
+	return DefaultCallTimeout
+}
+
+func msOr(ms int, def time.Duration) time.Duration {
+	if ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return def
+}
+
+// Client returns a client bound to the shared transport; only the timeout differs per call.
+func (f *ClientFactory) Client(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: f.transport, Timeout: timeout}
+}
+
+// Transport exposes the shared transport (e.g. for CloseIdleConnections on shutdown).
+func (f *ClientFactory) Transport() *http.Transport { return f.transport }
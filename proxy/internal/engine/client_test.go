@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+// dialCounter starts an upstream that counts the connections opened to it.
+func dialCounter(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var conns atomic.Int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return ts, &conns
+}
+
+func TestClientFactoryReusesConnections(t *testing.T) {
+	ts, conns := dialCounter(t)
+	srv, tenant := testTarget(ts.URL)
+	f := NewClientFactory(DefaultTransportOptions())
+
+	for i := 0; i < 20; i++ {
+		// A fresh client per call, as the handlers get one, still shares the pool
+		if _, err := ExecuteBalanced(context.Background(), nil, f.Client(CallTimeout(srv)), srv, tenant, testTool("t", "/x"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Fatalf("%d connections for 20 sequential calls, want 1", n)
+	}
+}
+
+func TestServerClientSharesTransportPerTimeouts(t *testing.T) {
+	ts, conns := dialCounter(t)
+	srv, tenant := testTarget(ts.URL)
+	srv.Timeouts = &store.UpstreamTimeouts{ConnectMs: 500}
+	f := NewClientFactory(DefaultTransportOptions())
+
+	if f.ServerClient(srv).Transport != f.ServerClient(srv).Transport {
+		t.Fatal("servers with the same timeouts should share a transport")
+	}
+	if f.ServerClient(srv).Transport == f.Client(0).Transport {
+		t.Fatal("custom timeouts should get their own transport")
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := ExecuteBalanced(context.Background(), nil, f.ServerClient(srv), srv, tenant, testTool("t", "/x"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Fatalf("%d connections for 10 calls, want 1", n)
+	}
+}
+
+func BenchmarkPooledCalls(b *testing.B) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	srv, tenant := testTarget(ts.URL)
+	f := NewClientFactory(DefaultTransportOptions())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ExecuteBalanced(context.Background(), nil, f.Client(0), srv, tenant, testTool("t", "/x"), nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"gateway/proxy/internal/config"
+)
+
+// compressMinBytes is the smallest request body worth gzipping when a tool opts in.
+const compressMinBytes = 1024
+
+// ErrResponseTooLarge is returned when an upstream body, encoded or decoded, exceeds
+// config.MaxUpstreamResponseBytes.
+var ErrResponseTooLarge = errors.New("upstream response too large")
+
+// readLimited reads r up to config.MaxUpstreamResponseBytes.
+func readLimited(r io.Reader) ([]byte, error) {
+	limit := int64(config.MaxUpstreamResponseBytes)
+	b, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, limit)
+	}
+	return b, nil
+}
+
+// readBody returns the response body, decoding gzip or deflate Content-Encoding. net/http
+// only decompresses transparently when it added Accept-Encoding itself, so responses to
+// mappings that set the header (or upstreams that compress unasked) arrive encoded. Both
+// the encoded and the decoded body are bounded by config.MaxUpstreamResponseBytes.
+func readBody(resp *http.Response) ([]byte, error) {
+	raw, err := readLimited(resp.Body)
+	if err != nil {
+		return raw, err
+	}
+	enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if resp.Uncompressed || enc == "" || enc == "identity" || len(raw) == 0 {
+		return raw, nil
+	}
+	var r io.Reader
+	switch enc {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("decode gzip response: %w", err)
+		}
+		r = zr
+	case "deflate":
+		// HTTP "deflate" is zlib-wrapped, but some servers send raw DEFLATE
+		if zr, err := zlib.NewReader(bytes.NewReader(raw)); err == nil {
+			r = zr
+		} else {
+			r = flate.NewReader(bytes.NewReader(raw))
+		}
+	default:
+		return raw, nil
+	}
+	decoded, err := readLimited(r)
+	if errors.Is(err, ErrResponseTooLarge) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode %s response: %w", enc, err)
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	return decoded, nil
+}
+
+// gzipBody compresses body when it is at least compressMinBytes long. It reports whether
+// the returned reader is compressed.
+func gzipBody(body io.Reader) (io.Reader, bool, error) {
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(raw) < compressMinBytes {
+		return bytes.NewReader(raw), false, nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return nil, false, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, false, err
+	}
+	return &buf, true, nil
+}
+
+// streamBody is readBody for relayed responses: it decodes gzip or deflate as the body is
+// read instead of buffering it. The returned reader must be closed instead of resp.Body.
+func streamBody(resp *http.Response) (io.ReadCloser, error) {
+	enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if resp.Uncompressed || enc == "" || enc == "identity" {
+		return resp.Body, nil
+	}
+	var r io.Reader
+	switch enc {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("decode gzip response: %w", err)
+		}
+		r = zr
+	case "deflate":
+		// Tell zlib-wrapped from raw DEFLATE by the two-byte zlib header
+		br := bufio.NewReader(resp.Body)
+		if h, err := br.Peek(2); err == nil && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("decode deflate response: %w", err)
+			}
+			r = zr
+		} else {
+			r = flate.NewReader(br)
+		}
+	default:
+		return resp.Body, nil
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, resp.Body}, nil
+}
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"gateway/proxy/internal/config"
+)
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExecuteDecodesCompressedResponses(t *testing.T) {
+	const body = `{"orders":[{"id":"o-1"},{"id":"o-2"}]}`
+	var zlibBuf, rawBuf bytes.Buffer
+	zw := zlib.NewWriter(&zlibBuf)
+	_, _ = zw.Write([]byte(body))
+	_ = zw.Close()
+	fw, _ := flate.NewWriter(&rawBuf, flate.DefaultCompression)
+	_, _ = fw.Write([]byte(body))
+	_ = fw.Close()
+
+	for _, tc := range []struct {
+		name, encoding string
+		encoded        []byte
+	}{
+		{"gzip", "gzip", gzipBytes(t, []byte(body))},
+		{"zlib deflate", "deflate", zlibBuf.Bytes()},
+		{"raw deflate", "deflate", rawBuf.Bytes()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Encoding", tc.encoding)
+				_, _ = w.Write(tc.encoded)
+			})
+			// A mapping that sets Accept-Encoding itself gets the encoded body from net/http
+			tool := testTool("listOrders", "/orders")
+			tool.Mapping.Headers = map[string]string{"Accept-Encoding": tc.encoding}
+			res, err := Execute(context.Background(), http.DefaultClient, srv, tenant, tool, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(res.UpstreamBody) != body {
+				t.Fatalf("got %s", res.UpstreamBody)
+			}
+			if res.UpstreamHeaders.Get("Content-Encoding") != "" {
+				t.Fatal("Content-Encoding kept after decoding")
+			}
+		})
+	}
+}
+
+func TestExecuteTransparentGzip(t *testing.T) {
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			t.Errorf("upstream got Accept-Encoding %q", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(gzipBytes(t, []byte(`{"ok":true}`)))
+	})
+	res, err := Execute(context.Background(), http.DefaultClient, srv, tenant, testTool("ping", "/ping"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.UpstreamBody) != `{"ok":true}` {
+		t.Fatalf("got %s", res.UpstreamBody)
+	}
+}
+
+func TestExecuteCompressesLargeRequestBodies(t *testing.T) {
+	payload := strings.Repeat("line of text\n", 200)
+	var gotEncoding atomic.Value
+	var gotBody atomic.Value
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding.Store(r.Header.Get("Content-Encoding"))
+		var rd io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			rd = zr
+		}
+		b, _ := io.ReadAll(rd)
+		gotBody.Store(string(b))
+		_, _ = w.Write([]byte(`{}`))
+	})
+	tool := testTool("upload", "/upload")
+	tool.Mapping.Method = http.MethodPost
+	tool.Mapping.Body = map[string]interface{}{"text": "{{text}}"}
+	tool.Mapping.CompressRequest = true
+	if _, err := Execute(context.Background(), http.DefaultClient, srv, tenant, tool, map[string]interface{}{"text": payload}); err != nil {
+		t.Fatal(err)
+	}
+	if gotEncoding.Load() != "gzip" {
+		t.Fatalf("Content-Encoding %q", gotEncoding.Load())
+	}
+	if !strings.Contains(gotBody.Load().(string), `line of text\n`) {
+		t.Fatalf("decoded body %q", gotBody.Load())
+	}
+
+	// Small bodies are sent as-is
+	if _, err := Execute(context.Background(), http.DefaultClient, srv, tenant, tool, map[string]interface{}{"text": "short"}); err != nil {
+		t.Fatal(err)
+	}
+	if gotEncoding.Load() != "" {
+		t.Fatalf("small body sent with Content-Encoding %q", gotEncoding.Load())
+	}
+}
+
+func TestExecuteRejectsDecompressionBomb(t *testing.T) {
+	prev := config.MaxUpstreamResponseBytes
+	config.MaxUpstreamResponseBytes = 1 << 20
+	t.Cleanup(func() { config.MaxUpstreamResponseBytes = prev })
+
+	bomb := gzipBytes(t, make([]byte, 16<<20))
+	if len(bomb) > 1<<20 {
+		t.Fatalf("bomb is %d bytes compressed", len(bomb))
+	}
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(bomb)
+	})
+	var secondHits atomic.Int32
+	second, _, _ := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		secondHits.Add(1)
+		_, _ = w.Write([]byte(`{}`))
+	})
+	srv.UpstreamBaseURLs = []string{srv.UpstreamBaseURL, second.URL}
+	tool := testTool("export", "/export")
+	tool.Mapping.Headers = map[string]string{"Accept-Encoding": "gzip"}
+
+	lb := NewBalancer()
+	_, err := ExecuteBalanced(context.Background(), lb, http.DefaultClient, srv, tenant, tool, nil)
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("got %v, want ErrResponseTooLarge", err)
+	}
+	if secondHits.Load() != 0 {
+		t.Fatal("oversized response failed over to the next upstream")
+	}
+
+	// The same limit applies to bodies that are not compressed
+	_, plain, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(make([]byte, 2<<20))
+	})
+	if _, err := Execute(context.Background(), http.DefaultClient, plain, tenant, testTool("export", "/export"), nil); !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("got %v for a plain oversized body", err)
+	}
+}
@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/store"
+)
+
+// UpstreamRPCError is a JSON-RPC error returned by an HTTP upstream for a custom method.
+type UpstreamRPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *UpstreamRPCError) Error() string {
+	return fmt.Sprintf("upstream error %d: %s", e.Code, e.Message)
+}
+
+// Call forwards a custom JSON-RPC method to the server's process and returns the raw result.
+func (b *StdioBridge) Call(ctx context.Context, srv store.Server, method string, params json.RawMessage) (json.RawMessage, error) {
+	p, err := b.process(ctx, srv)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if len(params) > 0 {
+		v = params
+	}
+	return p.call(ctx, method, v)
+}
+
+// CallMethod forwards a custom JSON-RPC method to an HTTP server: the request is POSTed
+// to srv.CustomMethodsPath on each upstream in balancer order, failing over like tool
+// calls, and the upstream's result or error is returned. The id sent upstream is the
+// caller's.
+func CallMethod(ctx context.Context, lb *Balancer, httpClient *http.Client, srv store.Server, tenant store.Tenant, id json.RawMessage, method string, params json.RawMessage) (json.RawMessage, error) {
+	if lb.failFast(srv) {
+		return nil, ErrUpstreamDown
+	}
+	bases := lb.Order(srv)
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("%w: upstream base URL not configured", ErrBadMapping)
+	}
+	msg := map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method}
+	if len(params) > 0 {
+		msg["params"] = params
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	forwarded := claimHeaders(srv.ClaimHeaderMappings, claimsFrom(ctx))
+	var errs []error
+	for _, base := range bases {
+		result, err := callMethodOnce(ctx, httpClient, base, srv.CustomMethodsPath, tenant, body, forwarded)
+		if err == nil {
+			lb.Report(base, true)
+			return result, nil
+		}
+		var rpcErr *UpstreamRPCError
+		if errors.As(err, &rpcErr) {
+			lb.Report(base, true)
+			return nil, err
+		}
+		if errors.Is(err, ErrResponseTooLarge) {
+			lb.Report(base, true)
+			return nil, err
+		}
+		if errors.Is(err, ErrBadMapping) || egressRefused(err) || ctx.Err() != nil {
+			return nil, err
+		}
+		var upstreamErr *UpstreamError
+		if errors.As(err, &upstreamErr) {
+			lb.Report(base, false)
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+func callMethodOnce(ctx context.Context, httpClient *http.Client, baseURL, path string, tenant store.Tenant, body []byte, forwarded map[string]string) (json.RawMessage, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/") + "/" + strings.TrimLeft(path, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: upstream base URL: %v", ErrBadMapping, err)
+	}
+	allowlist := egressAllowlist(tenant)
+	if len(allowlist) == 0 {
+		return nil, fmt.Errorf("%w: no egress allowlist configured for tenant %s (set its egressAllowlist or DEFAULT_EGRESS_ALLOWLIST)", ErrEgressDenied, tenant.Slug)
+	}
+	if !isHostAllowed(u.Hostname(), allowlist) {
+		return nil, fmt.Errorf("%w: %s", ErrEgressDenied, u.Hostname())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadMapping, err)
+	}
+	if config.UpstreamUserAgent != "" {
+		req.Header.Set("User-Agent", config.UpstreamUserAgent)
+	}
+	for k, v := range config.UpstreamDefaultHeaders {
+		req.Header.Set(k, v)
+	}
+	setTraceHeaders(ctx, req)
+	for k, v := range forwarded {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, &UpstreamError{Host: u.Host, Method: req.Method, Path: u.Path, Err: scrubURLError(err, u, u.Path)}
+	}
+	defer resp.Body.Close()
+	respBody, err := readBody(resp)
+	if errors.Is(err, ErrResponseTooLarge) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, &UpstreamError{Host: u.Host, Method: req.Method, Path: u.Path, Status: resp.StatusCode, Err: err}
+	}
+	var out struct {
+		Result json.RawMessage   `json:"result"`
+		Error  *UpstreamRPCError `json:"error"`
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil || (out.Result == nil && out.Error == nil) {
+		res := &ExecuteResult{UpstreamStatus: resp.StatusCode, UpstreamBody: respBody, Host: u.Host, Method: req.Method, Path: u.Path}
+		if statusErr := res.StatusError(); statusErr != nil {
+			return nil, statusErr
+		}
+		return nil, &UpstreamError{Host: u.Host, Method: req.Method, Path: u.Path, Status: resp.StatusCode, Err: errors.New("response is not a JSON-RPC response")}
+	}
+	if out.Error != nil {
+		return nil, out.Error
+	}
+	return out.Result, nil
+}
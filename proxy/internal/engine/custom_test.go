@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCallMethodForwardsRequest(t *testing.T) {
+	var got struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params"`
+	}
+	var path string
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":"abc","result":{"pong":true}}`))
+	})
+	srv.CustomMethodsPath = "/rpc"
+	result, err := CallMethod(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, json.RawMessage(`"abc"`), "custom/ping", json.RawMessage(`{"n":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/rpc" || got.JSONRPC != "2.0" || string(got.ID) != `"abc"` || got.Method != "custom/ping" || string(got.Params) != `{"n":1}` {
+		t.Fatalf("upstream got %s %+v", path, got)
+	}
+	if string(result) != `{"pong":true}` {
+		t.Fatalf("result %s", result)
+	}
+}
+
+func TestCallMethodErrors(t *testing.T) {
+	cases := []struct {
+		name, body string
+		status     int
+		check      func(error) bool
+	}{
+		{"rpc error", `{"jsonrpc":"2.0","id":1,"error":{"code":-32050,"message":"busy","data":{"retry":true}}}`, http.StatusOK, func(err error) bool {
+			var rpcErr *UpstreamRPCError
+			return errors.As(err, &rpcErr) && rpcErr.Code == -32050 && rpcErr.Message == "busy" && string(rpcErr.Data) == `{"retry":true}`
+		}},
+		{"not json-rpc", `{"pong":true}`, http.StatusOK, func(err error) bool {
+			var upstreamErr *UpstreamError
+			return errors.As(err, &upstreamErr)
+		}},
+		{"server error", `oops`, http.StatusBadGateway, func(err error) bool { return err != nil }},
+	}
+	for _, tc := range cases {
+		_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+			_, _ = w.Write([]byte(tc.body))
+		})
+		if _, err := CallMethod(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, json.RawMessage(`1`), "custom/ping", nil); !tc.check(err) {
+			t.Errorf("%s: err = %v", tc.name, err)
+		}
+	}
+
+	// The tenant's egress allowlist applies as for tool calls
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {})
+	tenant.EgressAllowlist = []string{"api.example.com"}
+	if _, err := CallMethod(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, json.RawMessage(`1`), "custom/ping", nil); !errors.Is(err, ErrEgressDenied) {
+		t.Fatalf("err = %v, want ErrEgressDenied", err)
+	}
+}
+
+func TestStdioCallCustomMethod(t *testing.T) {
+	b, srv := stdioServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	raw, err := b.Call(ctx, srv, "custom/echo", json.RawMessage(`{"arguments":{"n":1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res echoResult
+	if err := json.Unmarshal(raw, &res); err != nil || res.Args["n"] != float64(1) {
+		t.Fatalf("result %s, err %v", raw, err)
+	}
+}
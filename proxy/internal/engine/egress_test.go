@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"gateway/proxy/internal/config"
+)
+
+func TestIsHostAllowed(t *testing.T) {
+	allowlist := []string{"api.example.com", "*.internal.example.com", "10.0.0.0/8", "fd00::/8"}
+	cases := []struct {
+		host string
+		want bool
+	}{
+		{"api.example.com", true},
+		{"API.Example.com", true},
+		{"orders.internal.example.com", true},
+		{"a.b.Internal.Example.com", true},
+		{"internal.example.com", false},
+		{"evilinternal.example.com", false},
+		{"10.1.2.3", true},
+		{"11.1.2.3", false},
+		{"fd00::1", true},
+		{"::ffff:10.0.0.1", true},
+		{"other.example.com", false},
+		// Hostnames are not resolved for CIDR entries
+		{"ten.example.net", false},
+	}
+	for _, tc := range cases {
+		if got := isHostAllowed(tc.host, allowlist); got != tc.want {
+			t.Errorf("isHostAllowed(%q) = %v, want %v", tc.host, got, tc.want)
+		}
+	}
+}
+
+func TestEgressAllowlistCIDR(t *testing.T) {
+	var hits atomic.Int32
+	ts, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_, _ = w.Write([]byte(`{}`))
+	})
+	tenant.EgressAllowlist = []string{"127.0.0.0/8"}
+	res, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, testTool("t", "/x"), nil)
+	if err != nil || res.UpstreamStatus != http.StatusOK {
+		t.Fatalf("CIDR match: res %+v, err %v", res, err)
+	}
+
+	// A hostname is checked by name, not by the address it resolves to
+	u, _ := url.Parse(ts.URL)
+	srv.UpstreamBaseURL = "http://localhost:" + u.Port()
+	if _, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, testTool("t", "/x"), nil); !errors.Is(err, ErrEgressDenied) {
+		t.Fatalf("err = %v, want ErrEgressDenied", err)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Fatalf("upstream reached %d times, want 1", n)
+	}
+}
+
+func withDefaultEgress(t *testing.T, allowlist ...string) {
+	t.Helper()
+	prev := config.DefaultEgressAllowlist
+	t.Cleanup(func() { config.DefaultEgressAllowlist = prev })
+	config.DefaultEgressAllowlist = allowlist
+}
+
+func TestDefaultEgressAllowlist(t *testing.T) {
+	withDefaultEgress(t, "127.0.0.1")
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(`{}`)) })
+	tenant.EgressAllowlist = nil
+	res, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, testTool("t", "/x"), nil)
+	if err != nil || res.UpstreamStatus != http.StatusOK {
+		t.Fatalf("default allowlist: res %+v, err %v", res, err)
+	}
+
+	// A tenant's own list replaces the default rather than adding to it
+	tenant.EgressAllowlist = []string{"api.example.com"}
+	if _, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, testTool("t", "/x"), nil); !errors.Is(err, ErrEgressDenied) {
+		t.Fatalf("err = %v, want ErrEgressDenied", err)
+	}
+}
+
+func TestEgressDeniedMessages(t *testing.T) {
+	withDefaultEgress(t)
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	tenant.EgressAllowlist = nil
+	_, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, testTool("t", "/x"), nil)
+	if !errors.Is(err, ErrEgressDenied) || !strings.Contains(err.Error(), "no egress allowlist configured for tenant acme") {
+		t.Fatalf("empty allowlist: err = %v", err)
+	}
+
+	tenant.EgressAllowlist = []string{"api.example.com"}
+	_, err = ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, testTool("t", "/x"), nil)
+	if !errors.Is(err, ErrEgressDenied) || strings.Contains(err.Error(), "no egress allowlist") || !strings.Contains(err.Error(), "127.0.0.1") {
+		t.Fatalf("host not allowed: err = %v", err)
+	}
+}
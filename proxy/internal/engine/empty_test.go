@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gateway/proxy/internal/config"
+)
+
+func withEmptyResponse(t *testing.T, mode string) {
+	t.Helper()
+	prev := config.EmptyResponse
+	t.Cleanup(func() { config.EmptyResponse = prev })
+	config.EmptyResponse = mode
+}
+
+func TestEmptyUpstreamResponses(t *testing.T) {
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/no-content":
+			w.WriteHeader(http.StatusNoContent)
+		case "/blank":
+			_, _ = w.Write([]byte(" \n"))
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+		}
+	})
+	cases := []struct {
+		mode, path string
+		status     int
+		want       string
+	}{
+		{config.EmptyResponseNull, "/no-content", http.StatusNoContent, `null`},
+		{config.EmptyResponseNull, "/empty", http.StatusOK, `null`},
+		{config.EmptyResponseOK, "/no-content", http.StatusNoContent, `{"ok":true,"status":204}`},
+		{config.EmptyResponseOK, "/blank", http.StatusOK, `{"ok":true,"status":200}`},
+		{config.EmptyResponseOK, "/created", http.StatusCreated, `{"ok":true,"status":201}`},
+		{config.EmptyResponseText, "/no-content", http.StatusNoContent, `{"text":""}`},
+		{config.EmptyResponseText, "/empty", http.StatusOK, `{"text":""}`},
+	}
+	for _, tc := range cases {
+		withEmptyResponse(t, tc.mode)
+		res, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, testTool("t", tc.path), nil)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tc.mode, tc.path, err)
+		}
+		if res.UpstreamStatus != tc.status || string(res.UpstreamBody) != tc.want {
+			t.Errorf("%s %s: %d %s, want %d %s", tc.mode, tc.path, res.UpstreamStatus, res.UpstreamBody, tc.status, tc.want)
+		}
+	}
+}
@@ -7,10 +7,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/netip"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 
+	"gateway/proxy/internal/config"
 	"gateway/proxy/internal/store"
 )
 
@@ -18,28 +23,308 @@ type ExecuteResult struct {
 	UpstreamStatus  int
 	UpstreamBody    json.RawMessage
 	UpstreamHeaders http.Header
+	// Attempted request, kept for diagnostics (no headers or query to avoid leaking secrets)
+	Host   string
+	Method string
+	Path   string
 }
 
+// Kinds of Execute failure, for callers to tell apart with errors.Is.
+var (
+	// ErrEgressDenied: the upstream host, or a redirect target, is not on the tenant egress allowlist.
+	ErrEgressDenied = errors.New("egress host not allowed")
+	// ErrUpstreamUnavailable: no upstream produced a response (connection or read failure,
+	// timeout, or a server failing its health checks). Every UpstreamError matches it.
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+	// ErrBadMapping: the tool mapping or server configuration cannot produce an upstream
+	// request, so retrying or failing over cannot help.
+	ErrBadMapping = errors.New("invalid tool mapping")
+	// ErrRedirectDenied: the redirect policy refused to follow a redirect. Redirects to
+	// hosts off the egress allowlist also match ErrEgressDenied.
+	ErrRedirectDenied = errors.New("redirect not followed")
+)
+
+// maxErrorBodyBytes bounds the upstream body snippet attached to errors.
+const maxErrorBodyBytes = 512
+
+// UpstreamError describes a failed upstream call. It is safe to return to clients as
+// JSON-RPC error data: request headers and query strings are never included.
+type UpstreamError struct {
+	Host   string `json:"host"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Status int    `json:"status,omitempty"`
+	Body   string `json:"body,omitempty"`
+	Err    error  `json:"-"`
+}
+
+func (e *UpstreamError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("upstream %s %s%s: %v", e.Method, e.Host, e.Path, e.Err)
+	}
+	return fmt.Sprintf("upstream %s %s%s: status %d", e.Method, e.Host, e.Path, e.Status)
+}
+
+func (e *UpstreamError) Unwrap() error { return e.Err }
+
+func (e *UpstreamError) Is(target error) bool { return target == ErrUpstreamUnavailable }
+
+// StatusError returns an UpstreamError when the upstream answered with a 5xx, else nil.
+func (r *ExecuteResult) StatusError() *UpstreamError {
+	if r.UpstreamStatus < 500 {
+		return nil
+	}
+	body := string(r.UpstreamBody)
+	if len(body) > maxErrorBodyBytes {
+		body = body[:maxErrorBodyBytes] + "..."
+	}
+	return &UpstreamError{Host: r.Host, Method: r.Method, Path: r.Path, Status: r.UpstreamStatus, Body: body}
+}
+
+// deniedResponseHeaders carry credentials and are never passed through, even if allowlisted.
+var deniedResponseHeaders = map[string]bool{
+	"Set-Cookie":          true,
+	"Set-Cookie2":         true,
+	"Cookie":              true,
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Proxy-Authenticate":  true,
+	"Www-Authenticate":    true,
+}
+
+// ResponseHeaders returns the upstream response headers that srv and tool pass through to
+// clients, keyed by canonical name. Repeated values are joined with ", ". Nil when none.
+func ResponseHeaders(srv store.Server, tool store.Tool, h http.Header) map[string]string {
+	var out map[string]string
+	for _, names := range [][]string{srv.ResponseHeaders, tool.Mapping.ResponseHeaders} {
+		for _, name := range names {
+			key := http.CanonicalHeaderKey(name)
+			if deniedResponseHeaders[key] || len(h.Values(key)) == 0 {
+				continue
+			}
+			if out == nil {
+				out = map[string]string{}
+			}
+			out[key] = strings.Join(h.Values(key), ", ")
+		}
+	}
+	return out
+}
+
+// Execute calls the tool's upstream. Failures match ErrEgressDenied, ErrUpstreamUnavailable
+// or ErrBadMapping where one applies; GraphQL errors are a *GraphQLError.
 func Execute(ctx context.Context, httpClient *http.Client, srv store.Server, tenant store.Tenant, tool store.Tool, args map[string]interface{}) (*ExecuteResult, error) {
-	if srv.UpstreamBaseURL == "" {
-		return nil, errors.New("upstream base URL not configured")
+	return ExecuteBalanced(ctx, nil, httpClient, srv, tenant, tool, args)
+}
+
+// ExecuteBalanced is Execute with upstream selection and passive health tracking delegated
+// to lb. A nil balancer tries upstreams strictly in configured order.
+func ExecuteBalanced(ctx context.Context, lb *Balancer, httpClient *http.Client, srv store.Server, tenant store.Tenant, tool store.Tool, args map[string]interface{}) (*ExecuteResult, error) {
+	if lb.failFast(srv) {
+		return nil, ErrUpstreamDown
 	}
-	// Egress allowlist
-	u, err := url.Parse(srv.UpstreamBaseURL)
+	bases := lb.Order(srv)
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("%w: upstream base URL not configured", ErrBadMapping)
+	}
+	args, err := applyTransforms(tool.Mapping.Transforms, args)
+	if err != nil {
+		return nil, &encodeError{err: err}
+	}
+	httpClient = withRedirectPolicy(httpClient, firstNonEmpty(tool.Mapping.RedirectPolicy, srv.RedirectPolicy), tenant)
+	// Try upstreams in order, failing over on egress denial, connection errors and 5xx; an
+	// SSRF guard refusal or a refused redirect ends the call. The last 5xx result is returned as-is; if none
+	// answered, the errors are joined.
+	forwarded := claimHeaders(srv.ClaimHeaderMappings, claimsFrom(ctx))
+	var errs []error
+	var lastRes *ExecuteResult
+	for _, base := range bases {
+		res, err := executeOnce(ctx, httpClient, base, tenant, tool, args, forwarded)
+		if err != nil {
+			var enc *encodeError
+			if errors.As(err, &enc) || egressRefused(err) || ctx.Err() != nil {
+				return nil, err
+			}
+			// The upstream answered; GraphQL errors and oversized bodies are not a reason to fail over
+			if errors.Is(err, ErrResponseTooLarge) {
+				lb.Report(base, true)
+				return nil, err
+			}
+			var gqlErr *GraphQLError
+			if errors.As(err, &gqlErr) {
+				lb.Report(base, true)
+				return nil, err
+			}
+			var upstreamErr *UpstreamError
+			if errors.As(err, &upstreamErr) {
+				lb.Report(base, false)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if res.UpstreamStatus >= 500 {
+			lb.Report(base, false)
+			lastRes = res
+			continue
+		}
+		lb.Report(base, true)
+		return res, nil
+	}
+	if lastRes != nil {
+		return lastRes, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Redirect policies for upstream calls.
+const (
+	RedirectFollow   = "follow"
+	RedirectSameHost = "follow-same-host"
+	RedirectNone     = "none"
+)
+
+// maxRedirects matches net/http's default limit.
+const maxRedirects = 10
+
+// withRedirectPolicy returns a copy of c whose CheckRedirect enforces policy. "none" hands
+// the 3xx back as the result. Every other policy, including "follow" and the default, follows
+// only redirects to the original host or to hosts on the tenant egress allowlist, so a
+// redirect cannot reach a host the call itself could not.
+func withRedirectPolicy(c *http.Client, policy string, tenant store.Tenant) *http.Client {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	cc := *c
+	switch policy {
+	case RedirectNone:
+		cc.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	default:
+		cc.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return &redirectError{msg: fmt.Sprintf("stopped after %d redirects", maxRedirects)}
+			}
+			if strings.EqualFold(req.URL.Host, via[0].URL.Host) || isHostAllowed(req.URL.Hostname(), egressAllowlist(tenant)) {
+				return nil
+			}
+			return &redirectError{msg: "redirect to " + req.URL.Hostname() + " blocked", egress: true}
+		}
+	}
+	return &cc
+}
+
+// redirectError is a redirect the redirect policy refused to follow. The upstream answered,
+// so it is neither an upstream failure nor a reason to try another upstream.
+type redirectError struct {
+	msg    string
+	egress bool
+}
+
+func (e *redirectError) Error() string { return e.msg }
+
+func (e *redirectError) Is(target error) bool {
+	return target == ErrRedirectDenied || e.egress && target == ErrEgressDenied
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// encodeError marks failures that are independent of the upstream, so failover is pointless.
+type encodeError struct{ err error }
+
+func (e *encodeError) Error() string { return e.err.Error() }
+func (e *encodeError) Unwrap() error { return e.err }
+
+func (e *encodeError) Is(target error) bool { return target == ErrBadMapping }
+
+func executeOnce(ctx context.Context, httpClient *http.Client, baseURL string, tenant store.Tenant, tool store.Tool, args map[string]interface{}, forwarded map[string]string) (*ExecuteResult, error) {
+	req, err := newUpstreamRequest(ctx, baseURL, tenant, tool, args, forwarded)
+	if err != nil {
+		return nil, err
+	}
+	reqURL := req.URL
+	path := diagnosticPath(reqURL.Path, tool, args)
+	resp, err := httpClient.Do(req)
 	if err != nil {
+		return nil, &UpstreamError{Host: reqURL.Host, Method: req.Method, Path: path, Err: scrubURLError(err, reqURL, path)}
+	}
+	defer resp.Body.Close()
+	if fn := progressFrom(ctx); fn != nil {
+		resp.Body = &progressReader{ReadCloser: resp.Body, total: resp.ContentLength, fn: fn}
+	}
+	respBody, err := readBody(resp)
+	if errors.Is(err, ErrResponseTooLarge) {
 		return nil, err
 	}
-	if !isHostAllowed(u.Hostname(), tenant.EgressAllowlist) {
-		return nil, fmt.Errorf("egress host not allowed: %s", u.Hostname())
+	if err != nil {
+		return nil, &UpstreamError{Host: reqURL.Host, Method: req.Method, Path: path, Status: resp.StatusCode, Err: err}
+	}
+
+	if tool.Mapping.Type == MappingTypeGraphQL && resp.StatusCode < 300 {
+		data, err := graphqlResult(respBody)
+		if err != nil {
+			return nil, err
+		}
+		data = applyResponseMapping(tool.Mapping.ResponseMapping, data)
+		return &ExecuteResult{UpstreamStatus: resp.StatusCode, UpstreamBody: data, UpstreamHeaders: resp.Header, Host: reqURL.Host, Method: req.Method, Path: path}, nil
+	}
+
+	// Try to keep as JSON; if not JSON, wrap as string
+	var raw json.RawMessage
+	if resp.StatusCode == http.StatusNoContent || len(bytes.TrimSpace(respBody)) == 0 {
+		raw = EmptyBody(resp.StatusCode)
+	} else if json.Valid(respBody) {
+		raw = json.RawMessage(respBody)
+		if resp.StatusCode < 300 {
+			raw = applyResponseMapping(tool.Mapping.ResponseMapping, raw)
+		}
+	} else {
+		// wrap into {"text": "..."}
+		wrapped, _ := json.Marshal(map[string]string{"text": string(respBody)})
+		raw = json.RawMessage(wrapped)
+	}
+	return &ExecuteResult{UpstreamStatus: resp.StatusCode, UpstreamBody: raw, UpstreamHeaders: resp.Header, Host: reqURL.Host, Method: req.Method, Path: path}, nil
+}
+
+// EmptyBody is the result data for an upstream response without a body (e.g. 204), per
+// config.EmptyResponse.
+func EmptyBody(status int) json.RawMessage {
+	switch config.EmptyResponse {
+	case config.EmptyResponseOK:
+		return json.RawMessage(`{"ok":true,"status":` + strconv.Itoa(status) + `}`)
+	case config.EmptyResponseText:
+		return json.RawMessage(`{"text":""}`)
+	}
+	return json.RawMessage("null")
+}
+
+// newUpstreamRequest checks egress and builds the upstream request for one base URL.
+func newUpstreamRequest(ctx context.Context, baseURL string, tenant store.Tenant, tool store.Tool, args map[string]interface{}, forwarded map[string]string) (*http.Request, error) {
+	// Egress allowlist
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: upstream base URL: %v", ErrBadMapping, err)
+	}
+	allowlist := egressAllowlist(tenant)
+	if len(allowlist) == 0 {
+		return nil, fmt.Errorf("%w: no egress allowlist configured for tenant %s (set its egressAllowlist or DEFAULT_EGRESS_ALLOWLIST)", ErrEgressDenied, tenant.Slug)
+	}
+	if !isHostAllowed(u.Hostname(), allowlist) {
+		return nil, fmt.Errorf("%w: %s", ErrEgressDenied, u.Hostname())
 	}
 
 	// Build request URL
 	path := substitute(tool.Mapping.Path, args)
-	base := strings.TrimRight(srv.UpstreamBaseURL, "/")
+	base := strings.TrimRight(baseURL, "/")
 	full := base + path
 	reqURL, err := url.Parse(full)
 	if err != nil {
-		return nil, err
+		return nil, &encodeError{err: err}
 	}
 	q := reqURL.Query()
 	for k, v := range tool.Mapping.Query {
@@ -49,18 +334,40 @@ func Execute(ctx context.Context, httpClient *http.Client, srv store.Server, ten
 
 	// Body
 	var body io.Reader
-	if tool.Mapping.Body != nil {
+	var contentType string
+	method := tool.Mapping.Method
+	if tool.Mapping.Type == MappingTypeGraphQL {
+		method = http.MethodPost
+		contentType = "application/json"
+		if body, err = graphqlBody(tool, args); err != nil {
+			return nil, &encodeError{err: err}
+		}
+	} else if tool.Mapping.Body != nil {
 		// simple arg substitution for string fields inside body
 		resolved := resolveBody(tool.Mapping.Body, args)
-		buf, _ := json.Marshal(resolved)
-		body = bytes.NewReader(buf)
+		body, contentType, err = encodeBody(resolved, tool.Mapping.BodyEncoding)
+		if err != nil {
+			return nil, &encodeError{err: err}
+		}
+	}
+	compressed := false
+	if body != nil && tool.Mapping.CompressRequest {
+		if body, compressed, err = gzipBody(body); err != nil {
+			return nil, &encodeError{err: err}
+		}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(tool.Mapping.Method), reqURL.String(), body)
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), reqURL.String(), body)
 	if err != nil {
-		return nil, err
+		return nil, &encodeError{err: err}
+	}
+	// Headers: gateway defaults first so the tool's own headers override them
+	if config.UpstreamUserAgent != "" {
+		req.Header.Set("User-Agent", config.UpstreamUserAgent)
+	}
+	for k, v := range config.UpstreamDefaultHeaders {
+		req.Header.Set(k, v)
 	}
-	// Headers
 	hasContentType := false
 	for k, v := range tool.Mapping.Headers {
 		sv := substitute(v, args)
@@ -69,53 +376,154 @@ func Execute(ctx context.Context, httpClient *http.Client, srv store.Server, ten
 			hasContentType = true
 		}
 	}
-	if body != nil && !hasContentType {
-		req.Header.Set("Content-Type", "application/json")
+	setTraceHeaders(ctx, req)
+	if key := idempotencyKeyFrom(ctx); key != "" {
+		req.Header.Set(IdempotencyHeader, key)
+	}
+	// Identity headers come last so neither mappings nor arguments can spoof them
+	for k, v := range forwarded {
+		req.Header.Set(k, v)
+	}
+	// multipart needs its generated boundary, so it always wins over a mapped header
+	if body != nil && (!hasContentType || tool.Mapping.BodyEncoding == "multipart") {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
 	}
 
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, err
+	return req, nil
+}
+
+// encodeBody serializes a resolved body per the mapping's encoding and returns the matching content type.
+func encodeBody(resolved map[string]interface{}, encoding string) (io.Reader, string, error) {
+	switch encoding {
+	case "", "json":
+		buf, _ := json.Marshal(resolved)
+		return bytes.NewReader(buf), "application/json", nil
+	case "form":
+		values := url.Values{}
+		for k, v := range resolved {
+			values.Set(k, formValue(v))
+		}
+		return strings.NewReader(values.Encode()), "application/x-www-form-urlencoded", nil
+	case "multipart":
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		keys := make([]string, 0, len(resolved))
+		for k := range resolved {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := mw.WriteField(k, formValue(resolved[k])); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return nil, "", err
+		}
+		return &buf, mw.FormDataContentType(), nil
+	default:
+		return nil, "", fmt.Errorf("unsupported body encoding: %s", encoding)
 	}
-	defer resp.Body.Close()
-	respBody, _ := io.ReadAll(resp.Body)
+}
 
-	// Try to keep as JSON; if not JSON, wrap as string
-	var raw json.RawMessage
-	if json.Valid(respBody) {
-		raw = json.RawMessage(respBody)
-	} else {
-		// wrap into {"text": "..."}
-		wrapped, _ := json.Marshal(map[string]string{"text": string(respBody)})
-		raw = json.RawMessage(wrapped)
+// formValue flattens a body value into a form field; nested objects are sent as JSON.
+func formValue(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(t)
+		return string(b)
+	default:
+		return fmt.Sprintf("%v", t)
+	}
+}
+
+// egressAllowlist returns the tenant's allowlist, or the global default when it is empty.
+func egressAllowlist(tenant store.Tenant) []string {
+	if len(tenant.EgressAllowlist) > 0 {
+		return tenant.EgressAllowlist
 	}
-	return &ExecuteResult{UpstreamStatus: resp.StatusCode, UpstreamBody: raw, UpstreamHeaders: resp.Header}, nil
+	return config.DefaultEgressAllowlist
 }
 
+// isHostAllowed matches host against the tenant egress allowlist. Entries are exact hosts
+// (case-insensitive), wildcard subdomains such as "*.internal.example.com" (which do not
+// match the apex itself), or CIDR ranges such as "10.0.0.0/8" that match IP-literal hosts.
 func isHostAllowed(host string, allowlist []string) bool {
+	ip, ipErr := netip.ParseAddr(host)
 	for _, a := range allowlist {
-		if strings.EqualFold(host, a) {
+		switch {
+		case strings.Contains(a, "/"):
+			if prefix, err := netip.ParsePrefix(a); err == nil && ipErr == nil && prefix.Contains(ip.Unmap()) {
+				return true
+			}
+		case strings.HasPrefix(a, "*."):
+			if len(host) > len(a)-1 && strings.EqualFold(host[len(host)-(len(a)-1):], a[1:]) {
+				return true
+			}
+		case strings.EqualFold(host, a):
 			return true
 		}
 	}
 	return false
 }
 
+// substitute replaces each {{name}} in template with the argument of exactly that name,
+// in one left-to-right pass: overlapping names (id, orderId) cannot clash and values that
+// themselves contain {{...}} are not expanded again. Placeholders without an argument
+// stay as written.
 func substitute(template string, args map[string]interface{}) string {
-	out := template
+	if !strings.Contains(template, "{{") {
+		return template
+	}
+	return placeholderPattern.ReplaceAllStringFunc(template, func(m string) string {
+		v, ok := args[m[2:len(m)-2]]
+		if !ok {
+			return m
+		}
+		return fmt.Sprintf("%v", v)
+	})
+}
+
+// scrubURLError rewrites the URL that net/http embeds in transport errors to the
+// diagnostic form: no query string and sensitive path values redacted.
+func scrubURLError(err error, reqURL *url.URL, path string) error {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		ue.URL = reqURL.Scheme + "://" + reqURL.Host + path
+	}
+	return err
+}
+
+// diagnosticPath is the request path as reported in errors and logs: values of sensitive
+// arguments substituted into it are replaced by store.Redacted.
+func diagnosticPath(path string, tool store.Tool, args map[string]interface{}) string {
 	for k, v := range args {
-		placeholder := "{{" + k + "}}"
-		val := fmt.Sprintf("%v", v)
-		out = strings.ReplaceAll(out, placeholder, val)
+		if sv := fmt.Sprintf("%v", v); sv != "" && tool.IsSensitiveArg(k) {
+			path = strings.ReplaceAll(path, sv, store.Redacted)
+		}
 	}
-	return out
+	return path
 }
 
+// resolveBody fills placeholders in body values. A value that is exactly one placeholder
+// takes the argument as-is, so numbers and booleans keep their JSON type; placeholders
+// embedded in longer strings are stringified.
 func resolveBody(body map[string]interface{}, args map[string]interface{}) map[string]interface{} {
 	resolved := make(map[string]interface{}, len(body))
 	for k, v := range body {
 		switch t := v.(type) {
 		case string:
+			if m := placeholderPattern.FindStringSubmatch(t); m != nil && m[0] == t {
+				if arg, ok := args[m[1]]; ok {
+					resolved[k] = arg
+					continue
+				}
+			}
 			resolved[k] = substitute(t, args)
 		case map[string]interface{}:
 			resolved[k] = resolveBody(t, args)
@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+func TestExecuteErrorKinds(t *testing.T) {
+	ts, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {})
+	u, _ := url.Parse(ts.URL)
+	graphqlNoQuery := store.Tool{Name: "q", Mapping: store.RequestTemplate{Type: MappingTypeGraphQL, Path: "/graphql"}}
+
+	cases := []struct {
+		name    string
+		base    string
+		tool    store.Tool
+		want    error
+		notWant error
+	}{
+		{"no upstream", "", testTool("t", "/x"), ErrBadMapping, ErrUpstreamUnavailable},
+		{"unparsable upstream", "http://[::1", testTool("t", "/x"), ErrBadMapping, ErrUpstreamUnavailable},
+		{"body cannot be built", ts.URL, graphqlNoQuery, ErrBadMapping, ErrUpstreamUnavailable},
+		{"host off the allowlist", "http://localhost:" + u.Port(), testTool("t", "/x"), ErrEgressDenied, ErrUpstreamUnavailable},
+		{"connection refused", downURL(t), testTool("t", "/x"), ErrUpstreamUnavailable, ErrBadMapping},
+	}
+	for _, tc := range cases {
+		srv.UpstreamBaseURL = tc.base
+		_, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, tc.tool, nil)
+		if !errors.Is(err, tc.want) || errors.Is(err, tc.notWant) {
+			t.Errorf("%s: err = %v, want %v and not %v", tc.name, err, tc.want, tc.notWant)
+		}
+	}
+
+	// Unavailability carries the diagnostic details
+	srv.UpstreamBaseURL = downURL(t)
+	_, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, testTool("t", "/x"), nil)
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) || upstreamErr.Method != http.MethodGet || upstreamErr.Path != "/x" {
+		t.Fatalf("err = %#v, want an *UpstreamError for GET /x", err)
+	}
+}
@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// downURL is the address of an upstream that has gone away.
+func downURL(t *testing.T) string {
+	t.Helper()
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
+	return ts.URL
+}
+
+func statusUpstream(t *testing.T, status int, body string) string {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+func TestFailoverToSecondUpstream(t *testing.T) {
+	healthy := statusUpstream(t, http.StatusOK, `{"from":"second"}`)
+	cases := map[string]string{
+		"connection refused": downURL(t),
+		"5xx":                statusUpstream(t, http.StatusServiceUnavailable, `{"from":"first"}`),
+		// localhost is not on the tenant allowlist, which only admits 127.0.0.1
+		"egress denied": strings.Replace(statusUpstream(t, http.StatusOK, `{"from":"first"}`), "127.0.0.1", "localhost", 1),
+	}
+	for name, first := range cases {
+		srv, tenant := testTarget("")
+		srv.UpstreamBaseURLs = []string{first, healthy}
+		res, err := Execute(context.Background(), http.DefaultClient, srv, tenant, testTool("t", "/orders"), nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if res.UpstreamStatus != http.StatusOK || string(res.UpstreamBody) != `{"from":"second"}` {
+			t.Fatalf("%s: got %d %s", name, res.UpstreamStatus, res.UpstreamBody)
+		}
+	}
+}
+
+func TestFailoverAllUpstreamsDown(t *testing.T) {
+	first, second := downURL(t), downURL(t)
+	srv, tenant := testTarget("")
+	srv.UpstreamBaseURLs = []string{first, second}
+	_, err := Execute(context.Background(), http.DefaultClient, srv, tenant, testTool("t", "/orders"), nil)
+	if !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Fatalf("err = %v, want ErrUpstreamUnavailable", err)
+	}
+	for _, base := range []string{first, second} {
+		if !strings.Contains(err.Error(), strings.TrimPrefix(base, "http://")) {
+			t.Errorf("error %q does not mention %s", err, base)
+		}
+	}
+}
+
+func TestFailoverAllUpstreams5xxReturnsLast(t *testing.T) {
+	srv, tenant := testTarget("")
+	srv.UpstreamBaseURLs = []string{statusUpstream(t, http.StatusBadGateway, `{"from":"first"}`), statusUpstream(t, http.StatusServiceUnavailable, `{"from":"second"}`)}
+	res, err := Execute(context.Background(), http.DefaultClient, srv, tenant, testTool("t", "/orders"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.UpstreamStatus != http.StatusServiceUnavailable || string(res.UpstreamBody) != `{"from":"second"}` {
+		t.Fatalf("got %d %s, want the second upstream's 503", res.UpstreamStatus, res.UpstreamBody)
+	}
+}
+
+func TestNoFailoverOn4xx(t *testing.T) {
+	var secondHits int
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { secondHits++ }))
+	t.Cleanup(second.Close)
+	srv, tenant := testTarget("")
+	srv.UpstreamBaseURLs = []string{statusUpstream(t, http.StatusNotFound, `{}`), second.URL}
+	res, err := Execute(context.Background(), http.DefaultClient, srv, tenant, testTool("t", "/orders"), nil)
+	if err != nil || res.UpstreamStatus != http.StatusNotFound || secondHits != 0 {
+		t.Fatalf("got %v, err %v, second hits %d", res, err, secondHits)
+	}
+}
@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/store"
+)
+
+// ErrDocumentTooLarge is returned by FetchDocument when the body exceeds its size limit.
+var ErrDocumentTooLarge = errors.New("document too large")
+
+// FetchDocument GETs an http(s) URL on behalf of tenant, e.g. an OpenAPI spec. The URL and
+// every redirect target must pass the tenant egress allowlist, so the control plane cannot
+// be used to reach hosts tool calls could not. Bodies over maxBytes fail.
+func FetchDocument(ctx context.Context, client *http.Client, tenant store.Tenant, rawURL string, maxBytes int64) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("url must be an absolute http or https URL")
+	}
+	allowlist := egressAllowlist(tenant)
+	if !isHostAllowed(u.Hostname(), allowlist) {
+		return nil, fmt.Errorf("%w: %s", ErrEgressDenied, u.Hostname())
+	}
+	cc := *client
+	cc.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return &redirectError{msg: fmt.Sprintf("stopped after %d redirects", maxRedirects)}
+		}
+		if !isHostAllowed(req.URL.Hostname(), allowlist) {
+			return &redirectError{msg: "redirect to " + req.URL.Hostname() + " blocked", egress: true}
+		}
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json, application/yaml;q=0.9, */*;q=0.5")
+	if config.UpstreamUserAgent != "" {
+		req.Header.Set("User-Agent", config.UpstreamUserAgent)
+	}
+	resp, err := cc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: status %d", u.Host, resp.StatusCode)
+	}
+	if resp.ContentLength > maxBytes {
+		return nil, ErrDocumentTooLarge
+	}
+	// Limit the decoded size so a small compressed body cannot expand without bound
+	decoded, err := streamBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	defer decoded.Close()
+	body, err := io.ReadAll(io.LimitReader(decoded, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > maxBytes {
+		return nil, ErrDocumentTooLarge
+	}
+	return body, nil
+}
@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFetchDocumentSizeLimit(t *testing.T) {
+	ts, _, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			// No Content-Length, so only the read limit catches it
+			w.(http.Flusher).Flush()
+		}
+		_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+	})
+	for _, path := range []string{"/sized", "/chunked"} {
+		if _, err := FetchDocument(context.Background(), http.DefaultClient, tenant, ts.URL+path, 99); !errors.Is(err, ErrDocumentTooLarge) {
+			t.Errorf("%s: err = %v, want ErrDocumentTooLarge", path, err)
+		}
+		body, err := FetchDocument(context.Background(), http.DefaultClient, tenant, ts.URL+path, 100)
+		if err != nil || len(body) != 100 {
+			t.Errorf("%s at the limit: %d bytes, err %v", path, len(body), err)
+		}
+	}
+}
+
+func TestFetchDocumentEgress(t *testing.T) {
+	ts, _, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+	})
+	if _, err := FetchDocument(context.Background(), http.DefaultClient, tenant, ts.URL+"/spec", 1<<20); !errors.Is(err, ErrEgressDenied) {
+		t.Fatalf("redirect: err = %v, want ErrEgressDenied", err)
+	}
+	tenant.EgressAllowlist = []string{"api.example.com"}
+	if _, err := FetchDocument(context.Background(), http.DefaultClient, tenant, ts.URL+"/spec", 1<<20); !errors.Is(err, ErrEgressDenied) {
+		t.Fatalf("host: err = %v, want ErrEgressDenied", err)
+	}
+	if _, err := FetchDocument(context.Background(), http.DefaultClient, tenant, "ftp://api.example.com/spec", 1<<20); err == nil {
+		t.Fatal("expected an error for a non-http URL")
+	}
+}
@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gateway/proxy/internal/store"
+)
+
+// MappingTypeGraphQL marks tools whose mapping posts a GraphQL document instead of a REST request.
+const MappingTypeGraphQL = "graphql"
+
+// GraphQLError carries the errors array of a GraphQL response. It is safe to return to
+// clients as JSON-RPC error data.
+type GraphQLError struct {
+	Errors []json.RawMessage `json:"errors"`
+}
+
+func (e *GraphQLError) Error() string {
+	return fmt.Sprintf("graphql: %d error(s)", len(e.Errors))
+}
+
+// graphqlBody builds the {query, variables} request; tool arguments become the variables
+// unchanged, so their JSON types survive (unlike REST templating).
+func graphqlBody(tool store.Tool, args map[string]interface{}) (io.Reader, error) {
+	if tool.Mapping.GraphQLQuery == "" {
+		return nil, fmt.Errorf("graphql tool %s has no query", tool.Name)
+	}
+	variables := args
+	if variables == nil {
+		variables = map[string]interface{}{}
+	}
+	b, err := json.Marshal(map[string]interface{}{"query": tool.Mapping.GraphQLQuery, "variables": variables})
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+// graphqlResult extracts data from a GraphQL response, or a GraphQLError when the
+// response lists errors. Bodies that are not a GraphQL envelope are returned unchanged.
+func graphqlResult(body []byte) (json.RawMessage, error) {
+	var envelope struct {
+		Data   json.RawMessage   `json:"data"`
+		Errors []json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return body, nil
+	}
+	if len(envelope.Errors) > 0 {
+		return nil, &GraphQLError{Errors: envelope.Errors}
+	}
+	if envelope.Data == nil {
+		return body, nil
+	}
+	return envelope.Data, nil
+}
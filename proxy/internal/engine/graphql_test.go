@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+func graphqlTool() store.Tool {
+	return store.Tool{Name: "get_order", Mapping: store.RequestTemplate{
+		Type:         MappingTypeGraphQL,
+		Path:         "/graphql",
+		GraphQLQuery: "query($id: ID!, $limit: Int) { order(id: $id) { id lines(limit: $limit) } }",
+	}}
+}
+
+func TestGraphQLVariablesFromArgs(t *testing.T) {
+	var got struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	var method, path string
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"data":{"order":{"id":"42","lines":[]}}}`))
+	})
+	tool := graphqlTool()
+	res, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, tool, map[string]interface{}{"id": "42", "limit": float64(5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPost || path != "/graphql" {
+		t.Fatalf("upstream got %s %s", method, path)
+	}
+	if got.Query != tool.Mapping.GraphQLQuery {
+		t.Fatalf("query = %q", got.Query)
+	}
+	if got.Variables["id"] != "42" || got.Variables["limit"] != float64(5) {
+		t.Fatalf("variables = %v", got.Variables)
+	}
+	if string(res.UpstreamBody) != `{"order":{"id":"42","lines":[]}}` {
+		t.Fatalf("body = %s, want the data member", res.UpstreamBody)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":null,"errors":[{"message":"order not found","path":["order"]}]}`))
+	})
+	_, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, graphqlTool(), map[string]interface{}{"id": "7"})
+	var gqlErr *GraphQLError
+	if !errors.As(err, &gqlErr) || len(gqlErr.Errors) != 1 {
+		t.Fatalf("err = %v, want one GraphQL error", err)
+	}
+}
+
+func TestGraphQLToolWithoutQuery(t *testing.T) {
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {})
+	tool := graphqlTool()
+	tool.Mapping.GraphQLQuery = ""
+	if _, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, tool, nil); err == nil {
+		t.Fatal("expected an error for a GraphQL tool without a query")
+	}
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/store"
+)
+
+func withUpstreamHeaders(t *testing.T, userAgent string, defaults map[string]string) {
+	t.Helper()
+	prevUA, prevDefaults := config.UpstreamUserAgent, config.UpstreamDefaultHeaders
+	t.Cleanup(func() { config.UpstreamUserAgent, config.UpstreamDefaultHeaders = prevUA, prevDefaults })
+	config.UpstreamUserAgent, config.UpstreamDefaultHeaders = userAgent, defaults
+}
+
+// headerUpstream returns a call that runs a tool with the given mapping headers and
+// returns the headers the upstream received.
+func headerUpstream(t *testing.T) func(map[string]string) http.Header {
+	t.Helper()
+	var got http.Header
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		_, _ = w.Write([]byte(`{}`))
+	})
+	call := func(toolHeaders map[string]string) http.Header {
+		t.Helper()
+		tool := testTool("t", "/x")
+		tool.Mapping.Headers = toolHeaders
+		if _, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, tool, map[string]interface{}{"team": "sales"}); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	return call
+}
+
+func TestDefaultUserAgent(t *testing.T) {
+	withUpstreamHeaders(t, "mcp-gateway/"+config.Version, nil)
+	call := headerUpstream(t)
+
+	if ua := call(nil).Get("User-Agent"); ua != "mcp-gateway/"+config.Version {
+		t.Fatalf("User-Agent %q, want the gateway default", ua)
+	}
+	if ua := call(map[string]string{"User-Agent": "orders-client/2"}).Get("User-Agent"); ua != "orders-client/2" {
+		t.Fatalf("User-Agent %q, want the tool override", ua)
+	}
+}
+
+func TestEmptyUserAgentKeepsClientDefault(t *testing.T) {
+	withUpstreamHeaders(t, "", nil)
+	call := headerUpstream(t)
+	if ua := call(nil).Get("User-Agent"); ua != "Go-http-client/1.1" {
+		t.Fatalf("User-Agent %q, want the http client's own", ua)
+	}
+}
+
+func TestDefaultHeadersMergeUnderToolHeaders(t *testing.T) {
+	withUpstreamHeaders(t, "mcp-gateway/test", map[string]string{"X-Org": "acme", "X-Team": "platform"})
+	call := headerUpstream(t)
+
+	h := call(map[string]string{"X-Team": "{{team}}", "X-Api-Version": "2"})
+	if h.Get("X-Org") != "acme" || h.Get("X-Team") != "sales" || h.Get("X-Api-Version") != "2" {
+		t.Fatalf("headers %v", h)
+	}
+	if v := h.Values("X-Team"); len(v) != 1 {
+		t.Fatalf("X-Team sent %d times", len(v))
+	}
+}
+
+func TestResponseHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Link", `</orders?page=2>; rel="next"`)
+	h.Add("Etag", `"v1"`)
+	h.Add("X-Rate-Limit", "10")
+	h.Add("X-Rate-Limit", "20")
+	h.Set("Set-Cookie", "session=secret")
+	h.Set("Www-Authenticate", "Bearer")
+	srv := store.Server{ResponseHeaders: []string{"link", "Set-Cookie", "X-Rate-Limit"}}
+	tool := store.Tool{Mapping: store.RequestTemplate{ResponseHeaders: []string{"ETag", "WWW-Authenticate", "X-Missing"}}}
+
+	got := ResponseHeaders(srv, tool, h)
+	want := map[string]string{"Link": `</orders?page=2>; rel="next"`, "Etag": `"v1"`, "X-Rate-Limit": "10, 20"}
+	if len(got) != len(want) {
+		t.Fatalf("headers %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+	if got := ResponseHeaders(store.Server{}, store.Tool{}, h); got != nil {
+		t.Fatalf("nothing allowlisted: %v, want nil", got)
+	}
+}
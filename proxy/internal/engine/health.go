@@ -0,0 +1,259 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/metrics"
+	"gateway/proxy/internal/store"
+)
+
+// ErrUpstreamDown is returned without calling the upstream when a server with
+// HealthCheck.FailFast is failing its health checks.
+var ErrUpstreamDown = fmt.Errorf("%w: server is failing health checks", ErrUpstreamUnavailable)
+
+var upstreamHealthy = metrics.NewGaugeVec("upstream_healthy", "Whether a server's upstreams pass active health checks (1) or not (0).", "server")
+
+const (
+	// healthTick is how often due probes are looked for; it bounds interval precision
+	healthTick = 2 * time.Second
+	// healthListInterval is how often the server list is read again, so servers that add,
+	// change or drop their healthCheck are picked up within it
+	healthListInterval = 30 * time.Second
+	// defaultHealthInterval applies when HealthCheck.IntervalSeconds is unset
+	defaultHealthInterval = 10 * time.Second
+	// maxProbeTimeout caps a single probe; shorter intervals shorten it to match
+	maxProbeTimeout = 5 * time.Second
+)
+
+// ServerHealth is the latest health check outcome of a server. LastError names upstream
+// hosts, so it is logged but never serialized for the unauthenticated /readyz.
+type ServerHealth struct {
+	Healthy          bool   `json:"healthy"`
+	CheckedUnixMilli int64  `json:"checkedUnixMilli"`
+	LastError        string `json:"-"`
+}
+
+// HealthStore is the subset of store methods the health checker reads.
+type HealthStore interface {
+	ListServers() ([]store.Server, error)
+	GetTenant(slug string) (store.Tenant, error)
+}
+
+// HealthChecker probes the upstreams of servers that configure a HealthCheck and records
+// their state on the balancer, where fail-fast servers short-circuit tool calls.
+type HealthChecker struct {
+	store  HealthStore
+	client *http.Client
+	lb     *Balancer
+
+	mu       sync.Mutex
+	servers  []store.Server // servers that configure health checks, as of listedAt
+	listedAt time.Time
+	state    map[string]ServerHealth
+	next     map[string]time.Time
+	inflight map[string]bool
+
+	stop chan struct{}
+	once sync.Once
+}
+
+func NewHealthChecker(s HealthStore, client *http.Client, lb *Balancer) *HealthChecker {
+	return &HealthChecker{
+		store:    s,
+		client:   client,
+		lb:       lb,
+		state:    make(map[string]ServerHealth),
+		next:     make(map[string]time.Time),
+		inflight: make(map[string]bool),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start probes in the background until Close is called.
+func (h *HealthChecker) Start() {
+	go func() {
+		ticker := time.NewTicker(healthTick)
+		defer ticker.Stop()
+		for {
+			h.CheckDue(time.Now())
+			select {
+			case <-h.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close stops background probing.
+func (h *HealthChecker) Close() {
+	h.once.Do(func() { close(h.stop) })
+}
+
+// Statuses returns the latest outcome per probed server slug.
+func (h *HealthChecker) Statuses() map[string]ServerHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make(map[string]ServerHealth, len(h.state))
+	for slug, st := range h.state {
+		out[slug] = st
+	}
+	return out
+}
+
+// Reload makes the next CheckDue read the server list again instead of waiting for
+// healthListInterval, e.g. after a server was written.
+func (h *HealthChecker) Reload() {
+	h.mu.Lock()
+	h.listedAt = time.Time{}
+	h.mu.Unlock()
+}
+
+// CheckDue starts a probe round for every server whose interval has elapsed. The server
+// list is read at most every healthListInterval; servers that no longer configure health
+// checks are forgotten then.
+func (h *HealthChecker) CheckDue(now time.Time) {
+	h.mu.Lock()
+	servers, stale := h.servers, h.listedAt.IsZero() || now.Sub(h.listedAt) >= healthListInterval
+	h.mu.Unlock()
+	if stale {
+		var err error
+		if servers, err = h.listServers(now); err != nil {
+			slog.Warn("health check: listing servers failed", "error", err.Error())
+			return
+		}
+	}
+	for _, srv := range servers {
+		h.mu.Lock()
+		due := !h.inflight[srv.Slug] && !now.Before(h.next[srv.Slug])
+		if due {
+			h.inflight[srv.Slug] = true
+			h.next[srv.Slug] = now.Add(healthInterval(srv.HealthCheck))
+		}
+		h.mu.Unlock()
+		if due {
+			go h.Probe(srv)
+		}
+	}
+}
+
+// listServers reads the servers that configure health checks, caches them and forgets the
+// state of those that no longer do.
+func (h *HealthChecker) listServers(now time.Time) ([]store.Server, error) {
+	all, err := h.store.ListServers()
+	if err != nil {
+		return nil, err
+	}
+	var servers []store.Server
+	configured := map[string]bool{}
+	for _, srv := range all {
+		if srv.HealthCheck == nil || srv.HealthCheck.Path == "" || !srv.Enabled || srv.Backend == BackendStdio {
+			continue
+		}
+		servers = append(servers, srv)
+		configured[srv.Slug] = true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.servers, h.listedAt = servers, now
+	for slug := range h.state {
+		if !configured[slug] {
+			delete(h.state, slug)
+			delete(h.next, slug)
+			h.lb.setServerDown(slug, false)
+			upstreamHealthy.Set(1, slug)
+		}
+	}
+	return servers, nil
+}
+
+// Probe runs one probe round for srv and records the result. The server is healthy when
+// any of its upstreams answers the health path with a 2xx or 3xx.
+func (h *HealthChecker) Probe(srv store.Server) ServerHealth {
+	defer func() {
+		h.mu.Lock()
+		delete(h.inflight, srv.Slug)
+		h.mu.Unlock()
+	}()
+	tenant, _ := h.store.GetTenant(srv.TenantSlug)
+	timeout := healthInterval(srv.HealthCheck)
+	if timeout > maxProbeTimeout {
+		timeout = maxProbeTimeout
+	}
+	client := withRedirectPolicy(h.client, RedirectNone, tenant)
+	var errs []string
+	for _, base := range srv.UpstreamBases() {
+		err := probe(client, tenant, base, srv.HealthCheck.Path, timeout)
+		if err == nil {
+			errs = nil
+			break
+		}
+		errs = append(errs, err.Error())
+	}
+	if len(srv.UpstreamBases()) == 0 {
+		errs = append(errs, "upstream base URL not configured")
+	}
+	st := ServerHealth{Healthy: len(errs) == 0, CheckedUnixMilli: time.Now().UnixMilli(), LastError: strings.Join(errs, "; ")}
+
+	h.mu.Lock()
+	prev, seen := h.state[srv.Slug]
+	h.state[srv.Slug] = st
+	h.mu.Unlock()
+	h.lb.setServerDown(srv.Slug, !st.Healthy)
+	if st.Healthy {
+		upstreamHealthy.Set(1, srv.Slug)
+	} else {
+		upstreamHealthy.Set(0, srv.Slug)
+	}
+	if seen && prev.Healthy != st.Healthy || !seen && !st.Healthy {
+		if st.Healthy {
+			slog.Info("health check: server is up", "server", srv.Slug)
+		} else {
+			slog.Warn("health check: server is down", "server", srv.Slug, "error", st.LastError)
+		}
+	}
+	return st
+}
+
+func probe(client *http.Client, tenant store.Tenant, base, path string, timeout time.Duration) error {
+	u, err := url.Parse(base)
+	if err != nil {
+		return err
+	}
+	if !isHostAllowed(u.Hostname(), egressAllowlist(tenant)) {
+		return fmt.Errorf("%w: %s", ErrEgressDenied, u.Hostname())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(base, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	if config.UpstreamUserAgent != "" {
+		req.Header.Set("User-Agent", config.UpstreamUserAgent)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", u.Host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s: status %d", u.Host, resp.StatusCode)
+	}
+	return nil
+}
+
+func healthInterval(hc *store.HealthCheck) time.Duration {
+	if hc.IntervalSeconds > 0 {
+		return time.Duration(hc.IntervalSeconds) * time.Second
+	}
+	return defaultHealthInterval
+}
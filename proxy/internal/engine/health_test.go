@@ -0,0 +1,144 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gateway/proxy/internal/store"
+)
+
+// healthStore serves a fixed server list and counts how often it is read.
+type healthStore struct {
+	mu      sync.Mutex
+	servers []store.Server
+	tenant  store.Tenant
+	lists   atomic.Int32
+}
+
+func (s *healthStore) ListServers() ([]store.Server, error) {
+	s.lists.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]store.Server(nil), s.servers...), nil
+}
+
+func (s *healthStore) GetTenant(string) (store.Tenant, error) { return s.tenant, nil }
+
+func (s *healthStore) set(servers ...store.Server) {
+	s.mu.Lock()
+	s.servers = servers
+	s.mu.Unlock()
+}
+
+func TestHealthCheckDownAndRecover(t *testing.T) {
+	var down atomic.Bool
+	var toolHits atomic.Int32
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			if down.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		}
+		toolHits.Add(1)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	})
+	srv.HealthCheck = &store.HealthCheck{Path: "/healthz", FailFast: true}
+	lb := NewBalancer()
+	h := NewHealthChecker(&healthStore{servers: []store.Server{srv}, tenant: tenant}, http.DefaultClient, lb)
+	tool := testTool("get_order", "/orders/1")
+
+	if st := h.Probe(srv); !st.Healthy || lb.ServerDown(srv.Slug) {
+		t.Fatalf("healthy upstream probed as %+v", st)
+	}
+
+	down.Store(true)
+	st := h.Probe(srv)
+	if st.Healthy || st.LastError == "" || !lb.ServerDown(srv.Slug) {
+		t.Fatalf("failing upstream probed as %+v", st)
+	}
+	if got := h.Statuses()[srv.Slug]; got.Healthy {
+		t.Fatalf("Statuses reports %+v while down", got)
+	}
+	_, err := ExecuteBalanced(context.Background(), lb, http.DefaultClient, srv, tenant, tool, nil)
+	if !errors.Is(err, ErrUpstreamDown) || !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Fatalf("call while down: err = %v, want ErrUpstreamDown", err)
+	}
+	if n := toolHits.Load(); n != 0 {
+		t.Fatalf("fail-fast call reached the upstream %d times", n)
+	}
+
+	down.Store(false)
+	if st := h.Probe(srv); !st.Healthy || lb.ServerDown(srv.Slug) {
+		t.Fatalf("recovered upstream probed as %+v", st)
+	}
+	res, err := ExecuteBalanced(context.Background(), lb, http.DefaultClient, srv, tenant, tool, nil)
+	if err != nil || res.UpstreamStatus != http.StatusOK {
+		t.Fatalf("call after recovery: res %+v, err %v", res, err)
+	}
+}
+
+func TestHealthCheckWithoutFailFastStillCalls(t *testing.T) {
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	})
+	srv.HealthCheck = &store.HealthCheck{Path: "/healthz"}
+	lb := NewBalancer()
+	h := NewHealthChecker(&healthStore{tenant: tenant}, http.DefaultClient, lb)
+	if st := h.Probe(srv); st.Healthy {
+		t.Fatal("probe should fail")
+	}
+	if _, err := ExecuteBalanced(context.Background(), lb, http.DefaultClient, srv, tenant, testTool("t", "/x"), nil); err != nil {
+		t.Fatalf("call without failFast: %v", err)
+	}
+}
+
+func TestHealthCheckCachesServerList(t *testing.T) {
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {})
+	srv.HealthCheck = &store.HealthCheck{Path: "/healthz", IntervalSeconds: 1}
+	hs := &healthStore{servers: []store.Server{srv}, tenant: tenant}
+	h := NewHealthChecker(hs, http.DefaultClient, NewBalancer())
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		h.CheckDue(now.Add(time.Duration(i) * healthTick))
+	}
+	if n := hs.lists.Load(); n != 1 {
+		t.Fatalf("server list read %d times within healthListInterval, want 1", n)
+	}
+	h.CheckDue(now.Add(healthListInterval))
+	if n := hs.lists.Load(); n != 2 {
+		t.Fatalf("server list read %d times after healthListInterval, want 2", n)
+	}
+
+	waitFor(t, func() bool { _, ok := h.Statuses()[srv.Slug]; return ok })
+	hs.set()
+	h.Reload()
+	h.CheckDue(now.Add(healthListInterval + time.Second))
+	if n := hs.lists.Load(); n != 3 {
+		t.Fatalf("server list read %d times after Reload, want 3", n)
+	}
+	if _, ok := h.Statuses()[srv.Slug]; ok {
+		t.Fatal("server without healthCheck is still reported")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 5s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,30 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+// testUpstream starts h as an upstream and returns a server pointing at it and a tenant
+// whose egress allowlist admits it.
+func testUpstream(t *testing.T, h http.HandlerFunc) (*httptest.Server, store.Server, store.Tenant) {
+	t.Helper()
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	srv, tenant := testTarget(ts.URL)
+	return ts, srv, tenant
+}
+
+// testTarget is server orders of tenant acme calling baseURL on 127.0.0.1.
+func testTarget(baseURL string) (store.Server, store.Tenant) {
+	tenant := store.Tenant{Slug: "acme", Enabled: true, EgressAllowlist: []string{"127.0.0.1"}}
+	return store.Server{Slug: "orders", TenantSlug: tenant.Slug, Enabled: true, UpstreamBaseURL: baseURL}, tenant
+}
+
+// testTool is a GET tool on path.
+func testTool(name, path string) store.Tool {
+	return store.Tool{Name: name, Mapping: store.RequestTemplate{Method: http.MethodGet, Path: path}}
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyHeader carries the client's idempotency key to the upstream.
+const IdempotencyHeader = "Idempotency-Key"
+
+// Idempotency replays the prior result of a tools/call that repeats an idempotency key
+// with the same tool and arguments within the TTL, so retries do not repeat side effects.
+// A retry that arrives while the first call is still running waits for it and shares its
+// result.
+type Idempotency struct {
+	cache   *MemoryCache
+	ttl     time.Duration
+	flights *flightGroup
+}
+
+func NewIdempotency(maxEntries int, ttl time.Duration) *Idempotency {
+	return &Idempotency{cache: NewMemoryCache(maxEntries), ttl: ttl, flights: &flightGroup{flights: map[string]*flight{}}}
+}
+
+// Key scopes a client key to the caller (e.g. server slug and subject), tool and arguments;
+// reusing a key with different arguments is treated as a different call.
+func (i *Idempotency) Key(clientKey, scope, toolName string, args map[string]interface{}) string {
+	return CacheKey(scope+"\x00"+clientKey, toolName, args)
+}
+
+// Do returns the stored result for key, or runs call and stores its result unless it
+// failed or the upstream answered 5xx (both are safe to retry). Concurrent calls with the
+// same key run call once, detached from the first caller's cancellation like cache
+// misses. replayed reports a result that call did not produce for this caller.
+func (i *Idempotency) Do(ctx context.Context, key string, call func(context.Context) (*ExecuteResult, error)) (res *ExecuteResult, replayed bool, err error) {
+	if res, ok := i.cache.Get(key); ok {
+		return res, true, nil
+	}
+	var hit bool
+	res, shared, err := i.flights.do(ctx, key, func(ctx context.Context) (*ExecuteResult, error) {
+		// The previous flight for key may have finished since the lookup above
+		if res, ok := i.cache.Get(key); ok {
+			hit = true
+			return res, nil
+		}
+		res, err := call(ctx)
+		if err == nil && res.UpstreamStatus < 500 {
+			i.cache.Set(key, res, i.ttl)
+		}
+		return res, err
+	})
+	return res, shared || hit, err
+}
+
+type idempotencyKeyCtx struct{}
+
+// WithIdempotencyKey returns a context whose upstream calls send key as the
+// IdempotencyHeader. The header is set after the mapping's headers are templated, so the
+// client's key is sent verbatim and overrides a mapped header of the same name.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyCtx{}, key)
+}
+
+func idempotencyKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyCtx{}).(string)
+	return key
+}
@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyReplaysRepeatedKey(t *testing.T) {
+	var hits atomic.Int32
+	var gotKey atomic.Value
+	ts, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		gotKey.Store(r.Header.Get(IdempotencyHeader))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"orderId":"o-1"}`))
+	})
+	idem := NewIdempotency(100, time.Minute)
+	args := map[string]interface{}{"sku": "a"}
+	call := func(key string) (*ExecuteResult, bool) {
+		tool := testTool("createOrder", "/orders")
+		res, replayed, err := idem.Do(WithIdempotencyKey(context.Background(), key), idem.Key(key, "orders\x00alice", tool.Name, args), func(ctx context.Context) (*ExecuteResult, error) {
+			return Execute(ctx, ts.Client(), srv, tenant, tool, args)
+		})
+		if err != nil {
+			t.Fatalf("call with key %s: %v", key, err)
+		}
+		return res, replayed
+	}
+
+	first, replayed := call("k1")
+	if replayed || hits.Load() != 1 {
+		t.Fatalf("first call: replayed=%v hits=%d", replayed, hits.Load())
+	}
+	if gotKey.Load() != "k1" {
+		t.Fatalf("upstream got %s %q", IdempotencyHeader, gotKey.Load())
+	}
+	second, replayed := call("k1")
+	if !replayed || hits.Load() != 1 {
+		t.Fatalf("repeated key: replayed=%v hits=%d", replayed, hits.Load())
+	}
+	if string(second.UpstreamBody) != string(first.UpstreamBody) {
+		t.Fatalf("replayed body %s, want %s", second.UpstreamBody, first.UpstreamBody)
+	}
+	if _, replayed := call("k2"); replayed || hits.Load() != 2 {
+		t.Fatalf("new key: replayed=%v hits=%d", replayed, hits.Load())
+	}
+}
+
+func TestIdempotencyKeyIsNotTemplated(t *testing.T) {
+	var gotKey atomic.Value
+	ts, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		gotKey.Store(r.Header.Get(IdempotencyHeader))
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	})
+	tool := testTool("createOrder", "/orders")
+	tool.Mapping.Headers = map[string]string{IdempotencyHeader: "{{id}}"}
+	ctx := WithIdempotencyKey(context.Background(), "retry-{{id}}")
+	if _, err := Execute(ctx, ts.Client(), srv, tenant, tool, map[string]interface{}{"id": "42"}); err != nil {
+		t.Fatal(err)
+	}
+	if gotKey.Load() != "retry-{{id}}" {
+		t.Fatalf("upstream got %s %q, want the key verbatim", IdempotencyHeader, gotKey.Load())
+	}
+}
+
+func TestIdempotencyConcurrentDuplicatesShareOneCall(t *testing.T) {
+	var hits atomic.Int32
+	release := make(chan struct{})
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	})
+	idem := NewIdempotency(100, time.Minute)
+	tool := testTool("createOrder", "/orders")
+	key := idem.Key("k1", "orders\x00alice", tool.Name, nil)
+
+	const callers = 5
+	var wg sync.WaitGroup
+	var replays atomic.Int32
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, replayed, err := idem.Do(WithIdempotencyKey(context.Background(), "k1"), key, func(ctx context.Context) (*ExecuteResult, error) {
+				return Execute(ctx, http.DefaultClient, srv, tenant, tool, nil)
+			})
+			if replayed {
+				replays.Add(1)
+			}
+			errs <- err
+		}()
+	}
+	// Let every caller reach the in-flight call before the upstream answers
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("upstream hit %d times, want 1", hits.Load())
+	}
+	if replays.Load() != callers-1 {
+		t.Fatalf("%d callers replayed, want %d", replays.Load(), callers-1)
+	}
+}
+
+func TestIdempotencyDoesNotStoreFailures(t *testing.T) {
+	var hits atomic.Int32
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	})
+	idem := NewIdempotency(100, time.Minute)
+	tool := testTool("createOrder", "/orders")
+	call := func() *ExecuteResult {
+		res, _, err := idem.Do(context.Background(), "k", func(ctx context.Context) (*ExecuteResult, error) {
+			return Execute(ctx, http.DefaultClient, srv, tenant, tool, nil)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	if res := call(); res.UpstreamStatus != http.StatusBadGateway {
+		t.Fatalf("first status %d", res.UpstreamStatus)
+	}
+	if res := call(); res.UpstreamStatus != http.StatusOK || hits.Load() != 2 {
+		t.Fatalf("retry after 5xx: status %d, hits %d", res.UpstreamStatus, hits.Load())
+	}
+}
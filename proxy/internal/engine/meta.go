@@ -0,0 +1,35 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+)
+
+type metaKey struct{}
+
+// WithRequestMeta returns a context carrying the _meta object the client attached to
+// the tools/call request.
+func WithRequestMeta(ctx context.Context, meta map[string]interface{}) context.Context {
+	return context.WithValue(ctx, metaKey{}, meta)
+}
+
+// RequestMeta returns the _meta object of the tools/call being executed, or nil.
+func RequestMeta(ctx context.Context) map[string]interface{} {
+	meta, _ := ctx.Value(metaKey{}).(map[string]interface{})
+	return meta
+}
+
+// metaTraceHeaders are W3C Trace Context fields a client may put in _meta to have the
+// upstream call join its trace.
+var metaTraceHeaders = []string{"traceparent", "tracestate"}
+
+// setTraceHeaders copies string trace context fields from the request _meta onto req
+// unless the tool mapping or gateway defaults already set them.
+func setTraceHeaders(ctx context.Context, req *http.Request) {
+	meta := RequestMeta(ctx)
+	for _, name := range metaTraceHeaders {
+		if v, ok := meta[name].(string); ok && v != "" && req.Header.Get(name) == "" {
+			req.Header.Set(name, v)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestRequestMetaTraceHeaders(t *testing.T) {
+	var got http.Header
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		_, _ = w.Write([]byte(`{}`))
+	})
+	ctx := WithRequestMeta(context.Background(), map[string]interface{}{"traceparent": "00-abc-def-01", "tracestate": "vendor=1", "progressToken": "tok-1"})
+	if _, err := ExecuteBalanced(ctx, NewBalancer(), http.DefaultClient, srv, tenant, testTool("t", "/x"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("Traceparent") != "00-abc-def-01" || got.Get("Tracestate") != "vendor=1" {
+		t.Fatalf("trace headers %q %q", got.Get("Traceparent"), got.Get("Tracestate"))
+	}
+	if got.Get("ProgressToken") != "" {
+		t.Fatal("a non-trace _meta field became a header")
+	}
+
+	// A mapping's own trace header wins over the client's
+	tool := testTool("t", "/x")
+	tool.Mapping.Headers = map[string]string{"traceparent": "00-mapped-01"}
+	if _, err := ExecuteBalanced(ctx, NewBalancer(), http.DefaultClient, srv, tenant, tool, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("Traceparent") != "00-mapped-01" {
+		t.Fatalf("traceparent %q, want the mapping's", got.Get("Traceparent"))
+	}
+	if RequestMeta(context.Background()) != nil {
+		t.Fatal("RequestMeta without _meta should be nil")
+	}
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"regexp"
+	"sort"
+
+	"gateway/proxy/internal/store"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{\{([^{}]*)\}\}`)
+
+// Placeholder is one {{name}} reference in a request mapping and where it appears, e.g.
+// "path", "query.page", "headers.X-Org" or "body.customer.id".
+type Placeholder struct {
+	Name     string `json:"name"`
+	Location string `json:"location"`
+}
+
+// MappingReport cross-checks a tool's mapping placeholders against its input schema.
+type MappingReport struct {
+	Tool  string `json:"tool"`
+	Valid bool   `json:"valid"`
+	// Placeholders with no matching input schema property or default; they are sent literally
+	UndefinedPlaceholders []Placeholder `json:"undefinedPlaceholders"`
+	// Schema properties no placeholder references; their arguments never reach the upstream
+	UnusedProperties []string `json:"unusedProperties"`
+}
+
+// CheckMapping reports placeholders in the path, query, headers and body that neither the
+// input schema, the tool's Defaults nor a transform declare, and declared properties that
+// neither a placeholder nor a transform references. GraphQL tools
+// pass every argument as a variable, so only their path and headers are checked and no
+// property counts as unused.
+func CheckMapping(tool store.Tool) MappingReport {
+	refs := mappingPlaceholders(tool.Mapping)
+	props, _ := tool.InputSchema["properties"].(map[string]interface{})
+	rep := MappingReport{Tool: tool.Name, UndefinedPlaceholders: []Placeholder{}, UnusedProperties: []string{}}
+	used := transformRefs(tool.Mapping.Transforms)
+	assigned := map[string]bool{}
+	for _, t := range tool.Mapping.Transforms {
+		assigned[t.Arg] = true
+	}
+	for _, p := range refs {
+		used[p.Name] = true
+		_, declared := props[p.Name]
+		if _, ok := tool.Defaults[p.Name]; !ok && !declared && !assigned[p.Name] {
+			rep.UndefinedPlaceholders = append(rep.UndefinedPlaceholders, p)
+		}
+	}
+	if tool.Mapping.Type != MappingTypeGraphQL {
+		for name := range props {
+			if !used[name] {
+				rep.UnusedProperties = append(rep.UnusedProperties, name)
+			}
+		}
+		sort.Strings(rep.UnusedProperties)
+	}
+	rep.Valid = len(rep.UndefinedPlaceholders) == 0 && len(rep.UnusedProperties) == 0
+	return rep
+}
+
+// mappingPlaceholders lists placeholders in the order substitution would meet them;
+// map-valued parts are visited in key order so reports are stable.
+func mappingPlaceholders(m store.RequestTemplate) []Placeholder {
+	var out []Placeholder
+	collect := func(location, template string) {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+			out = append(out, Placeholder{Name: match[1], Location: location})
+		}
+	}
+	collect("path", m.Path)
+	if m.Type != MappingTypeGraphQL {
+		for _, k := range sortedKeys(m.Query) {
+			collect("query."+k, m.Query[k])
+		}
+	}
+	for _, k := range sortedKeys(m.Headers) {
+		collect("headers."+k, m.Headers[k])
+	}
+	if m.Type != MappingTypeGraphQL {
+		var walk func(prefix string, body map[string]interface{})
+		walk = func(prefix string, body map[string]interface{}) {
+			keys := make([]string, 0, len(body))
+			for k := range body {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				switch v := body[k].(type) {
+				case string:
+					collect(prefix+k, v)
+				case map[string]interface{}:
+					walk(prefix+k+".", v)
+				}
+			}
+		}
+		walk("body.", m.Body)
+	}
+	return out
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
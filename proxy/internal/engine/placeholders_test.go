@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+func schemaWith(props ...string) map[string]interface{} {
+	p := map[string]interface{}{}
+	for _, name := range props {
+		p[name] = map[string]interface{}{"type": "string"}
+	}
+	return map[string]interface{}{"type": "object", "properties": p}
+}
+
+func TestCheckMappingConsistent(t *testing.T) {
+	tool := store.Tool{
+		Name:        "update_order",
+		InputSchema: schemaWith("orderId", "org", "note", "expand"),
+		Mapping: store.RequestTemplate{
+			Method:  http.MethodPatch,
+			Path:    "/orders/{{orderId}}",
+			Query:   map[string]string{"expand": "{{expand}}"},
+			Headers: map[string]string{"X-Org": "{{org}}"},
+			Body:    map[string]interface{}{"meta": map[string]interface{}{"note": "{{note}}"}},
+		},
+	}
+	rep := CheckMapping(tool)
+	if !rep.Valid || len(rep.UndefinedPlaceholders) != 0 || len(rep.UnusedProperties) != 0 {
+		t.Fatalf("report %+v", rep)
+	}
+}
+
+func TestCheckMappingMismatch(t *testing.T) {
+	tool := store.Tool{
+		Name:        "get_order",
+		InputSchema: schemaWith("orderId", "verbose"),
+		Mapping: store.RequestTemplate{
+			Method:  http.MethodGet,
+			Path:    "/orders/{{orderID}}",
+			Headers: map[string]string{"X-Region": "{{region}}"},
+			Body:    map[string]interface{}{"customer": map[string]interface{}{"id": "{{customerId}}"}},
+		},
+	}
+	rep := CheckMapping(tool)
+	if rep.Valid {
+		t.Fatal("mismatched mapping reported valid")
+	}
+	wantUndefined := []Placeholder{{Name: "orderID", Location: "path"}, {Name: "region", Location: "headers.X-Region"}, {Name: "customerId", Location: "body.customer.id"}}
+	if !reflect.DeepEqual(rep.UndefinedPlaceholders, wantUndefined) {
+		t.Fatalf("undefined %+v, want %+v", rep.UndefinedPlaceholders, wantUndefined)
+	}
+	if want := []string{"orderId", "verbose"}; !reflect.DeepEqual(rep.UnusedProperties, want) {
+		t.Fatalf("unused %v, want %v", rep.UnusedProperties, want)
+	}
+}
+
+func TestCheckMappingGraphQL(t *testing.T) {
+	// GraphQL arguments travel as variables, so no property is unused
+	gql := store.Tool{Name: "order", InputSchema: schemaWith("id"), Mapping: store.RequestTemplate{Type: MappingTypeGraphQL, Path: "/graphql", GraphQLQuery: "query($id: ID!) { order(id: $id) { id } }"}}
+	if rep := CheckMapping(gql); !rep.Valid {
+		t.Fatalf("graphql: %+v", rep)
+	}
+}
+
+func TestCheckMappingDefaults(t *testing.T) {
+	// A placeholder with a tool default always has a value, declared or not
+	tool := store.Tool{
+		Name:        "list_orders",
+		InputSchema: schemaWith("status"),
+		Defaults:    map[string]interface{}{"pageSize": float64(50)},
+		Mapping:     store.RequestTemplate{Method: http.MethodGet, Path: "/orders/{{status}}", Query: map[string]string{"pageSize": "{{pageSize}}"}},
+	}
+	if rep := CheckMapping(tool); !rep.Valid || len(rep.UndefinedPlaceholders) != 0 {
+		t.Fatalf("report %+v", rep)
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	args := map[string]interface{}{
+		"id":      "1",
+		"orderId": "42",
+		"order":   "o",
+		"Id":      "X",
+		"lineNo":  float64(3),
+		"nested":  "{{id}}",
+	}
+	cases := []struct{ template, want string }{
+		{"/api/orders/{{orderId}}/items", "/api/orders/42/items"},
+		{"/orders/{{order}}{{Id}}/{{id}}", "/orders/oX/1"},
+		{"{{orderId}}{{id}}{{orderId}}", "42142"},
+		{"/lines/{{lineNo}}", "/lines/3"},
+		// Values are not expanded again
+		{"/echo/{{nested}}", "/echo/{{id}}"},
+		// Unknown placeholders and partial braces stay as written
+		{"/x/{{missing}}/{{ id }}/{id}/{{", "/x/{{missing}}/{{ id }}/{id}/{{"},
+		{"/plain", "/plain"},
+	}
+	for _, tc := range cases {
+		// Map iteration order varies between runs; the output must not
+		for i := 0; i < 20; i++ {
+			if got := substitute(tc.template, args); got != tc.want {
+				t.Fatalf("substitute(%q) = %q, want %q", tc.template, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestOverlappingArgsInRequest(t *testing.T) {
+	var got string
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.RequestURI()
+		_, _ = w.Write([]byte(`{}`))
+	})
+	tool := testTool("get_line", "/orders/{{orderId}}/lines/{{id}}{{idSuffix}}")
+	tool.Mapping.Query = map[string]string{"ref": "{{id}}-{{orderId}}"}
+	args := map[string]interface{}{"id": "7", "orderId": "42", "idSuffix": "b"}
+	for i := 0; i < 10; i++ {
+		if _, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, tool, args); err != nil {
+			t.Fatal(err)
+		}
+		if got != "/orders/42/lines/7b?ref=7-42" {
+			t.Fatalf("upstream got %s", got)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package engine
+
+import (
+	"context"
+	"io"
+)
+
+// ProgressFunc receives the number of upstream response bytes read so far and the
+// declared body size, or -1 when the upstream did not send Content-Length.
+type ProgressFunc func(read, total int64)
+
+type progressKey struct{}
+
+// WithProgress returns a context under which upstream response bodies report each
+// chunk read to fn.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+func progressFrom(ctx context.Context) ProgressFunc {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return fn
+}
+
+// progressReader reports after every Read, i.e. at each chunk boundary of the upstream stream.
+type progressReader struct {
+	io.ReadCloser
+	read  int64
+	total int64
+	fn    ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.fn(p.read, p.total)
+	}
+	return n, err
+}
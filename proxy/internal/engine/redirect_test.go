@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+// redirector is an upstream that redirects /start to target and answers /done with 200.
+type redirector struct {
+	base   string
+	starts atomic.Int32
+	done   atomic.Int32
+	srv    store.Server
+	tenant store.Tenant
+}
+
+func newRedirector(t *testing.T, target func(r *redirector) string) *redirector {
+	t.Helper()
+	rd := &redirector{}
+	ts, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/start":
+			rd.starts.Add(1)
+			http.Redirect(w, r, target(rd), http.StatusFound)
+		case "/done":
+			rd.done.Add(1)
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		}
+	})
+	rd.base, rd.srv, rd.tenant = ts.URL, srv, tenant
+	return rd
+}
+
+// otherHost is the redirector under a hostname that is not on the egress allowlist.
+func (rd *redirector) otherHost(path string) string {
+	u, _ := url.Parse(rd.base)
+	return "http://localhost:" + u.Port() + path
+}
+
+func TestRedirectSameHostFollowsSameHost(t *testing.T) {
+	rd := newRedirector(t, func(rd *redirector) string { return rd.base + "/done" })
+	rd.srv.RedirectPolicy = RedirectSameHost
+
+	res, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, rd.srv, rd.tenant, testTool("t", "/start"), nil)
+	if err != nil || res.UpstreamStatus != http.StatusOK || rd.done.Load() != 1 {
+		t.Fatalf("same-host redirect: res %+v, err %v, done %d", res, err, rd.done.Load())
+	}
+}
+
+func TestRedirectSameHostBlocksOtherHost(t *testing.T) {
+	rd := newRedirector(t, func(rd *redirector) string { return rd.otherHost("/done") })
+	rd.srv.RedirectPolicy = RedirectSameHost
+	// A second upstream would be tried if the refusal counted as an upstream failure
+	rd.srv.UpstreamBaseURL, rd.srv.UpstreamBaseURLs = "", []string{rd.base, rd.base + "/"}
+	lb := NewBalancer()
+
+	for i := 0; i < unhealthyAfter+1; i++ {
+		_, err := ExecuteBalanced(context.Background(), lb, http.DefaultClient, rd.srv, rd.tenant, testTool("t", "/start"), nil)
+		if !errors.Is(err, ErrEgressDenied) || !errors.Is(err, ErrRedirectDenied) {
+			t.Fatalf("cross-host redirect: err = %v, want ErrEgressDenied and ErrRedirectDenied", err)
+		}
+	}
+	if n := rd.done.Load(); n != 0 {
+		t.Fatalf("redirect target reached %d times", n)
+	}
+	if n := rd.starts.Load(); n != unhealthyAfter+1 {
+		t.Fatalf("%d upstream requests for %d calls, want no failover", n, unhealthyAfter+1)
+	}
+	if len(lb.health) != 0 {
+		t.Fatalf("refused redirects were reported as upstream failures: %v", lb.health)
+	}
+}
+
+func TestRedirectSameHostStopsLoops(t *testing.T) {
+	rd := newRedirector(t, func(rd *redirector) string { return rd.base + "/start" })
+	rd.srv.RedirectPolicy = RedirectSameHost
+
+	_, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, rd.srv, rd.tenant, testTool("t", "/start"), nil)
+	if !errors.Is(err, ErrRedirectDenied) || errors.Is(err, ErrEgressDenied) {
+		t.Fatalf("redirect loop: err = %v, want ErrRedirectDenied only", err)
+	}
+}
+
+func TestRedirectNoneReturnsRedirect(t *testing.T) {
+	rd := newRedirector(t, func(rd *redirector) string { return rd.base + "/done" })
+	tool := testTool("t", "/start")
+	tool.Mapping.RedirectPolicy = RedirectNone
+
+	res, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, rd.srv, rd.tenant, tool, nil)
+	if err != nil || res.UpstreamStatus != http.StatusFound || rd.done.Load() != 0 {
+		t.Fatalf("none: res %+v, err %v, done %d", res, err, rd.done.Load())
+	}
+	if loc := res.UpstreamHeaders.Get("Location"); loc != rd.base+"/done" {
+		t.Fatalf("Location %q", loc)
+	}
+}
+
+func TestRedirectFollowBlocksDisallowedHost(t *testing.T) {
+	for _, policy := range []string{RedirectFollow, ""} {
+		rd := newRedirector(t, func(rd *redirector) string { return rd.otherHost("/done") })
+		rd.srv.RedirectPolicy = policy
+
+		_, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, rd.srv, rd.tenant, testTool("t", "/start"), nil)
+		if !errors.Is(err, ErrEgressDenied) {
+			t.Fatalf("policy %q: err = %v, want ErrEgressDenied", policy, err)
+		}
+		if n := rd.done.Load(); n != 0 {
+			t.Fatalf("policy %q: redirect target reached %d times", policy, n)
+		}
+	}
+}
+
+func TestRedirectFollowFollowsAllowedHost(t *testing.T) {
+	rd := newRedirector(t, func(rd *redirector) string { return rd.base + "/done" })
+	rd.srv.RedirectPolicy = RedirectFollow
+
+	res, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, rd.srv, rd.tenant, testTool("t", "/start"), nil)
+	if err != nil || res.UpstreamStatus != http.StatusOK || rd.done.Load() != 1 {
+		t.Fatalf("follow: res %+v, err %v, done %d", res, err, rd.done.Load())
+	}
+}
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Response mappings project a successful JSON upstream body into a new object: each key is
+// an output field and each value a dotted path into the body. A numeric segment indexes an
+// array and * applies the rest of the path to every element (items.*.id lists the ids).
+// Paths only select existing values, so a mapping cannot loop or build data of its own.
+const (
+	// maxResponseMappingFields bounds the output fields of one mapping
+	maxResponseMappingFields = 100
+	// maxResponsePathBytes bounds a single source path
+	maxResponsePathBytes = 512
+)
+
+// applyResponseMapping returns body projected through mapping. Fields whose path does not
+// resolve are left out; inside a * they are null so list positions line up. Bodies that
+// are not JSON are returned unchanged.
+func applyResponseMapping(mapping map[string]string, body json.RawMessage) json.RawMessage {
+	if len(mapping) == 0 {
+		return body
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return body
+	}
+	out := make(map[string]interface{}, len(mapping))
+	for field, path := range mapping {
+		if v, ok := selectPath(doc, strings.Split(path, ".")); ok {
+			out[field] = v
+		}
+	}
+	projected, err := json.Marshal(out)
+	if err != nil {
+		return body
+	}
+	return projected
+}
+
+// selectPath resolves path segments against v.
+func selectPath(v interface{}, path []string) (interface{}, bool) {
+	for i, seg := range path {
+		switch cur := v.(type) {
+		case map[string]interface{}:
+			next, ok := cur[seg]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []interface{}:
+			if seg == "*" {
+				list := make([]interface{}, len(cur))
+				for j, elem := range cur {
+					list[j], _ = selectPath(elem, path[i+1:])
+				}
+				return list, true
+			}
+			n, err := strconv.Atoi(seg)
+			if err != nil || n < 0 || n >= len(cur) {
+				return nil, false
+			}
+			v = cur[n]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// CompileResponseMapping reports the first invalid field or path, so definitions can be
+// rejected when they are saved.
+func CompileResponseMapping(mapping map[string]string) error {
+	if len(mapping) > maxResponseMappingFields {
+		return fmt.Errorf("response mapping has more than %d fields", maxResponseMappingFields)
+	}
+	for field, path := range mapping {
+		if field == "" {
+			return errors.New("response mapping field without name")
+		}
+		if len(path) > maxResponsePathBytes {
+			return fmt.Errorf("response mapping %s: path longer than %d bytes", field, maxResponsePathBytes)
+		}
+		for _, seg := range strings.Split(path, ".") {
+			if seg == "" {
+				return fmt.Errorf("response mapping %s: empty segment in path %q", field, path)
+			}
+		}
+	}
+	return nil
+}
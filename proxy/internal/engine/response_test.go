@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const orderBody = `{"data":{"order":{"id":9007199254740993,"status":"shipped","customer":{"name":"Ada"},
+	"items":[{"sku":"A-1","qty":2},{"sku":"B-2"},{"qty":1}]}},"meta":{"requestId":"r-1"}}`
+
+func TestApplyResponseMapping(t *testing.T) {
+	cases := []struct {
+		name    string
+		mapping map[string]string
+		want    string
+	}{
+		{"rename and flatten", map[string]string{"orderId": "data.order.id", "customer": "data.order.customer.name"}, `{"customer":"Ada","orderId":9007199254740993}`},
+		{"subset object", map[string]string{"order": "data.order.customer"}, `{"order":{"name":"Ada"}}`},
+		{"array index", map[string]string{"first": "data.order.items.0.sku"}, `{"first":"A-1"}`},
+		{"every element", map[string]string{"skus": "data.order.items.*.sku", "qty": "data.order.items.*.qty"}, `{"qty":[2,null,1],"skus":["A-1","B-2",null]}`},
+		{"missing paths left out", map[string]string{"status": "data.order.status", "gone": "data.order.total", "oob": "data.order.items.7", "through": "data.order.status.x"}, `{"status":"shipped"}`},
+	}
+	for _, tc := range cases {
+		if got := applyResponseMapping(tc.mapping, json.RawMessage(orderBody)); string(got) != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+
+	// Without a mapping, or for a body that is not JSON, nothing changes
+	if got := applyResponseMapping(nil, json.RawMessage(orderBody)); string(got) != orderBody {
+		t.Errorf("no mapping: got %s", got)
+	}
+	if got := applyResponseMapping(map[string]string{"a": "b"}, json.RawMessage(`not json`)); string(got) != "not json" {
+		t.Errorf("not json: got %s", got)
+	}
+}
+
+func TestCompileResponseMapping(t *testing.T) {
+	many := map[string]string{}
+	for i := 0; i <= maxResponseMappingFields; i++ {
+		many[strings.Repeat("f", i+1)] = "a"
+	}
+	cases := []struct {
+		name    string
+		mapping map[string]string
+		wantErr string
+	}{
+		{"valid", map[string]string{"id": "data.order.id", "skus": "data.items.*.sku"}, ""},
+		{"empty field", map[string]string{"": "a"}, "without name"},
+		{"empty segment", map[string]string{"id": "data..id"}, "empty segment"},
+		{"empty path", map[string]string{"id": ""}, "empty segment"},
+		{"long path", map[string]string{"id": strings.Repeat("a.", maxResponsePathBytes)}, "longer than"},
+		{"too many fields", many, "more than"},
+	}
+	for _, tc := range cases {
+		err := CompileResponseMapping(tc.mapping)
+		if (tc.wantErr == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tc.wantErr)) {
+			t.Errorf("%s: err = %v, want %q", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestResponseMappingOnToolCall(t *testing.T) {
+	status := http.StatusOK
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(orderBody))
+	})
+	tool := testTool("get_order", "/orders/1")
+	tool.Mapping.ResponseMapping = map[string]string{"id": "data.order.id", "skus": "data.order.items.*.sku"}
+	tool.Mapping.Stream = true
+	if Streams(tool) {
+		t.Fatal("a tool with a response mapping must take the buffered path")
+	}
+
+	res, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, tool, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.UpstreamBody) != `{"id":9007199254740993,"skus":["A-1","B-2",null]}` {
+		t.Fatalf("body %s", res.UpstreamBody)
+	}
+
+	// Error bodies are passed on as the upstream sent them
+	status = http.StatusNotFound
+	res, _ = ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, tool, nil)
+	if res == nil || string(res.UpstreamBody) != orderBody {
+		t.Fatalf("error response was mapped: %+v", res)
+	}
+}
+
+func TestResponseMappingOnGraphQLData(t *testing.T) {
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"order":{"id":"42","lines":[{"sku":"A"}]}}}`))
+	})
+	tool := graphqlTool()
+	tool.Mapping.ResponseMapping = map[string]string{"id": "order.id", "skus": "order.lines.*.sku"}
+	res, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, tool, map[string]interface{}{"id": "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.UpstreamBody) != `{"id":"42","skus":["A"]}` {
+		t.Fatalf("body %s, want the data member projected", res.UpstreamBody)
+	}
+}
@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"context"
+	"sync"
+
+	"gateway/proxy/internal/metrics"
+)
+
+// upstreamDeduplicated counts calls answered by another caller's in-flight upstream request.
+var upstreamDeduplicated = metrics.NewCounterVec("upstream_deduplicated_total", "Cacheable tool calls that shared an identical in-flight upstream request.", "server")
+
+// flight is one upstream request shared by identical concurrent calls.
+type flight struct {
+	done chan struct{}
+	res  *ExecuteResult
+	err  error
+}
+
+// flightGroup lets concurrent calls with the same key share one execution.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+var cacheMisses = &flightGroup{flights: map[string]*flight{}}
+
+// do runs call once for all concurrent callers of key. The call runs detached from the
+// first caller's cancellation (keeping its deadline and values), so one client going
+// away does not fail the others; each caller still stops waiting when its own ctx ends.
+// shared reports that the result came from another caller's execution.
+func (g *flightGroup) do(ctx context.Context, key string, call func(context.Context) (*ExecuteResult, error)) (res *ExecuteResult, shared bool, err error) {
+	g.mu.Lock()
+	f, ok := g.flights[key]
+	if !ok {
+		f = &flight{done: make(chan struct{})}
+		g.flights[key] = f
+		g.mu.Unlock()
+
+		callCtx, cancel := context.WithoutCancel(ctx), context.CancelFunc(func() {})
+		if deadline, hasDeadline := ctx.Deadline(); hasDeadline {
+			callCtx, cancel = context.WithDeadline(callCtx, deadline)
+		}
+		go func() {
+			defer cancel()
+			f.res, f.err = call(callCtx)
+			g.mu.Lock()
+			delete(g.flights, key)
+			g.mu.Unlock()
+			close(f.done)
+		}()
+	} else {
+		g.mu.Unlock()
+	}
+	select {
+	case <-f.done:
+		return f.res, ok, f.err
+	case <-ctx.Done():
+		return nil, ok, ctx.Err()
+	}
+}
@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gateway/proxy/internal/store"
+)
+
+// heldUpstream counts requests and holds each one until release is closed.
+func heldUpstream(t *testing.T) (hits *atomic.Int32, release chan struct{}, srv store.Server, tenant store.Tenant) {
+	t.Helper()
+	hits, release = &atomic.Int32{}, make(chan struct{})
+	_, srv, tenant = testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		_, _ = w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	})
+	return hits, release, srv, tenant
+}
+
+// waitForHits waits until the upstream has seen n requests, then gives callers that are
+// about to join the in-flight request a moment to do so.
+func waitForHits(t *testing.T, hits *atomic.Int32, n int32) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for hits.Load() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("upstream saw %d requests, want %d", hits.Load(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestConcurrentMissesShareOneRequest(t *testing.T) {
+	for _, withCache := range []bool{false, true} {
+		hits, release, srv, tenant := heldUpstream(t)
+		var cache Cache
+		if withCache {
+			cache = NewMemoryCache(100)
+		}
+		tool := cachedTool(http.MethodGet, "/orders/{{id}}")
+
+		const n = 10
+		var wg sync.WaitGroup
+		bodies := make([]string, n)
+		errs := make([]error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				res, err := ExecuteCached(context.Background(), cache, NewBalancer(), http.DefaultClient, srv, tenant, tool, map[string]interface{}{"id": "42"})
+				errs[i] = err
+				if err == nil {
+					bodies[i] = string(res.UpstreamBody)
+				}
+			}(i)
+		}
+		waitForHits(t, hits, 1)
+		close(release)
+		wg.Wait()
+
+		if got := hits.Load(); got != 1 {
+			t.Fatalf("cache %v: upstream hits = %d, want 1", withCache, got)
+		}
+		for i := range bodies {
+			if errs[i] != nil || bodies[i] != `{"path":"/orders/42"}` {
+				t.Errorf("cache %v: caller %d got %q, err %v", withCache, i, bodies[i], errs[i])
+			}
+		}
+	}
+}
+
+func TestConcurrentMissesKeyedByArguments(t *testing.T) {
+	hits, release, srv, tenant := heldUpstream(t)
+	tool := cachedTool(http.MethodGet, "/orders/{{id}}")
+	var wg sync.WaitGroup
+	for _, id := range []string{"1", "2", "1", "2"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			res, err := ExecuteCached(context.Background(), nil, NewBalancer(), http.DefaultClient, srv, tenant, tool, map[string]interface{}{"id": id})
+			if err != nil || string(res.UpstreamBody) != `{"path":"/orders/`+id+`"}` {
+				t.Errorf("id %s: res %+v, err %v", id, res, err)
+			}
+		}(id)
+	}
+	waitForHits(t, hits, 2)
+	close(release)
+	wg.Wait()
+	if got := hits.Load(); got != 2 {
+		t.Fatalf("upstream hits = %d, want one per distinct id", got)
+	}
+}
+
+func TestSharedCallSurvivesFirstCallerCancel(t *testing.T) {
+	hits, release, srv, tenant := heldUpstream(t)
+	tool := cachedTool(http.MethodGet, "/orders/{{id}}")
+	args := map[string]interface{}{"id": "42"}
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := ExecuteCached(firstCtx, nil, NewBalancer(), http.DefaultClient, srv, tenant, tool, args)
+		firstErr <- err
+	}()
+	waitForHits(t, hits, 1)
+	second := make(chan *ExecuteResult, 1)
+	go func() {
+		res, err := ExecuteCached(context.Background(), nil, NewBalancer(), http.DefaultClient, srv, tenant, tool, args)
+		if err != nil {
+			t.Error(err)
+		}
+		second <- res
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	cancelFirst()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("first caller: err = %v, want context.Canceled", err)
+	}
+	close(release)
+	if res := <-second; res == nil || res.UpstreamStatus != http.StatusOK {
+		t.Fatalf("second caller got %+v", res)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("upstream hits = %d, want 1", got)
+	}
+}
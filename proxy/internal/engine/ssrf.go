@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+// ErrBlockedAddress is returned when the SSRF guard refuses to connect to an address. It
+// matches ErrEgressDenied.
+type ErrBlockedAddress struct {
+	Addr netip.Addr
+}
+
+func (e *ErrBlockedAddress) Error() string {
+	return fmt.Sprintf("egress to internal address %s blocked", e.Addr)
+}
+
+func (e *ErrBlockedAddress) Unwrap() error { return ErrEgressDenied }
+
+// egressRefused reports whether err is the SSRF guard refusing a connection or the redirect
+// policy refusing a redirect. Those are egress policy rather than upstream failures, so
+// callers neither mark the upstream down nor fail over to another one.
+func egressRefused(err error) bool {
+	var blocked *ErrBlockedAddress
+	return errors.As(err, &blocked) || errors.Is(err, ErrRedirectDenied)
+}
+
+// ssrfControl is a net.Dialer Control hook. It runs after DNS resolution against the exact
+// address being connected, so a permitted hostname that resolves (or rebinds) to a private,
+// loopback or link-local IP is refused. Redirect targets go through the same dialer.
+func ssrfControl(allowed []netip.Prefix) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip, err := netip.ParseAddr(host)
+		if err != nil {
+			return err
+		}
+		ip = ip.Unmap()
+		for _, p := range allowed {
+			if p.Contains(ip) {
+				return nil
+			}
+		}
+		if isInternalAddr(ip) {
+			return &ErrBlockedAddress{Addr: ip}
+		}
+		return nil
+	}
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), not covered by IsPrivate.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func isInternalAddr(ip netip.Addr) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
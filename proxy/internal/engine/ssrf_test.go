@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func guardedClient(allowed ...string) *http.Client {
+	opts := DefaultTransportOptions()
+	opts.SSRFGuard = true
+	for _, p := range allowed {
+		opts.SSRFAllowed = append(opts.SSRFAllowed, netip.MustParsePrefix(p))
+	}
+	return NewClientFactory(opts).Client(0)
+}
+
+func TestSSRFGuardBlocksLoopbackResolution(t *testing.T) {
+	var hits atomic.Int32
+	ts, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) { hits.Add(1) })
+	// An allowlisted hostname that resolves to loopback
+	u, _ := url.Parse(ts.URL)
+	srv.UpstreamBaseURL = "http://localhost:" + u.Port()
+	tenant.EgressAllowlist = []string{"localhost"}
+
+	_, err := ExecuteBalanced(context.Background(), NewBalancer(), guardedClient(), srv, tenant, testTool("t", "/x"), nil)
+	var blocked *ErrBlockedAddress
+	if !errors.As(err, &blocked) || !blocked.Addr.IsLoopback() || !errors.Is(err, ErrEgressDenied) {
+		t.Fatalf("err = %v, want a blocked loopback address matching ErrEgressDenied", err)
+	}
+	if n := hits.Load(); n != 0 {
+		t.Fatalf("upstream reached %d times", n)
+	}
+}
+
+func TestSSRFGuardAllowsExemptAddress(t *testing.T) {
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	})
+	res, err := ExecuteBalanced(context.Background(), NewBalancer(), guardedClient("127.0.0.1/32"), srv, tenant, testTool("t", "/x"), nil)
+	if err != nil || res.UpstreamStatus != http.StatusOK {
+		t.Fatalf("exempt address: res %+v, err %v", res, err)
+	}
+}
+
+func TestSSRFGuardBlockDoesNotFailOver(t *testing.T) {
+	var hits atomic.Int32
+	ts, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) { hits.Add(1) })
+	// 127.0.0.2 is loopback outside the exemption; the second upstream would be allowed
+	u, _ := url.Parse(ts.URL)
+	blockedBase := "http://127.0.0.2:" + u.Port()
+	srv.UpstreamBaseURL = ""
+	srv.UpstreamBaseURLs = []string{blockedBase, ts.URL}
+	tenant.EgressAllowlist = []string{"127.0.0.1", "127.0.0.2"}
+	lb := NewBalancer()
+
+	for i := 0; i < unhealthyAfter+1; i++ {
+		_, err := ExecuteBalanced(context.Background(), lb, guardedClient("127.0.0.1/32"), srv, tenant, testTool("t", "/x"), nil)
+		if !errors.Is(err, ErrEgressDenied) {
+			t.Fatalf("err = %v, want ErrEgressDenied", err)
+		}
+	}
+	if n := hits.Load(); n != 0 {
+		t.Fatalf("failed over to the next upstream %d times", n)
+	}
+	if _, reported := lb.health[blockedBase]; reported {
+		t.Fatal("blocked upstream was reported unhealthy")
+	}
+	if order := lb.Order(srv); !strings.HasPrefix(order[0], blockedBase) {
+		t.Fatalf("order %v, blocked upstream should keep its place", order)
+	}
+}
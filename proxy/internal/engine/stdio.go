@@ -0,0 +1,269 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"sync"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/store"
+)
+
+// BackendStdio marks servers whose tools are served by a local MCP process over stdio.
+const BackendStdio = "stdio"
+
+// ErrStdioExited is returned for calls in flight when the child process exits.
+var ErrStdioExited = errors.New("stdio server exited")
+
+// StdioRPCError is a JSON-RPC error returned by the child process.
+type StdioRPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *StdioRPCError) Error() string {
+	return fmt.Sprintf("stdio server error %d: %s", e.Code, e.Message)
+}
+
+// StdioBridge runs one long-lived child process per stdio server and multiplexes
+// JSON-RPC requests over its stdin/stdout by request id. A process that exits is
+// restarted (and re-initialized) on the next call.
+type StdioBridge struct {
+	mu    sync.Mutex
+	procs map[string]*stdioProcess
+}
+
+func NewStdioBridge() *StdioBridge {
+	return &StdioBridge{procs: make(map[string]*stdioProcess)}
+}
+
+// CallTool invokes tools/call on the server's process and returns the raw MCP result.
+func (b *StdioBridge) CallTool(ctx context.Context, srv store.Server, name string, args map[string]interface{}) (json.RawMessage, error) {
+	p, err := b.process(ctx, srv)
+	if err != nil {
+		return nil, err
+	}
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	params := map[string]interface{}{"name": name, "arguments": args}
+	if meta := childMeta(RequestMeta(ctx)); len(meta) > 0 {
+		params["_meta"] = meta
+	}
+	return p.call(ctx, "tools/call", params)
+}
+
+// gatewayMetaKeys are _meta fields the gateway acts on itself; the rest is passed to
+// stdio children.
+var gatewayMetaKeys = map[string]bool{"progressToken": true, "elicitationId": true, "idempotencyKey": true}
+
+func childMeta(meta map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for k, v := range meta {
+		if !gatewayMetaKeys[k] {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// Close terminates all child processes.
+func (b *StdioBridge) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for slug, p := range b.procs {
+		p.kill()
+		delete(b.procs, slug)
+	}
+}
+
+// process returns a live, initialized process for srv, starting one if needed. A changed
+// command line replaces the running process.
+func (b *StdioBridge) process(ctx context.Context, srv store.Server) (*stdioProcess, error) {
+	if len(srv.StdioCommand) == 0 {
+		return nil, fmt.Errorf("server %s has no stdio command", srv.Slug)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if p, ok := b.procs[srv.Slug]; ok {
+		if p.alive() && equalArgs(p.argv, srv.StdioCommand) {
+			return p, nil
+		}
+		p.kill()
+		delete(b.procs, srv.Slug)
+	}
+	p, err := startStdioProcess(srv.StdioCommand)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.initialize(ctx); err != nil {
+		p.kill()
+		return nil, fmt.Errorf("initialize stdio server %s: %w", srv.Slug, err)
+	}
+	b.procs[srv.Slug] = p
+	return p, nil
+}
+
+type stdioResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *StdioRPCError  `json:"error"`
+}
+
+type stdioProcess struct {
+	argv []string
+	cmd  *exec.Cmd
+
+	writeMu sync.Mutex
+	stdin   io.WriteCloser
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan stdioResponse
+	done    chan struct{}
+}
+
+func startStdioProcess(argv []string) (*stdioProcess, error) {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &stdioProcess{argv: append([]string(nil), argv...), cmd: cmd, stdin: stdin, pending: make(map[int64]chan stdioResponse), done: make(chan struct{})}
+	go p.readLoop(stdout)
+	return p, nil
+}
+
+// readLoop dispatches responses to waiting callers until stdout closes, then fails
+// everything still pending.
+func (p *stdioProcess) readLoop(stdout io.Reader) {
+	sc := bufio.NewScanner(stdout)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var msg struct {
+			ID *int64 `json:"id"`
+			stdioResponse
+		}
+		if err := json.Unmarshal(sc.Bytes(), &msg); err != nil || msg.ID == nil {
+			// notifications and server-initiated requests are not bridged
+			continue
+		}
+		p.mu.Lock()
+		ch, ok := p.pending[*msg.ID]
+		delete(p.pending, *msg.ID)
+		p.mu.Unlock()
+		if ok {
+			ch <- msg.stdioResponse
+		}
+	}
+	_ = p.cmd.Wait()
+	p.mu.Lock()
+	close(p.done)
+	for id, ch := range p.pending {
+		close(ch)
+		delete(p.pending, id)
+	}
+	p.mu.Unlock()
+	log.Printf("stdio server %v exited", p.argv)
+}
+
+func (p *stdioProcess) alive() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (p *stdioProcess) kill() {
+	_ = p.stdin.Close()
+	if p.cmd.Process != nil {
+		_ = p.cmd.Process.Kill()
+	}
+}
+
+func (p *stdioProcess) call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	ch := make(chan stdioResponse, 1)
+	p.mu.Lock()
+	if !p.alive() {
+		p.mu.Unlock()
+		return nil, ErrStdioExited
+	}
+	p.nextID++
+	id := p.nextID
+	p.pending[id] = ch
+	p.mu.Unlock()
+
+	if err := p.send(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}); err != nil {
+		p.forget(id)
+		return nil, err
+	}
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, ErrStdioExited
+		}
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		return resp.Result, nil
+	case <-ctx.Done():
+		p.forget(id)
+		return nil, ctx.Err()
+	}
+}
+
+func (p *stdioProcess) forget(id int64) {
+	p.mu.Lock()
+	delete(p.pending, id)
+	p.mu.Unlock()
+}
+
+func (p *stdioProcess) send(msg interface{}) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	_, err = p.stdin.Write(append(b, '\n'))
+	return err
+}
+
+// initialize performs the MCP handshake the child expects before tools/call.
+func (p *stdioProcess) initialize(ctx context.Context) error {
+	if _, err := p.call(ctx, "initialize", map[string]interface{}{
+		"protocolVersion": config.MCPProtocolVersionLatest,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]interface{}{"name": "mcp-gateway", "version": "0.1.0"},
+	}); err != nil {
+		return err
+	}
+	return p.send(map[string]interface{}{"jsonrpc": "2.0", "method": "notifications/initialized"})
+}
+
+func equalArgs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
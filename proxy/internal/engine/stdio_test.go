@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"gateway/proxy/internal/store"
+)
+
+// TestStdioHelperProcess is not a real test: it is the fake MCP server the stdio tests
+// start by re-running the test binary. Tool "echo" answers with its arguments, _meta and
+// the process id after "delayMs", concurrently, so responses can overtake each other;
+// tool "crash" exits the process.
+func TestStdioHelperProcess(t *testing.T) {
+	if os.Getenv("GATEWAY_STDIO_HELPER") != "1" {
+		return
+	}
+	var mu sync.Mutex
+	out := bufio.NewWriter(os.Stdout)
+	reply := func(id json.RawMessage, result interface{}) {
+		b, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": id, "result": result})
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = out.Write(append(b, '\n'))
+		_ = out.Flush()
+	}
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				Name      string                 `json:"name"`
+				Arguments map[string]interface{} `json:"arguments"`
+				Meta      map[string]interface{} `json:"_meta"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &msg); err != nil || msg.ID == nil {
+			continue
+		}
+		switch {
+		case msg.Method == "initialize":
+			reply(msg.ID, map[string]interface{}{"protocolVersion": "2025-06-18"})
+		case msg.Params.Name == "crash":
+			os.Exit(1)
+		default:
+			go func(id json.RawMessage, args, meta map[string]interface{}) {
+				if ms, ok := args["delayMs"].(float64); ok {
+					time.Sleep(time.Duration(ms) * time.Millisecond)
+				}
+				reply(id, map[string]interface{}{"args": args, "meta": meta, "pid": os.Getpid()})
+			}(msg.ID, msg.Params.Arguments, msg.Params.Meta)
+		}
+	}
+	os.Exit(0)
+}
+
+func stdioServer(t *testing.T) (*StdioBridge, store.Server) {
+	t.Helper()
+	t.Setenv("GATEWAY_STDIO_HELPER", "1")
+	b := NewStdioBridge()
+	t.Cleanup(b.Close)
+	return b, store.Server{Slug: "local", Backend: BackendStdio, StdioCommand: []string{os.Args[0], "-test.run=^TestStdioHelperProcess$"}}
+}
+
+type echoResult struct {
+	Args map[string]interface{} `json:"args"`
+	Meta map[string]interface{} `json:"meta"`
+	Pid  int                    `json:"pid"`
+}
+
+func callEcho(ctx context.Context, b *StdioBridge, srv store.Server, args map[string]interface{}) (echoResult, error) {
+	var res echoResult
+	raw, err := b.CallTool(ctx, srv, "echo", args)
+	if err != nil {
+		return res, err
+	}
+	err = json.Unmarshal(raw, &res)
+	return res, err
+}
+
+func TestStdioCorrelatesConcurrentCalls(t *testing.T) {
+	b, srv := stdioServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Later calls answer first; every caller must still get its own response
+	const n = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := callEcho(ctx, b, srv, map[string]interface{}{"n": float64(i), "delayMs": float64((n - i) * 20)})
+			if err == nil && res.Args["n"] != float64(i) {
+				err = fmt.Errorf("call %d got the response for %v", i, res.Args["n"])
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestStdioRestartsAfterCrash(t *testing.T) {
+	b, srv := stdioServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	first, err := callEcho(ctx, b, srv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.CallTool(ctx, srv, "crash", nil); !errors.Is(err, ErrStdioExited) {
+		t.Fatalf("crash: err = %v, want ErrStdioExited", err)
+	}
+	second, err := callEcho(ctx, b, srv, nil)
+	if err != nil {
+		t.Fatalf("call after crash: %v", err)
+	}
+	if second.Pid == first.Pid {
+		t.Fatalf("process %d was not restarted", first.Pid)
+	}
+}
+
+func TestStdioWithoutCommand(t *testing.T) {
+	b := NewStdioBridge()
+	defer b.Close()
+	if _, err := b.CallTool(context.Background(), store.Server{Slug: "local", Backend: BackendStdio}, "echo", nil); err == nil {
+		t.Fatal("expected an error for a server without a stdio command")
+	}
+}
+
+func TestStdioForwardsRequestMeta(t *testing.T) {
+	b, srv := stdioServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Fields the gateway acts on itself stay with the gateway
+	ctx = WithRequestMeta(ctx, map[string]interface{}{"progressToken": "tok-1", "idempotencyKey": "k", "traceparent": "00-abc-def-01"})
+	res, err := callEcho(ctx, b, srv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Meta) != 1 || res.Meta["traceparent"] != "00-abc-def-01" {
+		t.Fatalf("child got _meta %v, want only traceparent", res.Meta)
+	}
+}
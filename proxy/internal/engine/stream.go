@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"gateway/proxy/internal/store"
+)
+
+// Stream is an upstream response whose body is relayed to the client as it arrives
+// instead of being buffered. Body is already decoded; the caller must close it.
+type Stream struct {
+	UpstreamStatus  int
+	UpstreamHeaders http.Header
+	Body            io.ReadCloser
+	Host            string
+	Method          string
+	Path            string
+}
+
+// Streams reports whether tool responses are relayed incrementally. Tools with an output
+// schema or a response mapping and GraphQL tools need the whole body and always take the
+// buffered path.
+func Streams(tool store.Tool) bool {
+	return tool.Mapping.Stream && tool.Mapping.Type != MappingTypeGraphQL && tool.OutputSchema == nil && len(tool.Mapping.ResponseMapping) == 0
+}
+
+// ExecuteStream is ExecuteBalanced for streamed tools. Failover happens before any body is
+// read: a 5xx from an upstream with others left to try is discarded, the last is returned.
+// Responses are never cached.
+func ExecuteStream(ctx context.Context, lb *Balancer, httpClient *http.Client, srv store.Server, tenant store.Tenant, tool store.Tool, args map[string]interface{}) (*Stream, error) {
+	if lb.failFast(srv) {
+		return nil, ErrUpstreamDown
+	}
+	bases := lb.Order(srv)
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("%w: upstream base URL not configured", ErrBadMapping)
+	}
+	args, err := applyTransforms(tool.Mapping.Transforms, args)
+	if err != nil {
+		return nil, &encodeError{err: err}
+	}
+	httpClient = withRedirectPolicy(httpClient, firstNonEmpty(tool.Mapping.RedirectPolicy, srv.RedirectPolicy), tenant)
+	forwarded := claimHeaders(srv.ClaimHeaderMappings, claimsFrom(ctx))
+	var errs []error
+	for i, base := range bases {
+		st, err := streamOnce(ctx, httpClient, base, tenant, tool, args, forwarded)
+		if err != nil {
+			var enc *encodeError
+			if errors.As(err, &enc) || egressRefused(err) || ctx.Err() != nil {
+				return nil, err
+			}
+			var upstreamErr *UpstreamError
+			if errors.As(err, &upstreamErr) {
+				lb.Report(base, false)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if st.UpstreamStatus >= 500 {
+			lb.Report(base, false)
+			if i < len(bases)-1 {
+				_ = st.Body.Close()
+				continue
+			}
+			return st, nil
+		}
+		lb.Report(base, true)
+		return st, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+func streamOnce(ctx context.Context, httpClient *http.Client, baseURL string, tenant store.Tenant, tool store.Tool, args map[string]interface{}, forwarded map[string]string) (*Stream, error) {
+	req, err := newUpstreamRequest(ctx, baseURL, tenant, tool, args, forwarded)
+	if err != nil {
+		return nil, err
+	}
+	path := diagnosticPath(req.URL.Path, tool, args)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, &UpstreamError{Host: req.URL.Host, Method: req.Method, Path: path, Err: scrubURLError(err, req.URL, path)}
+	}
+	if fn := progressFrom(ctx); fn != nil {
+		resp.Body = &progressReader{ReadCloser: resp.Body, total: resp.ContentLength, fn: fn}
+	}
+	body, err := streamBody(resp)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, &UpstreamError{Host: req.URL.Host, Method: req.Method, Path: path, Status: resp.StatusCode, Err: err}
+	}
+	return &Stream{UpstreamStatus: resp.StatusCode, UpstreamHeaders: resp.Header, Body: body, Host: req.URL.Host, Method: req.Method, Path: path}, nil
+}
+
+// StatusError returns an UpstreamError when the upstream answered with a 5xx, else nil.
+// It reads at most the error snippet from Body.
+func (s *Stream) StatusError() *UpstreamError {
+	if s.UpstreamStatus < 500 {
+		return nil
+	}
+	snippet, _ := io.ReadAll(io.LimitReader(s.Body, maxErrorBodyBytes+1))
+	res := &ExecuteResult{UpstreamStatus: s.UpstreamStatus, UpstreamBody: snippet, Host: s.Host, Method: s.Method, Path: s.Path}
+	return res.StatusError()
+}
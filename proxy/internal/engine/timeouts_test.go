@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"gateway/proxy/internal/store"
+)
+
+// stalledListener is a socket listening with a zero backlog that never accepts. Once its
+// queue is full further connection attempts hang in the handshake, which is how an
+// unresponsive upstream looks to the dialer. The test is skipped where the kernel still
+// completes connections.
+func stalledListener(t *testing.T) string {
+	t.Helper()
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Skipf("socket: %v", err)
+	}
+	t.Cleanup(func() { _ = syscall.Close(fd) })
+	if err := syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Skipf("bind: %v", err)
+	}
+	if err := syscall.Listen(fd, 0); err != nil {
+		t.Skipf("listen: %v", err)
+	}
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		t.Skipf("getsockname: %v", err)
+	}
+	addr := "127.0.0.1:" + strconv.Itoa(sa.(*syscall.SockaddrInet4).Port)
+	for i := 0; i < 16; i++ {
+		c, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+		if err != nil {
+			return addr
+		}
+		t.Cleanup(func() { _ = c.Close() })
+	}
+	t.Skip("connections to a full backlog still complete here")
+	return ""
+}
+
+// silentListener accepts connections and never writes, so a TLS handshake never finishes.
+func silentListener(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		var conns []net.Conn
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				break
+			}
+			conns = append(conns, c)
+		}
+		for _, c := range conns {
+			_ = c.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// callWithTimeouts makes one call to baseURL the way the handlers do: the server's client
+// under a context bounded by its CallTimeout.
+func callWithTimeouts(baseURL string, to store.UpstreamTimeouts) (time.Duration, error) {
+	srv, tenant := testTarget(baseURL)
+	srv.Timeouts = &to
+	ctx, cancel := context.WithTimeout(context.Background(), CallTimeout(srv))
+	defer cancel()
+	start := time.Now()
+	_, err := ExecuteBalanced(ctx, nil, NewClientFactory(DefaultTransportOptions()).ServerClient(srv), srv, tenant, testTool("t", "/x"), nil)
+	return time.Since(start), err
+}
+
+func TestConnectTimeout(t *testing.T) {
+	addr := stalledListener(t)
+	elapsed, err := callWithTimeouts("http://"+addr, store.UpstreamTimeouts{ConnectMs: 200, TotalMs: 5000})
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() || !strings.Contains(err.Error(), "dial") {
+		t.Fatalf("err = %v, want a dial timeout", err)
+	}
+	if elapsed > 2*time.Second {
+		t.Fatalf("took %s, want the connect timeout rather than the total", elapsed)
+	}
+}
+
+func TestTLSHandshakeTimeout(t *testing.T) {
+	addr := silentListener(t)
+	elapsed, err := callWithTimeouts("https://"+addr, store.UpstreamTimeouts{TLSHandshakeMs: 200, TotalMs: 5000})
+	if err == nil || !strings.Contains(err.Error(), "TLS handshake timeout") {
+		t.Fatalf("err = %v, want a TLS handshake timeout", err)
+	}
+	if elapsed > 2*time.Second {
+		t.Fatalf("took %s", elapsed)
+	}
+}
+
+func TestResponseHeaderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	ts, _, _ := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer close(release)
+	elapsed, err := callWithTimeouts(ts.URL, store.UpstreamTimeouts{ResponseHeaderMs: 100, TotalMs: 5000})
+	if err == nil || !strings.Contains(err.Error(), "timeout awaiting response headers") {
+		t.Fatalf("err = %v, want a response header timeout", err)
+	}
+	if elapsed > 2*time.Second {
+		t.Fatalf("took %s", elapsed)
+	}
+}
+
+func TestTotalTimeout(t *testing.T) {
+	release := make(chan struct{})
+	ts, _, _ := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		// Headers arrive in time; the body does not
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"partial":`))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer close(release)
+	elapsed, err := callWithTimeouts(ts.URL, store.UpstreamTimeouts{ResponseHeaderMs: 1000, TotalMs: 200})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the total budget to expire", err)
+	}
+	if elapsed > 2*time.Second {
+		t.Fatalf("took %s", elapsed)
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	cases := []struct {
+		name string
+		to   *store.UpstreamTimeouts
+		want time.Duration
+	}{
+		{"unset", nil, DefaultCallTimeout},
+		{"stages only", &store.UpstreamTimeouts{ConnectMs: 100}, DefaultCallTimeout},
+		{"total", &store.UpstreamTimeouts{TotalMs: 1500}, 1500 * time.Millisecond},
+	}
+	for _, tc := range cases {
+		if got := CallTimeout(store.Server{Timeouts: tc.to}); got != tc.want {
+			t.Errorf("%s: CallTimeout = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
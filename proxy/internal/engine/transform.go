@@ -0,0 +1,518 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gateway/proxy/internal/store"
+)
+
+// Transform expressions reshape arguments before templating. The language has no loops,
+// I/O or host access: literals ("a", 'a', 12, true, null), argument names (dotted for
+// nested objects: customer.id), parentheses, !, +, ==, !=, &&, ||, ?? (first non-null),
+// cond ? a : b, and the functions upper, lower, trim, string and join(list, sep). + adds
+// two numbers and otherwise concatenates as strings (null as "").
+const (
+	// maxTransformSteps bounds evaluated nodes across all of a tool's transforms per call
+	maxTransformSteps = 1000
+	// maxTransformExprBytes bounds a single expression's source
+	maxTransformExprBytes = 2048
+	// maxTransformValueBytes bounds strings built by concatenation and join
+	maxTransformValueBytes = 64 * 1024
+)
+
+var errStepBudget = errors.New("step budget exceeded")
+
+// applyTransforms evaluates tool transforms in order on a copy of args; each result is
+// stored under its Arg, and a null result removes the argument.
+func applyTransforms(transforms []store.ArgTransform, args map[string]interface{}) (map[string]interface{}, error) {
+	if len(transforms) == 0 {
+		return args, nil
+	}
+	out := make(map[string]interface{}, len(args)+len(transforms))
+	for k, v := range args {
+		out[k] = v
+	}
+	ev := &evaluator{args: out, steps: maxTransformSteps}
+	for _, t := range transforms {
+		n, err := parseExpr(t.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("transform %s: %w", t.Arg, err)
+		}
+		v, err := ev.eval(n)
+		if err != nil {
+			return nil, fmt.Errorf("transform %s: %w", t.Arg, err)
+		}
+		if v == nil {
+			delete(out, t.Arg)
+		} else {
+			out[t.Arg] = v
+		}
+	}
+	return out, nil
+}
+
+// CompileTransforms reports the first transform that does not parse, so definitions can
+// be rejected when they are saved rather than on every call.
+func CompileTransforms(transforms []store.ArgTransform) error {
+	for _, t := range transforms {
+		if t.Arg == "" {
+			return errors.New("transform without arg")
+		}
+		if _, err := parseExpr(t.Expr); err != nil {
+			return fmt.Errorf("transform %s: %w", t.Arg, err)
+		}
+	}
+	return nil
+}
+
+// transformRefs returns the argument names transform expressions read (the first segment
+// of dotted names).
+func transformRefs(transforms []store.ArgTransform) map[string]bool {
+	refs := map[string]bool{}
+	var walk func(n *exprNode)
+	walk = func(n *exprNode) {
+		if n.kind == nodeIdent {
+			refs[strings.SplitN(n.name, ".", 2)[0]] = true
+		}
+		for _, c := range n.args {
+			walk(c)
+		}
+	}
+	for _, t := range transforms {
+		if n, err := parseExpr(t.Expr); err == nil {
+			walk(n)
+		}
+	}
+	return refs
+}
+
+type nodeKind int
+
+const (
+	nodeLiteral nodeKind = iota
+	nodeIdent
+	nodeCall
+	nodeNot
+	nodeBinary
+	nodeTernary
+)
+
+type exprNode struct {
+	kind  nodeKind
+	value interface{} // literal value
+	name  string      // identifier, function name or binary operator
+	args  []*exprNode
+}
+
+var transformFuncs = map[string]int{"upper": 1, "lower": 1, "trim": 1, "string": 1, "join": 2}
+
+// parser is a recursive descent parser over the token list; precedence from lowest:
+// ?:, ??, ||, &&, == !=, +, !.
+type parser struct {
+	toks []token
+	pos  int
+}
+
+type token struct {
+	kind string // "str", "num", "ident", "op", "eof"
+	text string
+	val  interface{}
+}
+
+func parseExpr(src string) (*exprNode, error) {
+	if len(src) > maxTransformExprBytes {
+		return nil, fmt.Errorf("expression longer than %d bytes", maxTransformExprBytes)
+	}
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	n, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != "eof" {
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == "op" && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) ternary() (*exprNode, error) {
+	cond, err := p.binary(0)
+	if err != nil || !p.accept("?") {
+		return cond, err
+	}
+	a, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept(":") {
+		return nil, errors.New(`expected ":"`)
+	}
+	b, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	return &exprNode{kind: nodeTernary, args: []*exprNode{cond, a, b}}, nil
+}
+
+var binaryLevels = [][]string{{"??"}, {"||"}, {"&&"}, {"==", "!="}, {"+"}}
+
+func (p *parser) binary(level int) (*exprNode, error) {
+	if level == len(binaryLevels) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		matched := ""
+		for _, op := range binaryLevels[level] {
+			if p.accept(op) {
+				matched = op
+				break
+			}
+		}
+		if matched == "" {
+			return left, nil
+		}
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &exprNode{kind: nodeBinary, name: matched, args: []*exprNode{left, right}}
+	}
+}
+
+func (p *parser) unary() (*exprNode, error) {
+	if p.accept("!") {
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNode{kind: nodeNot, args: []*exprNode{n}}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (*exprNode, error) {
+	t := p.peek()
+	switch t.kind {
+	case "str", "num":
+		p.pos++
+		return &exprNode{kind: nodeLiteral, value: t.val}, nil
+	case "ident":
+		p.pos++
+		switch t.text {
+		case "true":
+			return &exprNode{kind: nodeLiteral, value: true}, nil
+		case "false":
+			return &exprNode{kind: nodeLiteral, value: false}, nil
+		case "null":
+			return &exprNode{kind: nodeLiteral}, nil
+		}
+		if !p.accept("(") {
+			return &exprNode{kind: nodeIdent, name: t.text}, nil
+		}
+		arity, ok := transformFuncs[t.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %s", t.text)
+		}
+		n := &exprNode{kind: nodeCall, name: t.text}
+		for !p.accept(")") {
+			if len(n.args) > 0 && !p.accept(",") {
+				return nil, errors.New(`expected "," or ")"`)
+			}
+			arg, err := p.ternary()
+			if err != nil {
+				return nil, err
+			}
+			n.args = append(n.args, arg)
+		}
+		if len(n.args) != arity {
+			return nil, fmt.Errorf("%s takes %d arguments", t.text, arity)
+		}
+		return n, nil
+	case "op":
+		if p.accept("(") {
+			n, err := p.ternary()
+			if err != nil {
+				return nil, err
+			}
+			if !p.accept(")") {
+				return nil, errors.New(`expected ")"`)
+			}
+			return n, nil
+		}
+	case "eof":
+		return nil, errors.New("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+func tokenize(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+					switch src[j] {
+					case 'n':
+						sb.WriteByte('\n')
+					case 't':
+						sb.WriteByte('\t')
+					default:
+						sb.WriteByte(src[j])
+					}
+					continue
+				}
+				sb.WriteByte(src[j])
+			}
+			if j == len(src) {
+				return nil, errors.New("unterminated string")
+			}
+			toks = append(toks, token{kind: "str", text: src[i : j+1], val: sb.String()})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			f, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", src[i:j])
+			}
+			toks = append(toks, token{kind: "num", text: src[i:j], val: f})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			toks = append(toks, token{kind: "ident", text: src[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range []string{"??", "||", "&&", "==", "!=", "+", "!", "?", ":", "(", ")", ","} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			toks = append(toks, token{kind: "op", text: op})
+			i += len(op)
+		}
+	}
+	return append(toks, token{kind: "eof", text: "end of expression"}), nil
+}
+
+type evaluator struct {
+	args  map[string]interface{}
+	steps int
+}
+
+func (e *evaluator) eval(n *exprNode) (interface{}, error) {
+	if e.steps--; e.steps < 0 {
+		return nil, errStepBudget
+	}
+	switch n.kind {
+	case nodeLiteral:
+		return n.value, nil
+	case nodeIdent:
+		return lookupArg(e.args, n.name), nil
+	case nodeNot:
+		v, err := e.eval(n.args[0])
+		return !truthy(v), err
+	case nodeTernary:
+		cond, err := e.eval(n.args[0])
+		if err != nil {
+			return nil, err
+		}
+		if truthy(cond) {
+			return e.eval(n.args[1])
+		}
+		return e.eval(n.args[2])
+	case nodeCall:
+		vals := make([]interface{}, len(n.args))
+		for i, a := range n.args {
+			v, err := e.eval(a)
+			if err != nil {
+				return nil, err
+			}
+			vals[i] = v
+		}
+		return callTransformFunc(n.name, vals)
+	}
+	// nodeBinary; the logical operators short-circuit
+	left, err := e.eval(n.args[0])
+	if err != nil {
+		return nil, err
+	}
+	switch n.name {
+	case "??":
+		if left != nil {
+			return left, nil
+		}
+		return e.eval(n.args[1])
+	case "||":
+		if truthy(left) {
+			return true, nil
+		}
+		right, err := e.eval(n.args[1])
+		return truthy(right), err
+	case "&&":
+		if !truthy(left) {
+			return false, nil
+		}
+		right, err := e.eval(n.args[1])
+		return truthy(right), err
+	}
+	right, err := e.eval(n.args[1])
+	if err != nil {
+		return nil, err
+	}
+	switch n.name {
+	case "==":
+		return valuesEqual(left, right), nil
+	case "!=":
+		return !valuesEqual(left, right), nil
+	}
+	// "+"
+	lf, lnum := toFloat(left)
+	rf, rnum := toFloat(right)
+	if lnum && rnum {
+		return lf + rf, nil
+	}
+	s := stringify(left) + stringify(right)
+	if len(s) > maxTransformValueBytes {
+		return nil, fmt.Errorf("value longer than %d bytes", maxTransformValueBytes)
+	}
+	return s, nil
+}
+
+func callTransformFunc(name string, vals []interface{}) (interface{}, error) {
+	switch name {
+	case "upper":
+		return strings.ToUpper(stringify(vals[0])), nil
+	case "lower":
+		return strings.ToLower(stringify(vals[0])), nil
+	case "trim":
+		return strings.TrimSpace(stringify(vals[0])), nil
+	case "string":
+		return stringify(vals[0]), nil
+	}
+	// join
+	list, ok := vals[0].([]interface{})
+	if !ok {
+		if vals[0] == nil {
+			return "", nil
+		}
+		return stringify(vals[0]), nil
+	}
+	parts := make([]string, len(list))
+	for i, v := range list {
+		parts[i] = stringify(v)
+	}
+	s := strings.Join(parts, stringify(vals[1]))
+	if len(s) > maxTransformValueBytes {
+		return nil, fmt.Errorf("value longer than %d bytes", maxTransformValueBytes)
+	}
+	return s, nil
+}
+
+// lookupArg resolves a possibly dotted name against args; missing values are nil.
+func lookupArg(args map[string]interface{}, name string) interface{} {
+	var cur interface{} = args
+	for _, part := range strings.Split(name, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[part]
+	}
+	return cur
+}
+
+func truthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case string:
+		return t != ""
+	}
+	if f, ok := toFloat(v); ok {
+		return f != 0
+	}
+	return true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case json.Number:
+		f, err := t.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if af, ok := toFloat(a); ok {
+		bf, ok := toFloat(b)
+		return ok && af == bf
+	}
+	switch a.(type) {
+	case nil, string, bool:
+		return a == b
+	}
+	return stringify(a) == stringify(b)
+}
+
+func stringify(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	if f, ok := toFloat(v); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+func TestApplyTransforms(t *testing.T) {
+	args := map[string]interface{}{
+		"first":    "Ada",
+		"last":     "Lovelace",
+		"page":     float64(2),
+		"premium":  false,
+		"tags":     []interface{}{"a", "b"},
+		"customer": map[string]interface{}{"id": "c-1"},
+	}
+	transforms := []store.ArgTransform{
+		{Arg: "fullName", Expr: `first + ' ' + upper(last)`},
+		{Arg: "limit", Expr: "pageSize ?? 50"},
+		{Arg: "next", Expr: "page + 1"},
+		{Arg: "tier", Expr: "premium ? 'gold' : null"},
+		{Arg: "tagList", Expr: `join(tags, ",")`},
+		{Arg: "ref", Expr: `"cust-" + customer.id`},
+		// Later transforms see earlier results
+		{Arg: "label", Expr: `lower(fullName) + (tier == null ? '' : '*')`},
+		{Arg: "first", Expr: "null"},
+	}
+	got, err := applyTransforms(transforms, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"fullName": "Ada LOVELACE",
+		"limit":    float64(50),
+		"next":     float64(3),
+		"tagList":  "a,b",
+		"ref":      "cust-c-1",
+		"label":    "ada lovelace",
+	}
+	for k, v := range want {
+		if !reflect.DeepEqual(got[k], v) {
+			t.Errorf("%s = %#v, want %#v", k, got[k], v)
+		}
+	}
+	for _, k := range []string{"tier", "first"} {
+		if _, ok := got[k]; ok {
+			t.Errorf("%s = %#v, want it removed", k, got[k])
+		}
+	}
+	if args["first"] != "Ada" || args["fullName"] != nil {
+		t.Fatal("caller's arguments were modified")
+	}
+
+	// A provided value wins over the ?? fallback
+	got, err = applyTransforms([]store.ArgTransform{{Arg: "limit", Expr: "pageSize ?? 50"}}, map[string]interface{}{"pageSize": float64(10)})
+	if err != nil || got["limit"] != float64(10) {
+		t.Fatalf("limit = %v, err %v", got["limit"], err)
+	}
+}
+
+func TestTransformStepBudget(t *testing.T) {
+	// Each expression fits the size limit, but together they evaluate more nodes than the budget allows
+	expr := "a" + strings.Repeat(" + a", 300)
+	transforms := []store.ArgTransform{{Arg: "x", Expr: expr}, {Arg: "y", Expr: expr}}
+	if err := CompileTransforms(transforms); err != nil {
+		t.Fatal(err)
+	}
+	_, err := applyTransforms(transforms, map[string]interface{}{"a": "x"})
+	if !errors.Is(err, errStepBudget) {
+		t.Fatalf("err = %v, want the step budget exceeded", err)
+	}
+	if _, err := applyTransforms(transforms[:1], map[string]interface{}{"a": "x"}); err != nil {
+		t.Fatalf("one expression: %v", err)
+	}
+}
+
+func TestCompileTransformsRejects(t *testing.T) {
+	cases := map[string]store.ArgTransform{
+		"missing arg":      {Expr: "a"},
+		"syntax":           {Arg: "x", Expr: "a +"},
+		"unknown function": {Arg: "x", Expr: "exec('ls')"},
+		"arity":            {Arg: "x", Expr: "upper(a, b)"},
+		"too long":         {Arg: "x", Expr: strings.Repeat("a+", maxTransformExprBytes)},
+	}
+	for name, tr := range cases {
+		if err := CompileTransforms([]store.ArgTransform{tr}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestTransformsBeforeTemplating(t *testing.T) {
+	var path string
+	_, srv, tenant := testUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.RequestURI()
+		_, _ = w.Write([]byte(`{}`))
+	})
+	tool := testTool("find_person", "/people/{{slug}}")
+	tool.Mapping.Query = map[string]string{"limit": "{{limit}}"}
+	tool.Mapping.Transforms = []store.ArgTransform{
+		{Arg: "slug", Expr: `lower(first) + '-' + lower(last)`},
+		{Arg: "limit", Expr: "limit ?? 20"},
+	}
+	if _, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, tool, map[string]interface{}{"first": "Ada", "last": "Lovelace"}); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/people/ada-lovelace?limit=20" {
+		t.Fatalf("upstream got %s", path)
+	}
+
+	// A failing evaluation is a bad mapping and never reaches the upstream
+	path = ""
+	tool.Mapping.Transforms = []store.ArgTransform{{Arg: "slug", Expr: "a" + strings.Repeat("+a", 1000)}}
+	if _, err := ExecuteBalanced(context.Background(), NewBalancer(), http.DefaultClient, srv, tenant, tool, nil); !errors.Is(err, ErrBadMapping) {
+		t.Fatalf("err = %v, want ErrBadMapping", err)
+	}
+	if path != "" {
+		t.Fatalf("upstream reached with %s", path)
+	}
+}
@@ -0,0 +1,205 @@
+package events
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Notification is a server-initiated JSON-RPC notification destined for MCP sessions.
+type Notification struct {
+	Method string      `json:"method"`
+	Params interface{} `json:"params,omitempty"`
+}
+
+const ToolsListChanged = "notifications/tools/list_changed"
+
+const Progress = "notifications/progress"
+
+// queueSize bounds the notifications held for a parked session; older sessions drop the
+// overflow (list_changed is idempotent anyway).
+const queueSize = 16
+
+// defaultStreamBuffer bounds undelivered notifications per open stream.
+const defaultStreamBuffer = 64
+
+// ErrSlowConsumer is returned by unsubscribe when the bus closed the stream because the
+// client stopped reading and its buffer filled up.
+var ErrSlowConsumer = errors.New("notification stream closed: client too slow")
+
+// parkTTL is how long notifications are held for a session that has no open stream.
+const parkTTL = 5 * time.Minute
+
+// parked holds notifications for a session between streams, e.g. after initialize and
+// before its first GET, or while a client reconnects.
+type parked struct {
+	queue []Notification
+	until time.Time
+}
+
+// subscription is an open stream's queue; slow is set when the bus closed it on overflow.
+type subscription struct {
+	ch   chan Notification
+	slow bool
+}
+
+// Bus fans notifications out to the sessions bound to a server.
+type Bus struct {
+	mu     sync.Mutex
+	subs   map[string]map[string]*subscription // server slug -> session id -> open stream
+	parked map[string]map[string]*parked       // same keys, sessions without a stream
+	buffer int
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[string]map[string]*subscription), parked: make(map[string]map[string]*parked), buffer: defaultStreamBuffer}
+}
+
+// SetStreamBuffer sets how many undelivered notifications an open stream may hold before
+// it is closed as a slow consumer; n <= 0 keeps the default. It applies to new streams.
+func (b *Bus) SetStreamBuffer(n int) {
+	if n <= 0 {
+		n = defaultStreamBuffer
+	}
+	b.mu.Lock()
+	b.buffer = n
+	b.mu.Unlock()
+}
+
+// Park starts holding notifications for a session that has no stream yet; the next
+// Subscribe for it receives them first. Held notifications expire after parkTTL.
+func (b *Bus) Park(serverSlug, sessionID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[serverSlug][sessionID]; !ok {
+		b.parkLocked(serverSlug, sessionID)
+	}
+}
+
+func (b *Bus) parkLocked(serverSlug, sessionID string) {
+	if b.parked[serverSlug] == nil {
+		b.parked[serverSlug] = make(map[string]*parked)
+	}
+	// Sweep sessions that never came back so the map stays bounded without publishes
+	now := time.Now()
+	for id, p := range b.parked[serverSlug] {
+		if now.After(p.until) {
+			delete(b.parked[serverSlug], id)
+		}
+	}
+	if p, ok := b.parked[serverSlug][sessionID]; ok {
+		p.until = time.Now().Add(parkTTL)
+		return
+	}
+	b.parked[serverSlug][sessionID] = &parked{until: time.Now().Add(parkTTL)}
+}
+
+// holdLocked queues n for a parked session, dropping it when the session's backlog is full.
+func (b *Bus) holdLocked(serverSlug, sessionID string, p *parked, now time.Time, n Notification) {
+	if now.After(p.until) {
+		delete(b.parked[serverSlug], sessionID)
+		if len(b.parked[serverSlug]) == 0 {
+			delete(b.parked, serverSlug)
+		}
+		return
+	}
+	if len(p.queue) < queueSize {
+		p.queue = append(p.queue, n)
+	}
+}
+
+// Subscribe registers a session's stream on a server, starting with any notifications held
+// while the session was parked. A repeated subscription for the same session replaces the
+// previous queue. The returned func unsubscribes and parks the session again.
+//
+// Publishers never wait for a stream: when its buffer is full the bus closes the channel,
+// parks the session with the notification that did not fit, and unsubscribe then returns
+// ErrSlowConsumer so the stream can be ended with an error.
+func (b *Bus) Subscribe(serverSlug, sessionID string) (<-chan Notification, func() error) {
+	b.mu.Lock()
+	sub := &subscription{ch: make(chan Notification, b.buffer)}
+	if p, ok := b.parked[serverSlug][sessionID]; ok {
+		if time.Now().Before(p.until) {
+			for _, n := range p.queue {
+				select {
+				case sub.ch <- n:
+				default:
+				}
+			}
+		}
+		delete(b.parked[serverSlug], sessionID)
+		if len(b.parked[serverSlug]) == 0 {
+			delete(b.parked, serverSlug)
+		}
+	}
+	if b.subs[serverSlug] == nil {
+		b.subs[serverSlug] = make(map[string]*subscription)
+	}
+	if old, ok := b.subs[serverSlug][sessionID]; ok {
+		close(old.ch)
+	}
+	b.subs[serverSlug][sessionID] = sub
+	b.mu.Unlock()
+	return sub.ch, func() error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if sub.slow {
+			return ErrSlowConsumer
+		}
+		if cur, ok := b.subs[serverSlug][sessionID]; ok && cur == sub {
+			b.dropLocked(serverSlug, sessionID, sub)
+		}
+		return nil
+	}
+}
+
+// dropLocked closes an open stream and parks its session again.
+func (b *Bus) dropLocked(serverSlug, sessionID string, sub *subscription) {
+	delete(b.subs[serverSlug], sessionID)
+	close(sub.ch)
+	if len(b.subs[serverSlug]) == 0 {
+		delete(b.subs, serverSlug)
+	}
+	b.parkLocked(serverSlug, sessionID)
+}
+
+// sendLocked queues n on an open stream. When the stream's buffer is full it is closed as
+// a slow consumer and false is returned; the session is parked, so the caller holds n.
+func (b *Bus) sendLocked(serverSlug, sessionID string, sub *subscription, n Notification) bool {
+	select {
+	case sub.ch <- n:
+		return true
+	default:
+	}
+	sub.slow = true
+	b.dropLocked(serverSlug, sessionID, sub)
+	return false
+}
+
+// Publish queues n for every session subscribed to serverSlug without blocking.
+func (b *Bus) Publish(serverSlug string, n Notification) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Sessions whose stream overflows are parked here and picked up by the loop below
+	for sessionID, sub := range b.subs[serverSlug] {
+		b.sendLocked(serverSlug, sessionID, sub, n)
+	}
+	now := time.Now()
+	for sessionID, p := range b.parked[serverSlug] {
+		b.holdLocked(serverSlug, sessionID, p, now, n)
+	}
+}
+
+// PublishTo queues n for a single session of serverSlug without blocking.
+func (b *Bus) PublishTo(serverSlug, sessionID string, n Notification) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if sub, ok := b.subs[serverSlug][sessionID]; ok {
+		if b.sendLocked(serverSlug, sessionID, sub, n) {
+			return
+		}
+	}
+	if p, ok := b.parked[serverSlug][sessionID]; ok {
+		b.holdLocked(serverSlug, sessionID, p, time.Now(), n)
+	}
+}
@@ -0,0 +1,118 @@
+package events
+
+import (
+	"errors"
+	"testing"
+)
+
+// drain returns the methods queued on ch without blocking.
+func drain(ch <-chan Notification) []string {
+	var methods []string
+	for {
+		select {
+		case n, ok := <-ch:
+			if !ok {
+				return methods
+			}
+			methods = append(methods, n.Method)
+		default:
+			return methods
+		}
+	}
+}
+
+func TestPublishReachesOnlyServerSessions(t *testing.T) {
+	b := NewBus()
+	a1, unsubA1 := b.Subscribe("orders", "s1")
+	defer unsubA1()
+	a2, unsubA2 := b.Subscribe("orders", "s2")
+	defer unsubA2()
+	other, unsubOther := b.Subscribe("billing", "s3")
+	defer unsubOther()
+
+	b.Publish("orders", Notification{Method: ToolsListChanged})
+	for name, ch := range map[string]<-chan Notification{"s1": a1, "s2": a2} {
+		if got := drain(ch); len(got) != 1 || got[0] != ToolsListChanged {
+			t.Fatalf("%s got %v", name, got)
+		}
+	}
+	if got := drain(other); len(got) != 0 {
+		t.Fatalf("billing session got %v", got)
+	}
+
+	b.PublishTo("orders", "s2", Notification{Method: Progress})
+	if got := drain(a1); len(got) != 0 {
+		t.Fatalf("s1 got %v", got)
+	}
+	if got := drain(a2); len(got) != 1 || got[0] != Progress {
+		t.Fatalf("s2 got %v", got)
+	}
+}
+
+func TestParkedSessionReceivesOnSubscribe(t *testing.T) {
+	b := NewBus()
+	b.Park("orders", "s1")
+	b.Publish("orders", Notification{Method: ToolsListChanged})
+	b.Publish("billing", Notification{Method: ToolsListChanged})
+
+	ch, unsub := b.Subscribe("orders", "s1")
+	if got := drain(ch); len(got) != 1 || got[0] != ToolsListChanged {
+		t.Fatalf("got %v", got)
+	}
+	// Unsubscribing parks the session again
+	if err := unsub(); err != nil {
+		t.Fatal(err)
+	}
+	b.Publish("orders", Notification{Method: ToolsListChanged})
+	ch, unsub = b.Subscribe("orders", "s1")
+	defer unsub()
+	if got := drain(ch); len(got) != 1 {
+		t.Fatalf("after reconnect got %v", got)
+	}
+}
+
+func TestParkedQueueIsBounded(t *testing.T) {
+	b := NewBus()
+	b.Park("orders", "s1")
+	for i := 0; i < queueSize+10; i++ {
+		b.Publish("orders", Notification{Method: ToolsListChanged})
+	}
+	ch, unsub := b.Subscribe("orders", "s1")
+	defer unsub()
+	if got := drain(ch); len(got) != queueSize {
+		t.Fatalf("held %d notifications, want %d", len(got), queueSize)
+	}
+}
+
+func TestSlowConsumerClosesStream(t *testing.T) {
+	b := NewBus()
+	b.SetStreamBuffer(2)
+	ch, unsub := b.Subscribe("orders", "s1")
+	other, unsubOther := b.Subscribe("orders", "s2")
+	defer unsubOther()
+
+	// The third notification does not fit: the stream is closed rather than the publisher
+	// waiting, and the notification is held for the session's next stream
+	b.Publish("orders", Notification{Method: ToolsListChanged})
+	b.Publish("orders", Notification{Method: ToolsListChanged})
+	drain(other)
+	b.Publish("orders", Notification{Method: Progress})
+	if got := drain(ch); len(got) != 2 {
+		t.Fatalf("got %v, want the two buffered notifications", got)
+	}
+	if _, ok := <-ch; ok {
+		t.Fatal("stream still open after its buffer overflowed")
+	}
+	if err := unsub(); !errors.Is(err, ErrSlowConsumer) {
+		t.Fatalf("unsubscribe err = %v, want ErrSlowConsumer", err)
+	}
+	if got := drain(other); len(got) != 1 {
+		t.Fatalf("a stream that keeps up got %v", got)
+	}
+
+	ch, unsub = b.Subscribe("orders", "s1")
+	defer unsub()
+	if got := drain(ch); len(got) != 1 || got[0] != Progress {
+		t.Fatalf("after reconnect got %v", got)
+	}
+}
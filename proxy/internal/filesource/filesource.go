@@ -0,0 +1,283 @@
+package filesource
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+
+	"gateway/proxy/internal/events"
+	"gateway/proxy/internal/store"
+)
+
+// Precedence decides who owns a server defined both in a file and through the control plane.
+type Precedence string
+
+const (
+	// PrecedenceFile re-applies files on every change and rejects control-plane writes to
+	// the servers they define
+	PrecedenceFile Precedence = "file"
+	// PrecedenceAPI only creates servers and tools that do not exist yet; existing ones
+	// belong to the control plane and are never overwritten from files
+	PrecedenceAPI Precedence = "api"
+)
+
+// reloadDelay coalesces the burst of events editors and ConfigMap updates produce.
+const reloadDelay = 250 * time.Millisecond
+
+// Definition is the content of one file: an optional server and its tools. Files that
+// only add tools to an existing server name it with serverSlug instead.
+type Definition struct {
+	Server     *store.Server `json:"server,omitempty"`
+	ServerSlug string        `json:"serverSlug,omitempty"`
+	Tools      []store.Tool  `json:"tools,omitempty"`
+}
+
+func (d Definition) slug() string {
+	if d.Server != nil {
+		return d.Server.Slug
+	}
+	return d.ServerSlug
+}
+
+// Store is the subset of store methods the loader applies definitions through.
+type Store interface {
+	GetServer(slug string) (store.Server, error)
+	UpsertServer(store.Server) error
+	UpsertToolsForServer(serverSlug string, tools []store.Tool) error
+	ListToolDefinitions(serverSlug string) ([]store.Tool, error)
+}
+
+// Loader applies *.yaml, *.yml and *.json definitions from a directory and re-applies
+// them when the directory changes. Files are read in name order; when several define
+// the same server, the later server definition wins and tools are merged by name.
+// Removing a file does not delete what it defined.
+type Loader struct {
+	dir        string
+	store      Store
+	bus        *events.Bus
+	precedence Precedence
+
+	mu      sync.RWMutex
+	managed map[string]bool
+
+	watcher *fsnotify.Watcher
+}
+
+func NewLoader(dir string, s Store, bus *events.Bus, precedence Precedence) *Loader {
+	if precedence != PrecedenceAPI {
+		precedence = PrecedenceFile
+	}
+	return &Loader{dir: dir, store: s, bus: bus, precedence: precedence, managed: make(map[string]bool)}
+}
+
+// Managed reports whether serverSlug is defined by a file and owned by it (PrecedenceFile).
+func (l *Loader) Managed(serverSlug string) bool {
+	if l.precedence != PrecedenceFile {
+		return false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.managed[serverSlug]
+}
+
+// Load reads and applies the whole directory. Files that fail to parse and servers that
+// fail to apply are logged and skipped so one bad definition does not block the rest;
+// only an unreadable directory is returned as an error.
+func (l *Loader) Load() error {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return err
+	}
+	names := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && isDefinitionFile(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	merged := map[string]*Definition{}
+	order := []string{}
+	for _, name := range names {
+		d, err := readDefinition(filepath.Join(l.dir, name))
+		if err != nil {
+			log.Printf("tool definitions: skipping %s: %v", name, err)
+			continue
+		}
+		slug := d.slug()
+		if slug == "" {
+			log.Printf("tool definitions: skipping %s: server.slug or serverSlug required", name)
+			continue
+		}
+		m, ok := merged[slug]
+		if !ok {
+			m = &Definition{ServerSlug: slug}
+			merged[slug] = m
+			order = append(order, slug)
+		}
+		if d.Server != nil {
+			m.Server = d.Server
+		}
+		m.Tools = mergeTools(m.Tools, d.Tools)
+	}
+
+	managed := make(map[string]bool, len(order))
+	for _, slug := range order {
+		managed[slug] = true
+		if err := l.apply(*merged[slug]); err != nil {
+			log.Printf("tool definitions: applying server %s: %v", slug, err)
+		}
+	}
+	l.mu.Lock()
+	l.managed = managed
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *Loader) apply(d Definition) error {
+	slug := d.slug()
+	_, getErr := l.store.GetServer(slug)
+	exists := getErr == nil
+	if d.Server != nil && (l.precedence == PrecedenceFile || !exists) {
+		if err := l.store.UpsertServer(*d.Server); err != nil {
+			return err
+		}
+		exists = true
+	}
+	if !exists {
+		return errors.New("server not found")
+	}
+	if len(d.Tools) == 0 {
+		return nil
+	}
+	tools := d.Tools
+	if l.precedence == PrecedenceAPI {
+		// Keep existing definitions and only add tools the control plane has not defined
+		existing, err := l.store.ListToolDefinitions(slug)
+		if err != nil {
+			return err
+		}
+		tools = mergeTools(d.Tools, existing)
+		if len(tools) == len(existing) {
+			return nil
+		}
+	}
+	if err := l.store.UpsertToolsForServer(slug, tools); err != nil {
+		return err
+	}
+	l.bus.Publish(slug, events.Notification{Method: events.ToolsListChanged})
+	return nil
+}
+
+// Watch re-applies the directory after changes until Close is called.
+func (l *Loader) Watch() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// Watch the directory rather than the files so new files and atomic renames are seen
+	if err := w.Add(l.dir); err != nil {
+		_ = w.Close()
+		return err
+	}
+	l.watcher = w
+	go func() {
+		var timer *time.Timer
+		var fire <-chan time.Time
+		for {
+			select {
+			case _, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if timer == nil {
+					timer = time.NewTimer(reloadDelay)
+				} else {
+					timer.Reset(reloadDelay)
+				}
+				fire = timer.C
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("tool definitions: watch error: %v", err)
+			case <-fire:
+				fire = nil
+				if err := l.Load(); err != nil {
+					log.Printf("tool definitions: reload failed: %v", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops watching the directory.
+func (l *Loader) Close() {
+	if l.watcher != nil {
+		_ = l.watcher.Close()
+	}
+}
+
+// mergeTools returns base with over's tools replacing same-named ones or appended.
+func mergeTools(base, over []store.Tool) []store.Tool {
+	out := make([]store.Tool, 0, len(base)+len(over))
+	index := map[string]int{}
+	for _, t := range base {
+		index[t.Name] = len(out)
+		out = append(out, t)
+	}
+	for _, t := range over {
+		if i, ok := index[t.Name]; ok {
+			out[i] = t
+			continue
+		}
+		index[t.Name] = len(out)
+		out = append(out, t)
+	}
+	return out
+}
+
+func isDefinitionFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// readDefinition decodes a file; YAML is re-decoded through JSON so the json tags on
+// store types apply to both formats.
+func readDefinition(path string) (Definition, error) {
+	var d Definition
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return d, err
+	}
+	if strings.ToLower(filepath.Ext(path)) != ".json" {
+		var doc interface{}
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return d, fmt.Errorf("invalid yaml: %w", err)
+		}
+		if b, err = json.Marshal(doc); err != nil {
+			return d, err
+		}
+	}
+	if err := json.Unmarshal(b, &d); err != nil {
+		return d, fmt.Errorf("invalid definition: %w", err)
+	}
+	return d, nil
+}
@@ -0,0 +1,205 @@
+package filesource
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"gateway/proxy/internal/events"
+	"gateway/proxy/internal/handlers"
+	"gateway/proxy/internal/store"
+)
+
+const ordersYAML = `server:
+  slug: orders
+  tenantSlug: acme
+  name: Orders
+  enabled: true
+  upstreamBaseUrl: https://orders.example.com
+tools:
+  - name: get_order
+    mapping:
+      method: GET
+      path: /orders/{{id}}
+`
+
+// controlStore adapts the memory store to handlers.ControlStore for Guard.
+type controlStore struct{ *store.MemoryStore }
+
+func (controlStore) UpdateServerOpenAPI(string, []byte, string) error { return nil }
+
+func newStore(t *testing.T) *store.MemoryStore {
+	t.Helper()
+	s := store.NewMemoryStore("https://api.example.com")
+	if err := s.UpsertTenant(store.Tenant{Slug: "acme", Name: "Acme", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func toolNames(t *testing.T, s *store.MemoryStore, server string) string {
+	t.Helper()
+	tools, err := s.ListToolDefinitions(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = tool.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func TestLoadDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "10-orders.yaml", ordersYAML)
+	// A later file adds tools to the same server and replaces get_order by name
+	writeFile(t, dir, "20-orders-extra.json", `{"serverSlug":"orders","tools":[
+		{"name":"list_orders","mapping":{"method":"GET","path":"/orders"}},
+		{"name":"get_order","mapping":{"method":"GET","path":"/v2/orders/{{id}}"}}]}`)
+	writeFile(t, dir, "30-broken.yaml", "server: [")
+	writeFile(t, dir, ".hidden.json", `{"serverSlug":"orders","tools":[{"name":"hidden"}]}`)
+	writeFile(t, dir, "notes.txt", "not a definition")
+	s := newStore(t)
+
+	if err := NewLoader(dir, s, events.NewBus(), PrecedenceFile).Load(); err != nil {
+		t.Fatal(err)
+	}
+	srv, err := s.GetServer("orders")
+	if err != nil || srv.UpstreamBaseURL != "https://orders.example.com" {
+		t.Fatalf("server %+v, err %v", srv, err)
+	}
+	if got := toolNames(t, s, "orders"); got != "get_order,list_orders" {
+		t.Fatalf("tools %s", got)
+	}
+	tool, err := s.GetToolByName("orders", "get_order")
+	if err != nil || tool.Mapping.Path != "/v2/orders/{{id}}" {
+		t.Fatalf("get_order %+v, err %v", tool.Mapping, err)
+	}
+}
+
+func TestLoadMissingDirectory(t *testing.T) {
+	l := NewLoader(filepath.Join(t.TempDir(), "missing"), newStore(t), events.NewBus(), PrecedenceFile)
+	if err := l.Load(); err == nil {
+		t.Fatal("expected an error for a missing directory")
+	}
+}
+
+func TestWatchReappliesChanges(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "orders.yaml", ordersYAML)
+	s := newStore(t)
+	bus := events.NewBus()
+	l := NewLoader(dir, s, bus, PrecedenceFile)
+	if err := l.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Watch(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	notes, cancel := bus.Subscribe("orders", "sess-1")
+	defer cancel()
+
+	writeFile(t, dir, "orders.yaml", ordersYAML+`  - name: cancel_order
+    mapping:
+      method: DELETE
+      path: /orders/{{id}}
+`)
+	deadline := time.Now().Add(5 * time.Second)
+	for toolNames(t, s, "orders") != "get_order,cancel_order" {
+		if time.Now().After(deadline) {
+			t.Fatalf("tools %s after the file changed", toolNames(t, s, "orders"))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	select {
+	case n := <-notes:
+		if n.Method != events.ToolsListChanged {
+			t.Fatalf("notification %s", n.Method)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no tools/list_changed notification")
+	}
+}
+
+func TestPrecedenceAPIKeepsExisting(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "orders.yaml", ordersYAML+`  - name: list_orders
+    mapping:
+      method: GET
+      path: /orders
+`)
+	s := newStore(t)
+	if err := s.UpsertServer(store.Server{Slug: "orders", TenantSlug: "acme", Name: "Orders", Enabled: true, UpstreamBaseURL: "https://api.orders.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertToolsForServer("orders", []store.Tool{{Name: "get_order", Mapping: store.RequestTemplate{Method: http.MethodGet, Path: "/api/orders/{{id}}"}}}); err != nil {
+		t.Fatal(err)
+	}
+	l := NewLoader(dir, s, events.NewBus(), PrecedenceAPI)
+	if err := l.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, _ := s.GetServer("orders")
+	if srv.UpstreamBaseURL != "https://api.orders.example.com" {
+		t.Fatalf("file overwrote the server: %s", srv.UpstreamBaseURL)
+	}
+	if tool, _ := s.GetToolByName("orders", "get_order"); tool.Mapping.Path != "/api/orders/{{id}}" {
+		t.Fatalf("file overwrote get_order: %s", tool.Mapping.Path)
+	}
+	if got := toolNames(t, s, "orders"); got != "get_order,list_orders" {
+		t.Fatalf("tools %s, want the file's new tool added", got)
+	}
+	if l.Managed("orders") {
+		t.Fatal("nothing is file-managed under api precedence")
+	}
+}
+
+func TestGuardRejectsManagedWrites(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "orders.yaml", ordersYAML)
+	s := newStore(t)
+	l := NewLoader(dir, s, events.NewBus(), PrecedenceFile)
+	if err := l.Load(); err != nil {
+		t.Fatal(err)
+	}
+	cs := l.Guard(controlStore{s})
+
+	if err := cs.UpsertServer(store.Server{Slug: "orders", TenantSlug: "acme", Name: "Orders"}); !errors.Is(err, store.ErrManagedByFile) {
+		t.Fatalf("UpsertServer err = %v, want ErrManagedByFile", err)
+	}
+	if err := cs.SetToolsEnabled("orders", []string{"get_order"}, false); !errors.Is(err, store.ErrManagedByFile) {
+		t.Fatalf("SetToolsEnabled err = %v, want ErrManagedByFile", err)
+	}
+	if err := cs.UpsertServerBundle(store.Server{Slug: "orders", TenantSlug: "acme", Name: "Orders"}, nil); !errors.Is(err, store.ErrManagedByFile) {
+		t.Fatalf("UpsertServerBundle err = %v, want ErrManagedByFile", err)
+	}
+	if err := cs.UpsertServer(store.Server{Slug: "billing", TenantSlug: "acme", Name: "Billing"}); err != nil {
+		t.Fatalf("unmanaged server: %v", err)
+	}
+
+	// The control plane answers a rejected write with 409
+	r := chi.NewRouter()
+	r.Post("/servers/{server}/tools", handlers.UpsertToolsHandler(cs, events.NewBus()))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/servers/orders/tools", strings.NewReader(`{"tools":[{"name":"x","mapping":{"method":"GET","path":"/x"}}]}`)))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status %d, want 409", rec.Code)
+	}
+}
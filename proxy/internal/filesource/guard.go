@@ -0,0 +1,55 @@
+package filesource
+
+import (
+	"gateway/proxy/internal/handlers"
+	"gateway/proxy/internal/store"
+)
+
+// guardedStore rejects control-plane writes to servers owned by definition files.
+type guardedStore struct {
+	handlers.ControlStore
+	loader *Loader
+}
+
+// Guard wraps cs so that writes to file-managed servers fail with store.ErrManagedByFile.
+// Under PrecedenceAPI nothing is file-managed and every write passes through.
+func (l *Loader) Guard(cs handlers.ControlStore) handlers.ControlStore {
+	return guardedStore{ControlStore: cs, loader: l}
+}
+
+func (g guardedStore) UpsertServer(s store.Server) error {
+	if g.loader.Managed(s.Slug) {
+		return store.ErrManagedByFile
+	}
+	return g.ControlStore.UpsertServer(s)
+}
+
+func (g guardedStore) UpsertToolsForServer(serverSlug string, tools []store.Tool) error {
+	if g.loader.Managed(serverSlug) {
+		return store.ErrManagedByFile
+	}
+	return g.ControlStore.UpsertToolsForServer(serverSlug, tools)
+}
+
+func (g guardedStore) UpsertServerBundle(s store.Server, tools []store.Tool) error {
+	if g.loader.Managed(s.Slug) {
+		return store.ErrManagedByFile
+	}
+	return g.ControlStore.UpsertServerBundle(s, tools)
+}
+
+func (g guardedStore) SetToolsEnabled(serverSlug string, names []string, enabled bool) error {
+	if g.loader.Managed(serverSlug) {
+		return store.ErrManagedByFile
+	}
+	return g.ControlStore.SetToolsEnabled(serverSlug, names, enabled)
+}
+
+func (g guardedStore) ImportTenant(exp store.TenantExport) error {
+	for _, se := range exp.Servers {
+		if g.loader.Managed(se.Server.Slug) {
+			return store.ErrManagedByFile
+		}
+	}
+	return g.ControlStore.ImportTenant(exp)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"gateway/proxy/internal/auth"
+)
+
+// adminAPI serves the admin token routes behind the admin token middleware, as cmd/proxy does.
+func adminAPI(tokens *auth.AdminTokens) http.Handler {
+	mux := chi.NewRouter()
+	mux.Get("/api/admin-tokens", ListAdminTokensHandler(tokens))
+	mux.Post("/api/admin-tokens", AddAdminTokenHandler(tokens))
+	mux.Delete("/api/admin-tokens/{id}", RevokeAdminTokenHandler(tokens))
+	return auth.AdminTokenMiddleware(tokens)(mux)
+}
+
+func adminRequest(h http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	if token != "" {
+		req.Header.Set("X-Admin-Token", token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminTokensRotation(t *testing.T) {
+	tokens := auth.NewAdminTokens(strings.Split("old-secret, second-secret", ","))
+	api := adminAPI(tokens)
+
+	for _, token := range []string{"old-secret", "second-secret"} {
+		if rec := adminRequest(api, http.MethodGet, "/api/admin-tokens", token, ""); rec.Code != http.StatusOK {
+			t.Fatalf("token %q: status %d", token, rec.Code)
+		}
+	}
+	if rec := adminRequest(api, http.MethodGet, "/api/admin-tokens", "wrong", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("wrong token: status %d, want 403", rec.Code)
+	}
+
+	rec := adminRequest(api, http.MethodPost, "/api/admin-tokens", "old-secret", `{"label":"ci"}`)
+	var added struct {
+		ID    string `json:"id"`
+		Label string `json:"label"`
+		Token string `json:"token"`
+	}
+	if rec.Code != http.StatusCreated || json.Unmarshal(rec.Body.Bytes(), &added) != nil || added.Token == "" || added.Label != "ci" {
+		t.Fatalf("add: %d %s", rec.Code, rec.Body)
+	}
+	if rec := adminRequest(api, http.MethodGet, "/api/admin-tokens", added.Token, ""); rec.Code != http.StatusOK {
+		t.Fatalf("new token: status %d", rec.Code)
+	}
+
+	rec = adminRequest(api, http.MethodGet, "/api/admin-tokens", added.Token, "")
+	for _, secret := range []string{"old-secret", "second-secret", added.Token} {
+		if strings.Contains(rec.Body.String(), secret) {
+			t.Fatalf("list exposes a secret: %s", rec.Body)
+		}
+	}
+	var list struct {
+		Tokens []auth.AdminTokenInfo `json:"tokens"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list.Tokens) != 3 {
+		t.Fatalf("list %s", rec.Body)
+	}
+
+	// Revoking the env tokens locks them out while the new one keeps working
+	for _, info := range list.Tokens {
+		if info.ID == added.ID {
+			continue
+		}
+		if rec := adminRequest(api, http.MethodDelete, "/api/admin-tokens/"+info.ID, added.Token, ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("revoke %s: status %d", info.Label, rec.Code)
+		}
+	}
+	if rec := adminRequest(api, http.MethodGet, "/api/admin-tokens", "old-secret", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("revoked token: status %d, want 403", rec.Code)
+	}
+	if rec := adminRequest(api, http.MethodDelete, "/api/admin-tokens/"+added.ID, added.Token, ""); rec.Code != http.StatusConflict {
+		t.Fatalf("revoking the last token: status %d, want 409", rec.Code)
+	}
+	if rec := adminRequest(api, http.MethodDelete, "/api/admin-tokens/missing", added.Token, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("revoking an unknown token: status %d, want 404", rec.Code)
+	}
+}
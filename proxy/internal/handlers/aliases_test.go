@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestToolsCallByAlias(t *testing.T) {
+	g := newTestGateway(t)
+	seen := make(chan string, 1)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.URL.Path
+		_, _ = w.Write([]byte(`{}`))
+	})
+	tool := getTool("get_order", "/orders/{{id}}")
+	tool.Aliases = []string{"fetch_order"}
+	g.tools(t, tool)
+	sid := g.initialize(t)
+
+	if status, _ := toolResult(t, g.call(t, sid, "tools/call", map[string]interface{}{"name": "fetch_order", "arguments": map[string]interface{}{"id": "7"}})); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if got := <-seen; got != "/orders/7" {
+		t.Fatalf("upstream got %s", got)
+	}
+
+	// tools/list advertises the canonical name only
+	resp := g.call(t, sid, "tools/list", nil)
+	var page struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+	}
+	if err := json.Unmarshal(resp.Result, &page); err != nil || len(page.Tools) != 1 || page.Tools[0].Name != "get_order" {
+		t.Fatalf("tools/list %s, err %v", resp.Result, err)
+	}
+	if strings.Contains(string(resp.Result), "fetch_order") {
+		t.Fatalf("tools/list exposes the alias: %s", resp.Result)
+	}
+}
+
+func TestUpsertToolsRejectsAliasCollisions(t *testing.T) {
+	g := newTestGateway(t)
+	g.tools(t, getTool("get_order", "/orders/{{id}}"))
+	api := controlAPI(newControlStore(g.store), g.bus)
+
+	cases := []struct {
+		name, body         string
+		invalid, duplicate []string
+	}{
+		{
+			name:      "alias of another tool",
+			body:      `{"tools":[{"name":"get_order","mapping":{"method":"GET","path":"/o"}},{"name":"list_orders","aliases":["get_order"],"mapping":{"method":"GET","path":"/orders"}}]}`,
+			duplicate: []string{"get_order"},
+		},
+		{
+			name:      "alias on two tools",
+			body:      `{"tools":[{"name":"a","aliases":["shared"],"mapping":{"method":"GET","path":"/a"}},{"name":"b","aliases":["shared"],"mapping":{"method":"GET","path":"/b"}}]}`,
+			duplicate: []string{"shared"},
+		},
+		{
+			name:    "invalid alias",
+			body:    `{"tools":[{"name":"a","aliases":["old name"],"mapping":{"method":"GET","path":"/a"}}]}`,
+			invalid: []string{"old name"},
+		},
+	}
+	for _, tc := range cases {
+		rec := adminRequest(api, http.MethodPost, "/api/servers/orders/tools", "", tc.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status %d, want 400", tc.name, rec.Code)
+		}
+		var body struct {
+			InvalidNames   []string `json:"invalidNames"`
+			DuplicateNames []string `json:"duplicateNames"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v: %s", tc.name, err, rec.Body)
+		}
+		if strings.Join(body.InvalidNames, ",") != strings.Join(tc.invalid, ",") || strings.Join(body.DuplicateNames, ",") != strings.Join(tc.duplicate, ",") {
+			t.Fatalf("%s: body %s", tc.name, rec.Body)
+		}
+	}
+	if got := toolNamesOf(t, g.store); got != "get_order" {
+		t.Fatalf("tools after rejected upserts: %s", got)
+	}
+}
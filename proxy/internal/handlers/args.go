@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gateway/proxy/internal/store"
+)
+
+// argsError describes arguments rejected before dispatch; it maps to JSON-RPC -32602.
+type argsError struct {
+	Message string   `json:"-"`
+	Unknown []string `json:"unknown,omitempty"`
+	Missing []string `json:"missing,omitempty"`
+	// property name -> declared type the string value could not be converted to
+	Invalid map[string]string `json:"invalid,omitempty"`
+}
+
+func (e *argsError) Error() string { return e.Message }
+
+// coerceArgs applies the tool's defaults and the lightweight parts of its input schema
+// before templating: stripping (or in strict mode rejecting) unknown properties when
+// additionalProperties is false, filling absent arguments from defaults and then from
+// schema defaults, converting string values of number, integer and boolean properties to
+// their declared type, and required-property checks. Unknown-property checks only apply
+// to the caller's arguments. The returned map is a copy; the caller's args are not modified.
+func coerceArgs(schema map[string]interface{}, defaults map[string]interface{}, args map[string]interface{}, strict bool) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(args)+len(defaults))
+	for k, v := range args {
+		out[k] = v
+	}
+	props, _ := schema["properties"].(map[string]interface{})
+
+	if ap, ok := schema["additionalProperties"].(bool); ok && !ap {
+		unknown := []string{}
+		for k := range out {
+			if _, known := props[k]; !known {
+				unknown = append(unknown, k)
+			}
+		}
+		sort.Strings(unknown)
+		if len(unknown) > 0 {
+			if strict {
+				return nil, &argsError{Message: "invalid params: unknown arguments: " + strings.Join(unknown, ", "), Unknown: unknown}
+			}
+			for _, k := range unknown {
+				delete(out, k)
+			}
+		}
+	}
+
+	for name, def := range defaults {
+		if _, present := out[name]; !present {
+			out[name] = def
+		}
+	}
+	if schema == nil {
+		return out, nil
+	}
+	for name, p := range props {
+		if _, present := out[name]; present {
+			continue
+		}
+		if pm, ok := p.(map[string]interface{}); ok {
+			if def, ok := pm["default"]; ok {
+				out[name] = def
+			}
+		}
+	}
+
+	invalid := map[string]string{}
+	for name, v := range out {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		pm, _ := props[name].(map[string]interface{})
+		typ := scalarType(pm["type"])
+		if typ == "" {
+			continue
+		}
+		if cv, ok := convertString(str, typ); ok {
+			out[name] = cv
+		} else {
+			invalid[name] = typ
+		}
+	}
+	if len(invalid) > 0 {
+		names := make([]string, 0, len(invalid))
+		for k := range invalid {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return nil, &argsError{Message: "invalid params: wrong argument types: " + strings.Join(names, ", "), Invalid: invalid}
+	}
+
+	if missing := missingRequired(schema, out); len(missing) > 0 {
+		return nil, &argsError{Message: "invalid params: missing required arguments: " + strings.Join(missing, ", "), Missing: missing}
+	}
+	return out, nil
+}
+
+// scalarType returns the number, integer or boolean type a property declares, if any.
+// Union types such as ["integer","null"] count when they allow exactly one of these and
+// no string, since a string value is then already valid as-is.
+func scalarType(t interface{}) string {
+	switch tt := t.(type) {
+	case string:
+		if tt == "number" || tt == "integer" || tt == "boolean" {
+			return tt
+		}
+	case []interface{}:
+		found := ""
+		for _, x := range tt {
+			name, _ := x.(string)
+			switch name {
+			case "string":
+				return ""
+			case "number", "integer", "boolean":
+				if found != "" {
+					return ""
+				}
+				found = name
+			}
+		}
+		return found
+	}
+	return ""
+}
+
+// convertString parses s as the given scalar type.
+func convertString(s string, typ string) (interface{}, bool) {
+	s = strings.TrimSpace(s)
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, true
+		}
+	case "number":
+		// NaN and Inf parse but cannot be encoded as JSON
+		if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f, true
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// missingRequired lists required properties absent from args, in schema order.
+func missingRequired(schema map[string]interface{}, args map[string]interface{}) []string {
+	missing := []string{}
+	switch req := schema["required"].(type) {
+	case []interface{}:
+		for _, r := range req {
+			if name, ok := r.(string); ok {
+				if _, present := args[name]; !present {
+					missing = append(missing, name)
+				}
+			}
+		}
+	case []string:
+		for _, name := range req {
+			if _, present := args[name]; !present {
+				missing = append(missing, name)
+			}
+		}
+	}
+	return missing
+}
+
+// buildElicitation describes the missing fields in the shape of an MCP elicitation/create
+// request. The client answers by repeating tools/call with the values in arguments and
+// the id in _meta.elicitationId.
+func buildElicitation(id string, tool store.Tool, missing []string) map[string]interface{} {
+	props, _ := tool.InputSchema["properties"].(map[string]interface{})
+	requested := map[string]interface{}{}
+	for _, name := range missing {
+		if p, ok := props[name]; ok {
+			requested[name] = p
+		} else {
+			requested[name] = map[string]interface{}{"type": "string"}
+		}
+	}
+	return map[string]interface{}{
+		"id":      id,
+		"method":  "elicitation/create",
+		"message": "Please provide the missing arguments for " + firstNonEmpty(tool.Title, tool.Name) + ": " + strings.Join(missing, ", "),
+		"requestedSchema": map[string]interface{}{
+			"type":       "object",
+			"properties": requested,
+			"required":   missing,
+		},
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/store"
+)
+
+// closedSchema declares limit (default 10) and q (required), and no other properties.
+func closedSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"q":     map[string]interface{}{"type": "string"},
+			"limit": map[string]interface{}{"type": "integer", "default": float64(10)},
+		},
+		"required":             []interface{}{"q"},
+		"additionalProperties": false,
+	}
+}
+
+func TestCoerceArgsStripsUnknown(t *testing.T) {
+	args := map[string]interface{}{"q": "shoes", "debug": true, "limit": float64(5)}
+	got, err := coerceArgs(closedSchema(), nil, args, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"q": "shoes", "limit": float64(5)}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if _, ok := args["debug"]; !ok {
+		t.Fatal("caller's arguments were modified")
+	}
+
+	// Without additionalProperties:false unknown arguments are kept
+	open := closedSchema()
+	delete(open, "additionalProperties")
+	if got, err := coerceArgs(open, nil, args, true); err != nil || got["debug"] != true {
+		t.Fatalf("open schema: got %v, err %v", got, err)
+	}
+}
+
+func TestCoerceArgsStrictRejectsUnknown(t *testing.T) {
+	_, err := coerceArgs(closedSchema(), nil, map[string]interface{}{"q": "shoes", "zeta": 1, "alpha": 2}, true)
+	var ae *argsError
+	if !errors.As(err, &ae) {
+		t.Fatalf("err = %v, want *argsError", err)
+	}
+	if !reflect.DeepEqual(ae.Unknown, []string{"alpha", "zeta"}) {
+		t.Fatalf("unknown = %v", ae.Unknown)
+	}
+}
+
+func TestCoerceArgsFillsDefaults(t *testing.T) {
+	got, err := coerceArgs(closedSchema(), nil, map[string]interface{}{"q": "shoes"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["limit"] != float64(10) {
+		t.Fatalf("limit = %v, want schema default 10", got["limit"])
+	}
+	got, _ = coerceArgs(closedSchema(), nil, map[string]interface{}{"q": "shoes", "limit": float64(3)}, false)
+	if got["limit"] != float64(3) {
+		t.Fatalf("limit = %v, want caller value 3", got["limit"])
+	}
+
+	_, err = coerceArgs(closedSchema(), nil, map[string]interface{}{}, false)
+	var ae *argsError
+	if !errors.As(err, &ae) || !reflect.DeepEqual(ae.Missing, []string{"q"}) {
+		t.Fatalf("err = %v, want missing q", err)
+	}
+}
+
+func TestToolsCallCoercesArgs(t *testing.T) {
+	prev := config.StrictToolArgs
+	t.Cleanup(func() { config.StrictToolArgs = prev })
+	g := newTestGateway(t)
+	seen := make(chan string, 1)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.URL.RawQuery
+		_, _ = w.Write([]byte(`{}`))
+	})
+	tool := store.Tool{Name: "search", InputSchema: closedSchema(), Mapping: store.RequestTemplate{Method: http.MethodGet, Path: "/search", Query: map[string]string{"q": "{{q}}", "limit": "{{limit}}"}}}
+	g.tools(t, tool)
+	sid := g.initialize(t)
+
+	config.StrictToolArgs = false
+	status, _ := toolResult(t, g.call(t, sid, "tools/call", map[string]interface{}{"name": "search", "arguments": map[string]interface{}{"q": "shoes", "debug": true}}))
+	if status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if q := <-seen; q != "limit=10&q=shoes" {
+		t.Fatalf("upstream query %q, want the default limit filled in", q)
+	}
+
+	config.StrictToolArgs = true
+	resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "search", "arguments": map[string]interface{}{"q": "shoes", "debug": true}})
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Fatalf("strict: got %+v, want -32602", resp)
+	}
+	var data struct {
+		Unknown []string `json:"unknown"`
+	}
+	if err := json.Unmarshal(resp.Error.Data, &data); err != nil || !reflect.DeepEqual(data.Unknown, []string{"debug"}) {
+		t.Fatalf("strict: data %s", resp.Error.Data)
+	}
+	select {
+	case q := <-seen:
+		t.Fatalf("rejected call reached the upstream with %q", q)
+	default:
+	}
+}
+
+// typedSchema declares qty (integer), price (number) and gift (boolean).
+func typedSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"qty":   map[string]interface{}{"type": "integer"},
+			"price": map[string]interface{}{"type": "number"},
+			"gift":  map[string]interface{}{"type": "boolean"},
+			"note":  map[string]interface{}{"type": "string"},
+			"ref":   map[string]interface{}{"type": []interface{}{"integer", "null"}},
+		},
+	}
+}
+
+func TestCoerceArgsConvertsStrings(t *testing.T) {
+	got, err := coerceArgs(typedSchema(), nil, map[string]interface{}{"qty": "42", "price": " 9.5 ", "gift": "true", "note": "42", "ref": "7"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"qty": int64(42), "price": 9.5, "gift": true, "note": "42", "ref": int64(7)}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+	// Values that already have the declared type pass through
+	if got, err := coerceArgs(typedSchema(), nil, map[string]interface{}{"qty": float64(3), "gift": false}, false); err != nil || got["qty"] != float64(3) || got["gift"] != false {
+		t.Fatalf("got %v, err %v", got, err)
+	}
+}
+
+func TestCoerceArgsRejectsBadConversions(t *testing.T) {
+	_, err := coerceArgs(typedSchema(), nil, map[string]interface{}{"qty": "abc", "price": "NaN", "gift": "yes", "note": "fine"}, false)
+	var ae *argsError
+	if !errors.As(err, &ae) {
+		t.Fatalf("err = %v, want *argsError", err)
+	}
+	if want := map[string]string{"qty": "integer", "price": "number", "gift": "boolean"}; !reflect.DeepEqual(ae.Invalid, want) {
+		t.Fatalf("invalid = %v, want %v", ae.Invalid, want)
+	}
+}
+
+func TestToolsCallSendsTypedBody(t *testing.T) {
+	g := newTestGateway(t)
+	seen := make(chan map[string]interface{}, 1)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		seen <- body
+		_, _ = w.Write([]byte(`{}`))
+	})
+	tool := store.Tool{
+		Name:        "create_order",
+		InputSchema: typedSchema(),
+		Mapping: store.RequestTemplate{Method: http.MethodPost, Path: "/orders", Body: map[string]interface{}{
+			"qty": "{{qty}}", "gift": "{{gift}}", "label": "order of {{qty}}",
+		}},
+	}
+	g.tools(t, tool)
+	sid := g.initialize(t)
+
+	if status, _ := toolResult(t, g.call(t, sid, "tools/call", map[string]interface{}{"name": "create_order", "arguments": map[string]interface{}{"qty": "42", "gift": "true"}})); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	want := map[string]interface{}{"qty": float64(42), "gift": true, "label": "order of 42"}
+	if got := <-seen; !reflect.DeepEqual(got, want) {
+		t.Fatalf("upstream body %v, want %v", got, want)
+	}
+
+	resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "create_order", "arguments": map[string]interface{}{"qty": "abc"}})
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Fatalf("got %+v, want -32602", resp)
+	}
+	var data struct {
+		Invalid map[string]string `json:"invalid"`
+	}
+	if err := json.Unmarshal(resp.Error.Data, &data); err != nil || data.Invalid["qty"] != "integer" {
+		t.Fatalf("data %s", resp.Error.Data)
+	}
+}
+
+func TestCoerceArgsToolDefaults(t *testing.T) {
+	defaults := map[string]interface{}{"limit": "25", "sort": "newest"}
+	got, err := coerceArgs(closedSchema(), defaults, map[string]interface{}{"q": "shoes"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tool defaults win over schema defaults and take the schema's type; defaults outside a
+	// closed schema are not the caller's unknown arguments
+	if want := map[string]interface{}{"q": "shoes", "limit": int64(25), "sort": "newest"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+	got, err = coerceArgs(closedSchema(), defaults, map[string]interface{}{"q": "shoes", "limit": float64(3)}, false)
+	if err != nil || got["limit"] != float64(3) {
+		t.Fatalf("limit = %v, err %v, want caller value 3", got["limit"], err)
+	}
+	// Without a schema defaults are used as given
+	if got, err := coerceArgs(nil, defaults, nil, false); err != nil || got["limit"] != "25" {
+		t.Fatalf("no schema: got %v, err %v", got, err)
+	}
+}
+
+func TestToolsCallUsesToolDefaults(t *testing.T) {
+	g := newTestGateway(t)
+	seen := make(chan string, 1)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.URL.RequestURI()
+		_, _ = w.Write([]byte(`{}`))
+	})
+	tool := store.Tool{
+		Name:     "list_orders",
+		Defaults: map[string]interface{}{"status": "open", "pageSize": float64(50)},
+		Mapping:  store.RequestTemplate{Method: http.MethodGet, Path: "/orders/{{status}}", Query: map[string]string{"pageSize": "{{pageSize}}"}},
+	}
+	g.tools(t, tool)
+	sid := g.initialize(t)
+
+	cases := []struct {
+		name string
+		args map[string]interface{}
+		want string
+	}{
+		{"defaults", nil, "/orders/open?pageSize=50"},
+		{"overridden", map[string]interface{}{"status": "closed", "pageSize": float64(10)}, "/orders/closed?pageSize=10"},
+	}
+	for _, tc := range cases {
+		if status, _ := toolResult(t, g.call(t, sid, "tools/call", map[string]interface{}{"name": "list_orders", "arguments": tc.args})); status != http.StatusOK {
+			t.Fatalf("%s: status %d", tc.name, status)
+		}
+		if got := <-seen; got != tc.want {
+			t.Errorf("%s: upstream got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
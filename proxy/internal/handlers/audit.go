@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"gateway/proxy/internal/auth"
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/store"
+)
+
+// auditToolCall logs who called which tool with which arguments, with the values of the
+// tool's sensitive arguments redacted. The request id ties it to the request log line;
+// a progressToken or traceparent from the request _meta ties it to the client's view.
+func auditToolCall(r *http.Request, serverSlug, tenantSlug, sessionID string, tool store.Tool, args map[string]interface{}, meta map[string]interface{}) {
+	level := slog.LevelDebug
+	if config.AuditToolCalls {
+		level = slog.LevelInfo
+	}
+	ctx := r.Context()
+	if !slog.Default().Enabled(ctx, level) {
+		return
+	}
+	claims, _ := auth.ClaimsFromContext(ctx)
+	sub, _ := claims["sub"].(string)
+	attrs := []interface{}{
+		"request_id", middleware.GetReqID(ctx),
+		"server", serverSlug,
+		"tenant", tenantSlug,
+		"session_id", sessionID,
+		"subject", sub,
+		"tool", tool.Name,
+		"arguments", tool.RedactArgs(args),
+	}
+	if token, ok := meta["progressToken"]; ok {
+		attrs = append(attrs, "progress_token", token)
+	}
+	if tp, ok := meta["traceparent"].(string); ok {
+		attrs = append(attrs, "traceparent", tp)
+	}
+	slog.Default().Log(ctx, level, "tool call", attrs...)
+}
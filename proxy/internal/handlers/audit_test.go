@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/store"
+)
+
+func withAudit(t *testing.T) {
+	t.Helper()
+	prev := config.AuditToolCalls
+	t.Cleanup(func() { config.AuditToolCalls = prev })
+	config.AuditToolCalls = true
+}
+
+// sensitiveTool marks ssn through SensitiveArgs and token through the schema extension.
+func sensitiveTool(path string) store.Tool {
+	tool := getTool("verify_person", path)
+	tool.SensitiveArgs = []string{"ssn"}
+	tool.InputSchema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{
+		"ssn":     map[string]interface{}{"type": "string"},
+		"token":   map[string]interface{}{"type": "string", "x-sensitive": true},
+		"orderId": map[string]interface{}{"type": "string"},
+	}}
+	return tool
+}
+
+func TestAuditRedactsSensitiveArgs(t *testing.T) {
+	withAudit(t)
+	logs := captureLogs(t)
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(`{}`)) })
+	g.tools(t, sensitiveTool("/people/verify"))
+	sid := g.initialize(t)
+
+	args := map[string]interface{}{"ssn": "123-45-6789", "token": "tok-secret", "orderId": "42"}
+	toolResult(t, g.call(t, sid, "tools/call", map[string]interface{}{"name": "verify_person", "arguments": args}))
+
+	var entry struct {
+		Tool      string                 `json:"tool"`
+		Arguments map[string]interface{} `json:"arguments"`
+	}
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, `"msg":"tool call"`) {
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if entry.Tool != "verify_person" {
+		t.Fatalf("no audit entry in %s", logs)
+	}
+	want := map[string]interface{}{"ssn": store.Redacted, "token": store.Redacted, "orderId": "42"}
+	for k, v := range want {
+		if entry.Arguments[k] != v {
+			t.Errorf("argument %s logged as %v, want %v", k, entry.Arguments[k], v)
+		}
+	}
+	for _, secret := range []string{"123-45-6789", "tok-secret"} {
+		if strings.Contains(logs.String(), secret) {
+			t.Fatalf("logs contain %q", secret)
+		}
+	}
+}
+
+func TestUpstreamErrorRedactsSensitivePath(t *testing.T) {
+	withVerbosity(t, config.ErrorVerbosityDebug)
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "lookup failed", http.StatusInternalServerError)
+	})
+	g.tools(t, sensitiveTool("/people/{{ssn}}/orders/{{orderId}}"))
+	sid := g.initialize(t)
+
+	resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "verify_person", "arguments": map[string]interface{}{"ssn": "123-45-6789", "orderId": "42"}})
+	if resp.Error == nil {
+		t.Fatalf("result %s, want an upstream error", resp.Result)
+	}
+	var data upstreamErrorData
+	if err := json.Unmarshal(resp.Error.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Path != "/people/***/orders/42" {
+		t.Fatalf("path %q", data.Path)
+	}
+	if strings.Contains(string(resp.Error.Data), "123-45-6789") {
+		t.Fatalf("error data leaks the ssn: %s", resp.Error.Data)
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"gateway/proxy/internal/config"
+)
+
+// isBatch reports whether the request body is a JSON array. It leaves the body readable
+// from the start.
+func isBatch(r *http.Request) bool {
+	if r.Method != http.MethodPost || r.Body == nil {
+		return false
+	}
+	br := bufio.NewReader(r.Body)
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{br, r.Body}
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return false
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = br.ReadByte()
+		default:
+			return b[0] == '['
+		}
+	}
+}
+
+// serveBatch runs each entry of a JSON-RPC batch through single, at most
+// config.BatchMaxParallel at a time, and answers with the responses in request order.
+// Empty batches and batches over config.BatchMaxSize get a single -32600 error.
+// Every entry carries the request's own context, so per-call timeouts apply to each
+// entry separately. A batch of notifications only gets 202.
+func serveBatch(single http.HandlerFunc, w http.ResponseWriter, r *http.Request) {
+	var entries []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&entries); err != nil {
+		writeRPCError(w, nil, -32700, "parse error", nil)
+		return
+	}
+	if len(entries) == 0 {
+		writeRPCError(w, nil, -32600, "invalid request", "empty batch")
+		return
+	}
+	if config.BatchMaxSize > 0 && len(entries) > config.BatchMaxSize {
+		writeRPCError(w, nil, -32600, "invalid request", fmt.Sprintf("batch of %d entries exceeds the limit of %d", len(entries), config.BatchMaxSize))
+		return
+	}
+
+	parallel := config.BatchMaxParallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	sem := make(chan struct{}, parallel)
+	responses := make([]json.RawMessage, len(entries))
+	var wg sync.WaitGroup
+	for i, entry := range entries {
+		var probe struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		_ = json.Unmarshal(entry, &probe)
+		if probe.Method == "initialize" {
+			// The session id header of one entry could not be told apart from another's
+			responses[i], _ = json.Marshal(jsonRPCResponse{JSONRPC: "2.0", ID: probe.ID, Error: &jsonRPCError{Code: -32600, Message: "invalid request", Data: "initialize must not be part of a batch"}})
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, entry json.RawMessage) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer func() {
+				// A streamed result aborts with http.ErrAbortHandler; keep it to this entry
+				if p := recover(); p != nil {
+					slog.ErrorContext(r.Context(), "batch entry failed", "panic", p)
+					responses[i], _ = json.Marshal(jsonRPCResponse{JSONRPC: "2.0", ID: probe.ID, Error: &jsonRPCError{Code: -32603, Message: "internal error"}})
+				}
+			}()
+			responses[i] = dispatchBuffered(single, r, entry)
+		}(i, entry)
+	}
+	wg.Wait()
+
+	out := make([]json.RawMessage, 0, len(responses))
+	for _, resp := range responses {
+		if resp != nil {
+			out = append(out, resp)
+		}
+	}
+	if len(out) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// dispatchBuffered runs one JSON-RPC message through the POST handler with r's headers
+// and context, and returns the response to relay (nil for notifications).
+func dispatchBuffered(h http.Handler, r *http.Request, msg []byte) json.RawMessage {
+	req := r.Clone(r.Context())
+	req.Body = io.NopCloser(bytes.NewReader(msg))
+	req.ContentLength = int64(len(msg))
+	rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK, body: &bytes.Buffer{}}
+	h.ServeHTTP(rec, req)
+	return relayedMessage(rec, msg)
+}
+
+// relayedMessage turns a buffered response to msg into the JSON-RPC message to relay:
+// nil for notifications, and HTTP-level rejections become -32600 errors.
+func relayedMessage(rec *bufferedResponse, msg []byte) json.RawMessage {
+	switch {
+	case rec.status == http.StatusAccepted:
+		return nil
+	case rec.status >= 400:
+		var probe struct {
+			ID json.RawMessage `json:"id"`
+		}
+		_ = json.Unmarshal(msg, &probe)
+		b, _ := json.Marshal(jsonRPCResponse{JSONRPC: "2.0", ID: probe.ID, Error: &jsonRPCError{Code: -32600, Message: strings.TrimSpace(rec.body.String())}})
+		return b
+	default:
+		return bytes.TrimSpace(rec.body.Bytes())
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gateway/proxy/internal/config"
+)
+
+func withBatchParallel(t *testing.T, n int) {
+	t.Helper()
+	prev := config.BatchMaxParallel
+	t.Cleanup(func() { config.BatchMaxParallel = prev })
+	config.BatchMaxParallel = n
+}
+
+// slowGateway serves tool sleep, whose upstream answers after {{ms}} milliseconds with
+// the delay it slept.
+func slowGateway(t *testing.T) (*testGateway, string) {
+	t.Helper()
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		ms, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/sleep/"))
+		time.Sleep(time.Duration(ms) * time.Millisecond)
+		_, _ = w.Write([]byte(`{"slept":` + strconv.Itoa(ms) + `}`))
+	})
+	g.tools(t, getTool("sleep", "/sleep/{{ms}}"))
+	return g, g.initialize(t)
+}
+
+// postBatch sends entries as one JSON-RPC batch and decodes the response array.
+func (g *testGateway) postBatch(t *testing.T, sid string, entries ...string) (*http.Response, []rpcResponse) {
+	t.Helper()
+	resp := g.post(t, sid, "["+strings.Join(entries, ",")+"]")
+	raw, _ := io.ReadAll(resp.Body)
+	var out []rpcResponse
+	if resp.StatusCode == http.StatusOK {
+		if err := json.Unmarshal(raw, &out); err != nil {
+			t.Fatalf("batch response %q: %v", raw, err)
+		}
+	}
+	return resp, out
+}
+
+func sleepCall(id, ms int) string {
+	return `{"jsonrpc":"2.0","id":` + strconv.Itoa(id) + `,"method":"tools/call","params":{"name":"sleep","arguments":{"ms":"` + strconv.Itoa(ms) + `"}}}`
+}
+
+func TestBatchRunsCallsInParallel(t *testing.T) {
+	withBatchParallel(t, 4)
+	g, sid := slowGateway(t)
+
+	start := time.Now()
+	_, out := g.postBatch(t, sid, sleepCall(1, 400), sleepCall(2, 200), sleepCall(3, 300))
+	elapsed := time.Since(start)
+	if elapsed >= 800*time.Millisecond {
+		t.Fatalf("batch took %v, want about the slowest call (400ms)", elapsed)
+	}
+	// Responses keep request order although the first call finishes last
+	if len(out) != 3 {
+		t.Fatalf("%d responses", len(out))
+	}
+	for i, want := range []string{`{"slept":400}`, `{"slept":200}`, `{"slept":300}`} {
+		if string(out[i].ID) != strconv.Itoa(i+1) {
+			t.Fatalf("response %d has id %s", i, out[i].ID)
+		}
+		if _, data := toolResult(t, out[i]); string(data) != want {
+			t.Fatalf("response %d: %s, want %s", i, data, want)
+		}
+	}
+}
+
+func TestBatchParallelismIsBounded(t *testing.T) {
+	withBatchParallel(t, 1)
+	g, sid := slowGateway(t)
+
+	start := time.Now()
+	_, out := g.postBatch(t, sid, sleepCall(1, 150), sleepCall(2, 150), sleepCall(3, 150))
+	if elapsed := time.Since(start); elapsed < 450*time.Millisecond {
+		t.Fatalf("batch took %v with one call at a time, want at least the sum (450ms)", elapsed)
+	}
+	if len(out) != 3 {
+		t.Fatalf("%d responses", len(out))
+	}
+}
+
+func TestBatchEntries(t *testing.T) {
+	g, sid := slowGateway(t)
+
+	// Notifications get no response entry; initialize cannot be batched
+	_, out := g.postBatch(t, sid,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":"a","method":"initialize","params":{}}`,
+		sleepCall(2, 0),
+	)
+	if len(out) != 2 {
+		t.Fatalf("%d responses, want 2", len(out))
+	}
+	if string(out[0].ID) != `"a"` || out[0].Error == nil || out[0].Error.Code != -32600 {
+		t.Fatalf("initialize entry %+v", out[0])
+	}
+	if string(out[1].ID) != "2" || out[1].Error != nil {
+		t.Fatalf("tools/call entry %+v", out[1])
+	}
+
+	if resp, _ := g.postBatch(t, sid, `{"jsonrpc":"2.0","method":"notifications/initialized"}`); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("notification-only batch: status %d, want 202", resp.StatusCode)
+	}
+	var empty rpcResponse
+	if err := json.NewDecoder(g.post(t, sid, "[]").Body).Decode(&empty); err != nil || empty.Error == nil || empty.Error.Code != -32600 {
+		t.Fatalf("empty batch: %+v, err %v", empty, err)
+	}
+}
+
+func TestBatchMaxSize(t *testing.T) {
+	prev := config.BatchMaxSize
+	t.Cleanup(func() { config.BatchMaxSize = prev })
+	config.BatchMaxSize = 3
+	g, sid := slowGateway(t)
+	var hits atomic.Int32
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	_, out := g.postBatch(t, sid, sleepCall(1, 0), sleepCall(2, 0), sleepCall(3, 0))
+	if len(out) != 3 || hits.Load() != 3 {
+		t.Fatalf("at the limit: %d responses, %d upstream calls, want 3 each", len(out), hits.Load())
+	}
+
+	var over rpcResponse
+	resp := g.post(t, sid, "["+strings.Join([]string{sleepCall(1, 0), sleepCall(2, 0), sleepCall(3, 0), sleepCall(4, 0)}, ",")+"]")
+	if err := json.NewDecoder(resp.Body).Decode(&over); err != nil {
+		t.Fatal(err)
+	}
+	if over.Error == nil || over.Error.Code != -32600 || string(over.ID) != "null" || !strings.Contains(string(over.Error.Data), "exceeds the limit of 3") {
+		t.Fatalf("over the limit: %+v", over)
+	}
+	if n := hits.Load(); n != 3 {
+		t.Fatalf("over-limit batch made %d upstream calls, want none", n-3)
+	}
+
+	// Zero disables the limit
+	config.BatchMaxSize = 0
+	if _, out := g.postBatch(t, sid, sleepCall(1, 0), sleepCall(2, 0), sleepCall(3, 0), sleepCall(4, 0)); len(out) != 4 {
+		t.Fatalf("without a limit: %d responses", len(out))
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"gateway/proxy/internal/events"
+)
+
+func TestUpsertServerBundleEndpoint(t *testing.T) {
+	g := newTestGateway(t)
+	g.tools(t, getTool("get_order", "/orders/{{id}}"))
+	api := controlAPI(newControlStore(g.store), g.bus)
+	stream := g.openStream(t, "orders", g.initialize(t))
+
+	const server = `"server":{"tenantSlug":"acme","name":"Orders v2","audience":"https://api.example.com","enabled":true}`
+	rec := adminRequest(api, http.MethodPost, "/api/servers/orders/bundle", "", `{`+server+`,"tools":[{"name":"get_order","mapping":{"method":"GET","path":"/v2/orders/{{id}}"}},{"name":"list_orders","mapping":{"method":"GET","path":"/v2/orders"}}]}`)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if srv, _ := g.store.GetServer("orders"); srv.Name != "Orders v2" || toolNamesOf(t, g.store) != "get_order,list_orders" {
+		t.Fatalf("server %q with tools %s", srv.Name, toolNamesOf(t, g.store))
+	}
+	if m := nextMethod(t, stream, 2*time.Second); m != events.ToolsListChanged {
+		t.Fatalf("session got %q, want %s", m, events.ToolsListChanged)
+	}
+
+	// Any rejected part leaves the server and its tools untouched
+	cases := []struct{ name, body string }{
+		{"bad tool name", `{"server":{"tenantSlug":"acme","name":"Orders v3","audience":"https://api.example.com"},"tools":[{"name":"get order","mapping":{"method":"GET","path":"/x"}}]}`},
+		{"alias collision", `{"server":{"tenantSlug":"acme","name":"Orders v3","audience":"https://api.example.com"},"tools":[{"name":"a","mapping":{"method":"GET","path":"/a"}},{"name":"b","aliases":["a"],"mapping":{"method":"GET","path":"/b"}}]}`},
+		{"bad transform", `{"server":{"tenantSlug":"acme","name":"Orders v3","audience":"https://api.example.com"},"tools":[{"name":"a","mapping":{"method":"GET","path":"/a","transforms":[{"arg":"slug","expr":"first +"}]}}]}`},
+		{"invalid server", `{"server":{"tenantSlug":"acme","name":"Orders v3"},"tools":[]}`},
+		{"slug mismatch", `{"server":{"slug":"billing","tenantSlug":"acme","name":"Orders v3","audience":"https://api.example.com"},"tools":[]}`},
+	}
+	for _, tc := range cases {
+		if rec := adminRequest(api, http.MethodPost, "/api/servers/orders/bundle", "", tc.body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400: %s", tc.name, rec.Code, rec.Body)
+		}
+	}
+	if srv, _ := g.store.GetServer("orders"); srv.Name != "Orders v2" || toolNamesOf(t, g.store) != "get_order,list_orders" {
+		t.Fatalf("after rejected bundles: server %q with tools %s", srv.Name, toolNamesOf(t, g.store))
+	}
+	if m := nextMethod(t, stream, 100*time.Millisecond); m != "" {
+		t.Fatalf("session got %q after rejected bundles", m)
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestToolsCallParamShapes(t *testing.T) {
+	logs := captureLogs(t)
+	g := newTestGateway(t)
+	seen := make(chan string, 1)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.URL.Path
+		_, _ = w.Write([]byte(`{}`))
+	})
+	tool := getTool("get_order", "/orders/{{id}}")
+	tool.ID = "tool-1"
+	g.tools(t, tool)
+	sid := g.initialize(t)
+
+	cases := []struct {
+		name       string
+		params     map[string]interface{}
+		want       string
+		deprecated bool
+	}{
+		{"spec", map[string]interface{}{"name": "get_order", "arguments": map[string]interface{}{"id": "1"}}, "/orders/1", false},
+		{"legacy by name", map[string]interface{}{"toolId": "get_order", "args": map[string]interface{}{"id": "2"}}, "/orders/2", true},
+		{"legacy by id", map[string]interface{}{"toolId": "tool-1", "args": map[string]interface{}{"id": "3"}}, "/orders/3", true},
+		{"name with legacy args", map[string]interface{}{"name": "get_order", "args": map[string]interface{}{"id": "4"}}, "/orders/4", true},
+	}
+	for _, tc := range cases {
+		before := strings.Count(logs.String(), "deprecated tools/call params")
+		if status, _ := toolResult(t, g.call(t, sid, "tools/call", tc.params)); status != http.StatusOK {
+			t.Fatalf("%s: status %d", tc.name, status)
+		}
+		if got := <-seen; got != tc.want {
+			t.Errorf("%s: upstream got %s, want %s", tc.name, got, tc.want)
+		}
+		if warned := strings.Count(logs.String(), "deprecated tools/call params") > before; warned != tc.deprecated {
+			t.Errorf("%s: deprecation warning logged = %v", tc.name, warned)
+		}
+	}
+
+	// The spec's name does not match ids, and a missing name is invalid
+	if resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "tool-1"}); resp.Error == nil || resp.Error.Code != -32001 {
+		t.Fatalf("name matching an id: got %+v, want -32001", resp)
+	}
+	if resp := g.call(t, sid, "tools/call", map[string]interface{}{"arguments": map[string]interface{}{}}); resp.Error == nil || resp.Error.Code != -32602 {
+		t.Fatalf("missing name: got %+v, want -32602", resp)
+	}
+	// Disabled tools stay hidden under the legacy shape too
+	if err := g.store.SetToolsEnabled("orders", []string{"get_order"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if resp := g.call(t, sid, "tools/call", map[string]interface{}{"toolId": "tool-1"}); resp.Error == nil || resp.Error.Code != -32001 {
+		t.Fatalf("disabled tool by id: got %+v, want -32001", resp)
+	}
+}
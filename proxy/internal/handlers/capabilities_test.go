@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/store"
+)
+
+// initCapabilities runs initialize and returns the advertised capabilities.
+func (g *testGateway) initCapabilities(t *testing.T) map[string]json.RawMessage {
+	t.Helper()
+	resp := g.call(t, "", "initialize", map[string]interface{}{"protocolVersion": config.MCPProtocolVersionLatest})
+	if resp.Error != nil {
+		t.Fatalf("initialize: %+v", resp.Error)
+	}
+	var res struct {
+		Capabilities map[string]json.RawMessage `json:"capabilities"`
+	}
+	if err := json.Unmarshal(resp.Result, &res); err != nil {
+		t.Fatal(err)
+	}
+	return res.Capabilities
+}
+
+func (g *testGateway) setCapabilities(t *testing.T, caps *store.ServerCapabilities) {
+	t.Helper()
+	srv, err := g.store.GetServer("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Capabilities = caps
+	if err := g.store.UpsertServer(srv); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCapabilitiesDefaultToToolsOnly(t *testing.T) {
+	g := newTestGateway(t)
+	caps := g.initCapabilities(t)
+	if len(caps) != 1 || string(caps["tools"]) != `{"listChanged":true}` {
+		t.Fatalf("capabilities %v, want tools only", caps)
+	}
+}
+
+func TestCapabilitiesFollowServerConfig(t *testing.T) {
+	g := newTestGateway(t)
+	g.setCapabilities(t, &store.ServerCapabilities{Tools: true, Resources: true, ResourcesSubscribe: true, Logging: true})
+	caps := g.initCapabilities(t)
+	if _, ok := caps["prompts"]; ok {
+		t.Fatalf("capabilities %v advertise prompts", caps)
+	}
+	if _, ok := caps["logging"]; !ok {
+		t.Fatalf("capabilities %v lack logging", caps)
+	}
+	if string(caps["resources"]) != `{"subscribe":true}` {
+		t.Fatalf("resources %s, want subscribe true", caps["resources"])
+	}
+
+	g.setCapabilities(t, &store.ServerCapabilities{Tools: true, Resources: true})
+	if caps := g.initCapabilities(t); string(caps["resources"]) != `{"subscribe":false}` {
+		t.Fatalf("resources %s, want subscribe false", caps["resources"])
+	}
+}
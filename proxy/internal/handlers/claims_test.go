@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestToolsCallRequiredClaims(t *testing.T) {
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(`{}`)) })
+	sales := getTool("sales_report", "/reports/sales")
+	sales.RequiredClaims = map[string]interface{}{"department": "sales"}
+	regional := getTool("regional_report", "/reports/regional")
+	regional.RequiredClaims = map[string]interface{}{"regions": []interface{}{"emea", "apac"}}
+	scoped := getTool("close_quarter", "/reports/close")
+	scoped.RequiredScopes = []string{"reports:write"}
+	scoped.RequiredClaims = map[string]interface{}{"department": "finance"}
+	g.tools(t, sales, regional, scoped)
+
+	cases := []struct {
+		name   string
+		tool   string
+		claims map[string]interface{}
+		want   int // 0 for success, else the JSON-RPC error code
+	}{
+		{"matching claim", "sales_report", map[string]interface{}{"department": "sales"}, 0},
+		{"mismatched claim", "sales_report", map[string]interface{}{"department": "marketing"}, -32002},
+		{"missing claim", "sales_report", map[string]interface{}{"sub": "alice"}, -32002},
+		{"array claim contains value", "sales_report", map[string]interface{}{"department": []interface{}{"hr", "sales"}}, 0},
+		{"any of several values", "regional_report", map[string]interface{}{"regions": []interface{}{"amer", "apac"}}, 0},
+		{"none of several values", "regional_report", map[string]interface{}{"regions": "amer"}, -32002},
+		{"scope and claim", "close_quarter", map[string]interface{}{"scope": "reports:write", "department": "finance"}, 0},
+		{"scope without claim", "close_quarter", map[string]interface{}{"scope": "reports:write", "department": "sales"}, -32002},
+		{"claim without scope", "close_quarter", map[string]interface{}{"scope": "reports:read", "department": "finance"}, -32002},
+	}
+	sid := g.initialize(t)
+	for _, tc := range cases {
+		g.protect(tc.claims)
+		resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": tc.tool})
+		switch {
+		case tc.want == 0 && resp.Error != nil:
+			t.Errorf("%s: error %d %s", tc.name, resp.Error.Code, resp.Error.Message)
+		case tc.want != 0 && (resp.Error == nil || resp.Error.Code != tc.want):
+			t.Errorf("%s: got %+v, want error %d", tc.name, resp, tc.want)
+		}
+	}
+}
+
+func TestHasRequiredClaimsNonString(t *testing.T) {
+	claims := map[string]interface{}{"email_verified": true, "level": float64(3)}
+	if !hasRequiredClaims(claims, map[string]interface{}{"email_verified": true, "level": float64(3)}) {
+		t.Fatal("equal non-string claims did not match")
+	}
+	if hasRequiredClaims(claims, map[string]interface{}{"email_verified": false}) {
+		t.Fatal("unequal boolean claim matched")
+	}
+	if !hasRequiredClaims(claims, nil) {
+		t.Fatal("no requirements should always match")
+	}
+}
@@ -1,13 +1,26 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
 
+	"gateway/proxy/internal/auth"
+	"gateway/proxy/internal/engine"
+	"gateway/proxy/internal/events"
+	"gateway/proxy/internal/openapi"
+	"gateway/proxy/internal/session"
 	"gateway/proxy/internal/store"
 
 	"github.com/go-chi/chi/v5"
+	"gopkg.in/yaml.v3"
 )
 
 type ControlStore interface {
@@ -15,6 +28,16 @@ type ControlStore interface {
 	UpsertServer(store.Server) error
 	UpdateServerOpenAPI(serverSlug string, specJSON []byte, sourceURL string) error
 	UpsertToolsForServer(serverSlug string, tools []store.Tool) error
+	// UpsertServerBundle stores a server and its tools atomically
+	UpsertServerBundle(srv store.Server, tools []store.Tool) error
+	SetToolsEnabled(serverSlug string, names []string, enabled bool) error
+	GetTenant(slug string) (store.Tenant, error)
+	GetServer(slug string) (store.Server, error)
+	ListServersByTenant(tenantSlug string) ([]store.Server, error)
+	ImportTenant(store.TenantExport) error
+	ListToolDefinitions(serverSlug string) ([]store.Tool, error)
+	CreateAPIKey(store.APIKey) error
+	RevokeAPIKey(id string) error
 }
 
 func UpsertTenantHandler(s ControlStore) http.HandlerFunc {
@@ -40,6 +63,69 @@ func UpsertTenantHandler(s ControlStore) http.HandlerFunc {
 	}
 }
 
+// ExportTenantHandler dumps a tenant with all its servers and tools in the shape ImportHandler accepts.
+func ExportTenantHandler(s ControlStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, err := s.GetTenant(chi.URLParam(r, "slug"))
+		if err != nil {
+			http.Error(w, "tenant not found", http.StatusNotFound)
+			return
+		}
+		servers, err := s.ListServersByTenant(t.Slug)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		exp := store.TenantExport{Tenant: t, Servers: make([]store.ServerExport, 0, len(servers))}
+		for _, srv := range servers {
+			tools, err := s.ListToolDefinitions(srv.Slug)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			exp.Servers = append(exp.Servers, store.ServerExport{Server: srv, Tools: tools})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(exp)
+	}
+}
+
+// ImportHandler recreates a tenant, its servers and tools from an export document.
+func ImportHandler(s ControlStore, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var exp store.TenantExport
+		if err := json.NewDecoder(r.Body).Decode(&exp); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		if exp.Tenant.Slug == "" || exp.Tenant.Name == "" {
+			http.Error(w, "tenant slug and name required", http.StatusBadRequest)
+			return
+		}
+		if exp.Tenant.EgressAllowlist == nil {
+			exp.Tenant.EgressAllowlist = []string{}
+		}
+		for _, se := range exp.Servers {
+			if se.Server.Slug == "" || se.Server.Name == "" || se.Server.PrimaryAudience() == "" {
+				http.Error(w, "server slug, name, audience required", http.StatusBadRequest)
+				return
+			}
+			if invalid, duplicate := validateToolNames(se.Tools); len(invalid) > 0 || len(duplicate) > 0 {
+				http.Error(w, "invalid or duplicate tool names in server "+se.Server.Slug, http.StatusBadRequest)
+				return
+			}
+		}
+		if err := s.ImportTenant(exp); err != nil {
+			http.Error(w, err.Error(), writeErrorStatus(err))
+			return
+		}
+		for _, se := range exp.Servers {
+			bus.Publish(se.Server.Slug, events.Notification{Method: events.ToolsListChanged})
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
 func UpsertServerHandler(s ControlStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var srv store.Server
@@ -47,18 +133,128 @@ func UpsertServerHandler(s ControlStore) http.HandlerFunc {
 			http.Error(w, "invalid json", http.StatusBadRequest)
 			return
 		}
-		if srv.Slug == "" || srv.TenantSlug == "" || srv.Name == "" || srv.Audience == "" {
-			http.Error(w, "slug, tenantSlug, name, audience required", http.StatusBadRequest)
+		if msg := validateServer(srv); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
 			return
 		}
 		if err := s.UpsertServer(srv); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), writeErrorStatus(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// validateServer returns why srv cannot be stored, or "".
+func validateServer(srv store.Server) string {
+	if srv.Slug == "" || srv.TenantSlug == "" || srv.Name == "" || srv.PrimaryAudience() == "" {
+		return "slug, tenantSlug, name, audience required"
+	}
+	for _, m := range srv.CustomMethods {
+		if m == "" || gatewayMethods[m] || strings.HasPrefix(m, "notifications/") {
+			return "customMethods: \"" + m + "\" cannot be passed through"
+		}
+	}
+	for _, tag := range srv.Tags {
+		if strings.TrimSpace(tag) == "" {
+			return "tags must not be empty"
+		}
+	}
+	return ""
+}
+
+// UpsertServerBundleHandler stores {"server": {...}, "tools": [...]} for the {server} in
+// the path as one atomic write: if any tool is rejected the server is left unchanged.
+// Repeating the request is safe, as both parts are upserts.
+func UpsertServerBundleHandler(s ControlStore, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Server store.Server `json:"server"`
+			Tools  []store.Tool `json:"tools"`
+		}
+		if isYAML(r.Header.Get("Content-Type")) {
+			if err := decodeYAML(r.Body, &payload); err != nil {
+				http.Error(w, "invalid yaml", http.StatusBadRequest)
+				return
+			}
+		} else if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		serverSlug := chi.URLParam(r, "server")
+		if payload.Server.Slug == "" {
+			payload.Server.Slug = serverSlug
+		}
+		if payload.Server.Slug != serverSlug {
+			http.Error(w, "server slug does not match the path", http.StatusBadRequest)
+			return
+		}
+		if msg := validateServer(payload.Server); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
 			return
 		}
+		if invalid, duplicate := validateToolNames(payload.Tools); len(invalid) > 0 || len(duplicate) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":          "invalid tool names",
+				"invalidNames":   invalid,
+				"duplicateNames": duplicate,
+			})
+			return
+		}
+		for _, t := range payload.Tools {
+			if err := engine.CompileTransforms(t.Mapping.Transforms); err != nil {
+				http.Error(w, "tool "+t.Name+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := engine.CompileResponseMapping(t.Mapping.ResponseMapping); err != nil {
+				http.Error(w, "tool "+t.Name+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := s.UpsertServerBundle(payload.Server, payload.Tools); err != nil {
+			http.Error(w, err.Error(), writeErrorStatus(err))
+			return
+		}
+		bus.Publish(serverSlug, events.Notification{Method: events.ToolsListChanged})
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
+// serverSummary is one entry of the server listing.
+type serverSummary struct {
+	Slug        string   `json:"slug"`
+	TenantSlug  string   `json:"tenantSlug"`
+	Name        string   `json:"name"`
+	ServerTitle string   `json:"serverTitle,omitempty"`
+	Enabled     bool     `json:"enabled"`
+	Tags        []string `json:"tags"`
+}
+
+// ListServersHandler lists servers ordered by slug, only those tagged ?tag= when given.
+func ListServersHandler(s interface {
+	ListServersByTag(tag string) ([]store.Server, error)
+}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		servers, err := s.ListServersByTag(r.URL.Query().Get("tag"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		out := make([]serverSummary, 0, len(servers))
+		for _, srv := range servers {
+			tags := srv.Tags
+			if tags == nil {
+				tags = []string{}
+			}
+			out = append(out, serverSummary{Slug: srv.Slug, TenantSlug: srv.TenantSlug, Name: srv.Name, ServerTitle: srv.ServerTitle, Enabled: srv.Enabled, Tags: tags})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"servers": out})
+	}
+}
+
 func UploadOpenAPIHandler(s ControlStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		serverSlug := chi.URLParam(r, "server")
@@ -83,13 +279,187 @@ func UploadOpenAPIHandler(s ControlStore) http.HandlerFunc {
 	}
 }
 
-func UpsertToolsHandler(s ControlStore) http.HandlerFunc {
+// maxOpenAPIFetchBytes bounds a spec fetched by FetchOpenAPIHandler.
+const maxOpenAPIFetchBytes = 10 << 20
+
+// FetchOpenAPIHandler fetches {"url": ...} on the server's behalf, subject to its tenant's
+// egress allowlist, and stores it like an upload with the URL as source. With
+// "generateTools": true the spec's generated tools also replace the server's tools.
+func FetchOpenAPIHandler(s ControlStore, clients *engine.ClientFactory, bus *events.Bus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		serverSlug := chi.URLParam(r, "server")
+		srv, err := s.GetServer(serverSlug)
+		if err != nil {
+			http.Error(w, "server not found", http.StatusNotFound)
+			return
+		}
 		var payload struct {
+			URL           string `json:"url"`
+			GenerateTools bool   `json:"generateTools"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.URL == "" {
+			http.Error(w, "missing url", http.StatusBadRequest)
+			return
+		}
+		tenant, _ := s.GetTenant(srv.TenantSlug)
+		ctx, cancel := context.WithTimeout(r.Context(), toolCallTimeout)
+		defer cancel()
+		body, err := engine.FetchDocument(ctx, clients.Client(0), tenant, payload.URL, maxOpenAPIFetchBytes)
+		switch {
+		case errors.Is(err, engine.ErrEgressDenied):
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		case errors.Is(err, engine.ErrDocumentTooLarge):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		doc, err := openapi.Parse(body)
+		if err != nil {
+			http.Error(w, "invalid openapi: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		normalized, _ := json.Marshal(doc.Raw)
+		if err := s.UpdateServerOpenAPI(serverSlug, normalized, payload.URL); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		tools := []store.Tool{}
+		if payload.GenerateTools {
+			tools = doc.GenerateTools()
+			if len(tools) > 0 {
+				if err := s.UpsertToolsForServer(serverSlug, tools); err != nil {
+					http.Error(w, err.Error(), writeErrorStatus(err))
+					return
+				}
+				bus.Publish(serverSlug, events.Notification{Method: events.ToolsListChanged})
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Version string       `json:"version"`
+			BaseURL string       `json:"baseUrl,omitempty"`
+			Tools   []store.Tool `json:"tools"`
+		}{Version: doc.Version, BaseURL: doc.BaseURL(), Tools: tools})
+	}
+}
+
+// ValidateOpenAPIHandler parses an uploaded spec (JSON or YAML) and reports its version,
+// operations and unsupported features. Nothing is persisted.
+func ValidateOpenAPIHandler(s ControlStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := s.GetServer(chi.URLParam(r, "server")); err != nil {
+			http.Error(w, "server not found", http.StatusNotFound)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil || len(body) == 0 {
+			http.Error(w, "missing body", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(openapi.Validate(body))
+	}
+}
+
+// GenerateToolsHandler previews the tools an uploaded Swagger 2.0 or OpenAPI 3.x spec maps
+// to, along with the spec's suggested upstream base URL. Nothing is persisted; operators
+// review the result and submit it to the tools endpoint.
+func GenerateToolsHandler(s ControlStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := s.GetServer(chi.URLParam(r, "server")); err != nil {
+			http.Error(w, "server not found", http.StatusNotFound)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil || len(body) == 0 {
+			http.Error(w, "missing body", http.StatusBadRequest)
+			return
+		}
+		doc, err := openapi.Parse(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Version string       `json:"version"`
+			BaseURL string       `json:"baseUrl,omitempty"`
+			Tools   []store.Tool `json:"tools"`
+		}{Version: doc.Version, BaseURL: doc.BaseURL(), Tools: doc.GenerateTools()})
+	}
+}
+
+// GetToolsHandler returns the full tool definitions of a server, including request
+// mappings and disabled tools, which the MCP tools/list deliberately hides.
+func GetToolsHandler(s ControlStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serverSlug := chi.URLParam(r, "server")
+		if _, err := s.GetServer(serverSlug); err != nil {
+			http.Error(w, "server not found", http.StatusNotFound)
+			return
+		}
+		tools, err := s.ListToolDefinitions(serverSlug)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
 			Tools []store.Tool `json:"tools"`
+		}{Tools: tools})
+	}
+}
+
+// ValidateMappingsHandler cross-checks the {{arg}} placeholders of every tool mapping on a
+// server against the tool's input schema properties. Nothing is changed.
+func ValidateMappingsHandler(s ControlStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serverSlug := chi.URLParam(r, "server")
+		srv, err := s.GetServer(serverSlug)
+		if err != nil {
+			http.Error(w, "server not found", http.StatusNotFound)
+			return
 		}
-		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		if srv.Backend == engine.BackendStdio {
+			http.Error(w, "stdio servers have no tool mappings", http.StatusBadRequest)
+			return
+		}
+		tools, err := s.ListToolDefinitions(serverSlug)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		valid := true
+		reports := make([]engine.MappingReport, 0, len(tools))
+		for _, t := range tools {
+			rep := engine.CheckMapping(t)
+			valid = valid && rep.Valid
+			reports = append(reports, rep)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Valid bool                   `json:"valid"`
+			Tools []engine.MappingReport `json:"tools"`
+		}{Valid: valid, Tools: reports})
+	}
+}
+
+// UpsertToolsHandler replaces tool definitions and tells connected sessions to re-list.
+func UpsertToolsHandler(s ControlStore, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serverSlug := chi.URLParam(r, "server")
+		var payload struct {
+			Tools []store.Tool `json:"tools"`
+		}
+		if isYAML(r.Header.Get("Content-Type")) {
+			if err := decodeYAML(r.Body, &payload); err != nil {
+				http.Error(w, "invalid yaml", http.StatusBadRequest)
+				return
+			}
+		} else if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 			http.Error(w, "invalid json", http.StatusBadRequest)
 			return
 		}
@@ -97,10 +467,449 @@ func UpsertToolsHandler(s ControlStore) http.HandlerFunc {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
+		if invalid, duplicate := validateToolNames(payload.Tools); len(invalid) > 0 || len(duplicate) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":          "invalid tool names",
+				"invalidNames":   invalid,
+				"duplicateNames": duplicate,
+			})
+			return
+		}
+		for _, t := range payload.Tools {
+			if err := engine.CompileTransforms(t.Mapping.Transforms); err != nil {
+				http.Error(w, "tool "+t.Name+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := engine.CompileResponseMapping(t.Mapping.ResponseMapping); err != nil {
+				http.Error(w, "tool "+t.Name+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 		if err := s.UpsertToolsForServer(serverSlug, payload.Tools); err != nil {
+			http.Error(w, err.Error(), writeErrorStatus(err))
+			return
+		}
+		bus.Publish(serverSlug, events.Notification{Method: events.ToolsListChanged})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SetToolsEnabledHandler enables or disables tools by name without resubmitting their
+// definitions. The request is rejected as a whole if any name is unknown.
+func SetToolsEnabledHandler(s ControlStore, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serverSlug := chi.URLParam(r, "server")
+		var payload struct {
+			Names   []string `json:"names"`
+			Enabled *bool    `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		if len(payload.Names) == 0 || payload.Enabled == nil {
+			http.Error(w, "names and enabled required", http.StatusBadRequest)
+			return
+		}
+		if _, err := s.GetServer(serverSlug); err != nil {
+			http.Error(w, "server not found", http.StatusNotFound)
+			return
+		}
+		if err := s.SetToolsEnabled(serverSlug, payload.Names, *payload.Enabled); err != nil {
+			if errors.Is(err, store.ErrToolNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), writeErrorStatus(err))
+			return
+		}
+		bus.Publish(serverSlug, events.Notification{Method: events.ToolsListChanged})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ListSessionsHandler lists live MCP sessions, filterable by ?tenant= and ?server=.
+func ListSessionsHandler(sm *session.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Sessions []session.Info `json:"sessions"`
+		}{Sessions: sm.List(q.Get("tenant"), q.Get("server"))})
+	}
+}
+
+// TerminateSessionHandler forcibly ends a session; the client's next call fails with session not found.
+func TerminateSessionHandler(sm *session.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if _, err := sm.Get(id); err != nil {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+		sm.Delete(id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func ListAdminTokensHandler(tokens *auth.AdminTokens) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Tokens []auth.AdminTokenInfo `json:"tokens"`
+		}{Tokens: tokens.List()})
+	}
+}
+
+// AddAdminTokenHandler issues a new admin token. The secret is returned only in this response.
+func AddAdminTokenHandler(tokens *auth.AdminTokens) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Label string `json:"label"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && err != io.EOF {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		info, secret, err := tokens.Add(payload.Label)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(struct {
+			auth.AdminTokenInfo
+			Token string `json:"token"`
+		}{AdminTokenInfo: info, Token: secret})
+	}
+}
+
+func RevokeAdminTokenHandler(tokens *auth.AdminTokens) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		found, err := tokens.Revoke(chi.URLParam(r, "id"))
+		if !found {
+			http.Error(w, "token not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, auth.ErrLastAdminToken) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
+
+// CreateAPIKeyHandler issues a tenant-scoped API key. The secret is returned only once;
+// the store keeps its hash.
+func CreateAPIKeyHandler(s ControlStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var k store.APIKey
+		if err := json.NewDecoder(r.Body).Decode(&k); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		if k.TenantSlug == "" {
+			http.Error(w, "tenantSlug required", http.StatusBadRequest)
+			return
+		}
+		if k.Servers == nil {
+			k.Servers = []string{}
+		}
+		if k.Scopes == nil {
+			k.Scopes = []string{}
+		}
+		id, secret := auth.NewAPIKey()
+		k.ID = id
+		k.KeyHash = auth.HashAPIKey(secret)
+		k.Revoked = false
+		k.CreatedUnixMilli = time.Now().UnixMilli()
+		if err := s.CreateAPIKey(k); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(struct {
+			store.APIKey
+			Key string `json:"key"`
+		}{APIKey: k, Key: secret})
+	}
+}
+
+func RevokeAPIKeyHandler(s ControlStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.RevokeAPIKey(chi.URLParam(r, "id")); err != nil {
+			if errors.Is(err, store.ErrAPIKeyNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// writeErrorStatus maps a failed control-plane write to its HTTP status.
+func writeErrorStatus(err error) int {
+	if errors.Is(err, store.ErrManagedByFile) || errors.Is(err, store.ErrToolNameConflict) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// validateToolNames returns names and aliases violating the MCP tool-name constraints and
+// those repeated within the batch, so the whole upsert can be rejected before any write.
+func validateToolNames(tools []store.Tool) (invalid, duplicate []string) {
+	invalid, duplicate = []string{}, []string{}
+	seen := map[string]int{}
+	for _, t := range tools {
+		for _, name := range append([]string{t.Name}, t.Aliases...) {
+			if !toolNamePattern.MatchString(name) {
+				invalid = append(invalid, name)
+			}
+			seen[name]++
+			if seen[name] == 2 {
+				duplicate = append(duplicate, name)
+			}
+		}
+	}
+	return invalid, duplicate
+}
+
+func isYAML(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	return mt == "application/yaml" || mt == "text/yaml" || mt == "application/x-yaml"
+}
+
+// decodeYAML parses YAML and re-decodes it through JSON so the existing json tags on
+// store types apply and YAML and JSON payloads produce identical values.
+func decodeYAML(r io.Reader, v interface{}) error {
+	var doc interface{}
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		return err
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// ReadyzHandler reports readiness along with the active health of probed servers. The
+// gateway stays ready while individual upstreams are down, so the status is "degraded"
+// rather than a 503 that would take every server out of rotation.
+func ReadyzHandler(h interface {
+	Statuses() map[string]engine.ServerHealth
+}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statuses := h.Statuses()
+		status := "ok"
+		for _, st := range statuses {
+			if !st.Healthy {
+				status = "degraded"
+				break
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Status  string                         `json:"status"`
+			Servers map[string]engine.ServerHealth `json:"servers"`
+		}{Status: status, Servers: statuses})
+	}
+}
+
+// ReloadHealthOnWrite wraps cs so that server writes make the health checker read the
+// server list again, rather than waiting for its periodic refresh.
+func ReloadHealthOnWrite(cs ControlStore, h interface{ Reload() }) ControlStore {
+	return healthReloadStore{ControlStore: cs, health: h}
+}
+
+type healthReloadStore struct {
+	ControlStore
+	health interface{ Reload() }
+}
+
+func (s healthReloadStore) UpsertServer(srv store.Server) error {
+	defer s.health.Reload()
+	return s.ControlStore.UpsertServer(srv)
+}
+
+func (s healthReloadStore) UpsertServerBundle(srv store.Server, tools []store.Tool) error {
+	defer s.health.Reload()
+	return s.ControlStore.UpsertServerBundle(srv, tools)
+}
+
+func (s healthReloadStore) ImportTenant(exp store.TenantExport) error {
+	defer s.health.Reload()
+	return s.ControlStore.ImportTenant(exp)
+}
+
+// ValidateConfigHandler reports store misconfigurations such as servers of missing tenants
+// or without an audience. It answers 200 either way; valid tells whether any were found.
+func ValidateConfigHandler(v interface {
+	Validate() ([]store.ConfigProblem, error)
+}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		problems, err := v.Validate()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Valid    bool                  `json:"valid"`
+			Problems []store.ConfigProblem `json:"problems"`
+		}{Valid: len(problems) == 0, Problems: problems})
+	}
+}
+
+// CacheStatsHandler exposes upstream response cache hit/miss counters.
+func CacheStatsHandler(c interface{ Stats() engine.CacheStats }) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(c.Stats())
+	}
+}
+
+// TestToolHandler invokes a tool with sample args through engine.Execute so operators can
+// verify a mapping without an MCP session. Scopes are skipped (the caller is an admin) but
+// the tenant egress allowlist still applies.
+func TestToolHandler(s ControlStore, clients *engine.ClientFactory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serverSlug := chi.URLParam(r, "server")
+		toolName := chi.URLParam(r, "tool")
+		srv, err := s.GetServer(serverSlug)
+		if err != nil {
+			http.Error(w, "server not found", http.StatusNotFound)
+			return
+		}
+		tenant, err := s.GetTenant(srv.TenantSlug)
+		if err != nil {
+			http.Error(w, "tenant not found", http.StatusNotFound)
+			return
+		}
+		tools, err := s.ListToolDefinitions(serverSlug)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var (
+			tool  store.Tool
+			found bool
+		)
+		for _, t := range tools {
+			if t.Name == toolName {
+				tool, found = t, true
+				break
+			}
+		}
+		if !found {
+			http.Error(w, "tool not found", http.StatusNotFound)
+			return
+		}
+		var payload struct {
+			Args map[string]interface{} `json:"args"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && err != io.EOF {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), engine.CallTimeout(srv))
+		defer cancel()
+		res, err := engine.Execute(ctx, clients.ServerClient(srv), srv, tenant, tool, payload.Args)
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  res.UpstreamStatus,
+			"headers": res.UpstreamHeaders,
+			"body":    res.UpstreamBody,
+		})
+	}
+}
+
+// RefreshJWKSHandler flushes cached JWKS, optionally only for {"issuer": "..."}.
+func RefreshJWKSHandler(v interface{ RefreshJWKS(issuer string) int }) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Issuer string `json:"issuer"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && err != io.EOF {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		cleared := v.RefreshJWKS(payload.Issuer)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]int{"cleared": cleared})
+	}
+}
+
+// TestIssuerHandler checks that the gateway can discover {"issuer": "..."} and use its
+// key set, without enabling it anywhere. The report is returned with 200 either way.
+func TestIssuerHandler(v interface {
+	TestIssuer(issuer string) auth.IssuerReport
+}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Issuer string `json:"issuer"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		if u, err := url.Parse(payload.Issuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			http.Error(w, "issuer must be an absolute http or https URL", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v.TestIssuer(payload.Issuer))
+	}
+}
+
+// RefreshIssuersHandler re-discovers the OIDC metadata of every issuer a tenant or its
+// servers accept. Tokens keep validating against the previous metadata meanwhile.
+func RefreshIssuersHandler(s ControlStore, v interface {
+	RefreshIssuers(issuers []string) []auth.IssuerRefresh
+}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, err := s.GetTenant(chi.URLParam(r, "slug"))
+		if err != nil {
+			http.Error(w, "tenant not found", http.StatusNotFound)
+			return
+		}
+		servers, err := s.ListServersByTenant(t.Slug)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		seen := map[string]bool{}
+		issuers := []string{}
+		add := func(list []string) {
+			for _, iss := range list {
+				if iss != "" && !seen[iss] {
+					seen[iss] = true
+					issuers = append(issuers, iss)
+				}
+			}
+		}
+		add(t.AllowedIssuers)
+		for _, srv := range servers {
+			add(srv.AllowedIssuers)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"issuers": v.RefreshIssuers(issuers)})
+	}
+}
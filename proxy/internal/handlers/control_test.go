@@ -0,0 +1,693 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"gateway/proxy/internal/auth"
+	"gateway/proxy/internal/engine"
+	"gateway/proxy/internal/events"
+	"gateway/proxy/internal/store"
+)
+
+// controlAPI serves the admin control routes over s, as cmd/proxy does, without the admin
+// token middleware.
+func controlAPI(s ControlStore, bus *events.Bus) http.Handler {
+	mux := chi.NewRouter()
+	mux.Get("/api/tenants/{slug}/export", ExportTenantHandler(s))
+	mux.Post("/api/import", ImportHandler(s, bus))
+	mux.Post("/api/servers/{server}/openapi/validate", ValidateOpenAPIHandler(s))
+	mux.Post("/api/servers/{server}/openapi/fetch", FetchOpenAPIHandler(s, engine.NewClientFactory(engine.DefaultTransportOptions()), bus))
+	mux.Post("/api/servers/{server}/bundle", UpsertServerBundleHandler(s, bus))
+	mux.Post("/api/servers/{server}/tools", UpsertToolsHandler(s, bus))
+	mux.Get("/api/servers/{server}/tools", GetToolsHandler(s))
+	mux.Get("/api/servers/{server}/mappings", ValidateMappingsHandler(s))
+	mux.Patch("/api/servers/{server}/tools/enabled", SetToolsEnabledHandler(s, bus))
+	mux.Post("/api/servers/{server}/tools/{tool}/test", TestToolHandler(s, engine.NewClientFactory(engine.DefaultTransportOptions())))
+	return mux
+}
+
+func TestGetToolsReturnsMappings(t *testing.T) {
+	g := newTestGateway(t)
+	disabled := false
+	g.tools(t,
+		store.Tool{
+			Name:           "create_order",
+			RequiredScopes: []string{"orders:write"},
+			Mapping: store.RequestTemplate{
+				Method:  http.MethodPost,
+				Path:    "/orders",
+				Headers: map[string]string{"X-Api-Version": "2"},
+				Body:    map[string]interface{}{"amount": "{{amount}}"},
+			},
+			InputSchema: map[string]interface{}{"type": "object"},
+		},
+		store.Tool{Name: "legacy", Enabled: &disabled, Mapping: store.RequestTemplate{Method: http.MethodGet, Path: "/legacy"}},
+	)
+
+	rec := adminRequest(controlAPI(newControlStore(g.store), g.bus), http.MethodGet, "/api/servers/orders/tools", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got struct {
+		Tools []store.Tool `json:"tools"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]store.Tool{}
+	for _, tool := range got.Tools {
+		byName[tool.Name] = tool
+	}
+	create, ok := byName["create_order"]
+	if !ok {
+		t.Fatalf("create_order missing: %s", rec.Body)
+	}
+	if create.Mapping.Method != http.MethodPost || create.Mapping.Path != "/orders" || create.Mapping.Headers["X-Api-Version"] != "2" || create.Mapping.Body["amount"] != "{{amount}}" {
+		t.Fatalf("mapping = %+v", create.Mapping)
+	}
+	if len(create.RequiredScopes) != 1 || create.RequiredScopes[0] != "orders:write" || create.InputSchema["type"] != "object" {
+		t.Fatalf("tool = %+v", create)
+	}
+	if _, ok := byName["legacy"]; !ok {
+		t.Fatalf("disabled tool missing: %s", rec.Body)
+	}
+
+	// tools/list hides the mapping the admin endpoint exposes
+	resp := g.call(t, g.initialize(t), "tools/list", nil)
+	if resp.Error != nil {
+		t.Fatalf("tools/list: %+v", resp.Error)
+	}
+	for _, field := range []string{`"mapping"`, `"/orders"`, `"requiredScopes"`} {
+		if strings.Contains(string(resp.Result), field) {
+			t.Fatalf("tools/list exposes %s: %s", field, resp.Result)
+		}
+	}
+}
+
+func TestGetToolsUnknownServer(t *testing.T) {
+	g := newTestGateway(t)
+	if rec := adminRequest(controlAPI(newControlStore(g.store), g.bus), http.MethodGet, "/api/servers/nope/tools", "", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want 404", rec.Code)
+	}
+}
+
+func TestUpsertToolsValidatesNames(t *testing.T) {
+	g := newTestGateway(t)
+	g.tools(t, getTool("get_order", "/orders/{{id}}"))
+	api := controlAPI(newControlStore(g.store), g.bus)
+
+	cases := []struct {
+		name, body         string
+		invalid, duplicate []string
+	}{
+		{
+			name:      "duplicate names",
+			body:      `{"tools":[{"name":"list_orders","mapping":{"method":"GET","path":"/orders"}},{"name":"list_orders","mapping":{"method":"GET","path":"/v2/orders"}}]}`,
+			duplicate: []string{"list_orders"},
+		},
+		{
+			name:    "invalid characters",
+			body:    `{"tools":[{"name":"list orders","mapping":{"method":"GET","path":"/orders"}},{"name":"ok_tool","mapping":{"method":"GET","path":"/ok"}},{"name":"bad/name","mapping":{"method":"GET","path":"/bad"}}]}`,
+			invalid: []string{"list orders", "bad/name"},
+		},
+	}
+	for _, tc := range cases {
+		rec := adminRequest(api, http.MethodPost, "/api/servers/orders/tools", "", tc.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status %d, want 400", tc.name, rec.Code)
+		}
+		var body struct {
+			InvalidNames   []string `json:"invalidNames"`
+			DuplicateNames []string `json:"duplicateNames"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v: %s", tc.name, err, rec.Body)
+		}
+		if strings.Join(body.InvalidNames, ",") != strings.Join(tc.invalid, ",") || strings.Join(body.DuplicateNames, ",") != strings.Join(tc.duplicate, ",") {
+			t.Fatalf("%s: body %s", tc.name, rec.Body)
+		}
+	}
+	// rejected batches write nothing
+	if names := toolNamesOf(t, g.store); names != "get_order" {
+		t.Fatalf("tools after rejected batches = %s", names)
+	}
+
+	rec := adminRequest(api, http.MethodPost, "/api/servers/orders/tools", "", `{"tools":[{"name":"list_orders","mapping":{"method":"GET","path":"/orders"}},{"name":"get-order_v2","mapping":{"method":"GET","path":"/v2/orders/{{id}}"}}]}`)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("valid batch: status %d: %s", rec.Code, rec.Body)
+	}
+	if names := toolNamesOf(t, g.store); names != "get-order_v2,list_orders" {
+		t.Fatalf("tools after valid batch = %s", names)
+	}
+}
+
+// toolNamesOf lists the sorted tool names stored for server orders.
+func toolNamesOf(t *testing.T, s *store.MemoryStore) string {
+	t.Helper()
+	tools, err := s.ListToolDefinitions("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func TestUpsertToolsYAML(t *testing.T) {
+	const jsonBody = `{"tools":[{
+		"name":"create_order",
+		"description":"Create an order",
+		"requiredScopes":["orders:write"],
+		"mapping":{"method":"POST","path":"/orders","headers":{"X-Api-Version":"2"},"body":{"amount":"{{amount}}","rush":true}},
+		"inputSchema":{"type":"object","properties":{"amount":{"type":"integer"}},"required":["amount"]}
+	}]}`
+	const yamlBody = `
+tools:
+  - name: create_order
+    description: Create an order
+    requiredScopes: [orders:write]
+    mapping:
+      method: POST
+      path: /orders
+      headers:
+        X-Api-Version: "2"
+      body:
+        amount: "{{amount}}"
+        rush: true
+    inputSchema:
+      type: object
+      properties:
+        amount:
+          type: integer
+      required: [amount]
+`
+	stored := func(contentType, body string) []store.Tool {
+		t.Helper()
+		g := newTestGateway(t)
+		req := httptest.NewRequest(http.MethodPost, "/api/servers/orders/tools", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		controlAPI(newControlStore(g.store), g.bus).ServeHTTP(rec, req)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("%s: status %d: %s", contentType, rec.Code, rec.Body)
+		}
+		tools, err := g.store.ListToolDefinitions("orders")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range tools {
+			tools[i].ID = ""
+		}
+		return tools
+	}
+
+	fromJSON := stored("application/json", jsonBody)
+	for _, ct := range []string{"application/yaml", "text/yaml; charset=utf-8"} {
+		fromYAML := stored(ct, yamlBody)
+		if !reflect.DeepEqual(fromJSON, fromYAML) {
+			t.Fatalf("%s: stored tools differ\njson: %+v\nyaml: %+v", ct, fromJSON, fromYAML)
+		}
+	}
+
+	// YAML is only used when asked for
+	g := newTestGateway(t)
+	req := httptest.NewRequest(http.MethodPost, "/api/servers/orders/tools", strings.NewReader(yamlBody))
+	rec := httptest.NewRecorder()
+	controlAPI(newControlStore(g.store), g.bus).ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("yaml without content type: status %d, want 400", rec.Code)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src := newTestGateway(t)
+	src.tools(t,
+		store.Tool{
+			Name:           "create_order",
+			RequiredScopes: []string{"orders:write", "orders:admin"},
+			Mapping: store.RequestTemplate{
+				Method:  http.MethodPost,
+				Path:    "/orders",
+				Query:   map[string]string{"dryRun": "{{dryRun}}"},
+				Headers: map[string]string{"X-Api-Version": "2"},
+				Body:    map[string]interface{}{"amount": "{{amount}}"},
+			},
+			InputSchema: map[string]interface{}{"type": "object"},
+		},
+		getTool("get_order", "/orders/{{id}}"),
+	)
+	if err := src.store.UpsertServer(store.Server{Slug: "billing", TenantSlug: "acme", Name: "billing", Enabled: true, Audience: "https://billing.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.store.UpsertToolsForServer("billing", []store.Tool{{Name: "list_invoices", RequiredScopes: []string{"billing:read"}, Mapping: store.RequestTemplate{Method: http.MethodGet, Path: "/invoices"}}}); err != nil {
+		t.Fatal(err)
+	}
+	tenant, _ := src.store.GetTenant("acme")
+	tenant.Name = "Acme"
+	if err := src.store.UpsertTenant(tenant); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := adminRequest(controlAPI(newControlStore(src.store), src.bus), http.MethodGet, "/api/tenants/acme/export", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export: status %d: %s", rec.Code, rec.Body)
+	}
+	exported := rec.Body.String()
+	var exp store.TenantExport
+	if err := json.Unmarshal([]byte(exported), &exp); err != nil {
+		t.Fatal(err)
+	}
+	if exp.Tenant.Slug != "acme" || len(exp.Servers) != 2 {
+		t.Fatalf("export = %s", exported)
+	}
+
+	dst := store.NewMemoryStore("https://api.example.com")
+	api := controlAPI(newControlStore(dst), events.NewBus())
+	if rec := adminRequest(api, http.MethodPost, "/api/import", "", exported); rec.Code != http.StatusNoContent {
+		t.Fatalf("import: status %d: %s", rec.Code, rec.Body)
+	}
+	tools, err := dst.ListToolDefinitions("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var create *store.Tool
+	for i := range tools {
+		if tools[i].Name == "create_order" {
+			create = &tools[i]
+		}
+	}
+	if create == nil {
+		t.Fatalf("create_order not imported: %+v", tools)
+	}
+	if !reflect.DeepEqual(create.RequiredScopes, []string{"orders:write", "orders:admin"}) || create.Mapping.Path != "/orders" || create.Mapping.Query["dryRun"] != "{{dryRun}}" || create.Mapping.Body["amount"] != "{{amount}}" {
+		t.Fatalf("imported tool = %+v", *create)
+	}
+
+	// exporting the imported tenant reproduces the original document
+	rec = adminRequest(api, http.MethodGet, "/api/tenants/acme/export", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("re-export: status %d", rec.Code)
+	}
+	var again store.TenantExport
+	if err := json.Unmarshal(rec.Body.Bytes(), &again); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exp, again) {
+		t.Fatalf("round trip changed the export\nbefore: %s\nafter:  %s", exported, rec.Body)
+	}
+}
+
+func TestImportRejectsInvalidDocuments(t *testing.T) {
+	dst := store.NewMemoryStore("https://api.example.com")
+	api := controlAPI(newControlStore(dst), events.NewBus())
+	for name, body := range map[string]string{
+		"missing tenant name":     `{"tenant":{"slug":"acme"}}`,
+		"server without audience": `{"tenant":{"slug":"acme","name":"Acme"},"servers":[{"server":{"slug":"orders","name":"orders"}}]}`,
+		"duplicate tools":         `{"tenant":{"slug":"acme","name":"Acme"},"servers":[{"server":{"slug":"orders","name":"orders","audience":"https://api.example.com"},"tools":[{"name":"a"},{"name":"a"}]}]}`,
+	} {
+		if rec := adminRequest(api, http.MethodPost, "/api/import", "", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, rec.Code)
+		}
+	}
+	if _, err := dst.GetTenant("acme"); err == nil {
+		t.Fatal("rejected import stored the tenant")
+	}
+	if rec := adminRequest(api, http.MethodGet, "/api/tenants/nope/export", "", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("export unknown tenant: status %d, want 404", rec.Code)
+	}
+}
+
+func TestToolTestEndpoint(t *testing.T) {
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Upstream", "mock")
+		_, _ = w.Write([]byte(`{"id":"` + strings.TrimPrefix(r.URL.Path, "/orders/") + `"}`))
+	})
+	g.tools(t, getTool("get_order", "/orders/{{id}}"))
+	api := controlAPI(newControlStore(g.store), g.bus)
+
+	rec := adminRequest(api, http.MethodPost, "/api/servers/orders/tools/get_order/test", "", `{"args":{"id":"42"}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got struct {
+		Status  int                 `json:"status"`
+		Headers map[string][]string `json:"headers"`
+		Body    json.RawMessage     `json:"body"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != http.StatusOK || string(got.Body) != `{"id":"42"}` || got.Headers["X-Upstream"][0] != "mock" {
+		t.Fatalf("result = %s", rec.Body)
+	}
+
+	if rec := adminRequest(api, http.MethodPost, "/api/servers/orders/tools/nope/test", "", `{}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown tool: status %d, want 404", rec.Code)
+	}
+}
+
+func TestToolTestEndpointRespectsEgress(t *testing.T) {
+	g := newTestGateway(t)
+	var hits atomic.Int32
+	ts := g.upstream(t, func(w http.ResponseWriter, r *http.Request) { hits.Add(1) })
+	g.tools(t, getTool("get_order", "/orders/{{id}}"))
+	// localhost is not on the tenant allowlist, which only admits 127.0.0.1
+	srv, _ := g.store.GetServer("orders")
+	srv.UpstreamBaseURL = strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+	if err := g.store.UpsertServer(srv); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := adminRequest(controlAPI(newControlStore(g.store), g.bus), http.MethodPost, "/api/servers/orders/tools/get_order/test", "", `{"args":{"id":"42"}}`)
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "not allowed") {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if n := hits.Load(); n != 0 {
+		t.Fatalf("upstream hits = %d, want 0", n)
+	}
+}
+
+// refresher records RefreshJWKS calls.
+type refresher struct{ issuers []string }
+
+func (r *refresher) RefreshJWKS(issuer string) int {
+	r.issuers = append(r.issuers, issuer)
+	return 1
+}
+
+func TestRefreshJWKSHandler(t *testing.T) {
+	v := &refresher{}
+	mux := chi.NewRouter()
+	mux.Post("/api/jwks/refresh", RefreshJWKSHandler(v))
+
+	for _, body := range []string{"", `{}`, `{"issuer":"https://idp.example.com"}`} {
+		rec := adminRequest(mux, http.MethodPost, "/api/jwks/refresh", "", body)
+		if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"cleared":1}` {
+			t.Fatalf("body %q: %d %s", body, rec.Code, rec.Body)
+		}
+	}
+	if got := strings.Join(v.issuers, ","); got != ",,https://idp.example.com" {
+		t.Fatalf("refreshed %q", got)
+	}
+	if rec := adminRequest(mux, http.MethodPost, "/api/jwks/refresh", "", `{`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid json: status %d, want 400", rec.Code)
+	}
+}
+
+// issuerRefresher records RefreshIssuers calls.
+type issuerRefresher struct{ issuers []string }
+
+func (r *issuerRefresher) RefreshIssuers(issuers []string) []auth.IssuerRefresh {
+	r.issuers = issuers
+	out := make([]auth.IssuerRefresh, len(issuers))
+	for i, iss := range issuers {
+		out[i] = auth.IssuerRefresh{Issuer: iss, JWKSURI: iss + "/jwks", Discovered: true}
+	}
+	return out
+}
+
+func TestRefreshIssuersHandler(t *testing.T) {
+	g := newTestGateway(t)
+	tenant, _ := g.store.GetTenant("acme")
+	tenant.AllowedIssuers = []string{"https://idp.example.com", "https://other.example.com"}
+	if err := g.store.UpsertTenant(tenant); err != nil {
+		t.Fatal(err)
+	}
+	srv, _ := g.store.GetServer("orders")
+	srv.AllowedIssuers = []string{"https://other.example.com", "https://orders-idp.example.com"}
+	if err := g.store.UpsertServer(srv); err != nil {
+		t.Fatal(err)
+	}
+	v := &issuerRefresher{}
+	mux := chi.NewRouter()
+	mux.Post("/api/tenants/{slug}/issuers/refresh", RefreshIssuersHandler(newControlStore(g.store), v))
+
+	rec := adminRequest(mux, http.MethodPost, "/api/tenants/acme/issuers/refresh", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if got := strings.Join(v.issuers, ","); got != "https://idp.example.com,https://other.example.com,https://orders-idp.example.com" {
+		t.Fatalf("refreshed %q, want the tenant's and its servers' issuers once each", got)
+	}
+	var out struct {
+		Issuers []auth.IssuerRefresh `json:"issuers"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || len(out.Issuers) != 3 || out.Issuers[0].JWKSURI != "https://idp.example.com/jwks" {
+		t.Fatalf("body %s, err %v", rec.Body, err)
+	}
+	if rec := adminRequest(mux, http.MethodPost, "/api/tenants/nope/issuers/refresh", "", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown tenant: status %d, want 404", rec.Code)
+	}
+}
+
+// issuerTester reports every issuer on idp.example.com as usable and others as unreachable.
+type issuerTester struct{ issuers []string }
+
+func (v *issuerTester) TestIssuer(issuer string) auth.IssuerReport {
+	v.issuers = append(v.issuers, issuer)
+	if issuer != "https://idp.example.com" {
+		return auth.IssuerReport{Issuer: issuer, DiscoveryError: "connection refused", JWKSError: "connection refused", Algorithms: []string{}, KeyTypes: []string{}}
+	}
+	return auth.IssuerReport{Issuer: issuer, OK: true, Discovered: true, JWKSURI: issuer + "/jwks", JWKSReachable: true, KeyCount: 1, UsableKeys: 1, Algorithms: []string{"RS256"}, KeyTypes: []string{"RSA"}}
+}
+
+func TestTestIssuerHandler(t *testing.T) {
+	v := &issuerTester{}
+	mux := chi.NewRouter()
+	mux.Post("/api/issuers/test", TestIssuerHandler(v))
+
+	for _, tc := range []struct {
+		issuer string
+		ok     bool
+	}{
+		{"https://idp.example.com", true},
+		{"https://down.example.com", false},
+	} {
+		// A failing issuer is a report, not an error status
+		rec := adminRequest(mux, http.MethodPost, "/api/issuers/test", "", `{"issuer":"`+tc.issuer+`"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", tc.issuer, rec.Code, rec.Body)
+		}
+		var rep auth.IssuerReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &rep); err != nil || rep.Issuer != tc.issuer || rep.OK != tc.ok {
+			t.Fatalf("%s: body %s, err %v", tc.issuer, rec.Body, err)
+		}
+		if !tc.ok && (rep.JWKSError == "" || !strings.Contains(rec.Body.String(), `"algorithms":[]`)) {
+			t.Fatalf("%s: body %s", tc.issuer, rec.Body)
+		}
+	}
+
+	for _, body := range []string{`{`, `{}`, `{"issuer":"idp.example.com"}`, `{"issuer":"ftp://idp.example.com"}`} {
+		if rec := adminRequest(mux, http.MethodPost, "/api/issuers/test", "", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, rec.Code)
+		}
+	}
+	if len(v.issuers) != 2 {
+		t.Fatalf("tested %v, want invalid requests rejected before any fetch", v.issuers)
+	}
+}
+
+func TestValidateOpenAPIEndpoint(t *testing.T) {
+	g := newTestGateway(t)
+	cs := newControlStore(g.store)
+	api := controlAPI(cs, g.bus)
+
+	spec := `{"openapi":"3.0.0","info":{"title":"Orders"},"paths":{"/orders":{"get":{"operationId":"listOrders"},"post":{}}}}`
+	rec := adminRequest(api, http.MethodPost, "/api/servers/orders/openapi/validate", "", spec)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var rep struct {
+		Valid               bool     `json:"valid"`
+		Version             string   `json:"version"`
+		OperationCount      int      `json:"operationCount"`
+		MissingOperationIDs []string `json:"missingOperationIds"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &rep); err != nil {
+		t.Fatal(err)
+	}
+	if !rep.Valid || rep.Version != "3.0" || rep.OperationCount != 2 || !reflect.DeepEqual(rep.MissingOperationIDs, []string{"POST /orders"}) {
+		t.Fatalf("report %s", rec.Body)
+	}
+
+	// Invalid documents are reported, not rejected
+	rec = adminRequest(api, http.MethodPost, "/api/servers/orders/openapi/validate", "", `{"paths":{}}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"valid":false`) {
+		t.Fatalf("invalid spec: %d %s", rec.Code, rec.Body)
+	}
+	if len(cs.specs) != 0 {
+		t.Fatalf("validation stored a spec: %v", cs.specs)
+	}
+	if names := toolNamesOf(t, g.store); names != "" {
+		t.Fatalf("validation stored tools: %s", names)
+	}
+
+	if rec := adminRequest(api, http.MethodPost, "/api/servers/orders/openapi/validate", "", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("empty body: status %d, want 400", rec.Code)
+	}
+	if rec := adminRequest(api, http.MethodPost, "/api/servers/nope/openapi/validate", "", spec); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown server: status %d, want 404", rec.Code)
+	}
+}
+
+func TestValidateMappingsEndpoint(t *testing.T) {
+	g := newTestGateway(t)
+	schema := map[string]interface{}{"type": "object", "properties": map[string]interface{}{"orderId": map[string]interface{}{"type": "string"}}}
+	good := getTool("get_order", "/orders/{{orderId}}")
+	good.InputSchema = schema
+	typo := getTool("cancel_order", "/orders/{{orderID}}/cancel")
+	typo.InputSchema = schema
+	g.tools(t, good, typo)
+	api := controlAPI(newControlStore(g.store), g.bus)
+
+	rec := adminRequest(api, http.MethodGet, "/api/servers/orders/mappings", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got struct {
+		Valid bool                   `json:"valid"`
+		Tools []engine.MappingReport `json:"tools"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Valid || len(got.Tools) != 2 {
+		t.Fatalf("report %s", rec.Body)
+	}
+	for _, rep := range got.Tools {
+		switch rep.Tool {
+		case "get_order":
+			if !rep.Valid {
+				t.Errorf("get_order: %+v", rep)
+			}
+		case "cancel_order":
+			if rep.Valid || len(rep.UndefinedPlaceholders) != 1 || rep.UndefinedPlaceholders[0].Name != "orderID" || !reflect.DeepEqual(rep.UnusedProperties, []string{"orderId"}) {
+				t.Errorf("cancel_order: %+v", rep)
+			}
+		}
+	}
+
+	// Fixing the typo makes the server valid
+	typo.Mapping.Path = "/orders/{{orderId}}/cancel"
+	g.tools(t, good, typo)
+	rec = adminRequest(api, http.MethodGet, "/api/servers/orders/mappings", "", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || !got.Valid {
+		t.Fatalf("after the fix: %s", rec.Body)
+	}
+
+	if rec := adminRequest(api, http.MethodGet, "/api/servers/billing/mappings", "", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown server: status %d", rec.Code)
+	}
+}
+
+func TestValidateConfigEndpoint(t *testing.T) {
+	g := newTestGateway(t)
+	h := ValidateConfigHandler(g.store)
+	var out struct {
+		Valid    bool                  `json:"valid"`
+		Problems []store.ConfigProblem `json:"problems"`
+	}
+	decode := func() {
+		t.Helper()
+		rec := adminRequest(h, http.MethodGet, "/api/validate", "", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d", rec.Code)
+		}
+		out.Problems = nil
+		if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The test gateway's orders server has no upstream until one is set
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {})
+	decode()
+	if !out.Valid || len(out.Problems) != 0 {
+		t.Fatalf("clean configuration: %+v", out)
+	}
+
+	if err := g.store.UpsertServer(store.Server{Slug: "billing", TenantSlug: "globex", Audience: "https://api.example.com", UpstreamBaseURL: "https://billing.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	decode()
+	if out.Valid || len(out.Problems) != 1 || out.Problems[0].Server != "billing" {
+		t.Fatalf("dangling server: %+v", out)
+	}
+}
+
+func TestFetchOpenAPIEndpoint(t *testing.T) {
+	g := newTestGateway(t)
+	cs := newControlStore(g.store)
+	api := controlAPI(cs, g.bus)
+	spec := `{"openapi":"3.0.0","info":{"title":"Orders"},"servers":[{"url":"https://orders.example.com"}],"paths":{"/orders":{"get":{"operationId":"listOrders"}}}}`
+	specServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/openapi.json":
+			_, _ = w.Write([]byte(spec))
+		case "/moved":
+			http.Redirect(w, r, "/openapi.json", http.StatusFound)
+		case "/elsewhere":
+			http.Redirect(w, r, "http://localhost"+strings.TrimPrefix(r.Host, "127.0.0.1")+"/openapi.json", http.StatusFound)
+		default:
+			_, _ = w.Write([]byte(`{"not":"a spec"}`))
+		}
+	}))
+	t.Cleanup(specServer.Close)
+
+	rec := adminRequest(api, http.MethodPost, "/api/servers/orders/openapi/fetch", "", `{"url":"`+specServer.URL+`/moved","generateTools":true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var out struct {
+		Version string       `json:"version"`
+		BaseURL string       `json:"baseUrl"`
+		Tools   []store.Tool `json:"tools"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Version != "3.0" || out.BaseURL != "https://orders.example.com" || len(out.Tools) != 1 {
+		t.Fatalf("response %s", rec.Body)
+	}
+	if len(cs.specs["orders"]) == 0 || cs.sources["orders"] != specServer.URL+"/moved" {
+		t.Fatalf("stored spec %q from %q", cs.specs["orders"], cs.sources["orders"])
+	}
+	if names := toolNamesOf(t, g.store); names != "listOrders" {
+		t.Fatalf("tools %q, want the generated tool stored", names)
+	}
+
+	cases := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"host outside the allowlist", "http://localhost" + strings.TrimPrefix(specServer.URL, "http://127.0.0.1") + "/openapi.json", http.StatusForbidden},
+		{"redirect outside the allowlist", specServer.URL + "/elsewhere", http.StatusForbidden},
+		{"not a spec", specServer.URL + "/other", http.StatusUnprocessableEntity},
+		{"not http", "file:///etc/passwd", http.StatusBadGateway},
+	}
+	for _, tc := range cases {
+		delete(cs.specs, "orders")
+		rec := adminRequest(api, http.MethodPost, "/api/servers/orders/openapi/fetch", "", `{"url":"`+tc.url+`"}`)
+		if rec.Code != tc.want {
+			t.Errorf("%s: status %d, want %d: %s", tc.name, rec.Code, tc.want, rec.Body)
+		}
+		if cs.specs["orders"] != nil {
+			t.Errorf("%s: a spec was stored", tc.name)
+		}
+	}
+}
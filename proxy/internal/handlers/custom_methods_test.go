@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// customMethods allowlists methods on server orders, served by an upstream that answers
+// each JSON-RPC request with its own id, method and params, or an error for custom/fail.
+func (g *testGateway) customMethods(t *testing.T, methods ...string) *atomic.Int32 {
+	t.Helper()
+	var hits atomic.Int32
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Method == "custom/fail" {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "error": map[string]interface{}{"code": -32050, "message": "busy"}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": map[string]interface{}{"path": r.URL.Path, "id": req.ID, "method": req.Method, "params": req.Params}})
+	})
+	srv, _ := g.store.GetServer("orders")
+	srv.CustomMethods = methods
+	srv.CustomMethodsPath = "/rpc"
+	if err := g.store.UpsertServer(srv); err != nil {
+		t.Fatal(err)
+	}
+	return &hits
+}
+
+func TestCustomMethodPassthrough(t *testing.T) {
+	g := newTestGateway(t)
+	hits := g.customMethods(t, "custom/ping", "custom/fail")
+	sid := g.initialize(t)
+
+	resp := g.post(t, sid, `{"jsonrpc":"2.0","id":"req-9","method":"custom/ping","params":{"n":1}}`)
+	raw, _ := io.ReadAll(resp.Body)
+	var out struct {
+		ID     json.RawMessage `json:"id"`
+		Result struct {
+			Path   string          `json:"path"`
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("body %s: %v", raw, err)
+	}
+	if string(out.ID) != `"req-9"` || out.Result.Path != "/rpc" || string(out.Result.ID) != `"req-9"` || out.Result.Method != "custom/ping" || string(out.Result.Params) != `{"n":1}` {
+		t.Fatalf("body %s, want the id and params passed through", raw)
+	}
+
+	// The upstream's JSON-RPC error reaches the client as is
+	if resp := g.call(t, sid, "custom/fail", nil); resp.Error == nil || resp.Error.Code != -32050 || resp.Error.Message != "busy" {
+		t.Fatalf("upstream error: got %+v", resp.Error)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Fatalf("upstream reached %d times, want 2", n)
+	}
+}
+
+func TestCustomMethodNotAllowlisted(t *testing.T) {
+	g := newTestGateway(t)
+	hits := g.customMethods(t, "custom/ping")
+	sid := g.initialize(t)
+
+	for _, method := range []string{"custom/other", "resources/list"} {
+		if resp := g.call(t, sid, method, nil); resp.Error == nil || resp.Error.Code != -32601 {
+			t.Fatalf("%s: got %+v, want -32601", method, resp.Error)
+		}
+	}
+	// An allowlisted method still needs a session
+	if resp := g.call(t, "", "custom/ping", nil); resp.Error == nil || resp.Error.Code != -32005 {
+		t.Fatalf("without a session: got %+v, want -32005", resp.Error)
+	}
+	if n := hits.Load(); n != 0 {
+		t.Fatalf("upstream reached %d times, want 0", n)
+	}
+}
+
+func TestUpsertServerRejectsGatewayMethods(t *testing.T) {
+	g := newTestGateway(t)
+	mux := chi.NewRouter()
+	mux.Post("/api/servers", UpsertServerHandler(newControlStore(g.store)))
+
+	const server = `{"slug":"orders","tenantSlug":"acme","name":"orders","audience":"https://api.example.com","enabled":true,"customMethods":[%s]}`
+	for _, method := range []string{`"tools/call"`, `"initialize"`, `"notifications/cancelled"`, `""`} {
+		if rec := adminRequest(mux, http.MethodPost, "/api/servers", "", fmt.Sprintf(server, method)); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", method, rec.Code)
+		}
+	}
+	if rec := adminRequest(mux, http.MethodPost, "/api/servers", "", fmt.Sprintf(server, `"custom/ping"`)); rec.Code >= 300 {
+		t.Fatalf("custom method: status %d: %s", rec.Code, rec.Body)
+	}
+	if srv, _ := g.store.GetServer("orders"); len(srv.CustomMethods) != 1 || srv.CustomMethods[0] != "custom/ping" {
+		t.Fatalf("custom methods %v", srv.CustomMethods)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+// elicitTool looks up an order by id, which the client may omit and supply when asked.
+func elicitTool() store.Tool {
+	return store.Tool{
+		Name:   "get_order",
+		Elicit: true,
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id":     map[string]interface{}{"type": "string", "description": "Order id"},
+				"expand": map[string]interface{}{"type": "string"},
+			},
+			"required": []interface{}{"id"},
+		},
+		Mapping: store.RequestTemplate{Method: http.MethodGet, Path: "/orders/{{id}}", Query: map[string]string{"expand": "{{expand}}"}},
+	}
+}
+
+type elicitationData struct {
+	Missing     []string `json:"missing"`
+	Elicitation struct {
+		ID              string `json:"id"`
+		Method          string `json:"method"`
+		RequestedSchema struct {
+			Properties map[string]map[string]interface{} `json:"properties"`
+			Required   []string                          `json:"required"`
+		} `json:"requestedSchema"`
+	} `json:"elicitation"`
+}
+
+func TestToolsCallElicitsMissingArgument(t *testing.T) {
+	g := newTestGateway(t)
+	seen := make(chan string, 1)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.URL.RequestURI()
+		_, _ = w.Write([]byte(`{}`))
+	})
+	g.tools(t, elicitTool())
+	sid := g.initialize(t)
+
+	resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "get_order", "arguments": map[string]interface{}{"expand": "items"}})
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Fatalf("got %+v, want -32602", resp)
+	}
+	var data elicitationData
+	if err := json.Unmarshal(resp.Error.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	el := data.Elicitation
+	if el.Method != "elicitation/create" || el.ID == "" || !reflect.DeepEqual(el.RequestedSchema.Required, []string{"id"}) || !reflect.DeepEqual(data.Missing, []string{"id"}) {
+		t.Fatalf("data = %s", resp.Error.Data)
+	}
+	if p := el.RequestedSchema.Properties["id"]; p["type"] != "string" || p["description"] != "Order id" {
+		t.Fatalf("requested id schema = %v", p)
+	}
+	if _, ok := el.RequestedSchema.Properties["expand"]; ok {
+		t.Fatalf("requested a supplied argument: %s", resp.Error.Data)
+	}
+
+	// The follow-up supplies only the missing value; the parked arguments are kept
+	follow := map[string]interface{}{"name": "get_order", "arguments": map[string]interface{}{"id": "42"}, "_meta": map[string]interface{}{"elicitationId": el.ID}}
+	if status, _ := toolResult(t, g.call(t, sid, "tools/call", follow)); status != http.StatusOK {
+		t.Fatalf("follow-up status %d", status)
+	}
+	if uri := <-seen; uri != "/orders/42?expand=items" {
+		t.Fatalf("upstream saw %q", uri)
+	}
+
+	// Each elicitation id is single-use
+	if resp := g.call(t, sid, "tools/call", follow); resp.Error == nil || resp.Error.Code != -32602 || resp.Error.Message != "invalid params: unknown elicitation" {
+		t.Fatalf("reused elicitation: %+v", resp.Error)
+	}
+}
+
+func TestToolsCallWithoutElicitFails(t *testing.T) {
+	g := newTestGateway(t)
+	tool := elicitTool()
+	tool.Elicit = false
+	g.tools(t, tool)
+
+	resp := g.call(t, g.initialize(t), "tools/call", map[string]interface{}{"name": "get_order", "arguments": map[string]interface{}{}})
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Fatalf("got %+v, want -32602", resp)
+	}
+	var data elicitationData
+	if err := json.Unmarshal(resp.Error.Data, &data); err != nil || data.Elicitation.Method != "" || !reflect.DeepEqual(data.Missing, []string{"id"}) {
+		t.Fatalf("data = %s", resp.Error.Data)
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/store"
+)
+
+func TestToolsCallEmptyResponse(t *testing.T) {
+	prev := config.EmptyResponse
+	t.Cleanup(func() { config.EmptyResponse = prev })
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/orders/1" {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	streamed := store.Tool{Name: "delete_streamed", Mapping: store.RequestTemplate{Method: http.MethodDelete, Path: "/orders/1", Stream: true}}
+	g.tools(t, getTool("delete_order", "/orders/1"), getTool("touch_order", "/orders/2"), streamed)
+	sid := g.initialize(t)
+
+	cases := []struct {
+		mode, tool string
+		status     int
+		want       string
+	}{
+		{config.EmptyResponseNull, "delete_order", http.StatusNoContent, `null`},
+		{config.EmptyResponseOK, "delete_order", http.StatusNoContent, `{"ok":true,"status":204}`},
+		{config.EmptyResponseOK, "touch_order", http.StatusOK, `{"ok":true,"status":200}`},
+		{config.EmptyResponseOK, "delete_streamed", http.StatusNoContent, `{"ok":true,"status":204}`},
+		{config.EmptyResponseText, "touch_order", http.StatusOK, `{"text":""}`},
+	}
+	for _, tc := range cases {
+		config.EmptyResponse = tc.mode
+		status, data := toolResult(t, g.call(t, sid, "tools/call", map[string]interface{}{"name": tc.tool}))
+		if status != tc.status || string(data) != tc.want {
+			t.Errorf("%s %s: %d %s, want %d %s", tc.mode, tc.tool, status, data, tc.status, tc.want)
+		}
+	}
+}
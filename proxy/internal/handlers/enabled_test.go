@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/events"
+)
+
+func TestDisabledToolIsHiddenAndNotCallable(t *testing.T) {
+	g := newTestGateway(t)
+	var hits atomic.Int32
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) { hits.Add(1) })
+	off := false
+	hidden := getTool("delete_order", "/orders/1")
+	hidden.Enabled = &off
+	g.tools(t, getTool("get_order", "/orders/1"), hidden)
+	sid := g.initialize(t)
+
+	list := g.call(t, sid, "tools/list", nil)
+	if list.Error != nil || !strings.Contains(string(list.Result), `"get_order"`) || strings.Contains(string(list.Result), "delete_order") {
+		t.Fatalf("tools/list %s %+v", list.Result, list.Error)
+	}
+	resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "delete_order"})
+	if resp.Error == nil || resp.Error.Code != -32001 {
+		t.Fatalf("calling a disabled tool: %+v, want -32001", resp.Error)
+	}
+	if n := hits.Load(); n != 0 {
+		t.Fatalf("disabled tool reached the upstream %d times", n)
+	}
+}
+
+func TestDisabledServerIsNotFound(t *testing.T) {
+	g := newTestGateway(t)
+	g.tools(t, getTool("get_order", "/orders/1"))
+	sid := g.initialize(t)
+	srv, _ := g.store.GetServer("orders")
+	srv.Enabled = false
+	if err := g.store.UpsertServer(srv); err != nil {
+		t.Fatal(err)
+	}
+	if resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "get_order"}); resp.Error == nil || resp.Error.Code != -32004 {
+		t.Fatalf("tools/call on a disabled server: %+v, want -32004", resp.Error)
+	}
+	if resp := g.call(t, "", "initialize", map[string]interface{}{"protocolVersion": config.MCPProtocolVersionLatest}); resp.Error == nil || resp.Error.Code != -32004 {
+		t.Fatalf("initialize on a disabled server: %+v, want -32004", resp.Error)
+	}
+}
+
+func TestSetToolsEnabledEndpoint(t *testing.T) {
+	g := newTestGateway(t)
+	g.tools(t, getTool("get_order", "/orders/1"), getTool("list_orders", "/orders"))
+	sid := g.initialize(t)
+	stream := g.openStream(t, "orders", sid)
+	api := controlAPI(newControlStore(g.store), g.bus)
+
+	rec := adminRequest(api, http.MethodPatch, "/api/servers/orders/tools/enabled", "", `{"names":["list_orders"],"enabled":false}`)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if m := nextMethod(t, stream, time.Second); m != events.ToolsListChanged {
+		t.Fatalf("notification %q, want %s", m, events.ToolsListChanged)
+	}
+	list := g.call(t, sid, "tools/list", nil)
+	if !strings.Contains(string(list.Result), `"get_order"`) || strings.Contains(string(list.Result), "list_orders") {
+		t.Fatalf("tools/list %s", list.Result)
+	}
+
+	for _, tc := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/api/servers/orders/tools/enabled", `{"names":["missing"],"enabled":false}`, http.StatusNotFound},
+		{"/api/servers/billing/tools/enabled", `{"names":["get_order"],"enabled":false}`, http.StatusNotFound},
+		{"/api/servers/orders/tools/enabled", `{"names":["get_order"]}`, http.StatusBadRequest},
+		{"/api/servers/orders/tools/enabled", `{"enabled":true}`, http.StatusBadRequest},
+	} {
+		if rec := adminRequest(api, http.MethodPatch, tc.path, "", tc.body); rec.Code != tc.want {
+			t.Errorf("%s %s: status %d, want %d", tc.path, tc.body, rec.Code, tc.want)
+		}
+	}
+}
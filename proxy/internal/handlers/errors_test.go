@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"gateway/proxy/internal/config"
+)
+
+// syncBuffer collects log output written from handler goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func captureLogs(t *testing.T) *syncBuffer {
+	t.Helper()
+	buf := &syncBuffer{}
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return buf
+}
+
+func withVerbosity(t *testing.T, v string) {
+	t.Helper()
+	prev := config.ErrorVerbosity
+	t.Cleanup(func() { config.ErrorVerbosity = prev })
+	config.ErrorVerbosity = v
+}
+
+func failingToolGateway(t *testing.T) (*testGateway, string) {
+	t.Helper()
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "db at 10.0.0.7 is down", http.StatusInternalServerError)
+	})
+	g.tools(t, getTool("get_order", "/orders/1"))
+	return g, g.initialize(t)
+}
+
+func TestProductionErrorHidesDetail(t *testing.T) {
+	withVerbosity(t, config.ErrorVerbosityProduction)
+	logs := captureLogs(t)
+	g, sid := failingToolGateway(t)
+
+	resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "get_order"})
+	if resp.Error == nil || resp.Error.Code != -32000 || resp.Error.Message != "internal error" {
+		t.Fatalf("error %+v, want -32000 internal error", resp.Error)
+	}
+	var data struct {
+		CorrelationID string `json:"correlationId"`
+	}
+	if err := json.Unmarshal(resp.Error.Data, &data); err != nil || data.CorrelationID == "" {
+		t.Fatalf("data %s has no correlationId", resp.Error.Data)
+	}
+	if strings.Contains(string(resp.Error.Data), "10.0.0.7") {
+		t.Fatalf("detail leaked to the client: %s", resp.Error.Data)
+	}
+
+	var entry map[string]interface{}
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, data.CorrelationID) {
+			_ = json.Unmarshal([]byte(line), &entry)
+		}
+	}
+	if entry["msg"] != "request failed" || entry["method"] != "tools/call" || !strings.Contains(entry["error"].(string), "500") {
+		t.Fatalf("log entry %v, want request failed for tools/call with the upstream status", entry)
+	}
+}
+
+func TestDebugErrorReturnsDetail(t *testing.T) {
+	withVerbosity(t, config.ErrorVerbosityDebug)
+	g, sid := failingToolGateway(t)
+
+	resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "get_order"})
+	if resp.Error == nil || resp.Error.Code != -32000 || resp.Error.Message == "internal error" {
+		t.Fatalf("error %+v, want the underlying error", resp.Error)
+	}
+	if strings.Contains(string(resp.Error.Data), "correlationId") {
+		t.Fatalf("debug error carries a correlation id: %s", resp.Error.Data)
+	}
+}
+
+func TestProductionUpstreamUnavailable(t *testing.T) {
+	withVerbosity(t, config.ErrorVerbosityProduction)
+	g := newTestGateway(t)
+	up := g.upstream(t, func(w http.ResponseWriter, r *http.Request) {})
+	up.Close()
+	g.tools(t, getTool("get_order", "/orders/1"))
+	sid := g.initialize(t)
+
+	resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "get_order"})
+	if resp.Error == nil || resp.Error.Code != -32007 || resp.Error.Message != "upstream unavailable" {
+		t.Fatalf("error %+v, want -32007 upstream unavailable", resp.Error)
+	}
+	if strings.Contains(string(resp.Error.Data), "127.0.0.1") {
+		t.Fatalf("upstream host leaked: %s", resp.Error.Data)
+	}
+}
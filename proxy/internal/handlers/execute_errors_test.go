@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"gateway/proxy/internal/engine"
+	"gateway/proxy/internal/store"
+)
+
+func TestToolsCallErrorCodes(t *testing.T) {
+	g := newTestGateway(t)
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(live.Close)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	down.Close()
+	u, _ := url.Parse(live.URL)
+	graphqlNoQuery := store.Tool{Name: "get_order", Mapping: store.RequestTemplate{Type: engine.MappingTypeGraphQL, Path: "/graphql"}}
+
+	cases := []struct {
+		name string
+		base string
+		tool store.Tool
+		code int
+	}{
+		{"egress denied", "http://localhost:" + u.Port(), getTool("get_order", "/orders/1"), -32006},
+		{"upstream unavailable", down.URL, getTool("get_order", "/orders/1"), -32007},
+		{"no upstream configured", "", getTool("get_order", "/orders/1"), -32008},
+		{"unbuildable request", live.URL, graphqlNoQuery, -32008},
+	}
+	sid := g.initialize(t)
+	for _, tc := range cases {
+		srv, err := g.store.GetServer("orders")
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.UpstreamBaseURL = tc.base
+		if err := g.store.UpsertServer(srv); err != nil {
+			t.Fatal(err)
+		}
+		g.tools(t, tc.tool)
+		resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "get_order"})
+		if resp.Error == nil || resp.Error.Code != tc.code {
+			t.Errorf("%s: got %+v, want error %d", tc.name, resp.Error, tc.code)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gateway/proxy/internal/engine"
+	"gateway/proxy/internal/store"
+)
+
+func TestToolsCallGraphQLErrors(t *testing.T) {
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"errors":[{"message":"order not found"},{"message":"access denied"}]}`))
+	})
+	g.tools(t, store.Tool{Name: "get_order", Mapping: store.RequestTemplate{Type: engine.MappingTypeGraphQL, Path: "/graphql", GraphQLQuery: "query($id: ID!) { order(id: $id) { id } }"}})
+	sid := g.initialize(t)
+
+	resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "get_order", "arguments": map[string]interface{}{"id": "7"}})
+	if resp.Error == nil || resp.Error.Code != -32000 {
+		t.Fatalf("error %+v, want -32000", resp.Error)
+	}
+	var data struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(resp.Error.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Errors) != 2 || data.Errors[0].Message != "order not found" {
+		t.Fatalf("data %s, want the GraphQL error list", resp.Error.Data)
+	}
+}
@@ -1,15 +1,31 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 
 	"gateway/proxy/internal/auth"
 	"gateway/proxy/internal/config"
 	"gateway/proxy/internal/engine"
+	"gateway/proxy/internal/events"
+	"gateway/proxy/internal/metrics"
 	"gateway/proxy/internal/session"
 	"gateway/proxy/internal/store"
 )
@@ -22,17 +38,16 @@ type ProtectedResourceMetadata struct {
 }
 
 func ProtectedResourceMetadataHandler(s interface {
-	GetServer(string) (store.Server, error)
-	GetTenant(string) (store.Tenant, error)
+	GetServerWithTenant(string) (store.Server, store.Tenant, error)
+	ListToolsByServer(string) ([]store.Tool, error)
 }) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		serverSlug := chi.URLParam(r, "server")
-		srv, err := s.GetServer(serverSlug)
+		srv, tenant, err := s.GetServerWithTenant(serverSlug)
 		if err != nil || !srv.Enabled {
 			http.Error(w, "server not found or disabled", http.StatusNotFound)
 			return
 		}
-		tenant, _ := s.GetTenant(srv.TenantSlug)
 		refsMap := map[string]store.AuthorizationServerRef{}
 		for _, iss := range tenant.AllowedIssuers {
 			refsMap[iss] = store.AuthorizationServerRef{Issuer: iss, MetadataURL: iss + "/.well-known/openid-configuration"}
@@ -44,7 +59,40 @@ func ProtectedResourceMetadataHandler(s interface {
 		for _, v := range refsMap {
 			refs = append(refs, v)
 		}
-		resp := ProtectedResourceMetadata{Resource: srv.Audience, AuthorizationServers: refs, TokenFormatsSupported: []string{"jwt"}}
+		resp := ProtectedResourceMetadata{Resource: srv.PrimaryAudience(), AuthorizationServers: refs, TokenFormatsSupported: []string{"jwt"}}
+		// Advertise the scopes the server's tools require so clients can request them up front
+		if tools, err := s.ListToolsByServer(serverSlug); err == nil {
+			resp.ScopesSupported = scopesForTools(tools)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// scopesForTools returns the sorted, deduplicated union of the tools' required scopes.
+func scopesForTools(tools []store.Tool) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, t := range tools {
+		for _, sc := range t.RequiredScopes {
+			if sc != "" && !seen[sc] {
+				seen[sc] = true
+				out = append(out, sc)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// RootProtectedResourceMetadataHandler serves aggregate metadata at the root well-known path for
+// clients that probe it before the per-server endpoint. Issuers are the union across tenants.
+func RootProtectedResourceMetadataHandler(s interface {
+	ResourceAudience() string
+	AllAuthorizationServerRefs() []store.AuthorizationServerRef
+}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := ProtectedResourceMetadata{Resource: s.ResourceAudience(), AuthorizationServers: s.AllAuthorizationServerRefs(), TokenFormatsSupported: []string{"jwt"}}
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(resp)
 	}
@@ -69,18 +117,26 @@ func ListToolsHandler(s interface {
 
 // Deprecated REST invoke handler removed in favor of JSON-RPC MCP endpoint.
 
-// JSON-RPC minimal types
+// toolsPageSize is the number of tools returned per tools/list page.
+const toolsPageSize = 100
+
+// toolCallTimeout caps upstream calls made outside a server's own budget (e.g. spec
+// fetches); the request context may impose a shorter deadline.
+const toolCallTimeout = engine.DefaultCallTimeout
+
+// JSON-RPC minimal types. Ids stay raw so they are echoed byte-for-byte: decoding into
+// interface{} would turn 1 into the float64 1.0. A nil id marshals as null.
 type jsonRPCRequest struct {
 	JSONRPC string          `json:"jsonrpc"`
-	ID      interface{}     `json:"id,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
 	Method  string          `json:"method"`
 	Params  json.RawMessage `json:"params,omitempty"`
 }
 type jsonRPCResponse struct {
-	JSONRPC string        `json:"jsonrpc"`
-	ID      interface{}   `json:"id,omitempty"`
-	Result  interface{}   `json:"result,omitempty"`
-	Error   *jsonRPCError `json:"error,omitempty"`
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *jsonRPCError   `json:"error,omitempty"`
 }
 type jsonRPCError struct {
 	Code    int         `json:"code"`
@@ -88,41 +144,24 @@ type jsonRPCError struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
-// MCPEndpointHandler implements the single POST endpoint for Streamable HTTP (JSON only for MVP)
+// MCPEndpointHandler implements the single POST endpoint for Streamable HTTP (JSON only for MVP).
+// A JSON array body is a JSON-RPC batch whose entries are dispatched concurrently.
 func MCPEndpointHandler(s interface {
-	GetServer(string) (store.Server, error)
-	GetTenant(string) (store.Tenant, error)
+	GetServerWithTenant(string) (store.Server, store.Tenant, error)
 	ListToolsByServer(string) ([]store.Tool, error)
-}, sm *session.Manager) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Origin validation (if configured and not unprotected)
-		if !config.Unprotected && len(config.AllowedOrigins) > 0 {
-			origin := r.Header.Get("Origin")
-			allowed := false
-			for _, o := range config.AllowedOrigins {
-				if o == origin {
-					allowed = true
-					break
-				}
-			}
-			if !allowed {
-				http.Error(w, "forbidden origin", http.StatusForbidden)
-				return
-			}
-		}
+	ListToolsByServerPaged(string, int, int, string) ([]store.Tool, int, error)
+	GetToolByName(serverSlug, name string) (store.Tool, error)
+}, sm *session.Manager, bus *events.Bus, clients *engine.ClientFactory, cache engine.Cache, lb *engine.Balancer, stdio *engine.StdioBridge, idem *engine.Idempotency) http.HandlerFunc {
+	single := func(w http.ResponseWriter, r *http.Request) {
+		// Origin/Host validation is applied by auth.OriginHostMiddleware on all MCP routes
 
-		// Protocol version header check (tolerant)
+		// Protocol version header: must be supported when present; required after initialize (below)
 		version := r.Header.Get("MCP-Protocol-Version")
-		if version != "" && version != config.MCPProtocolVersionLatest && version != config.MCPProtocolVersionFallback {
+		if version != "" && !config.IsSupportedProtocolVersion(version) {
 			http.Error(w, "unsupported MCP protocol version", http.StatusBadRequest)
 			return
 		}
 
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
 		serverSlug := chi.URLParam(r, "server")
 		var rpcReq jsonRPCRequest
 		if err := json.NewDecoder(r.Body).Decode(&rpcReq); err != nil {
@@ -133,6 +172,30 @@ func MCPEndpointHandler(s interface {
 			writeRPCError(w, rpcReq.ID, -32600, "invalid request", "jsonrpc must be 2.0")
 			return
 		}
+		if !validRPCID(rpcReq.ID) {
+			writeRPCError(w, nil, -32600, "invalid request", "id must be a string, number or null")
+			return
+		}
+
+		// Every post-initialize request must carry the version negotiated for its session
+		if rpcReq.Method != "initialize" {
+			if version == "" {
+				http.Error(w, "missing MCP-Protocol-Version header", http.StatusBadRequest)
+				return
+			}
+			if sid := sessionID(r); sid != "" {
+				if sess, err := sm.Get(sid); err == nil && sess.ProtocolVersion != "" && sess.ProtocolVersion != version {
+					http.Error(w, "MCP-Protocol-Version does not match the negotiated version "+sess.ProtocolVersion, http.StatusBadRequest)
+					return
+				}
+			}
+		}
+
+		// A request without an id is a notification: it gets no JSON-RPC response
+		if rpcReq.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
 
 		switch rpcReq.Method {
 		case "initialize":
@@ -144,29 +207,60 @@ func MCPEndpointHandler(s interface {
 					Name    string `json:"name"`
 					Version string `json:"version"`
 				} `json:"clientInfo"`
+				// Optional preferred language tag; takes precedence over Accept-Language
+				Locale string `json:"locale"`
 			}
 			if len(rpcReq.Params) > 0 {
 				if err := json.Unmarshal(rpcReq.Params, &initParams); err != nil {
 					writeRPCError(w, rpcReq.ID, -32602, "invalid params", nil)
 					return
 				}
+				if field := unknownParam(rpcReq.Params, initParams); field != "" {
+					writeUnknownParam(w, rpcReq.ID, field)
+					return
+				}
 			}
 
 			// Issue a new session and return session ID in header
-			srv, err := s.GetServer(serverSlug)
-			if err != nil {
+			srv, tenant, err := s.GetServerWithTenant(serverSlug)
+			if err != nil || !srv.Enabled {
 				writeRPCError(w, rpcReq.ID, -32004, "server not found", nil)
 				return
 			}
-			tenant, _ := s.GetTenant(srv.TenantSlug)
 			var claims map[string]interface{}
 			if c, ok := auth.ClaimsFromContext(r.Context()); ok {
 				claims = c
 			} else {
 				claims = map[string]interface{}{}
 			}
-			sess := sm.NewSession(serverSlug, tenant.Slug, claims)
-			w.Header().Set("Mcp-Session-Id", sess.ID)
+			// Echo the client's version when supported, otherwise offer our latest and let the client decide
+			negotiated := config.MCPProtocolVersionLatest
+			if config.IsSupportedProtocolVersion(initParams.ProtocolVersion) {
+				negotiated = initParams.ProtocolVersion
+			}
+			// The locale is kept on the session so tools/list localizes consistently
+			locales := requestedLocales(r.Header.Get("Accept-Language"))
+			if initParams.Locale != "" {
+				locales = append([]string{initParams.Locale}, locales...)
+			}
+			locale := ""
+			if len(locales) > 0 {
+				locale = locales[0]
+			}
+			sess, err := sm.NewSession(serverSlug, tenant.Slug, negotiated, locale, claims)
+			if err != nil {
+				if errors.Is(err, session.ErrSessionLimit) {
+					writeRPCError(w, rpcReq.ID, -32000, err.Error(), nil)
+					return
+				}
+				writeInternalError(w, r, rpcReq.ID, rpcReq.Method, err, nil)
+				return
+			}
+			setSessionID(w, r, sess.ID)
+			if bus != nil {
+				// Hold notifications until the client opens its GET stream
+				bus.Park(serverSlug, sess.ID)
+			}
 
 			// Build InitializeResult with per-server info
 			type ServerInfo struct {
@@ -175,43 +269,49 @@ func MCPEndpointHandler(s interface {
 				Version string `json:"version"`
 			}
 			result := map[string]interface{}{
-				"protocolVersion": config.MCPProtocolVersionLatest,
-				"capabilities": map[string]interface{}{
-					"prompts":   map[string]interface{}{},
-					"resources": map[string]interface{}{"subscribe": true},
-					// Per spec, declare tools capability and whether listChanged notifications are emitted
-					"tools":       map[string]interface{}{"listChanged": false},
-					"logging":     map[string]interface{}{},
-					"completions": map[string]interface{}{},
-					"elicitation": map[string]interface{}{},
-				},
+				"protocolVersion": negotiated,
+				"capabilities":    buildCapabilities(srv.EffectiveCapabilities()),
 				"serverInfo": ServerInfo{
 					Name:    srv.Name,
 					Title:   firstNonEmpty(srv.ServerTitle, srv.Name),
 					Version: firstNonEmpty(srv.ServerVersion, "0.1.0"),
 				},
-				"instructions": firstNonEmpty(srv.Instructions, "Welcome to Gateway MCP Proxy."),
+				"instructions": renderInstructions(localize(firstNonEmpty(srv.Instructions, "Welcome to Gateway MCP Proxy."), srv.LocalizedInstructions, locales), claims, config.KeepUnresolvedPlaceholders),
 			}
 			writeRPCResult(w, rpcReq.ID, result)
 			return
 		case "tools/list":
 			// Params: optional pagination cursor per spec
+			var listParams struct {
+				Cursor string `json:"cursor,omitempty"`
+			}
 			if len(rpcReq.Params) > 0 {
-				var listParams struct {
-					Cursor string `json:"cursor,omitempty"`
-				}
 				_ = json.Unmarshal(rpcReq.Params, &listParams) // tolerate unknown params
 			}
-			if sid := r.Header.Get("Mcp-Session-Id"); sid == "" {
+			offset, err := decodeCursor(listParams.Cursor)
+			if err != nil {
+				writeRPCError(w, rpcReq.ID, -32602, "invalid params: bad cursor", nil)
+				return
+			}
+			sid := sessionID(r)
+			if sid == "" {
 				writeRPCError(w, rpcReq.ID, -32005, "missing session", nil)
 				return
-			} else {
-				if _, err := sm.Get(sid); err != nil {
-					writeRPCError(w, rpcReq.ID, -32005, "session not found", nil)
-					return
-				}
 			}
-			tools, err := s.ListToolsByServer(serverSlug)
+			sess, err := sm.Get(sid)
+			if err != nil {
+				writeRPCError(w, rpcReq.ID, -32005, "session not found", nil)
+				return
+			}
+			if sess.ServerSlug != serverSlug {
+				writeRPCError(w, rpcReq.ID, -32005, "session does not belong to this server", nil)
+				return
+			}
+			locales := requestedLocales(r.Header.Get("Accept-Language"))
+			if sess.Locale != "" {
+				locales = append([]string{sess.Locale}, locales...)
+			}
+			tools, total, err := s.ListToolsByServerPaged(serverSlug, toolsPageSize, offset, "")
 			if err != nil {
 				writeRPCError(w, rpcReq.ID, -32004, "server not found", nil)
 				return
@@ -221,12 +321,13 @@ func MCPEndpointHandler(s interface {
 			for _, t := range tools {
 				tool := map[string]interface{}{
 					"name":        t.Name,
-					"description": t.Description,
+					"description": localize(t.Description, t.LocalizedDescriptions, locales),
 					"inputSchema": t.InputSchema,
+					"annotations": t.EffectiveAnnotations(),
 				}
 				// Add optional fields if present
-				if t.Title != "" {
-					tool["title"] = t.Title
+				if title := localize(t.Title, t.LocalizedTitles, locales); title != "" {
+					tool["title"] = title
 				}
 				if t.OutputSchema != nil {
 					// Only include outputSchema if it is a valid JSON Schema object with type=="object"
@@ -236,59 +337,102 @@ func MCPEndpointHandler(s interface {
 				}
 				out = append(out, tool)
 			}
-			// For MVP, return full list without pagination; omit nextCursor
 			result := map[string]interface{}{"tools": out}
+			if next := offset + len(tools); next < total {
+				result["nextCursor"] = encodeCursor(next)
+			}
 			writeRPCResult(w, rpcReq.ID, result)
 			return
 		case "tools/call":
-			if sid := r.Header.Get("Mcp-Session-Id"); sid == "" {
+			sid := sessionID(r)
+			if sid == "" {
 				writeRPCError(w, rpcReq.ID, -32005, "missing session", nil)
 				return
-			} else {
-				if _, err := sm.Get(sid); err != nil {
-					writeRPCError(w, rpcReq.ID, -32005, "session not found", nil)
-					return
-				}
 			}
-			// MCP spec shape only
+			sess, err := sm.Get(sid)
+			if err != nil {
+				writeRPCError(w, rpcReq.ID, -32005, "session not found", nil)
+				return
+			}
+			if sess.ServerSlug != serverSlug {
+				writeRPCError(w, rpcReq.ID, -32005, "session does not belong to this server", nil)
+				return
+			}
 			var params struct {
 				Name      string                 `json:"name"`
 				Arguments map[string]interface{} `json:"arguments"`
+				// Deprecated pre-spec aliases of name and arguments, accepted until the next release;
+				// toolId may also be the tool's id
+				ToolID string                 `json:"toolId"`
+				Args   map[string]interface{} `json:"args"`
+				Meta   struct {
+					// Set when answering an earlier elicitation for this call
+					ElicitationID string `json:"elicitationId,omitempty"`
+					// Deduplicates retries of mutating calls; forwarded upstream as Idempotency-Key
+					IdempotencyKey string `json:"idempotencyKey,omitempty"`
+					// Requests notifications/progress on the session stream while the call runs
+					ProgressToken json.RawMessage `json:"progressToken,omitempty"`
+				} `json:"_meta"`
+			}
+			// The whole _meta object, including fields the gateway does not act on
+			var rawMeta struct {
+				Meta map[string]interface{} `json:"_meta"`
 			}
-			if err := json.Unmarshal(rpcReq.Params, &params); err != nil {
+			if err := json.Unmarshal(rpcReq.Params, &params); err != nil || json.Unmarshal(rpcReq.Params, &rawMeta) != nil {
 				writeRPCError(w, rpcReq.ID, -32602, "invalid params", nil)
 				return
 			}
+			if field := unknownParam(rpcReq.Params, params); field != "" {
+				writeUnknownParam(w, rpcReq.ID, field)
+				return
+			}
+			legacy := params.Name == "" && params.ToolID != ""
+			if legacy || (params.Arguments == nil && params.Args != nil) {
+				slog.WarnContext(r.Context(), "deprecated tools/call params; send name and arguments", "server", serverSlug)
+			}
+			if params.Arguments == nil {
+				params.Arguments = params.Args
+			}
+			if legacy {
+				params.Name = params.ToolID
+			}
 			if params.Name == "" {
 				writeRPCError(w, rpcReq.ID, -32602, "invalid params: missing tool name", nil)
 				return
 			}
-			// Resolve tool strictly by name per MCP spec
-			toolsForServer, err := s.ListToolsByServer(serverSlug)
+			// Resolve tool by the name tools/list advertises; disabled tools are reported as not
+			// found to avoid leaking their existence
+			tool, err := s.GetToolByName(serverSlug, params.Name)
+			if legacy && errors.Is(err, store.ErrToolNotFound) {
+				tool, err = toolByID(s, serverSlug, params.ToolID)
+			}
+			if errors.Is(err, store.ErrToolNotFound) {
+				writeRPCError(w, rpcReq.ID, -32001, "tool not found", nil)
+				return
+			}
 			if err != nil {
 				writeRPCError(w, rpcReq.ID, -32004, "server not found", nil)
 				return
 			}
-			var (
-				tool  store.Tool
-				found bool
-			)
-			for _, t := range toolsForServer {
-				if t.Name == params.Name {
-					tool = t
-					found = true
-					break
-				}
-			}
-			if !found {
-				writeRPCError(w, rpcReq.ID, -32001, "tool not found", nil)
+			srv, tenant, err := s.GetServerWithTenant(serverSlug)
+			if err != nil || !srv.Enabled {
+				writeRPCError(w, rpcReq.ID, -32004, "server not found", nil)
 				return
 			}
 			// Scope check (skip if unprotected)
 			if !config.Unprotected {
 				if claims, ok := auth.ClaimsFromContext(r.Context()); ok {
-					if !hasRequiredScopes(claims, tool.RequiredScopes) {
-						writeRPCError(w, rpcReq.ID, -32002, "insufficient_scope", nil)
+					scopeClaims := srv.ScopeClaimNames(tenant)
+					if missing := missingScopes(claims, scopeClaims, tool.RequiredScopes); len(missing) > 0 {
+						writeScopeError(w, r, rpcReq.ID, missing, nil)
+						return
+					}
+					if methodScopes := srv.MethodScopesFor(tool.Mapping.Method); !tool.SkipMethodScopes && !hasAnyScope(claims, scopeClaims, methodScopes) {
+						writeScopeError(w, r, rpcReq.ID, nil, methodScopes)
+						return
+					}
+					if !hasRequiredClaims(claims, tool.RequiredClaims) {
+						writeRPCError(w, rpcReq.ID, -32002, "insufficient_claims", nil)
 						return
 					}
 				} else {
@@ -296,41 +440,387 @@ func MCPEndpointHandler(s interface {
 					return
 				}
 			}
-			srv, _ := s.GetServer(serverSlug)
-			tenant, _ := s.GetTenant(srv.TenantSlug)
-			client := &http.Client{Timeout: 20 * time.Second}
-			res, err := engine.Execute(r.Context(), client, srv, tenant, tool, params.Arguments)
+			// A follow-up to an elicitation merges the newly supplied values over the parked arguments
+			if eid := params.Meta.ElicitationID; eid != "" {
+				pending, ok := sm.TakePendingCall(sid, eid)
+				if !ok || pending.ToolName != tool.Name {
+					writeRPCError(w, rpcReq.ID, -32602, "invalid params: unknown elicitation", nil)
+					return
+				}
+				merged := make(map[string]interface{}, len(pending.Arguments)+len(params.Arguments))
+				for k, v := range pending.Arguments {
+					merged[k] = v
+				}
+				for k, v := range params.Arguments {
+					merged[k] = v
+				}
+				params.Arguments = merged
+			}
+			args, err := coerceArgs(tool.InputSchema, tool.Defaults, params.Arguments, config.StrictToolArgs)
+			if err != nil {
+				var ae *argsError
+				if !errors.As(err, &ae) {
+					writeRPCError(w, rpcReq.ID, -32602, err.Error(), nil)
+					return
+				}
+				if len(ae.Missing) > 0 && tool.Elicit {
+					eid, perr := sm.AddPendingCall(sid, session.PendingCall{ToolName: tool.Name, Arguments: params.Arguments})
+					if perr == nil {
+						writeRPCError(w, rpcReq.ID, -32602, ae.Message, map[string]interface{}{
+							"missing":     ae.Missing,
+							"elicitation": buildElicitation(eid, tool, ae.Missing),
+						})
+						return
+					}
+				}
+				writeRPCError(w, rpcReq.ID, -32602, ae.Message, ae)
+				return
+			}
+			auditToolCall(r, serverSlug, srv.TenantSlug, sid, tool, args, rawMeta.Meta)
+			outcome := "error"
+			defer func(name string) { recordToolCall(srv.TenantSlug, serverSlug, name, outcome) }(tool.Name)
+			// The upstream deadline derives from the request context so client disconnects and the
+			// router timeout cancel the in-flight call; the client itself carries no timeout.
+			ctx, cancel := context.WithTimeout(r.Context(), engine.CallTimeout(srv))
+			defer cancel()
+			if rawMeta.Meta != nil {
+				ctx = engine.WithRequestMeta(ctx, rawMeta.Meta)
+			}
+			if token := params.Meta.ProgressToken; token != nil && bus != nil {
+				progress := newProgressReporter(bus, serverSlug, sid, token)
+				defer progress.stop()
+				ctx = engine.WithProgress(ctx, progress.bytes)
+			}
+			if claims, ok := auth.ClaimsFromContext(r.Context()); ok {
+				ctx = engine.WithClaims(ctx, claims)
+			}
+			if srv.Backend == engine.BackendStdio {
+				// The child already speaks MCP, so its CallToolResult is relayed unchanged
+				result, err := stdio.CallTool(ctx, srv, tool.Name, args)
+				if err != nil {
+					var rpcErr *engine.StdioRPCError
+					if errors.As(err, &rpcErr) {
+						writeRPCError(w, rpcReq.ID, rpcErr.Code, rpcErr.Message, rpcErr.Data)
+						return
+					}
+					writeInternalError(w, r, rpcReq.ID, rpcReq.Method, err, nil)
+					return
+				}
+				outcome = "ok"
+				writeRPCResult(w, rpcReq.ID, result)
+				return
+			}
+			// Idempotent replay needs the whole result, so keyed calls are buffered
+			if engine.Streams(tool) && (params.Meta.IdempotencyKey == "" || idem == nil) {
+				st, err := engine.ExecuteStream(ctx, lb, clients.ServerClient(srv), srv, tenant, tool, args)
+				if err != nil {
+					writeExecuteError(w, r, rpcReq.ID, rpcReq.Method, err)
+					return
+				}
+				defer st.Body.Close()
+				if statusErr := st.StatusError(); statusErr != nil {
+					writeInternalError(w, r, rpcReq.ID, rpcReq.Method, statusErr, statusErr)
+					return
+				}
+				outcome = "ok"
+				writeRPCStream(w, r, rpcReq.ID, st, resultMeta(engine.ResponseHeaders(srv, tool, st.UpstreamHeaders), params.Meta.ProgressToken))
+				return
+			}
+			call := func(ctx context.Context) (*engine.ExecuteResult, error) {
+				return engine.ExecuteCached(ctx, cache, lb, clients.ServerClient(srv), srv, tenant, tool, args)
+			}
+			var res *engine.ExecuteResult
+			if key := params.Meta.IdempotencyKey; key != "" && idem != nil {
+				ctx = engine.WithIdempotencyKey(ctx, key)
+				// Scope by subject so one caller's key never replays another caller's result
+				claims, _ := auth.ClaimsFromContext(r.Context())
+				sub, _ := claims["sub"].(string)
+				res, _, err = idem.Do(ctx, idem.Key(key, serverSlug+"\x00"+sub, tool.Name, args), call)
+			} else {
+				res, err = call(ctx)
+			}
 			if err != nil {
-				writeRPCError(w, rpcReq.ID, -32000, err.Error(), nil)
+				writeExecuteError(w, r, rpcReq.ID, rpcReq.Method, err)
 				return
 			}
-			writeRPCResult(w, rpcReq.ID, map[string]interface{}{"status": res.UpstreamStatus, "data": json.RawMessage(res.UpstreamBody)})
+			if statusErr := res.StatusError(); statusErr != nil {
+				writeInternalError(w, r, rpcReq.ID, rpcReq.Method, statusErr, statusErr)
+				return
+			}
+			result := map[string]interface{}{"status": res.UpstreamStatus, "data": json.RawMessage(res.UpstreamBody)}
+			if meta := resultMeta(engine.ResponseHeaders(srv, tool, res.UpstreamHeaders), params.Meta.ProgressToken); meta != nil {
+				result["_meta"] = meta
+			}
+			outcome = "ok"
+			writeRPCResult(w, rpcReq.ID, result)
 			return
 			// removed duplicate initialize case
 		case "terminate":
-			if sid := r.Header.Get("Mcp-Session-Id"); sid != "" {
+			if sid := sessionID(r); sid != "" {
 				sm.Delete(sid)
+				clearSessionID(w, r)
 				writeRPCResult(w, rpcReq.ID, map[string]interface{}{"terminated": true})
 				return
 			}
 			writeRPCError(w, rpcReq.ID, -32005, "missing session", nil)
 			return
 		default:
-			writeRPCError(w, rpcReq.ID, -32601, "method not found", nil)
+			srv, tenant, err := s.GetServerWithTenant(serverSlug)
+			if err != nil || !srv.Enabled || !hasCustomMethod(srv, rpcReq.Method) {
+				writeRPCError(w, rpcReq.ID, -32601, "method not found", nil)
+				return
+			}
+			sid := sessionID(r)
+			if sid == "" {
+				writeRPCError(w, rpcReq.ID, -32005, "missing session", nil)
+				return
+			}
+			sess, err := sm.Get(sid)
+			if err != nil {
+				writeRPCError(w, rpcReq.ID, -32005, "session not found", nil)
+				return
+			}
+			if sess.ServerSlug != serverSlug {
+				writeRPCError(w, rpcReq.ID, -32005, "session does not belong to this server", nil)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), engine.CallTimeout(srv))
+			defer cancel()
+			if claims, ok := auth.ClaimsFromContext(r.Context()); ok {
+				ctx = engine.WithClaims(ctx, claims)
+			}
+			var result json.RawMessage
+			if srv.Backend == engine.BackendStdio {
+				result, err = stdio.Call(ctx, srv, rpcReq.Method, rpcReq.Params)
+			} else {
+				result, err = engine.CallMethod(ctx, lb, clients.ServerClient(srv), srv, tenant, rpcReq.ID, rpcReq.Method, rpcReq.Params)
+			}
+			if err != nil {
+				var stdioErr *engine.StdioRPCError
+				var upstreamErr *engine.UpstreamRPCError
+				switch {
+				case errors.As(err, &stdioErr):
+					writeRPCError(w, rpcReq.ID, stdioErr.Code, stdioErr.Message, stdioErr.Data)
+				case errors.As(err, &upstreamErr):
+					writeRPCError(w, rpcReq.ID, upstreamErr.Code, upstreamErr.Message, upstreamErr.Data)
+				default:
+					writeExecuteError(w, r, rpcReq.ID, rpcReq.Method, err)
+				}
+				return
+			}
+			writeRPCResult(w, rpcReq.ID, result)
 			return
 		}
 	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isBatch(r) {
+			serveBatch(single, w, r)
+			return
+		}
+		single(w, r)
+	}
 }
 
-func writeRPCResult(w http.ResponseWriter, id interface{}, result interface{}) {
+func writeRPCResult(w http.ResponseWriter, id json.RawMessage, result interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(jsonRPCResponse{JSONRPC: "2.0", ID: id, Result: result})
 }
-func writeRPCError(w http.ResponseWriter, id interface{}, code int, message string, data interface{}) {
+
+// resultMeta builds a tools/call result's _meta: passed-through upstream response headers
+// and the request's progressToken, echoed so clients can tie the result to its
+// notifications. It returns nil when there is neither.
+func resultMeta(headers map[string]string, progressToken json.RawMessage) map[string]interface{} {
+	meta := map[string]interface{}{}
+	if headers != nil {
+		meta["responseHeaders"] = headers
+	}
+	if progressToken != nil {
+		meta["progressToken"] = progressToken
+	}
+	if len(meta) == 0 {
+		return nil
+	}
+	return meta
+}
+func writeRPCError(w http.ResponseWriter, id json.RawMessage, code int, message string, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(jsonRPCResponse{JSONRPC: "2.0", ID: id, Error: &jsonRPCError{Code: code, Message: message, Data: data}})
 }
 
+// writeInternalError reports a -32000 failure whose message may expose internals such as
+// upstream hosts or Go error strings. In production verbosity the client only gets a
+// generic message and a correlation id under which the detail is logged; in debug
+// verbosity err and data are returned as-is. method is the JSON-RPC method that failed.
+func writeInternalError(w http.ResponseWriter, r *http.Request, id json.RawMessage, method string, err error, data interface{}) {
+	writeOpaqueError(w, r, id, method, -32000, "internal error", err, data)
+}
+
+// writeOpaqueError is writeInternalError with its own code and production message.
+func writeOpaqueError(w http.ResponseWriter, r *http.Request, id json.RawMessage, method string, code int, message string, err error, data interface{}) {
+	if config.ErrorVerbosity == config.ErrorVerbosityDebug {
+		writeRPCError(w, id, code, err.Error(), data)
+		return
+	}
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	correlationID := hex.EncodeToString(b[:])
+	slog.ErrorContext(r.Context(), "request failed", "method", method, "correlation_id", correlationID, "request_id", middleware.GetReqID(r.Context()), "error", err.Error())
+	writeRPCError(w, id, code, message, map[string]string{"correlationId": correlationID})
+}
+
+// writeExecuteError maps an engine execution error to its JSON-RPC error: -32006 egress
+// denied, -32007 upstream unavailable, -32008 invalid tool mapping, anything else -32000.
+// Egress is checked first because a blocked redirect also surfaces as an UpstreamError.
+func writeExecuteError(w http.ResponseWriter, r *http.Request, id json.RawMessage, method string, err error) {
+	var upstreamErr *engine.UpstreamError
+	var gqlErr *engine.GraphQLError
+	switch {
+	case errors.Is(err, engine.ErrEgressDenied):
+		writeOpaqueError(w, r, id, method, -32006, "egress denied", err, nil)
+	case errors.Is(err, engine.ErrBadMapping):
+		writeOpaqueError(w, r, id, method, -32008, "invalid tool mapping", err, nil)
+	case errors.Is(err, engine.ErrUpstreamDown):
+		// Fail-fast health state names no upstream details
+		writeRPCError(w, id, -32007, err.Error(), nil)
+	case errors.Is(err, engine.ErrResponseTooLarge):
+		writeRPCError(w, id, -32000, engine.ErrResponseTooLarge.Error(), nil)
+	case errors.As(err, &upstreamErr):
+		writeOpaqueError(w, r, id, method, -32007, "upstream unavailable", err, upstreamErr)
+	case errors.As(err, &gqlErr):
+		// GraphQL errors are the upstream API's own answer, not gateway internals
+		writeRPCError(w, id, -32000, err.Error(), gqlErr)
+	default:
+		writeInternalError(w, r, id, method, err, nil)
+	}
+}
+
+// gatewayMethods are answered by the gateway itself and cannot be passed through.
+var gatewayMethods = map[string]bool{"initialize": true, "tools/list": true, "tools/call": true, "terminate": true}
+
+// hasCustomMethod reports whether srv passes method through to its upstream.
+func hasCustomMethod(srv store.Server, method string) bool {
+	if gatewayMethods[method] {
+		return false
+	}
+	for _, m := range srv.CustomMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownParam returns the first top-level field of raw that the params struct v does not
+// declare, or "" when there is none or config.StrictParams is off. Nested objects are not
+// checked, and _meta is always allowed. Fields are matched exactly, not case-insensitively
+// as json.Unmarshal does.
+func unknownParam(raw json.RawMessage, v interface{}) string {
+	if !config.StrictParams {
+		return ""
+	}
+	known := map[string]bool{"_meta": true}
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		known[name] = true
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return ""
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		if key, _ := tok.(string); !known[key] {
+			return key
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return ""
+		}
+	}
+	return ""
+}
+
+func writeUnknownParam(w http.ResponseWriter, id json.RawMessage, field string) {
+	writeRPCError(w, id, -32602, "invalid params: unknown field "+strconv.Quote(field), map[string]interface{}{"field": field})
+}
+
+// validRPCID reports whether a raw id is absent, null, a string or a number.
+func validRPCID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	switch id[0] {
+	case '"', 'n', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return true
+	}
+	return false
+}
+
+// buildCapabilities renders the initialize capabilities object, advertising only what is enabled.
+func buildCapabilities(c store.ServerCapabilities) map[string]interface{} {
+	caps := map[string]interface{}{}
+	if c.Tools {
+		// Per spec, declare tools capability and whether listChanged notifications are emitted
+		caps["tools"] = map[string]interface{}{"listChanged": true}
+	}
+	if c.Prompts {
+		caps["prompts"] = map[string]interface{}{}
+	}
+	if c.Resources {
+		caps["resources"] = map[string]interface{}{"subscribe": c.ResourcesSubscribe}
+	}
+	if c.Logging {
+		caps["logging"] = map[string]interface{}{}
+	}
+	if c.Completions {
+		caps["completions"] = map[string]interface{}{}
+	}
+	if c.Elicitation {
+		caps["elicitation"] = map[string]interface{}{}
+	}
+	return caps
+}
+
+// encodeCursor and decodeCursor keep tools/list cursors opaque to clients.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(string(b))
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid cursor")
+	}
+	return n, nil
+}
+
+// toolByID finds an enabled tool by id, for the deprecated toolId param.
+func toolByID(s interface {
+	ListToolsByServer(string) ([]store.Tool, error)
+}, serverSlug, id string) (store.Tool, error) {
+	tools, err := s.ListToolsByServer(serverSlug)
+	if err != nil {
+		return store.Tool{}, err
+	}
+	for _, t := range tools {
+		if t.ID == id && t.IsEnabled() {
+			return t, nil
+		}
+	}
+	return store.Tool{}, store.ErrToolNotFound
+}
+
 func firstNonEmpty(values ...string) string {
 	for _, v := range values {
 		if v != "" {
@@ -340,11 +830,28 @@ func firstNonEmpty(values ...string) string {
 	return ""
 }
 
+// mcpAllowedMethods are the methods served on the MCP endpoint routes.
+const mcpAllowedMethods = "GET, POST, DELETE, OPTIONS"
+
+// MCPMethodHandler answers the methods the MCP endpoint routes do not otherwise serve:
+// OPTIONS gets 204 and anything else 405, both with an Allow header. It needs no
+// credentials, so clients and proxies can probe the endpoint (e.g. CORS preflights).
+func MCPMethodHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", mcpAllowedMethods)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 // MCPSessionDeleteHandler handles HTTP DELETE to terminate a session
 func MCPSessionDeleteHandler(sm *session.Manager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		serverSlug := chi.URLParam(r, "server")
-		sid := r.Header.Get("Mcp-Session-Id")
+		sid := sessionID(r)
 		if sid == "" {
 			http.Error(w, "missing session", http.StatusBadRequest)
 			return
@@ -359,37 +866,274 @@ func MCPSessionDeleteHandler(sm *session.Manager) http.HandlerFunc {
 			return
 		}
 		sm.Delete(sid)
+		clearSessionID(w, r)
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
-func hasRequiredScopes(claims map[string]interface{}, required []string) bool {
+var toolCalls = metrics.NewCounterVec("mcp_tool_calls_total", "Tool calls that reached the backend, by outcome.", "tenant", "server", "tool", "outcome")
+
+// toolLabels keeps the tool label of toolCalls within config.MetricsMaxToolLabels.
+var toolLabels = metrics.NewLabelGuard()
+
+// recordToolCall counts a tool call; outcome is "ok" or "error".
+func recordToolCall(tenant, server, tool, outcome string) {
+	label := toolLabels.Value(config.MetricsMaxToolLabels, server+"\x00"+tool, tool)
+	toolCalls.Inc(tenant, server, label, outcome)
+}
+
+// streamKeepAlive is how often an idle SSE stream emits a comment so proxies keep it open.
+const streamKeepAlive = 25 * time.Second
+
+var (
+	sseStreamsOpen       = metrics.NewGaugeVec("mcp_sse_streams_open", "Open SSE notification streams by tenant.", "tenant")
+	sseStreamsRejected   = metrics.NewCounterVec("mcp_sse_streams_rejected_total", "SSE streams refused by the per-tenant stream cap.", "tenant")
+	sseStreamsSlowClosed = metrics.NewCounterVec("mcp_sse_streams_slow_closed_total", "SSE streams closed because the client stopped reading.", "tenant")
+)
+
+// sseStreams counts open SSE streams per tenant for config.SSEMaxStreamsPerTenant.
+var sseStreams = struct {
+	mu     sync.Mutex
+	counts map[string]int
+}{counts: map[string]int{}}
+
+// acquireStream reserves a stream slot for tenant, reporting false at the cap.
+func acquireStream(tenant string) bool {
+	sseStreams.mu.Lock()
+	defer sseStreams.mu.Unlock()
+	if max := config.SSEMaxStreamsPerTenant; max > 0 && sseStreams.counts[tenant] >= max {
+		return false
+	}
+	sseStreams.counts[tenant]++
+	sseStreamsOpen.Set(float64(sseStreams.counts[tenant]), tenant)
+	return true
+}
+
+func releaseStream(tenant string) {
+	sseStreams.mu.Lock()
+	defer sseStreams.mu.Unlock()
+	if sseStreams.counts[tenant]--; sseStreams.counts[tenant] <= 0 {
+		delete(sseStreams.counts, tenant)
+	}
+	sseStreamsOpen.Set(float64(sseStreams.counts[tenant]), tenant)
+}
+
+// MCPStreamHandler serves HTTP GET as the Streamable HTTP SSE stream, carrying
+// server-initiated notifications for the session until the client disconnects.
+// Notifications published since initialize (or since the previous stream closed) are
+// delivered first. Requests that do not accept text/event-stream get 405; a tenant at
+// its stream cap gets 429. A client that stops reading is cut off: a write that does not
+// complete within config.SSEWriteTimeout, or a notification buffer that fills up, ends
+// the stream (the latter with an error event).
+func MCPStreamHandler(sm *session.Manager, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serverSlug := chi.URLParam(r, "server")
+		// The GET endpoint only serves event streams (the router timeout also keys on this Accept)
+		if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			w.Header().Set("Allow", mcpAllowedMethods)
+			http.Error(w, "GET requires Accept: text/event-stream", http.StatusMethodNotAllowed)
+			return
+		}
+		sid := sessionID(r)
+		if sid == "" {
+			http.Error(w, "missing session", http.StatusBadRequest)
+			return
+		}
+		s, err := sm.Get(sid)
+		if err != nil {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+		if s.ServerSlug != serverSlug {
+			http.Error(w, "session does not belong to this server", http.StatusBadRequest)
+			return
+		}
+		if !acquireStream(s.TenantSlug) {
+			sseStreamsRejected.Inc(s.TenantSlug)
+			http.Error(w, "too many open streams for this tenant", http.StatusTooManyRequests)
+			return
+		}
+		defer releaseStream(s.TenantSlug)
+		rc := http.NewResponseController(w)
+		// The stream outlives the server-wide write timeout; each write gets its own deadline
+		_ = rc.SetWriteDeadline(time.Now().Add(config.SSEWriteTimeout))
+
+		queue, unsubscribe := bus.Subscribe(serverSlug, sid)
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		ticker := time.NewTicker(streamKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				_ = rc.SetWriteDeadline(time.Now().Add(config.SSEWriteTimeout))
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+			case n, ok := <-queue:
+				if !ok {
+					if errors.Is(unsubscribe(), events.ErrSlowConsumer) {
+						sseStreamsSlowClosed.Inc(s.TenantSlug)
+						_ = rc.SetWriteDeadline(time.Now().Add(config.SSEWriteTimeout))
+						_, _ = fmt.Fprintf(w, "event: error\ndata: %s\n\n", events.ErrSlowConsumer)
+						_ = rc.Flush()
+					}
+					// otherwise superseded by a newer stream for the same session
+					return
+				}
+				_ = rc.SetWriteDeadline(time.Now().Add(config.SSEWriteTimeout))
+				if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", notificationJSON(n)); err != nil {
+					return
+				}
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// notificationJSON encodes n as a JSON-RPC notification message.
+func notificationJSON(n events.Notification) []byte {
+	b, _ := json.Marshal(struct {
+		JSONRPC string `json:"jsonrpc"`
+		events.Notification
+	}{JSONRPC: "2.0", Notification: n})
+	return b
+}
+
+// missingScopes returns the required scopes the claims do not grant, in required order.
+func missingScopes(claims map[string]interface{}, scopeClaims []string, required []string) []string {
 	if len(required) == 0 {
+		return nil
+	}
+	have := grantedScopes(claims, scopeClaims)
+	var missing []string
+	for _, need := range required {
+		if !have[need] {
+			missing = append(missing, need)
+		}
+	}
+	return missing
+}
+
+// writeScopeError answers a tools/call whose token lacks scopes with -32002. The data
+// names what is needed: missingScopes (all required) or anyOf (method scope patterns,
+// one suffices), plus the RFC 6750 challenge a client can use to request them, which is
+// also set as the WWW-Authenticate header.
+func writeScopeError(w http.ResponseWriter, r *http.Request, id json.RawMessage, missing, anyOf []string) {
+	scopes := missing
+	data := map[string]interface{}{"error": "insufficient_scope"}
+	if len(missing) > 0 {
+		data["missingScopes"] = missing
+	} else {
+		data["anyOf"] = anyOf
+		// Wildcard patterns cannot be requested from an authorization server
+		for _, p := range anyOf {
+			if !strings.HasSuffix(p, "*") {
+				scopes = append(scopes, p)
+			}
+		}
+	}
+	challenge := auth.InsufficientScopeChallenge(r, scopes)
+	data["scope"] = strings.Join(scopes, " ")
+	data["wwwAuthenticate"] = challenge
+	w.Header().Set("WWW-Authenticate", challenge)
+	writeRPCError(w, id, -32002, "insufficient_scope", data)
+}
+
+// hasAnyScope reports whether the claims grant at least one of the patterns; a pattern
+// ending in "*" matches any scope with that prefix. An empty list allows everything.
+func hasAnyScope(claims map[string]interface{}, scopeClaims []string, patterns []string) bool {
+	if len(patterns) == 0 {
 		return true
 	}
-	// support space-separated scope string per OAuth 2.0
-	var have map[string]bool = map[string]bool{}
-	if s, ok := claims["scope"].(string); ok {
-		for _, part := range splitSpaces(s) {
-			have[part] = true
+	have := grantedScopes(claims, scopeClaims)
+	for _, p := range patterns {
+		if prefix, wildcard := strings.CutSuffix(p, "*"); wildcard {
+			for sc := range have {
+				if strings.HasPrefix(sc, prefix) {
+					return true
+				}
+			}
+		} else if have[p] {
+			return true
 		}
 	}
-	// some IDPs may put scopes in an array claim
-	if arr, ok := claims["scopes"].([]interface{}); ok {
-		for _, v := range arr {
-			if vs, ok := v.(string); ok {
-				have[vs] = true
+	return false
+}
+
+// grantedScopes collects scopes from the named claims, each either a space-separated
+// string (as OAuth 2.0 "scope") or an array of strings (as "scopes", "scp" or
+// "permissions" of some IDPs). API key claims always carry their scopes in "scope".
+func grantedScopes(claims map[string]interface{}, scopeClaims []string) map[string]bool {
+	if claims["auth_method"] == "api_key" {
+		scopeClaims = store.DefaultScopeClaims
+	}
+	have := map[string]bool{}
+	for _, name := range scopeClaims {
+		switch v := claims[name].(type) {
+		case string:
+			for _, part := range splitSpaces(v) {
+				have[part] = true
+			}
+		case []interface{}:
+			for _, x := range v {
+				if xs, ok := x.(string); ok {
+					have[xs] = true
+				}
 			}
 		}
 	}
-	for _, need := range required {
-		if !have[need] {
+	return have
+}
+
+// hasRequiredClaims checks each required claim with string equality or array membership.
+func hasRequiredClaims(claims map[string]interface{}, required map[string]interface{}) bool {
+	for name, want := range required {
+		have, ok := claims[name]
+		if !ok {
+			return false
+		}
+		wants := []interface{}{want}
+		if arr, ok := want.([]interface{}); ok {
+			wants = arr
+		}
+		matched := false
+		for _, w := range wants {
+			if claimContains(have, w) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
 			return false
 		}
 	}
 	return true
 }
 
+func claimContains(have interface{}, want interface{}) bool {
+	ws := fmt.Sprintf("%v", want)
+	if arr, ok := have.([]interface{}); ok {
+		for _, h := range arr {
+			if fmt.Sprintf("%v", h) == ws {
+				return true
+			}
+		}
+		return false
+	}
+	return fmt.Sprintf("%v", have) == ws
+}
+
 func splitSpaces(s string) []string {
 	out := []string{}
 	start := -1
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"gateway/proxy/internal/auth"
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/engine"
+	"gateway/proxy/internal/events"
+	"gateway/proxy/internal/session"
+	"gateway/proxy/internal/store"
+)
+
+// testGateway serves the MCP routes the way cmd/proxy does, without authentication, over
+// a memory store holding tenant acme and its server orders.
+type testGateway struct {
+	*httptest.Server
+	store    *store.MemoryStore
+	sessions *session.Manager
+	bus      *events.Bus
+	idem     *engine.Idempotency
+	// claims, when set, authenticate every request in place of a token; see protect
+	claims atomic.Pointer[map[string]interface{}]
+}
+
+func newTestGateway(t *testing.T) *testGateway {
+	t.Helper()
+	prevUnprotected := config.Unprotected
+	t.Cleanup(func() { config.Unprotected = prevUnprotected })
+	config.Unprotected = true
+	g := &testGateway{
+		store:    store.NewMemoryStore("https://api.example.com"),
+		sessions: session.NewManager(time.Hour),
+		bus:      events.NewBus(),
+		idem:     engine.NewIdempotency(100, time.Minute),
+	}
+	if err := g.store.UpsertTenant(store.Tenant{Slug: "acme", Enabled: true, EgressAllowlist: []string{"127.0.0.1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.store.UpsertServer(store.Server{Slug: "orders", TenantSlug: "acme", Name: "orders", Enabled: true, Audience: "https://api.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	stdio := engine.NewStdioBridge()
+	t.Cleanup(stdio.Close)
+	endpoint := MCPEndpointHandler(g.store, g.sessions, g.bus, engine.NewClientFactory(engine.DefaultTransportOptions()), engine.NewMemoryCache(100), engine.NewBalancer(), stdio, g.idem)
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if claims := g.claims.Load(); claims != nil {
+				r = r.WithContext(auth.WithClaims(r.Context(), *claims))
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	mcp := r.With(ServerFromHeaderMiddleware)
+	for _, path := range []string{"/proxy/{server}/mcp", "/mcp"} {
+		r.Handle(path, MCPMethodHandler())
+		mcp.Post(path, endpoint)
+		mcp.Get(path, MCPStreamHandler(g.sessions, g.bus))
+		mcp.Delete(path, MCPSessionDeleteHandler(g.sessions))
+	}
+	mcp.Get("/proxy/{server}/ws", MCPWebSocketHandler(endpoint, g.sessions, g.bus))
+	g.Server = httptest.NewServer(r)
+	t.Cleanup(g.Close)
+	return g
+}
+
+// protect turns authorization checks on and authenticates every following request with
+// claims, as the JWT middleware would after validating a token carrying them.
+func (g *testGateway) protect(claims map[string]interface{}) {
+	config.Unprotected = false
+	g.claims.Store(&claims)
+}
+
+// upstream points server orders at h.
+func (g *testGateway) upstream(t *testing.T, h http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	srv, err := g.store.GetServer("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.UpstreamBaseURL = ts.URL
+	if err := g.store.UpsertServer(srv); err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func (g *testGateway) tools(t *testing.T, tools ...store.Tool) {
+	t.Helper()
+	if err := g.store.UpsertToolsForServer("orders", tools); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// rpcResponse is a decoded JSON-RPC response.
+type rpcResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	} `json:"error"`
+}
+
+// post sends body to /proxy/orders/mcp with the session and the latest protocol version.
+func (g *testGateway) post(t *testing.T, sid string, body string) *http.Response {
+	t.Helper()
+	return g.postTo(t, "orders", sid, body)
+}
+
+// postTo is post for another server's endpoint.
+func (g *testGateway) postTo(t *testing.T, server, sid string, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, g.URL+"/proxy/"+server+"/mcp", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("MCP-Protocol-Version", config.MCPProtocolVersionLatest)
+	if sid != "" {
+		req.Header.Set("Mcp-Session-Id", sid)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// call sends one JSON-RPC request and decodes the response.
+func (g *testGateway) call(t *testing.T, sid, method string, params interface{}) rpcResponse {
+	t.Helper()
+	msg := map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	b, _ := json.Marshal(msg)
+	resp := g.post(t, sid, string(b))
+	raw, _ := io.ReadAll(resp.Body)
+	var out rpcResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("%s: status %d, body %q", method, resp.StatusCode, raw)
+	}
+	return out
+}
+
+// initialize opens a session on server orders and returns its id.
+func (g *testGateway) initialize(t *testing.T) string {
+	t.Helper()
+	return g.initializeOn(t, "orders")
+}
+
+// initializeOn opens a session on server and returns its id.
+func (g *testGateway) initializeOn(t *testing.T, server string) string {
+	t.Helper()
+	resp := g.postTo(t, server, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+config.MCPProtocolVersionLatest+`"}}`)
+	sid := resp.Header.Get("Mcp-Session-Id")
+	if resp.StatusCode != http.StatusOK || sid == "" {
+		t.Fatalf("initialize: status %d, session %q", resp.StatusCode, sid)
+	}
+	return sid
+}
+
+// toolResult decodes a buffered tools/call result.
+func toolResult(t *testing.T, resp rpcResponse) (status int, data json.RawMessage) {
+	t.Helper()
+	if resp.Error != nil {
+		t.Fatalf("tools/call error %d %s: %s", resp.Error.Code, resp.Error.Message, resp.Error.Data)
+	}
+	var res struct {
+		Status int             `json:"status"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Result, &res); err != nil {
+		t.Fatal(err)
+	}
+	return res.Status, res.Data
+}
+
+// getTool is a GET tool on path.
+func getTool(name, path string) store.Tool {
+	return store.Tool{Name: name, Mapping: store.RequestTemplate{Method: http.MethodGet, Path: path}}
+}
+
+// controlStore adapts the memory store to ControlStore, recording uploaded OpenAPI
+// documents and their source URLs in memory since only Postgres persists them.
+type controlStore struct {
+	*store.MemoryStore
+	specs   map[string][]byte
+	sources map[string]string
+}
+
+func newControlStore(s *store.MemoryStore) *controlStore {
+	return &controlStore{MemoryStore: s, specs: map[string][]byte{}, sources: map[string]string{}}
+}
+
+func (s *controlStore) UpdateServerOpenAPI(serverSlug string, specJSON []byte, sourceURL string) error {
+	s.specs[serverSlug] = specJSON
+	s.sources[serverSlug] = sourceURL
+	return nil
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([a-zA-Z0-9_.:/-]+)\s*\}\}`)
+
+// renderInstructions interpolates {{claim}} placeholders (dotted paths reach nested claims)
+// from the session claims. Unresolved placeholders are blanked, or kept literally when
+// keepUnresolved is set.
+func renderInstructions(tmpl string, claims map[string]interface{}, keepUnresolved bool) string {
+	if !strings.Contains(tmpl, "{{") {
+		return tmpl
+	}
+	return placeholderPattern.ReplaceAllStringFunc(tmpl, func(m string) string {
+		path := placeholderPattern.FindStringSubmatch(m)[1]
+		if v, ok := lookupClaim(claims, path); ok {
+			return v
+		}
+		if keepUnresolved {
+			return m
+		}
+		return ""
+	})
+}
+
+func lookupClaim(claims map[string]interface{}, path string) (string, bool) {
+	// Exact key first so claims containing dots (e.g. URLs) still resolve
+	if v, ok := claims[path]; ok {
+		return claimString(v)
+	}
+	var cur interface{} = claims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if cur, ok = m[part]; !ok {
+			return "", false
+		}
+	}
+	return claimString(cur)
+}
+
+func claimString(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return t, true
+	case []interface{}:
+		parts := make([]string, 0, len(t))
+		for _, p := range t {
+			parts = append(parts, fmt.Sprintf("%v", p))
+		}
+		return strings.Join(parts, ", "), true
+	default:
+		return fmt.Sprintf("%v", t), true
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRenderInstructions(t *testing.T) {
+	claims := map[string]interface{}{
+		"name":                     "Alice",
+		"tenant":                   "acme",
+		"roles":                    []interface{}{"admin", "billing"},
+		"org":                      map[string]interface{}{"name": "Acme Corp"},
+		"https://example.com/plan": "gold",
+		"email_verified":           true,
+	}
+	cases := []struct {
+		tmpl, blank, literal string
+	}{
+		{"Hello {{name}} of {{tenant}}", "Hello Alice of acme", "Hello Alice of acme"},
+		{"Hello {{ name }}", "Hello Alice", "Hello Alice"},
+		{"Roles: {{roles}}", "Roles: admin, billing", "Roles: admin, billing"},
+		{"Org: {{org.name}}", "Org: Acme Corp", "Org: Acme Corp"},
+		{"Plan: {{https://example.com/plan}}", "Plan: gold", "Plan: gold"},
+		{"Verified: {{email_verified}}", "Verified: true", "Verified: true"},
+		{"Hi {{nickname}}!", "Hi !", "Hi {{nickname}}!"},
+		{"Team {{org.team}}", "Team ", "Team {{org.team}}"},
+		{"No placeholders", "No placeholders", "No placeholders"},
+	}
+	for _, tc := range cases {
+		if got := renderInstructions(tc.tmpl, claims, false); got != tc.blank {
+			t.Errorf("%q blank: got %q, want %q", tc.tmpl, got, tc.blank)
+		}
+		if got := renderInstructions(tc.tmpl, claims, true); got != tc.literal {
+			t.Errorf("%q literal: got %q, want %q", tc.tmpl, got, tc.literal)
+		}
+	}
+	if got := renderInstructions("Hello {{name}}", nil, false); got != "Hello " {
+		t.Errorf("no claims: got %q", got)
+	}
+}
+
+func TestInitializeRendersInstructions(t *testing.T) {
+	g := newTestGateway(t)
+	srv, _ := g.store.GetServer("orders")
+	srv.Instructions = "Hello {{name}}, use the orders tools."
+	if err := g.store.UpsertServer(srv); err != nil {
+		t.Fatal(err)
+	}
+	// Unprotected sessions carry no claims, so the placeholder renders blank
+	resp := g.call(t, "", "initialize", map[string]interface{}{"protocolVersion": "2025-06-18"})
+	var res struct {
+		Instructions string `json:"instructions"`
+	}
+	if err := json.Unmarshal(resp.Result, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Instructions != "Hello , use the orders tools." {
+		t.Fatalf("instructions = %q", res.Instructions)
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// requestedLocales parses an Accept-Language header into language tags ordered by
+// preference (q-value, then position). Wildcards and q=0 entries are dropped.
+func requestedLocales(header string) []string {
+	type entry struct {
+		tag string
+		q   float64
+	}
+	entries := []entry{}
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(f), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			entries = append(entries, entry{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].q > entries[j].q })
+	out := make([]string, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e.tag)
+	}
+	return out
+}
+
+// localize returns the translation best matching the requested locales, or def. Each
+// locale is tried exactly (case-insensitive) and then by its primary language, so
+// "fr-CA" falls back to "fr" before the next requested locale is considered.
+func localize(def string, translations map[string]string, locales []string) string {
+	if len(translations) == 0 {
+		return def
+	}
+	for _, loc := range locales {
+		if v, ok := lookupLocale(translations, loc); ok {
+			return v
+		}
+		if lang, _, found := strings.Cut(loc, "-"); found {
+			if v, ok := lookupLocale(translations, lang); ok {
+				return v
+			}
+		}
+	}
+	return def
+}
+
+func lookupLocale(translations map[string]string, tag string) (string, bool) {
+	if v, ok := translations[tag]; ok {
+		return v, true
+	}
+	for k, v := range translations {
+		if strings.EqualFold(k, tag) {
+			return v, true
+		}
+	}
+	return "", false
+}
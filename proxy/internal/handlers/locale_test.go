@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/store"
+)
+
+func TestRequestedLocales(t *testing.T) {
+	got := requestedLocales("fr-CA, en;q=0.5, de;q=0.8, *;q=0.1, es;q=0")
+	if want := []string{"fr-CA", "de", "en"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("locales %v, want %v", got, want)
+	}
+	if got := requestedLocales(""); len(got) != 0 {
+		t.Fatalf("empty header: %v", got)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	translations := map[string]string{"fr": "Bonjour", "fr-CA": "Allô", "de": "Hallo"}
+	cases := []struct {
+		name    string
+		locales []string
+		want    string
+	}{
+		{"exact locale", []string{"fr-CA"}, "Allô"},
+		{"exact locale, other case", []string{"FR-ca"}, "Allô"},
+		{"language fallback", []string{"fr-BE"}, "Bonjour"},
+		{"language fallback before the next locale", []string{"de-AT", "fr"}, "Hallo"},
+		{"next locale", []string{"ja", "de"}, "Hallo"},
+		{"default", []string{"ja"}, "Hello"},
+		{"no locale", nil, "Hello"},
+	}
+	for _, tc := range cases {
+		if got := localize("Hello", translations, tc.locales); got != tc.want {
+			t.Errorf("%s: %q, want %q", tc.name, got, tc.want)
+		}
+	}
+	if got := localize("Hello", nil, []string{"fr"}); got != "Hello" {
+		t.Errorf("no translations: %q", got)
+	}
+}
+
+// initializeLocalized opens a session with the given Accept-Language and initialize
+// locale param, returning the session id and the instructions.
+func (g *testGateway) initializeLocalized(t *testing.T, acceptLanguage, locale string) (string, string) {
+	t.Helper()
+	params, _ := json.Marshal(map[string]string{"protocolVersion": config.MCPProtocolVersionLatest, "locale": locale})
+	req, err := http.NewRequest(http.MethodPost, g.URL+"/proxy/orders/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":`+string(params)+`}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Result struct {
+			Instructions string `json:"instructions"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Header.Get("Mcp-Session-Id"), out.Result.Instructions
+}
+
+func TestLocalizedInstructionsAndTools(t *testing.T) {
+	g := newTestGateway(t)
+	srv, err := g.store.GetServer("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Instructions = "Manage orders."
+	srv.LocalizedInstructions = map[string]string{"fr": "Gérer les commandes.", "fr-CA": "Gérer les commandes (Canada)."}
+	if err := g.store.UpsertServer(srv); err != nil {
+		t.Fatal(err)
+	}
+	tool := getTool("get_order", "/orders/{{id}}")
+	tool.Title = "Get order"
+	tool.Description = "Fetch one order."
+	tool.LocalizedTitles = map[string]string{"fr": "Obtenir la commande"}
+	tool.LocalizedDescriptions = map[string]string{"fr": "Récupère une commande."}
+	g.tools(t, tool)
+
+	cases := []struct {
+		name, acceptLanguage, locale string
+		instructions, title, desc    string
+	}{
+		{"exact locale", "fr-CA", "", "Gérer les commandes (Canada).", "Obtenir la commande", "Récupère une commande."},
+		{"language fallback", "fr-BE,en;q=0.5", "", "Gérer les commandes.", "Obtenir la commande", "Récupère une commande."},
+		{"default", "ja", "", "Manage orders.", "Get order", "Fetch one order."},
+		{"initialize param wins", "ja", "fr", "Gérer les commandes.", "Obtenir la commande", "Récupère une commande."},
+	}
+	for _, tc := range cases {
+		sid, instructions := g.initializeLocalized(t, tc.acceptLanguage, tc.locale)
+		if instructions != tc.instructions {
+			t.Errorf("%s: instructions %q, want %q", tc.name, instructions, tc.instructions)
+		}
+		// tools/list carries no Accept-Language; the session remembers the locale
+		resp := g.call(t, sid, "tools/list", nil)
+		var page struct {
+			Tools []struct {
+				Title       string `json:"title"`
+				Description string `json:"description"`
+			} `json:"tools"`
+		}
+		if err := json.Unmarshal(resp.Result, &page); err != nil || len(page.Tools) != 1 {
+			t.Fatalf("%s: tools/list %s, err %v", tc.name, resp.Result, err)
+		}
+		if got := page.Tools[0]; got.Title != tc.title || got.Description != tc.desc {
+			t.Errorf("%s: tool %+v, want %q / %q", tc.name, got, tc.title, tc.desc)
+		}
+	}
+}
+
+func TestLocalizedTitleWithoutDefault(t *testing.T) {
+	g := newTestGateway(t)
+	// An unmatched locale falls back to the empty default title, which is omitted
+	tool := store.Tool{Name: "get_order", Mapping: store.RequestTemplate{Method: http.MethodGet, Path: "/x"}, LocalizedTitles: map[string]string{"fr": "Obtenir"}}
+	g.tools(t, tool)
+	sid, _ := g.initializeLocalized(t, "en", "")
+	resp := g.call(t, sid, "tools/list", nil)
+	if strings.Contains(string(resp.Result), `"title"`) {
+		t.Fatalf("tools/list %s has a title", resp.Result)
+	}
+}
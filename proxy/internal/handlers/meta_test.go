@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestToolsCallMetaPassThrough(t *testing.T) {
+	withAudit(t)
+	logs := captureLogs(t)
+	g := newTestGateway(t)
+	seen := make(chan http.Header, 1)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.Header.Clone()
+		_, _ = w.Write([]byte(`{}`))
+	})
+	g.tools(t, getTool("list_orders", "/orders"))
+	sid := g.initialize(t)
+
+	meta := map[string]interface{}{"progressToken": "tok-9", "traceparent": testTraceparent, "clientHint": "x"}
+	resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "list_orders", "_meta": meta})
+	toolResult(t, resp)
+	if h := <-seen; h.Get("Traceparent") != testTraceparent {
+		t.Fatalf("upstream traceparent %q", h.Get("Traceparent"))
+	}
+
+	// The progressToken is echoed on the result; fields the gateway does not act on are not
+	var out struct {
+		Meta map[string]interface{} `json:"_meta"`
+	}
+	if err := json.Unmarshal(resp.Result, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Meta["progressToken"] != "tok-9" || out.Meta["clientHint"] != nil {
+		t.Fatalf("result _meta %v", out.Meta)
+	}
+
+	var entry struct {
+		ProgressToken string `json:"progress_token"`
+		Traceparent   string `json:"traceparent"`
+	}
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, `"msg":"tool call"`) {
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if entry.ProgressToken != "tok-9" || entry.Traceparent != testTraceparent {
+		t.Fatalf("audit entry %+v in %s", entry, logs)
+	}
+}
+
+func TestToolsCallMetaMustBeObject(t *testing.T) {
+	g := newTestGateway(t)
+	g.tools(t, getTool("list_orders", "/orders"))
+	sid := g.initialize(t)
+	resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "list_orders", "_meta": "tok-9"})
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Fatalf("got %+v, want -32602", resp)
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"gateway/proxy/internal/store"
+)
+
+func decodeMetadata(t *testing.T, rec *httptest.ResponseRecorder) ProtectedResourceMetadata {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var md ProtectedResourceMetadata
+	if err := json.Unmarshal(rec.Body.Bytes(), &md); err != nil {
+		t.Fatal(err)
+	}
+	return md
+}
+
+func issuersOf(md ProtectedResourceMetadata) []string {
+	out := []string{}
+	for _, ref := range md.AuthorizationServers {
+		out = append(out, ref.Issuer)
+	}
+	return out
+}
+
+func TestRootProtectedResourceMetadataDedupesIssuers(t *testing.T) {
+	s := store.NewMemoryStore("https://gateway.example.com/proxy")
+	_ = s.UpsertTenant(store.Tenant{Slug: "acme", Enabled: true, AllowedIssuers: []string{"https://idp.example.com", "https://acme.example.com"}})
+	_ = s.UpsertTenant(store.Tenant{Slug: "globex", Enabled: true, AllowedIssuers: []string{"https://idp.example.com"}})
+	_ = s.UpsertServer(store.Server{Slug: "orders", TenantSlug: "globex", Enabled: true, AllowedIssuers: []string{"https://orders-idp.example.com", "https://acme.example.com"}})
+
+	rec := httptest.NewRecorder()
+	RootProtectedResourceMetadataHandler(s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/oauth-protected-resource", nil))
+	md := decodeMetadata(t, rec)
+	if md.Resource != "https://gateway.example.com/proxy" {
+		t.Fatalf("resource %q", md.Resource)
+	}
+	want := []string{"https://acme.example.com", "https://idp.example.com", "https://orders-idp.example.com"}
+	if got := issuersOf(md); !reflect.DeepEqual(got, want) {
+		t.Fatalf("issuers %v, want %v", got, want)
+	}
+	for _, ref := range md.AuthorizationServers {
+		if ref.MetadataURL != ref.Issuer+"/.well-known/openid-configuration" {
+			t.Fatalf("metadata URL %q for %q", ref.MetadataURL, ref.Issuer)
+		}
+	}
+}
+
+func TestRootProtectedResourceMetadataWithoutIssuers(t *testing.T) {
+	s := store.NewMemoryStore("https://gateway.example.com/proxy")
+	rec := httptest.NewRecorder()
+	RootProtectedResourceMetadataHandler(s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/oauth-protected-resource", nil))
+	if md := decodeMetadata(t, rec); md.AuthorizationServers == nil || len(md.AuthorizationServers) != 0 {
+		t.Fatalf("authorization servers %v, want an empty list", md.AuthorizationServers)
+	}
+}
+
+// serverMetadata serves the per-server metadata of slug from s.
+func serverMetadata(s *store.MemoryStore, slug string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.Get("/proxy/{server}/.well-known/oauth-protected-resource", ProtectedResourceMetadataHandler(s))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/proxy/"+slug+"/.well-known/oauth-protected-resource", nil))
+	return rec
+}
+
+func TestProtectedResourceMetadataListsToolScopes(t *testing.T) {
+	s := store.NewMemoryStore("https://gateway.example.com/proxy")
+	_ = s.UpsertTenant(store.Tenant{Slug: "acme", Enabled: true, AllowedIssuers: []string{"https://idp.example.com"}})
+	_ = s.UpsertServer(store.Server{Slug: "orders", TenantSlug: "acme", Enabled: true, Audience: "https://api.example.com/orders"})
+	read, write, admin := getTool("get_order", "/orders/1"), getTool("update_order", "/orders/1"), getTool("purge", "/purge")
+	read.RequiredScopes = []string{"orders:read"}
+	write.RequiredScopes = []string{"orders:write", "orders:read"}
+	admin.RequiredScopes = []string{"admin", ""}
+	off := false
+	hidden := getTool("hidden", "/hidden")
+	hidden.RequiredScopes, hidden.Enabled = []string{"secret"}, &off
+	_ = s.UpsertToolsForServer("orders", []store.Tool{read, write, admin, hidden})
+
+	md := decodeMetadata(t, serverMetadata(s, "orders"))
+	if want := []string{"admin", "orders:read", "orders:write"}; !reflect.DeepEqual(md.ScopesSupported, want) {
+		t.Fatalf("scopes_supported %v, want %v", md.ScopesSupported, want)
+	}
+	if md.Resource != "https://api.example.com/orders" || !reflect.DeepEqual(issuersOf(md), []string{"https://idp.example.com"}) {
+		t.Fatalf("metadata %+v", md)
+	}
+}
+
+func TestProtectedResourceMetadataUnknownServer(t *testing.T) {
+	if rec := serverMetadata(store.NewMemoryStore(""), "missing"); rec.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want 404", rec.Code)
+	}
+}
+
+func TestProtectedResourceMetadataAdvertisesPrimaryAudience(t *testing.T) {
+	s := store.NewMemoryStore("https://gateway.example.com/proxy")
+	_ = s.UpsertTenant(store.Tenant{Slug: "acme", Enabled: true, AllowedIssuers: []string{"https://idp.example.com"}})
+	_ = s.UpsertServer(store.Server{Slug: "orders", TenantSlug: "acme", Enabled: true, Audience: "https://orders.example.com", Audiences: []string{"https://legacy.example.com"}})
+	// Without Audience, the first of Audiences is primary
+	_ = s.UpsertServer(store.Server{Slug: "billing", TenantSlug: "acme", Enabled: true, Audiences: []string{"https://billing.example.com", "https://legacy.example.com"}})
+	r := chi.NewRouter()
+	r.Get("/proxy/{server}/.well-known/oauth-protected-resource", ProtectedResourceMetadataHandler(s))
+
+	for server, want := range map[string]string{"orders": "https://orders.example.com", "billing": "https://billing.example.com"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/proxy/"+server+"/.well-known/oauth-protected-resource", nil))
+		if md := decodeMetadata(t, rec); md.Resource != want {
+			t.Errorf("%s: resource %q, want %q", server, md.Resource, want)
+		}
+	}
+}
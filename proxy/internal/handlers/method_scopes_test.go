@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+func TestToolsCallMethodScopes(t *testing.T) {
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(`{}`)) })
+	srv, err := g.store.GetServer("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.MethodScopes = map[string][]string{"*": {"write:*"}, "DELETE": {"admin"}}
+	if err := g.store.UpsertServer(srv); err != nil {
+		t.Fatal(err)
+	}
+	create := store.Tool{Name: "create_order", Mapping: store.RequestTemplate{Method: http.MethodPost, Path: "/orders"}}
+	cancel := store.Tool{Name: "cancel_order", Mapping: store.RequestTemplate{Method: http.MethodDelete, Path: "/orders/1"}}
+	ping := store.Tool{Name: "ping", Mapping: store.RequestTemplate{Method: http.MethodPost, Path: "/ping"}, SkipMethodScopes: true}
+	g.tools(t, getTool("list_orders", "/orders"), create, cancel, ping)
+
+	cases := []struct {
+		name   string
+		tool   string
+		claims map[string]interface{}
+		denied bool
+	}{
+		{"GET without scopes", "list_orders", map[string]interface{}{"sub": "alice"}, false},
+		{"POST without scopes", "create_order", map[string]interface{}{"sub": "alice"}, true},
+		{"POST with read scope", "create_order", map[string]interface{}{"scope": "read:orders"}, true},
+		{"POST with write scope", "create_order", map[string]interface{}{"scope": "read:orders write:orders"}, false},
+		{"DELETE with write scope", "cancel_order", map[string]interface{}{"scope": "write:orders"}, true},
+		{"DELETE with its own scope", "cancel_order", map[string]interface{}{"scope": "admin"}, false},
+		{"opted-out POST", "ping", map[string]interface{}{"sub": "alice"}, false},
+	}
+	sid := g.initialize(t)
+	for _, tc := range cases {
+		g.protect(tc.claims)
+		resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": tc.tool})
+		switch {
+		case !tc.denied && resp.Error != nil:
+			t.Errorf("%s: error %d %s", tc.name, resp.Error.Code, resp.Error.Message)
+		case tc.denied && (resp.Error == nil || resp.Error.Code != -32002):
+			t.Errorf("%s: got %+v, want insufficient_scope", tc.name, resp)
+		}
+	}
+}
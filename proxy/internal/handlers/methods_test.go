@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMCPRoutesAdvertiseAllowedMethods(t *testing.T) {
+	g := newTestGateway(t)
+	for _, path := range []string{"/proxy/orders/mcp", "/mcp"} {
+		for _, tc := range []struct {
+			method string
+			header http.Header
+			status int
+		}{
+			{http.MethodOptions, nil, http.StatusNoContent},
+			{http.MethodPut, nil, http.StatusMethodNotAllowed},
+			{http.MethodPatch, nil, http.StatusMethodNotAllowed},
+			// GET only serves event streams
+			{http.MethodGet, http.Header{"Accept": {"application/json"}}, http.StatusMethodNotAllowed},
+		} {
+			req, _ := http.NewRequest(tc.method, g.URL+path, nil)
+			req.Header.Set(ServerHeader, "orders")
+			for k, v := range tc.header {
+				req.Header[k] = v
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.status {
+				t.Errorf("%s %s: status %d, want %d", tc.method, path, resp.StatusCode, tc.status)
+			}
+			if got := resp.Header.Get("Allow"); got != "GET, POST, DELETE, OPTIONS" {
+				t.Errorf("%s %s: Allow %q", tc.method, path, got)
+			}
+		}
+	}
+}
+
+func TestMCPRoutesServeRegisteredMethods(t *testing.T) {
+	g := newTestGateway(t)
+	// POST, GET and DELETE reach their handlers rather than the method handler
+	if sid := g.initialize(t); sid == "" {
+		t.Fatal("no session")
+	}
+	req, _ := http.NewRequest(http.MethodDelete, g.URL+"/proxy/orders/mcp", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("DELETE without session: %d, want 400", resp.StatusCode)
+	}
+}
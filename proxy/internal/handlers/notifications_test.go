@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/proxy/internal/events"
+	"gateway/proxy/internal/store"
+)
+
+// openStream opens the GET event stream of a session on server and returns the data of
+// each event as it arrives; the channel closes when the stream ends.
+func (g *testGateway) openStream(t *testing.T, server, sid string) <-chan string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, g.URL+"/proxy/"+server+"/mcp", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Mcp-Session-Id", sid)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("stream: status %d", resp.StatusCode)
+	}
+	data := make(chan string, 16)
+	go func() {
+		defer close(data)
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			if line, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+				data <- line
+			}
+		}
+	}()
+	return data
+}
+
+// nextMethod waits for the next event on stream and returns its JSON-RPC method, or ""
+// if none arrives within wait.
+func nextMethod(t *testing.T, stream <-chan string, wait time.Duration) string {
+	t.Helper()
+	select {
+	case data, ok := <-stream:
+		if !ok {
+			return ""
+		}
+		var n events.Notification
+		if err := json.Unmarshal([]byte(data), &n); err != nil {
+			t.Fatalf("event %q: %v", data, err)
+		}
+		return n.Method
+	case <-time.After(wait):
+		return ""
+	}
+}
+
+func TestToolUpsertNotifiesServerSessions(t *testing.T) {
+	g := newTestGateway(t)
+	if err := g.store.UpsertServer(store.Server{Slug: "billing", TenantSlug: "acme", Name: "billing", Enabled: true, Audience: "https://api.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	orders := g.openStream(t, "orders", g.initialize(t))
+	billing := g.openStream(t, "billing", g.initializeOn(t, "billing"))
+	// A session that has not opened its stream yet gets the notification when it does
+	parkedSID := g.initialize(t)
+
+	api := controlAPI(newControlStore(g.store), g.bus)
+	if rec := adminRequest(api, http.MethodPost, "/api/servers/orders/tools", "", `{"tools":[{"name":"list_orders","mapping":{"method":"GET","path":"/orders"}}]}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("upsert: status %d: %s", rec.Code, rec.Body)
+	}
+
+	if m := nextMethod(t, orders, 2*time.Second); m != events.ToolsListChanged {
+		t.Fatalf("orders session got %q, want %s", m, events.ToolsListChanged)
+	}
+	if m := nextMethod(t, g.openStream(t, "orders", parkedSID), 2*time.Second); m != events.ToolsListChanged {
+		t.Fatalf("parked session got %q, want %s", m, events.ToolsListChanged)
+	}
+	if m := nextMethod(t, billing, 100*time.Millisecond); m != "" {
+		t.Fatalf("billing session got %q", m)
+	}
+
+	// A rejected upsert changes nothing and notifies no one
+	if rec := adminRequest(api, http.MethodPost, "/api/servers/orders/tools", "", `{"tools":[{"name":"bad name"}]}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid upsert: status %d", rec.Code)
+	}
+	if m := nextMethod(t, orders, 100*time.Millisecond); m != "" {
+		t.Fatalf("orders session got %q after a rejected upsert", m)
+	}
+}
+
+func TestStreamDeliversQueuedNotification(t *testing.T) {
+	g := newTestGateway(t)
+	sid := g.initialize(t)
+	// Queued before the client opens its stream
+	g.bus.PublishTo("orders", sid, events.Notification{Method: events.ToolsListChanged})
+
+	stream := g.openStream(t, "orders", sid)
+	if m := nextMethod(t, stream, 2*time.Second); m != events.ToolsListChanged {
+		t.Fatalf("got %q, want the queued %s", m, events.ToolsListChanged)
+	}
+	// Later notifications arrive on the open stream
+	g.bus.PublishTo("orders", sid, events.Notification{Method: events.Progress, Params: map[string]interface{}{"progressToken": 1, "progress": 1}})
+	if m := nextMethod(t, stream, 2*time.Second); m != events.Progress {
+		t.Fatalf("got %q, want %s", m, events.Progress)
+	}
+}
+
+func TestStreamRejects(t *testing.T) {
+	g := newTestGateway(t)
+	if err := g.store.UpsertServer(store.Server{Slug: "billing", TenantSlug: "acme", Name: "billing", Enabled: true, Audience: "https://api.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	sid := g.initialize(t)
+	billingSID := g.initializeOn(t, "billing")
+
+	cases := []struct {
+		name, accept, sid string
+		want              int
+	}{
+		{"without event-stream accept", "application/json", sid, http.StatusMethodNotAllowed},
+		{"missing session", "text/event-stream", "", http.StatusBadRequest},
+		{"unknown session", "text/event-stream", "no-such-session", http.StatusNotFound},
+		{"another server's session", "text/event-stream", billingSID, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		req, err := http.NewRequest(http.MethodGet, g.URL+"/proxy/orders/mcp", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", tc.accept)
+		if tc.sid != "" {
+			req.Header.Set("Mcp-Session-Id", tc.sid)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, resp.StatusCode, tc.want)
+		}
+		if tc.want == http.StatusMethodNotAllowed && resp.Header.Get("Allow") == "" {
+			t.Errorf("%s: no Allow header", tc.name)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"gateway/proxy/internal/events"
+)
+
+// progressInterval is how often a tool call without streamed progress emits a heartbeat.
+const progressInterval = 2 * time.Second
+
+// progressMinGap throttles byte progress so a chunked upstream cannot flood the session queue.
+const progressMinGap = 250 * time.Millisecond
+
+// progressReporter emits notifications/progress for one tools/call to the calling
+// session's stream. Heartbeats carry elapsed seconds until the upstream body starts
+// streaming; from then on progress is the number of bytes received (with total when
+// known). Progress only ever increases, and nothing is sent after stop.
+type progressReporter struct {
+	bus        *events.Bus
+	serverSlug string
+	sessionID  string
+	token      json.RawMessage
+	start      time.Time
+	ticker     *time.Ticker
+	done       chan struct{}
+
+	mu        sync.Mutex
+	stopped   bool
+	streaming bool
+	last      float64
+	lastSent  time.Time
+}
+
+func newProgressReporter(bus *events.Bus, serverSlug, sessionID string, token json.RawMessage) *progressReporter {
+	p := &progressReporter{bus: bus, serverSlug: serverSlug, sessionID: sessionID, token: token, start: time.Now(), ticker: time.NewTicker(progressInterval), done: make(chan struct{})}
+	go func() {
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-p.ticker.C:
+				elapsed := time.Since(p.start).Seconds()
+				p.emit(elapsed, -1, fmt.Sprintf("waiting for upstream (%.0fs elapsed)", elapsed), false)
+			}
+		}
+	}()
+	return p
+}
+
+// bytes is an engine.ProgressFunc.
+func (p *progressReporter) bytes(read, total int64) {
+	p.emit(float64(read), float64(total), "receiving upstream response", true)
+}
+
+func (p *progressReporter) emit(progress, total float64, message string, streaming bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped || progress <= p.last || (p.streaming && !streaming) {
+		return
+	}
+	if streaming {
+		p.streaming = true
+		// Always report the final chunk; throttle the ones before it
+		if progress != total && time.Since(p.lastSent) < progressMinGap {
+			return
+		}
+	}
+	p.last, p.lastSent = progress, time.Now()
+	params := map[string]interface{}{"progressToken": p.token, "progress": progress, "message": message}
+	if total > 0 {
+		params["total"] = total
+	}
+	p.bus.PublishTo(p.serverSlug, p.sessionID, events.Notification{Method: events.Progress, Params: params})
+}
+
+// stop ends reporting; it is called when the tool call completes.
+func (p *progressReporter) stop() {
+	p.mu.Lock()
+	p.stopped = true
+	p.mu.Unlock()
+	p.ticker.Stop()
+	close(p.done)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/proxy/internal/events"
+)
+
+// progressEvents collects the notifications/progress params that arrive on stream within wait.
+func progressEvents(t *testing.T, stream <-chan string, wait time.Duration) []map[string]interface{} {
+	t.Helper()
+	var out []map[string]interface{}
+	deadline := time.After(wait)
+	for {
+		select {
+		case data, ok := <-stream:
+			if !ok {
+				return out
+			}
+			var n struct {
+				Method string                 `json:"method"`
+				Params map[string]interface{} `json:"params"`
+			}
+			if err := json.Unmarshal([]byte(data), &n); err != nil {
+				t.Fatalf("event %q: %v", data, err)
+			}
+			if n.Method == events.Progress {
+				out = append(out, n.Params)
+			}
+		case <-deadline:
+			return out
+		}
+	}
+}
+
+// callWithProgress runs tools/call for name with _meta.progressToken set to token.
+func (g *testGateway) callWithProgress(t *testing.T, sid, name string, token interface{}) rpcResponse {
+	t.Helper()
+	params := map[string]interface{}{"name": name}
+	if token != nil {
+		params["_meta"] = map[string]interface{}{"progressToken": token}
+	}
+	return g.call(t, sid, "tools/call", params)
+}
+
+func TestProgressFromStreamedUpstream(t *testing.T) {
+	g := newTestGateway(t)
+	body := `{"report":"` + strings.Repeat("x", 300) + `"}`
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		for i := 0; i < len(body); i += 100 {
+			_, _ = w.Write([]byte(body[i:min(i+100, len(body))]))
+			w.(http.Flusher).Flush()
+			time.Sleep(progressMinGap + 50*time.Millisecond)
+		}
+	})
+	g.tools(t, getTool("export_report", "/report"))
+	sid := g.initialize(t)
+	stream := g.openStream(t, "orders", sid)
+
+	status, _ := toolResult(t, g.callWithProgress(t, sid, "export_report", 7))
+	if status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	got := progressEvents(t, stream, 500*time.Millisecond)
+	if len(got) < 2 {
+		t.Fatalf("%d progress notifications, want one per chunk", len(got))
+	}
+	last := 0.0
+	for _, p := range got {
+		if p["progressToken"] != float64(7) {
+			t.Fatalf("progressToken %v, want the client's 7", p["progressToken"])
+		}
+		progress, _ := p["progress"].(float64)
+		if progress <= last || p["total"] != float64(len(body)) {
+			t.Fatalf("progress %v of %v after %v", p["progress"], p["total"], last)
+		}
+		last = progress
+	}
+	if last != float64(len(body)) {
+		t.Fatalf("final progress %v, want %d", last, len(body))
+	}
+}
+
+func TestProgressHeartbeatStopsOnCompletion(t *testing.T) {
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(progressInterval + 300*time.Millisecond)
+		_, _ = w.Write([]byte(`{}`))
+	})
+	g.tools(t, getTool("slow_report", "/report"))
+	sid := g.initialize(t)
+	stream := g.openStream(t, "orders", sid)
+
+	toolResult(t, g.callWithProgress(t, sid, "slow_report", "tok-1"))
+	got := progressEvents(t, stream, 200*time.Millisecond)
+	if len(got) != 1 {
+		t.Fatalf("%d heartbeats, want 1", len(got))
+	}
+	if got[0]["progressToken"] != "tok-1" || !strings.Contains(got[0]["message"].(string), "elapsed") {
+		t.Fatalf("heartbeat %v", got[0])
+	}
+	// Nothing follows once the call has completed
+	if late := progressEvents(t, stream, progressInterval+300*time.Millisecond); len(late) != 0 {
+		t.Fatalf("progress after completion: %v", late)
+	}
+}
+
+func TestNoProgressWithoutToken(t *testing.T) {
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(`{"ok":true}`)) })
+	g.tools(t, getTool("get_order", "/orders/1"))
+	sid := g.initialize(t)
+	stream := g.openStream(t, "orders", sid)
+
+	toolResult(t, g.callWithProgress(t, sid, "get_order", nil))
+	if got := progressEvents(t, stream, 200*time.Millisecond); len(got) != 0 {
+		t.Fatalf("progress without a token: %v", got)
+	}
+}
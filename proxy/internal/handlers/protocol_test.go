@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gateway/proxy/internal/config"
+)
+
+func TestInitializeNegotiatesProtocolVersion(t *testing.T) {
+	g := newTestGateway(t)
+	cases := []struct {
+		name      string
+		requested string
+		want      string
+	}{
+		{"latest", config.MCPProtocolVersionLatest, config.MCPProtocolVersionLatest},
+		{"older supported", config.MCPProtocolVersionFallback, config.MCPProtocolVersionFallback},
+		{"unsupported", "2023-01-01", config.MCPProtocolVersionLatest},
+		{"omitted", "", config.MCPProtocolVersionLatest},
+	}
+	for _, tc := range cases {
+		resp := g.post(t, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+tc.requested+`"}}`)
+		sid := resp.Header.Get("Mcp-Session-Id")
+		if resp.StatusCode != http.StatusOK || sid == "" {
+			t.Fatalf("%s: status %d, session %q", tc.name, resp.StatusCode, sid)
+		}
+		var out struct {
+			Result struct {
+				ProtocolVersion string `json:"protocolVersion"`
+			} `json:"result"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Result.ProtocolVersion != tc.want {
+			t.Errorf("%s: negotiated %q, want %q", tc.name, out.Result.ProtocolVersion, tc.want)
+		}
+		s, err := g.sessions.Get(sid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.ProtocolVersion != tc.want {
+			t.Errorf("%s: session recorded %q, want %q", tc.name, s.ProtocolVersion, tc.want)
+		}
+	}
+}
+
+func TestProtocolVersionHeaderAfterInitialize(t *testing.T) {
+	g := newTestGateway(t)
+	resp := g.post(t, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+config.MCPProtocolVersionFallback+`"}}`)
+	sid := resp.Header.Get("Mcp-Session-Id")
+	if sid == "" {
+		t.Fatalf("initialize: status %d", resp.StatusCode)
+	}
+	cases := []struct {
+		name    string
+		version string
+		want    int
+	}{
+		{"negotiated version", config.MCPProtocolVersionFallback, http.StatusOK},
+		{"missing header", "", http.StatusBadRequest},
+		{"other supported version", config.MCPProtocolVersionLatest, http.StatusBadRequest},
+		{"unsupported version", "2023-01-01", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		req, err := http.NewRequest(http.MethodPost, g.URL+"/proxy/orders/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Mcp-Session-Id", sid)
+		if tc.version != "" {
+			req.Header.Set("MCP-Protocol-Version", tc.version)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, resp.StatusCode, tc.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestResponseHeadersInResultMeta(t *testing.T) {
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `</orders?page=2>; rel="next"`)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Internal", "hidden")
+		_, _ = w.Write([]byte(`[]`))
+	})
+	srv, _ := g.store.GetServer("orders")
+	srv.ResponseHeaders = []string{"Link", "Set-Cookie"}
+	if err := g.store.UpsertServer(srv); err != nil {
+		t.Fatal(err)
+	}
+	tool := getTool("list_orders", "/orders")
+	tool.Mapping.ResponseHeaders = []string{"ETag"}
+	g.tools(t, tool, getTool("count_orders", "/orders"))
+	sid := g.initialize(t)
+
+	var out struct {
+		Meta struct {
+			ResponseHeaders map[string]string `json:"responseHeaders"`
+		} `json:"_meta"`
+	}
+	resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "list_orders"})
+	toolResult(t, resp)
+	if err := json.Unmarshal(resp.Result, &out); err != nil {
+		t.Fatal(err)
+	}
+	got := out.Meta.ResponseHeaders
+	if got["Link"] != `</orders?page=2>; rel="next"` || got["Etag"] != `"v1"` {
+		t.Fatalf("responseHeaders %v, want Link and Etag", got)
+	}
+	if _, ok := got["Set-Cookie"]; ok {
+		t.Fatal("Set-Cookie passed through")
+	}
+	if _, ok := got["X-Internal"]; ok {
+		t.Fatal("a header that is not allowlisted passed through")
+	}
+
+	// Only the server's list applies to a tool without its own
+	resp = g.call(t, sid, "tools/call", map[string]interface{}{"name": "count_orders"})
+	out.Meta.ResponseHeaders = nil
+	if err := json.Unmarshal(resp.Result, &out); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.Meta.ResponseHeaders; len(got) != 1 || got["Link"] == "" {
+		t.Fatalf("responseHeaders %v, want only Link", got)
+	}
+
+	// Nothing allowlisted leaves _meta out
+	srv.ResponseHeaders = nil
+	if err := g.store.UpsertServer(srv); err != nil {
+		t.Fatal(err)
+	}
+	var bare map[string]json.RawMessage
+	if err := json.Unmarshal(g.call(t, sid, "tools/call", map[string]interface{}{"name": "count_orders"}).Result, &bare); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bare["_meta"]; ok {
+		t.Fatalf("_meta present without allowlisted headers: %s", bare["_meta"])
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestToolsCallAppliesResponseMapping(t *testing.T) {
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"order":{"id":"o-1","customer":{"name":"Ada"},"items":[{"sku":"A"},{"sku":"B"}]}}}`))
+	})
+	mapped := getTool("get_order", "/orders/1")
+	mapped.Mapping.ResponseMapping = map[string]string{"id": "data.order.id", "customer": "data.order.customer.name", "skus": "data.order.items.*.sku"}
+	g.tools(t, mapped, getTool("raw_order", "/orders/1"))
+	sid := g.initialize(t)
+
+	if _, data := toolResult(t, g.call(t, sid, "tools/call", map[string]interface{}{"name": "get_order"})); string(data) != `{"customer":"Ada","id":"o-1","skus":["A","B"]}` {
+		t.Fatalf("mapped result %s", data)
+	}
+	if _, data := toolResult(t, g.call(t, sid, "tools/call", map[string]interface{}{"name": "raw_order"})); string(data) != `{"data":{"order":{"id":"o-1","customer":{"name":"Ada"},"items":[{"sku":"A"},{"sku":"B"}]}}}` {
+		t.Fatalf("unmapped result %s", data)
+	}
+}
+
+func TestUpsertToolsRejectsBadResponseMapping(t *testing.T) {
+	g := newTestGateway(t)
+	api := controlAPI(newControlStore(g.store), g.bus)
+	for _, path := range []string{"/api/servers/orders/tools", "/api/servers/orders/bundle"} {
+		body := `{"tools":[{"name":"get_order","mapping":{"method":"GET","path":"/o","responseMapping":{"id":"data..id"}}}]}`
+		if path == "/api/servers/orders/bundle" {
+			body = `{"server":{"tenantSlug":"acme","name":"orders","audience":"https://api.example.com"},` + body[1:]
+		}
+		if rec := adminRequest(api, http.MethodPost, path, "", body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status %d, want 400: %s", path, rec.Code, rec.Body)
+		}
+	}
+	if got := toolNamesOf(t, g.store); got != "" {
+		t.Fatalf("tools %s after rejected upserts", got)
+	}
+}
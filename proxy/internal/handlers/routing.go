@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ServerHeader selects the MCP server when routing by header instead of by path.
+const ServerHeader = "X-Gateway-Server"
+
+// WildcardServer is the path slug meaning "take the server from ServerHeader".
+const WildcardServer = "_"
+
+// ServerFromHeaderMiddleware resolves the {server} route param from ServerHeader so that
+// /mcp and /proxy/_/mcp behave like /proxy/{server}/mcp. It must run before auth and the
+// handlers, which all read chi.URLParam(r, "server"). A header naming a different server
+// than a concrete path slug is rejected rather than silently preferring one.
+func ServerFromHeaderMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get(ServerHeader)
+		rctx := chi.RouteContext(r.Context())
+		path := chi.URLParam(r, "server")
+		switch {
+		case header == "" && (path == "" || path == WildcardServer):
+			http.Error(w, "missing "+ServerHeader+" header", http.StatusBadRequest)
+			return
+		case header == "" || rctx == nil:
+		case path == "":
+			rctx.URLParams.Add("server", header)
+		case path == WildcardServer:
+			setURLParam(rctx, "server", header)
+		case path != header:
+			http.Error(w, ServerHeader+" does not match the server in the path", http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func setURLParam(rctx *chi.Context, key, value string) {
+	for i := len(rctx.URLParams.Keys) - 1; i >= 0; i-- {
+		if rctx.URLParams.Keys[i] == key {
+			rctx.URLParams.Values[i] = value
+			return
+		}
+	}
+	rctx.URLParams.Add(key, value)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/store"
+)
+
+// postRouted sends body to path on the gateway with the given X-Gateway-Server header.
+func (g *testGateway) postRouted(t *testing.T, path, server, sid, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, g.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("MCP-Protocol-Version", config.MCPProtocolVersionLatest)
+	if server != "" {
+		req.Header.Set(ServerHeader, server)
+	}
+	if sid != "" {
+		req.Header.Set("Mcp-Session-Id", sid)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestHeaderRoutingMatchesPathRouting(t *testing.T) {
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(`{"id":"42"}`)) })
+	g.tools(t, getTool("get_order", "/orders/42"))
+
+	initialize := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"` + config.MCPProtocolVersionLatest + `"}}`
+	call := `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"get_order"}}`
+	routes := []struct {
+		name, path, header string
+	}{
+		{"path", "/proxy/orders/mcp", ""},
+		{"header", "/mcp", "orders"},
+		{"wildcard path", "/proxy/" + WildcardServer + "/mcp", "orders"},
+		{"path and matching header", "/proxy/orders/mcp", "orders"},
+	}
+	var want string
+	for _, rt := range routes {
+		resp := g.postRouted(t, rt.path, rt.header, "", initialize)
+		sid := resp.Header.Get("Mcp-Session-Id")
+		if resp.StatusCode != http.StatusOK || sid == "" {
+			t.Fatalf("%s: initialize status %d", rt.name, resp.StatusCode)
+		}
+		if s, err := g.sessions.Get(sid); err != nil || s.ServerSlug != "orders" {
+			t.Fatalf("%s: session %+v, err %v", rt.name, s, err)
+		}
+		raw, _ := io.ReadAll(g.postRouted(t, rt.path, rt.header, sid, call).Body)
+		var out rpcResponse
+		if err := json.Unmarshal(raw, &out); err != nil {
+			t.Fatalf("%s: %q", rt.name, raw)
+		}
+		status, data := toolResult(t, out)
+		got := string(data)
+		if status != http.StatusOK || (want != "" && got != want) {
+			t.Fatalf("%s: status %d, data %s, want %s", rt.name, status, got, want)
+		}
+		want = got
+	}
+}
+
+func TestHeaderRoutingRejects(t *testing.T) {
+	g := newTestGateway(t)
+	initialize := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"` + config.MCPProtocolVersionLatest + `"}}`
+	cases := []struct {
+		name, path, header string
+	}{
+		{"missing header", "/mcp", ""},
+		{"wildcard without header", "/proxy/" + WildcardServer + "/mcp", ""},
+		{"header contradicts path", "/proxy/orders/mcp", "billing"},
+	}
+	for _, tc := range cases {
+		if resp := g.postRouted(t, tc.path, tc.header, "", initialize); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", tc.name, resp.StatusCode)
+		}
+	}
+}
+
+func TestSessionOfAnotherServerRejected(t *testing.T) {
+	g := newTestGateway(t)
+	hits := 0
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) { hits++ })
+	g.tools(t, getTool("get_order", "/orders/42"))
+	if err := g.store.UpsertServer(store.Server{Slug: "billing", TenantSlug: "acme", Name: "billing", Enabled: true, Audience: "https://api.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	billingSID := g.initializeOn(t, "billing")
+
+	for _, req := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"get_order"}}`,
+	} {
+		for _, path := range []string{"/proxy/orders/mcp", "/mcp"} {
+			raw, _ := io.ReadAll(g.postRouted(t, path, "orders", billingSID, req).Body)
+			var out rpcResponse
+			if err := json.Unmarshal(raw, &out); err != nil {
+				t.Fatalf("%s: %q", path, raw)
+			}
+			if out.Error == nil || out.Error.Code != -32005 || out.Error.Message != "session does not belong to this server" {
+				t.Fatalf("%s %s: got %s", path, req, raw)
+			}
+		}
+	}
+	if hits != 0 {
+		t.Fatalf("upstream called %d times with another server's session", hits)
+	}
+}
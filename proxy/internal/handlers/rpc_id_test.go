@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestRPCIDEchoedVerbatim(t *testing.T) {
+	g := newTestGateway(t)
+	sid := g.initialize(t)
+	for _, id := range []string{`1`, `0`, `-7`, `9007199254740993`, `"abc"`, `"1"`, `null`} {
+		resp := g.post(t, sid, `{"jsonrpc":"2.0","id":`+id+`,"method":"tools/list"}`)
+		raw, _ := io.ReadAll(resp.Body)
+		var out struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if err := json.Unmarshal(raw, &out); err != nil {
+			t.Fatalf("id %s: status %d, body %q", id, resp.StatusCode, raw)
+		}
+		if !bytes.Equal(out.ID, []byte(id)) {
+			t.Errorf("id %s echoed as %s", id, out.ID)
+		}
+	}
+}
+
+func TestRPCIDInvalid(t *testing.T) {
+	g := newTestGateway(t)
+	sid := g.initialize(t)
+	for _, id := range []string{`{"a":1}`, `[1]`, `true`} {
+		resp := g.post(t, sid, `{"jsonrpc":"2.0","id":`+id+`,"method":"tools/list"}`)
+		var out rpcResponse
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Error == nil || out.Error.Code != -32600 || string(out.ID) != "null" {
+			t.Errorf("id %s: got %+v, want -32600 with a null id", id, out)
+		}
+	}
+}
+
+func TestRPCNotificationGetsNoResponse(t *testing.T) {
+	g := newTestGateway(t)
+	sid := g.initialize(t)
+	resp := g.post(t, sid, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusAccepted || len(body) != 0 {
+		t.Fatalf("status %d, body %q, want 202 without a body", resp.StatusCode, body)
+	}
+}
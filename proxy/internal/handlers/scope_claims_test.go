@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+func TestGrantedScopesFromClaims(t *testing.T) {
+	cases := []struct {
+		name        string
+		scopeClaims []string
+		claims      map[string]interface{}
+		want        []string
+	}{
+		{"default scope string", store.DefaultScopeClaims, map[string]interface{}{"scope": "read:orders write:orders"}, []string{"read:orders", "write:orders"}},
+		{"default scopes array", store.DefaultScopeClaims, map[string]interface{}{"scopes": []interface{}{"read:orders"}}, []string{"read:orders"}},
+		{"scp string", []string{"scp"}, map[string]interface{}{"scp": "read:orders", "scope": "admin"}, []string{"read:orders"}},
+		{"permissions array", []string{"permissions"}, map[string]interface{}{"permissions": []interface{}{"read:orders", 7, "write:orders"}}, []string{"read:orders", "write:orders"}},
+		{"combined claims", []string{"permissions", "roles"}, map[string]interface{}{"permissions": []interface{}{"read:orders"}, "roles": "admin"}, []string{"admin", "read:orders"}},
+		{"api key", []string{"permissions"}, map[string]interface{}{"auth_method": "api_key", "scope": "read:orders"}, []string{"read:orders"}},
+	}
+	for _, tc := range cases {
+		var got []string
+		for sc := range grantedScopes(tc.claims, tc.scopeClaims) {
+			got = append(got, sc)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: scopes %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestToolsCallScopeClaims(t *testing.T) {
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(`{}`)) })
+	tool := getTool("list_orders", "/orders")
+	tool.RequiredScopes = []string{"read:orders"}
+	g.tools(t, tool)
+	sid := g.initialize(t)
+	srv, _ := g.store.GetServer("orders")
+	tenant, _ := g.store.GetTenant("acme")
+
+	cases := []struct {
+		name         string
+		tenantClaims []string
+		serverClaims []string
+		claims       map[string]interface{}
+		denied       bool
+	}{
+		{"default scope", nil, nil, map[string]interface{}{"scope": "read:orders"}, false},
+		{"scp unread by default", nil, nil, map[string]interface{}{"scp": "read:orders"}, true},
+		{"tenant scp", []string{"scp"}, nil, map[string]interface{}{"scp": "read:orders"}, false},
+		{"tenant scp ignores scope", []string{"scp"}, nil, map[string]interface{}{"scope": "read:orders"}, true},
+		{"server permissions", []string{"scp"}, []string{"permissions"}, map[string]interface{}{"permissions": []interface{}{"read:orders"}}, false},
+		{"server overrides tenant", []string{"scp"}, []string{"permissions"}, map[string]interface{}{"scp": "read:orders"}, true},
+	}
+	for _, tc := range cases {
+		tenant.ScopeClaims, srv.ScopeClaims = tc.tenantClaims, tc.serverClaims
+		if err := g.store.UpsertTenant(tenant); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.store.UpsertServer(srv); err != nil {
+			t.Fatal(err)
+		}
+		g.protect(tc.claims)
+		resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "list_orders"})
+		switch {
+		case !tc.denied && resp.Error != nil:
+			t.Errorf("%s: error %d %s", tc.name, resp.Error.Code, resp.Error.Message)
+		case tc.denied && (resp.Error == nil || resp.Error.Code != -32002):
+			t.Errorf("%s: got %+v, want insufficient_scope", tc.name, resp)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+type scopeErrorData struct {
+	Error           string   `json:"error"`
+	MissingScopes   []string `json:"missingScopes"`
+	AnyOf           []string `json:"anyOf"`
+	Scope           string   `json:"scope"`
+	WWWAuthenticate string   `json:"wwwAuthenticate"`
+}
+
+// scopeDenied calls tool with claims and decodes the -32002 error data and header.
+func (g *testGateway) scopeDenied(t *testing.T, sid, tool string, claims map[string]interface{}) (scopeErrorData, string) {
+	t.Helper()
+	g.protect(claims)
+	b, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": map[string]interface{}{"name": tool}})
+	resp := g.post(t, sid, string(b))
+	var out rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Error == nil || out.Error.Code != -32002 || out.Error.Message != "insufficient_scope" {
+		t.Fatalf("got %+v, want insufficient_scope", out)
+	}
+	var data scopeErrorData
+	if err := json.Unmarshal(out.Error.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	return data, resp.Header.Get("WWW-Authenticate")
+}
+
+func TestScopeErrorListsMissingScopes(t *testing.T) {
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(`{}`)) })
+	tool := getTool("export_orders", "/orders/export")
+	tool.RequiredScopes = []string{"read:orders", "export:orders", "read:customers"}
+	g.tools(t, tool)
+	sid := g.initialize(t)
+
+	data, header := g.scopeDenied(t, sid, "export_orders", map[string]interface{}{"scope": "read:orders openid"})
+	if want := []string{"export:orders", "read:customers"}; !reflect.DeepEqual(data.MissingScopes, want) {
+		t.Fatalf("missingScopes %v, want exactly %v", data.MissingScopes, want)
+	}
+	if data.Error != "insufficient_scope" || data.Scope != "export:orders read:customers" || data.AnyOf != nil {
+		t.Fatalf("data %+v", data)
+	}
+	if header == "" || header != data.WWWAuthenticate {
+		t.Fatalf("WWW-Authenticate %q, data %q", header, data.WWWAuthenticate)
+	}
+	for _, want := range []string{`error="insufficient_scope"`, `scope="export:orders read:customers"`, `resource_metadata="`} {
+		if !strings.Contains(header, want) {
+			t.Errorf("challenge %q lacks %s", header, want)
+		}
+	}
+	if strings.Contains(header, "read:orders") {
+		t.Fatalf("challenge %q names a satisfied scope", header)
+	}
+}
+
+func TestScopeErrorForMethodScopes(t *testing.T) {
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(`{}`)) })
+	srv, _ := g.store.GetServer("orders")
+	srv.MethodScopes = map[string][]string{"POST": {"write:*", "orders:admin"}}
+	if err := g.store.UpsertServer(srv); err != nil {
+		t.Fatal(err)
+	}
+	g.tools(t, store.Tool{Name: "create_order", Mapping: store.RequestTemplate{Method: http.MethodPost, Path: "/orders"}})
+	sid := g.initialize(t)
+
+	data, header := g.scopeDenied(t, sid, "create_order", map[string]interface{}{"scope": "read:orders"})
+	if !reflect.DeepEqual(data.AnyOf, []string{"write:*", "orders:admin"}) || data.MissingScopes != nil {
+		t.Fatalf("data %+v", data)
+	}
+	// Wildcards cannot be requested, so only the concrete scope is named
+	if data.Scope != "orders:admin" || !strings.Contains(header, `scope="orders:admin"`) {
+		t.Fatalf("scope %q, challenge %q", data.Scope, header)
+	}
+}
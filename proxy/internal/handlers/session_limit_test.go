@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/session"
+)
+
+func TestInitializeOverSessionLimit(t *testing.T) {
+	g := newTestGateway(t)
+	g.sessions.SetTenantLimit(1, session.LimitReject)
+	sid := g.initialize(t)
+
+	resp := g.call(t, "", "initialize", map[string]interface{}{"protocolVersion": config.MCPProtocolVersionLatest})
+	if resp.Error == nil || resp.Error.Code != -32000 || resp.Error.Message != session.ErrSessionLimit.Error() {
+		t.Fatalf("got %+v, want -32000 session limit", resp.Error)
+	}
+
+	// Ending the session lets a new one in
+	req, _ := http.NewRequest(http.MethodDelete, g.URL+"/proxy/orders/mcp", nil)
+	req.Header.Set("Mcp-Session-Id", sid)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	g.initialize(t)
+}
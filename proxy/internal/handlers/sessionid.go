@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"gateway/proxy/internal/config"
+)
+
+// sessionCookieName carries the session id when config.SessionCookie is enabled.
+const sessionCookieName = "mcp_session_id"
+
+// sessionID returns the request's MCP session id: the Mcp-Session-Id header, or the
+// session cookie when cookie transport is enabled and the header is absent.
+func sessionID(r *http.Request) string {
+	if sid := r.Header.Get("Mcp-Session-Id"); sid != "" || !config.SessionCookie {
+		return sid
+	}
+	if c, err := r.Cookie(sessionCookieName); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// setSessionID returns a new session id in the Mcp-Session-Id header and, when cookie
+// transport is enabled, as a cookie scoped to the server's MCP path.
+func setSessionID(w http.ResponseWriter, r *http.Request, sid string) {
+	w.Header().Set("Mcp-Session-Id", sid)
+	if config.SessionCookie {
+		http.SetCookie(w, sessionCookie(r, sid, 0))
+	}
+}
+
+// clearSessionID expires the session cookie after the session is terminated.
+func clearSessionID(w http.ResponseWriter, r *http.Request) {
+	if config.SessionCookie {
+		if _, err := r.Cookie(sessionCookieName); err == nil {
+			http.SetCookie(w, sessionCookie(r, "", -1))
+		}
+	}
+}
+
+func sessionCookie(r *http.Request, value string, maxAge int) *http.Cookie {
+	sameSite := http.SameSiteStrictMode
+	switch strings.ToLower(config.SessionCookieSameSite) {
+	case "lax":
+		sameSite = http.SameSiteLaxMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+	return &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    value,
+		Path:     "/proxy/" + chi.URLParam(r, "server"),
+		MaxAge:   maxAge,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: sameSite,
+	}
+}
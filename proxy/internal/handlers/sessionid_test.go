@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gateway/proxy/internal/config"
+)
+
+func withSessionCookie(t *testing.T, enabled bool) {
+	t.Helper()
+	prev := config.SessionCookie
+	t.Cleanup(func() { config.SessionCookie = prev })
+	config.SessionCookie = enabled
+}
+
+// listWithSession sends tools/list to server orders with the given session header and
+// cookie and returns the JSON-RPC error code, or 0 on success.
+func (g *testGateway) listWithSession(t *testing.T, header, cookie string) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, g.URL+"/proxy/orders/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("MCP-Protocol-Version", config.MCPProtocolVersionLatest)
+	if header != "" {
+		req.Header.Set("Mcp-Session-Id", header)
+	}
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: cookie})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("status %d: %v", resp.StatusCode, err)
+	}
+	if out.Error != nil {
+		return out.Error.Code
+	}
+	return 0
+}
+
+func TestSessionCookieIssuedOnInitialize(t *testing.T) {
+	withSessionCookie(t, true)
+	g := newTestGateway(t)
+	resp := g.post(t, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+config.MCPProtocolVersionLatest+`"}}`)
+	sid := resp.Header.Get("Mcp-Session-Id")
+	if sid == "" {
+		t.Fatalf("initialize: status %d", resp.StatusCode)
+	}
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == sessionCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatalf("no session cookie in %v", resp.Header["Set-Cookie"])
+	}
+	if cookie.Value != sid || !cookie.Secure || !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode || cookie.Path != "/proxy/orders" {
+		t.Fatalf("cookie %+v", cookie)
+	}
+}
+
+func TestSessionCookieNotIssuedByDefault(t *testing.T) {
+	withSessionCookie(t, false)
+	g := newTestGateway(t)
+	resp := g.post(t, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+config.MCPProtocolVersionLatest+`"}}`)
+	if c := resp.Header.Get("Set-Cookie"); c != "" {
+		t.Fatalf("Set-Cookie %q", c)
+	}
+	// Nor is a cookie accepted in place of the header
+	if code := g.listWithSession(t, "", resp.Header.Get("Mcp-Session-Id")); code != -32005 {
+		t.Fatal("cookie accepted with cookie transport disabled")
+	}
+}
+
+func TestSessionFromCookie(t *testing.T) {
+	withSessionCookie(t, true)
+	g := newTestGateway(t)
+	sid := g.initialize(t)
+	other := g.initialize(t)
+
+	cases := []struct {
+		name, header, cookie string
+		want                 int // 0 for success, else the JSON-RPC error code
+	}{
+		{"cookie only", "", sid, 0},
+		{"header wins over a stale cookie", sid, "stale", 0},
+		{"header wins over another session's cookie", "stale", other, -32005},
+	}
+	for _, tc := range cases {
+		if code := g.listWithSession(t, tc.header, tc.cookie); code != tc.want {
+			t.Errorf("%s: error %d, want %d", tc.name, code, tc.want)
+		}
+	}
+}
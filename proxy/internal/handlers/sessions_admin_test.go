@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"gateway/proxy/internal/session"
+	"gateway/proxy/internal/store"
+)
+
+func sessionsAPI(sm *session.Manager) http.Handler {
+	mux := chi.NewRouter()
+	mux.Get("/api/sessions", ListSessionsHandler(sm))
+	mux.Delete("/api/sessions/{id}", TerminateSessionHandler(sm))
+	return mux
+}
+
+func listSessions(t *testing.T, api http.Handler, query string) []session.Info {
+	t.Helper()
+	rec := adminRequest(api, http.MethodGet, "/api/sessions"+query, "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: status %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "s3cr3t") {
+		t.Fatalf("listing exposes claims: %s", rec.Body)
+	}
+	var out struct {
+		Sessions []session.Info `json:"sessions"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	return out.Sessions
+}
+
+func TestAdminSessionListing(t *testing.T) {
+	g := newTestGateway(t)
+	if err := g.store.UpsertServer(store.Server{Slug: "billing", TenantSlug: "acme", Name: "billing", Enabled: true, Audience: "https://api.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	g.protect(map[string]interface{}{"sub": "alice", "scope": "orders:read", "api_secret": "s3cr3t"})
+	first := g.initialize(t)
+	second := g.initialize(t)
+	billing := g.initializeOn(t, "billing")
+	api := sessionsAPI(g.sessions)
+
+	all := listSessions(t, api, "")
+	if len(all) != 3 {
+		t.Fatalf("listed %d sessions, want 3", len(all))
+	}
+	if all[0].ID != first || all[0].ServerSlug != "orders" || all[0].TenantSlug != "acme" || all[0].Subject != "alice" || all[0].CreatedAt.IsZero() || all[0].LastAccessed.IsZero() {
+		t.Fatalf("first session %+v", all[0])
+	}
+	if got := listSessions(t, api, "?server=billing"); len(got) != 1 || got[0].ID != billing {
+		t.Fatalf("server filter: %+v", got)
+	}
+	if got := listSessions(t, api, "?tenant=acme&server=orders"); len(got) != 2 || got[1].ID != second {
+		t.Fatalf("tenant and server filter: %+v", got)
+	}
+	if got := listSessions(t, api, "?tenant=globex"); len(got) != 0 {
+		t.Fatalf("other tenant: %+v", got)
+	}
+}
+
+func TestAdminSessionTermination(t *testing.T) {
+	g := newTestGateway(t)
+	sid := g.initialize(t)
+	api := sessionsAPI(g.sessions)
+
+	if resp := g.call(t, sid, "tools/list", nil); resp.Error != nil {
+		t.Fatalf("before termination: %+v", resp.Error)
+	}
+	if rec := adminRequest(api, http.MethodDelete, "/api/sessions/"+sid, "", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("terminate: status %d", rec.Code)
+	}
+	resp := g.call(t, sid, "tools/list", nil)
+	if resp.Error == nil || resp.Error.Code != -32005 || resp.Error.Message != "session not found" {
+		t.Fatalf("after termination: %+v", resp.Error)
+	}
+	if got := listSessions(t, api, ""); len(got) != 0 {
+		t.Fatalf("terminated session still listed: %+v", got)
+	}
+	if rec := adminRequest(api, http.MethodDelete, "/api/sessions/"+sid, "", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("terminate twice: status %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/events"
+	"gateway/proxy/internal/store"
+)
+
+// getStream opens the GET event stream of a session on server without reading it.
+func (g *testGateway) getStream(t *testing.T, server, sid string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, g.URL+"/proxy/"+server+"/mcp", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Mcp-Session-Id", sid)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// openStreams is the number of SSE streams counted against tenant.
+func openStreams(tenant string) int {
+	sseStreams.mu.Lock()
+	defer sseStreams.mu.Unlock()
+	return sseStreams.counts[tenant]
+}
+
+func TestStreamCapPerTenant(t *testing.T) {
+	prev := config.SSEMaxStreamsPerTenant
+	t.Cleanup(func() { config.SSEMaxStreamsPerTenant = prev })
+	config.SSEMaxStreamsPerTenant = 1
+	g := newTestGateway(t)
+	if err := g.store.UpsertTenant(store.Tenant{Slug: "globex", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.store.UpsertServer(store.Server{Slug: "billing", TenantSlug: "globex", Name: "billing", Enabled: true, Audience: "https://api.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	first := g.getStream(t, "orders", g.initialize(t))
+	if first.StatusCode != http.StatusOK {
+		t.Fatalf("first stream: status %d", first.StatusCode)
+	}
+	sid := g.initialize(t)
+	if resp := g.getStream(t, "orders", sid); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("stream over the cap: status %d, want 429", resp.StatusCode)
+	}
+	// The cap is per tenant
+	if resp := g.getStream(t, "billing", g.initializeOn(t, "billing")); resp.StatusCode != http.StatusOK {
+		t.Fatalf("other tenant: status %d", resp.StatusCode)
+	}
+
+	// Closing a stream frees its slot
+	first.Body.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for openStreams("acme") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("slot not released after the stream closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if resp := g.getStream(t, "orders", sid); resp.StatusCode != http.StatusOK {
+		t.Fatalf("after a stream closed: status %d", resp.StatusCode)
+	}
+}
+
+func TestStalledStreamIsClosed(t *testing.T) {
+	prev := config.SSEWriteTimeout
+	t.Cleanup(func() { config.SSEWriteTimeout = prev })
+	config.SSEWriteTimeout = 100 * time.Millisecond
+	g := newTestGateway(t)
+	g.bus.SetStreamBuffer(2)
+	sid := g.initialize(t)
+
+	// The client never reads; once the socket buffers and then the stream's notification
+	// buffer fill up, the gateway gives up on the stream instead of blocking
+	if resp := g.getStream(t, "orders", sid); resp.StatusCode != http.StatusOK {
+		t.Fatalf("stream: status %d", resp.StatusCode)
+	}
+	big := events.Notification{Method: events.Progress, Params: map[string]interface{}{"message": strings.Repeat("x", 64<<10)}}
+	deadline := time.Now().Add(10 * time.Second)
+	for openStreams("acme") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stalled stream still open")
+		}
+		g.bus.PublishTo("orders", sid, big)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The session itself survives and can open a new stream
+	if resp := g.getStream(t, "orders", sid); resp.StatusCode != http.StatusOK {
+		t.Fatalf("reconnect: status %d", resp.StatusCode)
+	}
+}
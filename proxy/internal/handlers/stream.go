@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/engine"
+)
+
+// streamChunkBytes bounds how much of an upstream body is held in memory while relaying it.
+const streamChunkBytes = 32 * 1024
+
+// writeRPCStream relays a streamed upstream response as the tools/call result, flushing
+// each chunk as chunked HTTP. The result has the same {status, data} shape as the buffered
+// path plus contentType: JSON bodies are copied as-is into data, anything else becomes
+// {"text": "..."}, escaped as it streams. Because JSON bodies are not validated, an
+// upstream that mislabels its content type yields an invalid response. meta, when set,
+// becomes the result's _meta. Once the header is written a failed upstream read can only
+// abort the connection. Each write must complete within config.SSEWriteTimeout, so a
+// client that stops reading releases the handler and the upstream connection.
+func writeRPCStream(w http.ResponseWriter, r *http.Request, id json.RawMessage, st *engine.Stream, meta map[string]interface{}) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	contentType := st.UpstreamHeaders.Get("Content-Type")
+	body := bufio.NewReaderSize(st.Body, streamChunkBytes)
+	_, peekErr := body.Peek(1)
+	empty := peekErr == io.EOF
+
+	// Large bodies may outlive the server-wide write timeout, so the deadline moves forward
+	// with every chunk instead
+	fw := flushWriter{w, http.NewResponseController(w)}
+	fw.extendDeadline()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	prefix, _ := json.Marshal(contentType)
+	metaField := ""
+	if meta != nil {
+		b, _ := json.Marshal(meta)
+		metaField = `"_meta":` + string(b) + `,`
+	}
+	_, err := io.WriteString(fw, `{"jsonrpc":"2.0","id":`+string(id)+`,"result":{`+metaField+`"status":`+strconv.Itoa(st.UpstreamStatus)+`,"contentType":`+string(prefix)+`,"data":`)
+
+	switch {
+	case err != nil:
+	case empty:
+		_, err = fw.Write(engine.EmptyBody(st.UpstreamStatus))
+	case isJSONContentType(contentType):
+		_, err = io.CopyBuffer(fw, body, make([]byte, streamChunkBytes))
+	default:
+		if _, err = io.WriteString(fw, `{"text":"`); err == nil {
+			err = copyJSONString(fw, body)
+		}
+		if err == nil {
+			_, err = io.WriteString(fw, `"}`)
+		}
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "streamed tool response aborted", "error", err.Error())
+		// Abort so the client sees a truncated response rather than a complete-looking one
+		panic(http.ErrAbortHandler)
+	}
+	_, _ = io.WriteString(fw, "}}\n")
+}
+
+// flushWriter flushes after every write so each chunk reaches the client as it arrives.
+// Each write gets config.SSEWriteTimeout to complete.
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	f.extendDeadline()
+	n, err := f.w.Write(p)
+	if err == nil {
+		err = f.rc.Flush()
+		if errors.Is(err, http.ErrNotSupported) {
+			err = nil
+		}
+	}
+	return n, err
+}
+
+func (f flushWriter) extendDeadline() {
+	_ = f.rc.SetWriteDeadline(time.Now().Add(config.SSEWriteTimeout))
+}
+
+// copyJSONString writes src as the contents of a JSON string (without quotes), one chunk
+// at a time. A UTF-8 sequence split across chunks is carried over to the next one.
+func copyJSONString(dst io.Writer, src io.Reader) error {
+	buf := make([]byte, streamChunkBytes)
+	var carry int
+	for {
+		n, err := src.Read(buf[carry:])
+		n += carry
+		end := n
+		if err == nil {
+			end = completeRunes(buf[:n])
+		}
+		if end > 0 {
+			quoted, _ := json.Marshal(string(buf[:end]))
+			if _, werr := dst.Write(quoted[1 : len(quoted)-1]); werr != nil {
+				return werr
+			}
+		}
+		carry = copy(buf, buf[end:n])
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// completeRunes returns the length of b without a trailing incomplete UTF-8 sequence.
+func completeRunes(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(b)
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
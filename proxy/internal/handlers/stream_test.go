@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/engine"
+)
+
+// jsonArrayReader produces a JSON array of n copies of a string item without holding it in
+// memory, counting the bytes read so far.
+type jsonArrayReader struct {
+	item      []byte // `"xxx",`
+	n, next   int
+	pending   []byte
+	bytesRead int64
+}
+
+func newJSONArrayReader(itemLen, n int) *jsonArrayReader {
+	return &jsonArrayReader{item: []byte(`"` + strings.Repeat("x", itemLen) + `",`), n: n}
+}
+
+func (r *jsonArrayReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		switch {
+		case r.next == 0:
+			r.pending = []byte("[")
+		case r.next > r.n+1:
+			return 0, io.EOF
+		case r.next == r.n+1:
+			r.pending = []byte(`""]`)
+		default:
+			r.pending = r.item
+		}
+		r.next++
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	r.bytesRead += int64(n)
+	return n, nil
+}
+
+// countingResponseWriter discards the body, remembering how much the upstream reader had
+// produced when the first byte of the result reached the client.
+type countingResponseWriter struct {
+	header      http.Header
+	written     int64
+	readAtFirst int64
+	upstream    *jsonArrayReader
+	head, tail  []byte
+	flushes     int
+}
+
+func (w *countingResponseWriter) Header() http.Header { return w.header }
+func (w *countingResponseWriter) WriteHeader(int)     {}
+func (w *countingResponseWriter) Flush()              { w.flushes++ }
+
+func (w *countingResponseWriter) Write(p []byte) (int, error) {
+	if w.written == 0 {
+		w.readAtFirst = w.upstream.bytesRead
+	}
+	if len(w.head) < 256 {
+		w.head = append(w.head, p[:min(len(p), 256-len(w.head))]...)
+	}
+	if len(p) >= 256 {
+		w.tail = append(w.tail[:0], p[len(p)-256:]...)
+	} else {
+		w.tail = append(w.tail, p...)
+		w.tail = w.tail[max(0, len(w.tail)-256):]
+	}
+	w.written += int64(len(p))
+	return len(p), nil
+}
+
+func TestWriteRPCStreamDoesNotBufferBody(t *testing.T) {
+	upstream := newJSONArrayReader(1000, 32*1024)
+	w := &countingResponseWriter{header: http.Header{}, upstream: upstream}
+	st := &engine.Stream{
+		UpstreamStatus:  http.StatusOK,
+		UpstreamHeaders: http.Header{"Content-Type": {"application/json"}},
+		Body:            io.NopCloser(upstream),
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	writeRPCStream(w, httptest.NewRequest(http.MethodPost, "/mcp", nil), json.RawMessage(`1`), st, nil)
+	runtime.ReadMemStats(&after)
+
+	size := upstream.bytesRead
+	if size < 32e6 {
+		t.Fatalf("upstream produced only %d bytes", size)
+	}
+	if w.readAtFirst > 2*streamChunkBytes {
+		t.Fatalf("%d upstream bytes read before the client got anything", w.readAtFirst)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > uint64(size/8) {
+		t.Fatalf("allocated %d bytes relaying a %d byte body", allocated, size)
+	}
+	if w.flushes < 100 {
+		t.Fatalf("only %d flushes", w.flushes)
+	}
+	if !strings.HasPrefix(string(w.head), `{"jsonrpc":"2.0","id":1,"result":{"status":200,"contentType":"application/json","data":["xxx`) {
+		t.Fatalf("unexpected head %s", w.head)
+	}
+	if !strings.HasSuffix(string(w.tail), `xxx",""]}}`+"\n") {
+		t.Fatalf("unexpected tail %s", w.tail)
+	}
+}
+
+func TestWriteRPCStreamEscapesText(t *testing.T) {
+	// Multi-byte runes straddle the chunk boundaries
+	text := strings.Repeat("é\"\n€", streamChunkBytes)
+	st := &engine.Stream{
+		UpstreamStatus:  http.StatusOK,
+		UpstreamHeaders: http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:            io.NopCloser(strings.NewReader(text)),
+	}
+	rec := httptest.NewRecorder()
+	writeRPCStream(rec, httptest.NewRequest(http.MethodPost, "/mcp", nil), json.RawMessage(`"a"`), st, map[string]interface{}{"progressToken": "p"})
+	var resp struct {
+		ID     string `json:"id"`
+		Result struct {
+			Meta        map[string]interface{} `json:"_meta"`
+			Status      int                    `json:"status"`
+			ContentType string                 `json:"contentType"`
+			Data        struct {
+				Text string `json:"text"`
+			} `json:"data"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if resp.ID != "a" || resp.Result.Status != 200 || resp.Result.Meta["progressToken"] != "p" || resp.Result.ContentType != "text/plain; charset=utf-8" {
+		t.Fatalf("unexpected envelope %+v", resp)
+	}
+	if resp.Result.Data.Text != text {
+		t.Fatal("text did not round-trip")
+	}
+}
+
+func TestWriteRPCStreamCutsOffStalledClient(t *testing.T) {
+	prev := config.SSEWriteTimeout
+	config.SSEWriteTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { config.SSEWriteTimeout = prev })
+
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		st := &engine.Stream{
+			UpstreamStatus:  http.StatusOK,
+			UpstreamHeaders: http.Header{"Content-Type": {"application/json"}},
+			Body:            io.NopCloser(newJSONArrayReader(1000, 1<<30)),
+		}
+		writeRPCStream(w, r, json.RawMessage(`1`), st, nil)
+	}))
+	t.Cleanup(ts.Close)
+
+	// A client that sends the request and then never reads
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "POST /mcp HTTP/1.1\r\nHost: %s\r\nContent-Length: 0\r\n\r\n", ts.Listener.Addr())
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("handler still writing to a client that stopped reading")
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"gateway/proxy/internal/config"
+)
+
+func withStrictParams(t *testing.T, on bool) {
+	t.Helper()
+	prev := config.StrictParams
+	t.Cleanup(func() { config.StrictParams = prev })
+	config.StrictParams = on
+}
+
+func TestStrictParamsRejectsUnknownFields(t *testing.T) {
+	withStrictParams(t, true)
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(`{}`)) })
+	g.tools(t, getTool("get_order", "/orders/{{id}}"))
+	sid := g.initialize(t)
+
+	cases := []struct {
+		name   string
+		params map[string]interface{}
+		field  string
+	}{
+		{"misspelled arguments", map[string]interface{}{"name": "get_order", "argumnets": map[string]interface{}{"id": "1"}}, "argumnets"},
+		{"wrong case", map[string]interface{}{"name": "get_order", "Arguments": map[string]interface{}{"id": "1"}}, "Arguments"},
+	}
+	for _, tc := range cases {
+		resp := g.call(t, sid, "tools/call", tc.params)
+		if resp.Error == nil || resp.Error.Code != -32602 {
+			t.Fatalf("%s: got %+v, want -32602", tc.name, resp.Error)
+		}
+		var data struct {
+			Field string `json:"field"`
+		}
+		if err := json.Unmarshal(resp.Error.Data, &data); err != nil || data.Field != tc.field {
+			t.Fatalf("%s: error data %s, want field %s", tc.name, resp.Error.Data, tc.field)
+		}
+	}
+
+	// Known fields, _meta and anything nested inside arguments are fine
+	ok := map[string]interface{}{"name": "get_order", "arguments": map[string]interface{}{"id": "1", "extra": true}, "_meta": map[string]interface{}{"progressToken": "t"}}
+	if status, _ := toolResult(t, g.call(t, sid, "tools/call", ok)); status != http.StatusOK {
+		t.Fatalf("known fields: status %d", status)
+	}
+
+	resp := g.post(t, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+config.MCPProtocolVersionLatest+`","clientInfo":{"name":"c"},"capabilitiez":{}}}`)
+	raw, _ := io.ReadAll(resp.Body)
+	var out rpcResponse
+	if err := json.Unmarshal(raw, &out); err != nil || out.Error == nil || out.Error.Code != -32602 || resp.Header.Get("Mcp-Session-Id") != "" {
+		t.Fatalf("initialize with an unknown field: %s", raw)
+	}
+}
+
+func TestLenientParamsIgnoreUnknownFields(t *testing.T) {
+	withStrictParams(t, false)
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(`{}`)) })
+	g.tools(t, getTool("list_orders", "/orders"))
+
+	resp := g.post(t, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+config.MCPProtocolVersionLatest+`","capabilitiez":{}}}`)
+	sid := resp.Header.Get("Mcp-Session-Id")
+	if sid == "" {
+		t.Fatalf("initialize: status %d, no session", resp.StatusCode)
+	}
+	if status, _ := toolResult(t, g.call(t, sid, "tools/call", map[string]interface{}{"name": "list_orders", "argumnets": map[string]interface{}{}})); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+}
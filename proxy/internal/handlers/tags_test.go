@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestListServersByTag(t *testing.T) {
+	g := newTestGateway(t)
+	mux := chi.NewRouter()
+	mux.Post("/api/servers", UpsertServerHandler(newControlStore(g.store)))
+	mux.Get("/api/servers", ListServersHandler(g.store))
+
+	for _, body := range []string{
+		`{"slug":"orders","tenantSlug":"acme","name":"orders","audience":"https://api.example.com","enabled":true,"tags":["team:payments","prod"]}`,
+		`{"slug":"billing","tenantSlug":"acme","name":"billing","audience":"https://api.example.com","enabled":true,"tags":["team:payments"]}`,
+		`{"slug":"search","tenantSlug":"acme","name":"search","audience":"https://api.example.com","enabled":true}`,
+	} {
+		if rec := adminRequest(mux, http.MethodPost, "/api/servers", "", body); rec.Code >= 300 {
+			t.Fatalf("upsert: status %d: %s", rec.Code, rec.Body)
+		}
+	}
+	if rec := adminRequest(mux, http.MethodPost, "/api/servers", "", `{"slug":"x","tenantSlug":"acme","name":"x","audience":"https://api.example.com","tags":[" "]}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("blank tag: status %d, want 400", rec.Code)
+	}
+
+	list := func(query string) string {
+		t.Helper()
+		rec := adminRequest(mux, http.MethodGet, "/api/servers"+query, "", "")
+		var out struct {
+			Servers []struct {
+				Slug string   `json:"slug"`
+				Tags []string `json:"tags"`
+			} `json:"servers"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || out.Servers == nil {
+			t.Fatalf("%s: status %d, body %s", query, rec.Code, rec.Body)
+		}
+		entries := make([]string, len(out.Servers))
+		for i, s := range out.Servers {
+			if s.Tags == nil {
+				t.Fatalf("%s: %s has null tags", query, s.Slug)
+			}
+			entries[i] = s.Slug + "[" + strings.Join(s.Tags, " ") + "]"
+		}
+		return strings.Join(entries, ",")
+	}
+	cases := []struct{ query, want string }{
+		{"", "billing[team:payments],orders[team:payments prod],search[]"},
+		{"?tag=team:payments", "billing[team:payments],orders[team:payments prod]"},
+		{"?tag=prod", "orders[team:payments prod]"},
+		{"?tag=staging", ""},
+	}
+	for _, tc := range cases {
+		if got := list(tc.query); got != tc.want {
+			t.Errorf("%q: %s, want %s", tc.query, got, tc.want)
+		}
+	}
+}
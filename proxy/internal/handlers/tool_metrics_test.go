@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/metrics"
+)
+
+func TestToolCallMetricsCardinalityGuard(t *testing.T) {
+	prevLimit, prevGuard := config.MetricsMaxToolLabels, toolLabels
+	t.Cleanup(func() { config.MetricsMaxToolLabels, toolLabels = prevLimit, prevGuard })
+	config.MetricsMaxToolLabels = 2
+	toolLabels = metrics.NewLabelGuard()
+
+	captureLogs(t)
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	})
+	g.tools(t, getTool("get_order", "/ok"), getTool("list_orders", "/fail"), getTool("cancel_order", "/ok"), getTool("refund_order", "/ok"))
+	sid := g.initialize(t)
+
+	count := func(tool, outcome string) float64 { return toolCalls.Value("acme", "orders", tool, outcome) }
+	before := map[string]float64{
+		"get_order ok":    count("get_order", "ok"),
+		"list_orders err": count("list_orders", "error"),
+		"other ok":        count(metrics.OtherLabel, "ok"),
+		"cancel_order ok": count("cancel_order", "ok"),
+		"refund_order ok": count("refund_order", "ok"),
+	}
+	for _, name := range []string{"get_order", "list_orders", "cancel_order", "refund_order", "get_order"} {
+		g.call(t, sid, "tools/call", map[string]interface{}{"name": name})
+	}
+
+	// The first two tools keep their labels, later ones share the other bucket
+	cases := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"get_order ok", count("get_order", "ok"), 2},
+		{"list_orders err", count("list_orders", "error"), 1},
+		{"other ok", count(metrics.OtherLabel, "ok"), 2},
+		{"cancel_order ok", count("cancel_order", "ok"), 0},
+		{"refund_order ok", count("refund_order", "ok"), 0},
+	}
+	for _, tc := range cases {
+		if d := tc.got - before[tc.name]; d != tc.want {
+			t.Errorf("%s: +%v, want +%v", tc.name, d, tc.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+type toolsPage struct {
+	Tools []struct {
+		Name string `json:"name"`
+	} `json:"tools"`
+	NextCursor string `json:"nextCursor"`
+}
+
+func TestToolsListCursorPaging(t *testing.T) {
+	g := newTestGateway(t)
+	var tools []store.Tool
+	for i := 0; i < toolsPageSize+20; i++ {
+		tools = append(tools, getTool(fmt.Sprintf("tool_%03d", i), "/x"))
+	}
+	g.tools(t, tools...)
+	sid := g.initialize(t)
+
+	var names []string
+	var params interface{}
+	for pages := 0; ; pages++ {
+		resp := g.call(t, sid, "tools/list", params)
+		if resp.Error != nil {
+			t.Fatalf("tools/list: %+v", resp.Error)
+		}
+		var page toolsPage
+		if err := json.Unmarshal(resp.Result, &page); err != nil {
+			t.Fatal(err)
+		}
+		for _, tool := range page.Tools {
+			names = append(names, tool.Name)
+		}
+		if page.NextCursor == "" {
+			if pages != 1 {
+				t.Fatalf("%d pages, want 2", pages+1)
+			}
+			break
+		}
+		params = map[string]string{"cursor": page.NextCursor}
+	}
+	if len(names) != len(tools) || names[0] != "tool_000" || names[len(names)-1] != fmt.Sprintf("tool_%03d", len(tools)-1) {
+		t.Fatalf("listed %d tools from %s to %s", len(names), names[0], names[len(names)-1])
+	}
+}
+
+func TestToolsListBadCursor(t *testing.T) {
+	g := newTestGateway(t)
+	sid := g.initialize(t)
+	for _, cursor := range []string{"not base64!", encodeCursorRaw("-1"), encodeCursorRaw("x")} {
+		if resp := g.call(t, sid, "tools/list", map[string]string{"cursor": cursor}); resp.Error == nil || resp.Error.Code != -32602 {
+			t.Fatalf("cursor %q: %+v, want -32602", cursor, resp.Error)
+		}
+	}
+}
+
+func encodeCursorRaw(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+func TestToolsListAnnotations(t *testing.T) {
+	g := newTestGateway(t)
+	no := false
+	search := store.Tool{Name: "search_orders", Mapping: store.RequestTemplate{Method: http.MethodPost, Path: "/orders/search"}, Annotations: &store.ToolAnnotations{Title: "Search orders", DestructiveHint: &no}}
+	create := store.Tool{Name: "create_order", Mapping: store.RequestTemplate{Method: http.MethodPost, Path: "/orders"}}
+	g.tools(t, getTool("list_orders", "/orders"), create, search)
+	sid := g.initialize(t)
+
+	resp := g.call(t, sid, "tools/list", nil)
+	if resp.Error != nil {
+		t.Fatalf("tools/list: %+v", resp.Error)
+	}
+	var page struct {
+		Tools []struct {
+			Name        string                 `json:"name"`
+			Annotations map[string]interface{} `json:"annotations"`
+		} `json:"tools"`
+	}
+	if err := json.Unmarshal(resp.Result, &page); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]interface{}{
+		"list_orders":   {"readOnlyHint": true, "destructiveHint": false, "idempotentHint": true, "openWorldHint": true},
+		"create_order":  {"readOnlyHint": false, "destructiveHint": true, "idempotentHint": false, "openWorldHint": true},
+		"search_orders": {"title": "Search orders", "readOnlyHint": false, "destructiveHint": false, "idempotentHint": false, "openWorldHint": true},
+	}
+	if len(page.Tools) != len(want) {
+		t.Fatalf("%d tools, want %d", len(page.Tools), len(want))
+	}
+	for _, tool := range page.Tools {
+		if !reflect.DeepEqual(tool.Annotations, want[tool.Name]) {
+			t.Errorf("%s: annotations %v, want %v", tool.Name, tool.Annotations, want[tool.Name])
+		}
+	}
+}
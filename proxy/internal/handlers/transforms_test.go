@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+func TestUpsertToolsRejectsBadTransforms(t *testing.T) {
+	g := newTestGateway(t)
+	api := controlAPI(newControlStore(g.store), g.bus)
+	rec := adminRequest(api, http.MethodPost, "/api/servers/orders/tools", "", `{"tools":[{"name":"find","mapping":{"method":"GET","path":"/p/{{slug}}","transforms":[{"arg":"slug","expr":"first +"}]}}]}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "tool find: transform slug") {
+		t.Fatalf("status %d, body %q", rec.Code, rec.Body)
+	}
+	rec = adminRequest(api, http.MethodPost, "/api/servers/orders/tools", "", `{"tools":[{"name":"find","mapping":{"method":"GET","path":"/p/{{slug}}","transforms":[{"arg":"slug","expr":"lower(first)"}]}}]}`)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("valid transform: status %d, body %q", rec.Code, rec.Body)
+	}
+}
+
+func TestToolsCallTransformOverBudget(t *testing.T) {
+	g := newTestGateway(t)
+	g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("upstream reached with %s", r.URL)
+	})
+	tool := getTool("find", "/p/{{slug}}")
+	tool.Mapping.Transforms = []store.ArgTransform{{Arg: "slug", Expr: "a" + strings.Repeat("+a", 1000)}}
+	g.tools(t, tool)
+	sid := g.initialize(t)
+	resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "find", "arguments": map[string]interface{}{"a": "x"}})
+	if resp.Error == nil || resp.Error.Code != -32008 {
+		t.Fatalf("got %+v, want -32008", resp)
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/store"
+)
+
+// upstreamErrorData is the data of a failed tools/call in debug verbosity.
+type upstreamErrorData struct {
+	Host   string `json:"host"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Status int    `json:"status"`
+	Body   string `json:"body"`
+}
+
+func secretTool() store.Tool {
+	tool := getTool("get_order", "/orders/{{id}}")
+	tool.Mapping.Headers = map[string]string{"Authorization": "Bearer upstream-secret"}
+	tool.Mapping.Query = map[string]string{"api_key": "query-secret"}
+	return tool
+}
+
+func TestUpstreamErrorDataConnectionRefused(t *testing.T) {
+	withVerbosity(t, config.ErrorVerbosityDebug)
+	g := newTestGateway(t)
+	up := g.upstream(t, func(w http.ResponseWriter, r *http.Request) {})
+	up.Close()
+	g.tools(t, secretTool())
+	sid := g.initialize(t)
+
+	resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "get_order", "arguments": map[string]interface{}{"id": "42"}})
+	if resp.Error == nil || resp.Error.Code != -32007 {
+		t.Fatalf("error %+v, want -32007", resp.Error)
+	}
+	var data upstreamErrorData
+	if err := json.Unmarshal(resp.Error.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(up.URL)
+	if data.Host != u.Host || data.Method != http.MethodGet || data.Path != "/orders/42" || data.Status != 0 {
+		t.Fatalf("data %+v", data)
+	}
+	assertNoSecrets(t, resp)
+}
+
+func TestUpstreamErrorData500(t *testing.T) {
+	withVerbosity(t, config.ErrorVerbosityDebug)
+	g := newTestGateway(t)
+	up := g.upstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("boom " + strings.Repeat("x", 4096)))
+	})
+	g.tools(t, secretTool())
+	sid := g.initialize(t)
+
+	resp := g.call(t, sid, "tools/call", map[string]interface{}{"name": "get_order", "arguments": map[string]interface{}{"id": "42"}})
+	if resp.Error == nil || resp.Error.Code != -32000 {
+		t.Fatalf("error %+v, want -32000", resp.Error)
+	}
+	var data upstreamErrorData
+	if err := json.Unmarshal(resp.Error.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(up.URL)
+	if data.Host != u.Host || data.Method != http.MethodGet || data.Path != "/orders/42" || data.Status != http.StatusInternalServerError {
+		t.Fatalf("data %+v", data)
+	}
+	if !strings.Contains(data.Body, "boom ") || len(data.Body) > 600 {
+		t.Fatalf("body snippet of %d bytes: %.40q", len(data.Body), data.Body)
+	}
+	assertNoSecrets(t, resp)
+}
+
+func assertNoSecrets(t *testing.T, resp rpcResponse) {
+	t.Helper()
+	raw := resp.Error.Message + string(resp.Error.Data)
+	for _, secret := range []string{"upstream-secret", "query-secret"} {
+		if strings.Contains(raw, secret) {
+			t.Fatalf("error leaks %q: %s", secret, raw)
+		}
+	}
+}
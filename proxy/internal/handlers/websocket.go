@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/events"
+	"gateway/proxy/internal/session"
+)
+
+// Origin and Host are already checked by auth.OriginHostMiddleware on MCP routes.
+var wsUpgrader = websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+
+// MCPWebSocketHandler serves MCP over a WebSocket. Each text message is a JSON-RPC
+// request dispatched through mcp (the POST endpoint handler) exactly as if it had been
+// POSTed with the upgrade request's credentials; the socket carries the session id and
+// negotiated protocol version itself, so clients never send those headers. Once
+// initialize has created the session, its server-initiated notifications are pushed
+// inline. Messages are handled in order. Messages are capped at config.WSMaxMessageBytes,
+// the gateway pings every half config.WSIdleTimeout and closes a socket that stays silent
+// for the whole timeout, and each write must finish within config.SSEWriteTimeout.
+func MCPWebSocketHandler(mcp http.Handler, sm *session.Manager, bus *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade has already replied with an HTTP error
+			return
+		}
+		defer conn.Close()
+
+		idle, writeTimeout := config.WSIdleTimeout, config.SSEWriteTimeout
+		var writeMu sync.Mutex
+		write := func(messageType int, b []byte) error {
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			return conn.WriteMessage(messageType, b)
+		}
+		send := func(b []byte) error { return write(websocket.TextMessage, b) }
+
+		conn.SetReadLimit(config.WSMaxMessageBytes)
+		_ = conn.SetReadDeadline(time.Now().Add(idle))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(idle))
+		})
+		stopPings := make(chan struct{})
+		defer close(stopPings)
+		go func() {
+			ticker := time.NewTicker(idle / 2)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stopPings:
+					return
+				case <-ticker.C:
+					if write(websocket.PingMessage, nil) != nil {
+						return
+					}
+				}
+			}
+		}()
+
+		var sid, version string
+		unsubscribe := func() error { return nil }
+		defer func() { unsubscribe() }()
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			_ = conn.SetReadDeadline(time.Now().Add(idle))
+			req := r.Clone(r.Context())
+			req.Method = http.MethodPost
+			req.Body = io.NopCloser(bytes.NewReader(msg))
+			req.ContentLength = int64(len(msg))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Del("Upgrade")
+			if sid != "" {
+				req.Header.Set("Mcp-Session-Id", sid)
+				req.Header.Set("MCP-Protocol-Version", version)
+			}
+			rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK, body: &bytes.Buffer{}}
+			mcp.ServeHTTP(rec, req)
+
+			// initialize issued a (new) session: bind the socket's notifications to it
+			if newSid := rec.header.Get("Mcp-Session-Id"); newSid != "" && newSid != sid {
+				if sess, err := sm.Get(newSid); err == nil {
+					unsubscribe()
+					sid, version = newSid, sess.ProtocolVersion
+					var queue <-chan events.Notification
+					queue, unsubscribe = bus.Subscribe(sess.ServerSlug, sid)
+					go func() {
+						for n := range queue {
+							if send(notificationJSON(n)) != nil {
+								return
+							}
+						}
+					}()
+				}
+			}
+
+			// HTTP-level rejections become JSON-RPC errors on the socket
+			if out := relayedMessage(rec, msg); out != nil {
+				err = send(out)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// bufferedResponse captures a handler's response so it can be relayed as one message.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   *bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) { b.status = status }
+
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
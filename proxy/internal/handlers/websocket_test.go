@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"gateway/proxy/internal/config"
+	"gateway/proxy/internal/events"
+)
+
+func (g *testGateway) dialWS(t *testing.T) *websocket.Conn {
+	t.Helper()
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(g.URL, "http")+"/proxy/orders/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func wsCall(t *testing.T, conn *websocket.Conn, id int, method string, params interface{}) rpcResponse {
+	t.Helper()
+	msg := map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatal(err)
+	}
+	var out rpcResponse
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.ReadJSON(&out); err != nil {
+		t.Fatalf("%s: %v", method, err)
+	}
+	return out
+}
+
+func withWSConfig(t *testing.T, maxBytes int64, idle time.Duration) {
+	t.Helper()
+	prevMax, prevIdle := config.WSMaxMessageBytes, config.WSIdleTimeout
+	t.Cleanup(func() { config.WSMaxMessageBytes, config.WSIdleTimeout = prevMax, prevIdle })
+	config.WSMaxMessageBytes, config.WSIdleTimeout = maxBytes, idle
+}
+
+func TestWebSocketInitializeListAndNotify(t *testing.T) {
+	g := newTestGateway(t)
+	g.tools(t, getTool("get_order", "/orders/1"))
+	conn := g.dialWS(t)
+
+	if resp := wsCall(t, conn, 1, "initialize", map[string]interface{}{"protocolVersion": config.MCPProtocolVersionLatest}); resp.Error != nil {
+		t.Fatalf("initialize: %+v", resp.Error)
+	}
+	resp := wsCall(t, conn, 2, "tools/list", nil)
+	if resp.Error != nil || !strings.Contains(string(resp.Result), `"get_order"`) {
+		t.Fatalf("tools/list: %s %+v", resp.Result, resp.Error)
+	}
+
+	g.bus.Publish("orders", events.Notification{Method: events.ToolsListChanged})
+	var n struct {
+		Method string `json:"method"`
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.ReadJSON(&n); err != nil || n.Method != events.ToolsListChanged {
+		t.Fatalf("notification %+v, err %v", n, err)
+	}
+}
+
+func TestWebSocketMessageTooLarge(t *testing.T) {
+	withWSConfig(t, 256, time.Minute)
+	g := newTestGateway(t)
+	conn := g.dialWS(t)
+
+	big := `{"jsonrpc":"2.0","id":1,"method":"ping","params":{"pad":"` + strings.Repeat("x", 1024) + `"}}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(big)); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Fatalf("oversized message: err = %v, want close 1009", err)
+	}
+}
+
+func TestWebSocketIdleTimeout(t *testing.T) {
+	withWSConfig(t, 1<<20, 300*time.Millisecond)
+	g := newTestGateway(t)
+
+	// A client that ignores pings is disconnected after the idle timeout
+	silent := g.dialWS(t)
+	silent.SetPingHandler(func(string) error { return nil })
+	start := time.Now()
+	_ = silent.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := silent.ReadMessage(); err == nil {
+		t.Fatal("silent client got a message")
+	} else if ne, ok := err.(interface{ Timeout() bool }); ok && ne.Timeout() {
+		t.Fatal("silent client was not disconnected")
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Fatalf("silent client disconnected after %v", d)
+	}
+
+	// One that answers pings stays connected well past it
+	live := g.dialWS(t)
+	msgs := make(chan []byte, 1)
+	go func() {
+		for {
+			_, msg, err := live.ReadMessage()
+			if err != nil {
+				close(msgs)
+				return
+			}
+			msgs <- msg
+		}
+	}()
+	time.Sleep(time.Second)
+	if err := live.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+config.MCPProtocolVersionLatest+`"}}`)); err != nil {
+		t.Fatalf("live client disconnected: %v", err)
+	}
+	select {
+	case msg, ok := <-msgs:
+		var resp rpcResponse
+		if !ok || json.Unmarshal(msg, &resp) != nil || resp.Error != nil {
+			t.Fatalf("live client: %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no response on live socket")
+	}
+}
+
+func TestWebSocketUpgradeRequired(t *testing.T) {
+	g := newTestGateway(t)
+	resp, err := http.Get(g.URL + "/proxy/orders/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("plain GET: status %d, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// NewLogger returns a JSON slog logger writing to stdout at the given level
+// (debug, info, warn, error; default info).
+func NewLogger(level string) *slog.Logger {
+	var l slog.Level
+	switch strings.ToLower(level) {
+	case "debug":
+		l = slog.LevelDebug
+	case "warn", "warning":
+		l = slog.LevelWarn
+	case "error":
+		l = slog.LevelError
+	default:
+		l = slog.LevelInfo
+	}
+	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: l}))
+}
+
+type contextKey string
+
+const fieldsKey contextKey = "logFields"
+
+// requestFields carries values resolved deeper in the chain (e.g. tenant) back to the logger.
+type requestFields struct {
+	tenant string
+}
+
+// SetTenant records the resolved tenant slug on the request's log line.
+func SetTenant(ctx context.Context, tenant string) {
+	if f, ok := ctx.Value(fieldsKey).(*requestFields); ok {
+		f.tenant = tenant
+	}
+}
+
+// Middleware emits one structured line per request with correlation fields:
+// request id, method, path, status, duration, server slug, tenant and MCP session id.
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			fields := &requestFields{}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), fieldsKey, fields)))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			attrs := []slog.Attr{
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Duration("duration", time.Since(start)),
+				slog.Int("bytes", ww.BytesWritten()),
+			}
+			if server := chi.URLParam(r, "server"); server != "" {
+				attrs = append(attrs, slog.String("server", server))
+			}
+			if fields.tenant != "" {
+				attrs = append(attrs, slog.String("tenant", fields.tenant))
+			}
+			// Session id comes from the request, or from the response on initialize
+			sid := r.Header.Get("Mcp-Session-Id")
+			if sid == "" {
+				sid = ww.Header().Get("Mcp-Session-Id")
+			}
+			if sid != "" {
+				attrs = append(attrs, slog.String("session_id", sid))
+			}
+			level := slog.LevelInfo
+			if status >= 500 {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(r.Context(), level, "http request", attrs...)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// logRequest serves one request through Middleware, the way cmd/proxy mounts it, and
+// returns the decoded log line.
+func logRequest(t *testing.T, h http.HandlerFunc, req *http.Request) map[string]interface{} {
+	t.Helper()
+	var buf bytes.Buffer
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(Middleware(slog.New(slog.NewJSONHandler(&buf, nil))))
+	r.Post("/proxy/{server}/mcp", h)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log output %q: %v", buf.String(), err)
+	}
+	return line
+}
+
+func TestMiddlewareLogsToolCall(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/proxy/orders/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"get_order"}}`))
+	req.Header.Set("Mcp-Session-Id", "sess-1")
+	line := logRequest(t, func(w http.ResponseWriter, r *http.Request) {
+		SetTenant(r.Context(), "acme")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	}, req)
+
+	want := map[string]interface{}{
+		"level":      "INFO",
+		"msg":        "http request",
+		"method":     "POST",
+		"path":       "/proxy/orders/mcp",
+		"status":     float64(200),
+		"server":     "orders",
+		"tenant":     "acme",
+		"session_id": "sess-1",
+	}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s = %v, want %v", k, line[k], v)
+		}
+	}
+	for _, k := range []string{"request_id", "duration", "bytes"} {
+		if _, ok := line[k]; !ok {
+			t.Errorf("missing %s in %v", k, line)
+		}
+	}
+	if id, _ := line["request_id"].(string); id == "" {
+		t.Errorf("empty request_id")
+	}
+}
+
+func TestMiddlewareSessionFromResponseAndErrors(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/proxy/orders/mcp", nil)
+	line := logRequest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Mcp-Session-Id", "new-session")
+		w.WriteHeader(http.StatusBadGateway)
+	}, req)
+	if line["session_id"] != "new-session" || line["status"] != float64(502) || line["level"] != "ERROR" {
+		t.Fatalf("line = %v", line)
+	}
+	if _, ok := line["tenant"]; ok {
+		t.Fatalf("tenant logged though never resolved: %v", line)
+	}
+}
+
+func TestNewLoggerLevel(t *testing.T) {
+	cases := map[string]slog.Level{"debug": slog.LevelDebug, "": slog.LevelInfo, "WARN": slog.LevelWarn, "warning": slog.LevelWarn, "error": slog.LevelError, "bogus": slog.LevelInfo}
+	for in, want := range cases {
+		l := NewLogger(in)
+		if !l.Enabled(context.Background(), want) || (want > slog.LevelDebug && l.Enabled(context.Background(), want-1)) {
+			t.Errorf("NewLogger(%q) is not at level %v", in, want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A minimal Prometheus text-format registry: labeled counters and gauges kept in memory
+// and rendered on GET /metrics. Metrics register themselves on creation.
+
+var registry = struct {
+	mu      sync.Mutex
+	metrics []*vec
+}{}
+
+type vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*sample
+}
+
+type sample struct {
+	labelValues []string
+	value       float64
+}
+
+func newVec(kind, name, help string, labels []string) *vec {
+	v := &vec{name: name, help: help, kind: kind, labels: labels, values: make(map[string]*sample)}
+	registry.mu.Lock()
+	registry.metrics = append(registry.metrics, v)
+	registry.mu.Unlock()
+	return v
+}
+
+func (v *vec) add(delta float64, set bool, labelValues []string) {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.values[key]
+	if !ok {
+		s = &sample{labelValues: append([]string(nil), labelValues...)}
+		v.values[key] = s
+	}
+	if set {
+		s.value = delta
+	} else {
+		s.value += delta
+	}
+}
+
+func (v *vec) get(labelValues []string) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.values[strings.Join(labelValues, "\xff")]; ok {
+		return s.value
+	}
+	return 0
+}
+
+// CounterVec is a monotonically increasing metric partitioned by label values.
+type CounterVec struct{ v *vec }
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{v: newVec("counter", name, help, labels)}
+}
+
+// Inc adds one to the counter for the given label values (in label order).
+func (c *CounterVec) Inc(labelValues ...string) { c.v.add(1, false, labelValues) }
+
+// Value returns the current count, mainly for tests and admin endpoints.
+func (c *CounterVec) Value(labelValues ...string) float64 { return c.v.get(labelValues) }
+
+// GaugeVec is a metric that can go up and down, partitioned by label values.
+type GaugeVec struct{ v *vec }
+
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{v: newVec("gauge", name, help, labels)}
+}
+
+func (g *GaugeVec) Set(value float64, labelValues ...string) { g.v.add(value, true, labelValues) }
+func (g *GaugeVec) Inc(labelValues ...string)                { g.v.add(1, false, labelValues) }
+func (g *GaugeVec) Dec(labelValues ...string)                { g.v.add(-1, false, labelValues) }
+func (g *GaugeVec) Value(labelValues ...string) float64      { return g.v.get(labelValues) }
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Handler renders every registered metric in the Prometheus text exposition format.
+func Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		registry.mu.Lock()
+		metrics := append([]*vec(nil), registry.metrics...)
+		registry.mu.Unlock()
+		sort.Slice(metrics, func(i, j int) bool { return metrics[i].name < metrics[j].name })
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		var b strings.Builder
+		for _, m := range metrics {
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+			m.mu.Lock()
+			keys := make([]string, 0, len(m.values))
+			for k := range m.values {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				s := m.values[k]
+				b.WriteString(m.name)
+				if len(m.labels) > 0 {
+					b.WriteByte('{')
+					for i, l := range m.labels {
+						if i > 0 {
+							b.WriteByte(',')
+						}
+						fmt.Fprintf(&b, "%s=\"%s\"", l, labelEscaper.Replace(s.labelValues[i]))
+					}
+					b.WriteByte('}')
+				}
+				fmt.Fprintf(&b, " %s\n", strconv.FormatFloat(s.value, 'g', -1, 64))
+			}
+			m.mu.Unlock()
+		}
+		_, _ = w.Write([]byte(b.String()))
+	}
+}
+
+// OtherLabel is the value a LabelGuard reports for label values beyond its limit.
+const OtherLabel = "__other__"
+
+// LabelGuard bounds the cardinality of a label. The first limit keys seen keep their own
+// value for as long as the process runs; keys first seen after that are reported as
+// OtherLabel, so existing series never move. A limit of zero or less disables the guard.
+type LabelGuard struct {
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func NewLabelGuard() *LabelGuard {
+	return &LabelGuard{seen: make(map[string]bool)}
+}
+
+// Value returns value, or OtherLabel when key is new and limit keys are already tracked.
+// Key identifies the series, e.g. server and tool name, and value is what is exported.
+func (g *LabelGuard) Value(limit int, key, value string) string {
+	if limit <= 0 {
+		return value
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.seen[key] {
+		return value
+	}
+	if len(g.seen) >= limit {
+		return OtherLabel
+	}
+	g.seen[key] = true
+	return value
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLabelGuard(t *testing.T) {
+	g := NewLabelGuard()
+	for _, tool := range []string{"get_order", "list_orders"} {
+		if got := g.Value(2, "orders\x00"+tool, tool); got != tool {
+			t.Fatalf("%s under the limit: got %s", tool, got)
+		}
+	}
+	// New keys beyond the limit collapse; keys already tracked keep their label
+	for _, tool := range []string{"cancel_order", "refund_order"} {
+		if got := g.Value(2, "orders\x00"+tool, tool); got != OtherLabel {
+			t.Fatalf("%s over the limit: got %s, want %s", tool, got, OtherLabel)
+		}
+	}
+	if got := g.Value(2, "orders\x00get_order", "get_order"); got != "get_order" {
+		t.Fatalf("tracked key: got %s", got)
+	}
+	// The key, not the exported value, identifies a series
+	if got := g.Value(2, "billing\x00get_order", "get_order"); got != OtherLabel {
+		t.Fatalf("same tool on another server: got %s", got)
+	}
+	if got := g.Value(0, "billing\x00get_order", "get_order"); got != "get_order" {
+		t.Fatalf("disabled guard: got %s", got)
+	}
+}
+
+func TestLabelGuardConcurrent(t *testing.T) {
+	g := NewLabelGuard()
+	const limit = 10
+	var mu sync.Mutex
+	kept := map[string]bool{}
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				key := fmt.Sprintf("tool-%d", (w*7+i)%40)
+				if v := g.Value(limit, key, key); v != OtherLabel {
+					mu.Lock()
+					kept[v] = true
+					mu.Unlock()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	if len(kept) != limit {
+		t.Fatalf("%d distinct labels kept, want %d", len(kept), limit)
+	}
+}
+
+func TestHandlerRendersCounters(t *testing.T) {
+	c := NewCounterVec("test_guarded_calls_total", "Calls in a test.", "tool")
+	c.Inc("get_order")
+	c.Inc(OtherLabel)
+	c.Inc(OtherLabel)
+	c.Inc(`say "hi"`)
+	rec := httptest.NewRecorder()
+	Handler()(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range []string{
+		"# TYPE test_guarded_calls_total counter",
+		`test_guarded_calls_total{tool="__other__"} 2`,
+		`test_guarded_calls_total{tool="get_order"} 1`,
+		`test_guarded_calls_total{tool="say \"hi\""} 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, rec.Body)
+		}
+	}
+}
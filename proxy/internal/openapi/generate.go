@@ -0,0 +1,331 @@
+package openapi
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gateway/proxy/internal/store"
+)
+
+// maxRefDepth bounds $ref inlining so recursive schemas terminate.
+const maxRefDepth = 8
+
+var (
+	pathParamRe   = regexp.MustCompile(`\{([^{}]+)\}`)
+	invalidNameRe = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+)
+
+// BaseURL suggests the upstream base URL declared by the spec: host, basePath and the
+// first scheme for Swagger 2.0, the first server (variables at their defaults) for 3.x.
+func (d *Document) BaseURL() string {
+	if d.Version == "2.0" {
+		host, _ := d.Raw["host"].(string)
+		if host == "" {
+			return ""
+		}
+		scheme := "https"
+		if schemes := listOf(d.Raw["schemes"]); len(schemes) > 0 {
+			if s, ok := schemes[0].(string); ok {
+				scheme = s
+			}
+		}
+		basePath, _ := d.Raw["basePath"].(string)
+		return scheme + "://" + host + strings.TrimRight(basePath, "/")
+	}
+	servers := listOf(d.Raw["servers"])
+	if len(servers) == 0 {
+		return ""
+	}
+	srv, _ := servers[0].(map[string]interface{})
+	u, _ := srv["url"].(string)
+	vars, _ := srv["variables"].(map[string]interface{})
+	for name, v := range vars {
+		vm, _ := v.(map[string]interface{})
+		u = strings.ReplaceAll(u, "{"+name+"}", fmt.Sprint(vm["default"]))
+	}
+	return strings.TrimRight(u, "/")
+}
+
+// GenerateTools maps every operation to a tool. Path, query and header parameters and
+// top-level properties of an object request body become arguments; Swagger 2.0 body and
+// formData parameters are normalized to the same shape as an OpenAPI 3 requestBody.
+func (d *Document) GenerateTools() []store.Tool {
+	tools := []store.Tool{}
+	used := map[string]int{}
+	for _, op := range d.Operations() {
+		name := toolName(op)
+		if used[name]++; used[name] > 1 {
+			name = fmt.Sprintf("%s_%d", name, used[name])
+		}
+		tools = append(tools, d.toolFor(op, name))
+	}
+	return tools
+}
+
+func (d *Document) toolFor(op Operation, name string) store.Tool {
+	summary, _ := op.item["summary"].(string)
+	description, _ := op.item["description"].(string)
+	props := map[string]interface{}{}
+	required := []interface{}{}
+	mapping := store.RequestTemplate{
+		Method: op.Method,
+		Path:   pathParamRe.ReplaceAllString(op.Path, "{{$1}}"),
+	}
+	addArg := func(argName string, schema map[string]interface{}, isRequired bool) bool {
+		if _, exists := props[argName]; exists {
+			return false
+		}
+		props[argName] = schema
+		if isRequired {
+			required = append(required, argName)
+		}
+		return true
+	}
+
+	var formParams []map[string]interface{}
+	for _, p := range d.parameters(op) {
+		pname, _ := p["name"].(string)
+		if pname == "" {
+			continue
+		}
+		isRequired, _ := p["required"].(bool)
+		switch p["in"] {
+		case "path":
+			addArg(pname, d.paramSchema(p), true)
+		case "query":
+			if addArg(pname, d.paramSchema(p), isRequired) {
+				if mapping.Query == nil {
+					mapping.Query = map[string]string{}
+				}
+				mapping.Query[pname] = "{{" + pname + "}}"
+			}
+		case "header":
+			if addArg(pname, d.paramSchema(p), isRequired) {
+				if mapping.Headers == nil {
+					mapping.Headers = map[string]string{}
+				}
+				mapping.Headers[pname] = "{{" + pname + "}}"
+			}
+		case "body":
+			// Swagger 2.0: the body parameter carries the schema directly
+			schema, _ := d.resolve(p["schema"], 0).(map[string]interface{})
+			d.addBody(&mapping, schema, "json", addArg)
+		case "formData":
+			formParams = append(formParams, p)
+		}
+	}
+	if len(formParams) > 0 {
+		encoding := "form"
+		for _, c := range d.consumes(op) {
+			if c == "multipart/form-data" {
+				encoding = "multipart"
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		req := []interface{}{}
+		for _, p := range formParams {
+			pname, _ := p["name"].(string)
+			schema["properties"].(map[string]interface{})[pname] = d.paramSchema(p)
+			if r, _ := p["required"].(bool); r {
+				req = append(req, pname)
+			}
+		}
+		schema["required"] = req
+		d.addBody(&mapping, schema, encoding, addArg)
+	}
+	if rb, ok := d.resolve(op.item["requestBody"], 0).(map[string]interface{}); ok {
+		content, _ := rb["content"].(map[string]interface{})
+		if enc := bodyEncodingFor(content); enc != "" {
+			var mt map[string]interface{}
+			switch enc {
+			case "form":
+				mt, _ = content["application/x-www-form-urlencoded"].(map[string]interface{})
+			case "multipart":
+				mt, _ = content["multipart/form-data"].(map[string]interface{})
+			default:
+				mt, _ = content["application/json"].(map[string]interface{})
+			}
+			schema, _ := d.resolve(mt["schema"], 0).(map[string]interface{})
+			d.addBody(&mapping, schema, enc, addArg)
+		}
+	}
+
+	return store.Tool{
+		Name:           name,
+		Title:          summary,
+		Description:    firstNonEmpty(description, summary, op.Method+" "+op.Path),
+		RequiredScopes: []string{},
+		Mapping:        mapping,
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": props,
+			"required":   required,
+		},
+	}
+}
+
+// addBody maps the top-level properties of an object body schema to arguments and
+// templates them into the request body.
+func (d *Document) addBody(mapping *store.RequestTemplate, schema map[string]interface{}, encoding string, addArg func(string, map[string]interface{}, bool) bool) {
+	bodyProps, _ := schema["properties"].(map[string]interface{})
+	if len(bodyProps) == 0 {
+		return
+	}
+	req := map[string]bool{}
+	for _, r := range listOf(schema["required"]) {
+		if s, ok := r.(string); ok {
+			req[s] = true
+		}
+	}
+	if mapping.Body == nil {
+		mapping.Body = map[string]interface{}{}
+	}
+	for pname, ps := range bodyProps {
+		pschema, _ := ps.(map[string]interface{})
+		if addArg(pname, pschema, req[pname]) {
+			mapping.Body[pname] = "{{" + pname + "}}"
+		}
+	}
+	if encoding != "json" {
+		mapping.BodyEncoding = encoding
+	}
+}
+
+// parameters merges path-level and operation-level parameters; the operation wins on
+// name+location clashes.
+func (d *Document) parameters(op Operation) []map[string]interface{} {
+	out := []map[string]interface{}{}
+	index := map[string]int{}
+	for _, raw := range append(listOf(op.pathItem["parameters"]), listOf(op.item["parameters"])...) {
+		p, ok := d.resolve(raw, 0).(map[string]interface{})
+		if !ok {
+			continue
+		}
+		key := fmt.Sprint(p["in"], ":", p["name"])
+		if i, seen := index[key]; seen {
+			out[i] = p
+			continue
+		}
+		index[key] = len(out)
+		out = append(out, p)
+	}
+	return out
+}
+
+// paramSchema returns a parameter's JSON schema: the schema object in 3.x, or the
+// inline type/format/enum/items fields in Swagger 2.0.
+func (d *Document) paramSchema(p map[string]interface{}) map[string]interface{} {
+	var schema map[string]interface{}
+	if s, ok := d.resolve(p["schema"], 0).(map[string]interface{}); ok {
+		schema = copyMap(s)
+	} else {
+		schema = map[string]interface{}{}
+		for _, k := range []string{"type", "format", "enum", "items", "default", "minimum", "maximum", "pattern"} {
+			if v, ok := p[k]; ok {
+				schema[k] = d.resolve(v, 0)
+			}
+		}
+		if schema["type"] == "file" {
+			schema["type"] = "string"
+			schema["format"] = "binary"
+		}
+	}
+	if desc, ok := p["description"].(string); ok && schema["description"] == nil {
+		schema["description"] = desc
+	}
+	if len(schema) == 0 {
+		schema["type"] = "string"
+	}
+	return schema
+}
+
+// consumes returns the operation's (or document's) Swagger 2.0 consumes list.
+func (d *Document) consumes(op Operation) []string {
+	list := listOf(op.item["consumes"])
+	if list == nil {
+		list = listOf(d.Raw["consumes"])
+	}
+	out := []string{}
+	for _, c := range list {
+		if s, ok := c.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// resolve inlines local $refs ("#/definitions/..." in 2.0, "#/components/..." in 3.x).
+// Unresolvable and external refs are left as-is.
+func (d *Document) resolve(v interface{}, depth int) interface{} {
+	if depth > maxRefDepth {
+		return map[string]interface{}{}
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := t["$ref"].(string); ok && strings.HasPrefix(ref, "#/") {
+			if target, ok := d.pointer(ref); ok {
+				return d.resolve(target, depth+1)
+			}
+			return t
+		}
+		out := make(map[string]interface{}, len(t))
+		for k, x := range t {
+			out[k] = d.resolve(x, depth)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, x := range t {
+			out[i] = d.resolve(x, depth)
+		}
+		return out
+	}
+	return v
+}
+
+// pointer follows a local JSON pointer such as "#/components/schemas/Pet".
+func (d *Document) pointer(ref string) (interface{}, bool) {
+	var cur interface{} = d.Raw
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// toolName derives a valid tool name from the operationId, or method and path.
+func toolName(op Operation) string {
+	base := op.OperationID
+	if base == "" {
+		base = strings.ToLower(op.Method) + op.Path
+	}
+	name := strings.Trim(invalidNameRe.ReplaceAllString(base, "_"), "_")
+	if name == "" {
+		name = strings.ToLower(op.Method)
+	}
+	return name
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
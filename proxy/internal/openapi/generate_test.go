@@ -0,0 +1,200 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+
+	"gateway/proxy/internal/store"
+)
+
+const petstoreSwagger = `
+swagger: "2.0"
+info: {title: Petstore, version: "1"}
+host: pets.example.com
+basePath: /v1/
+schemes: [http, https]
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      parameters:
+        - {name: limit, in: query, type: integer}
+    post:
+      operationId: createPet
+      parameters:
+        - name: pet
+          in: body
+          schema: {$ref: "#/definitions/NewPet"}
+  /pets/{petId}:
+    parameters:
+      - {name: petId, in: path, required: true, type: string}
+    get:
+      operationId: showPet
+      parameters:
+        - {name: X-Request-Id, in: header, type: string}
+  /pets/{petId}/photo:
+    post:
+      operationId: uploadPhoto
+      consumes: [multipart/form-data]
+      parameters:
+        - {name: petId, in: path, required: true, type: string}
+        - {name: caption, in: formData, required: true, type: string}
+definitions:
+  NewPet:
+    type: object
+    required: [name]
+    properties:
+      name: {type: string}
+      tag: {type: string}
+`
+
+const petstoreOpenAPI = `
+openapi: 3.0.3
+info: {title: Petstore, version: "1"}
+servers:
+  - url: "{scheme}://pets.example.com/v1/"
+    variables:
+      scheme: {default: http}
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      parameters:
+        - {name: limit, in: query, schema: {type: integer}}
+    post:
+      operationId: createPet
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/NewPet"}
+  /pets/{petId}:
+    parameters:
+      - {name: petId, in: path, required: true, schema: {type: string}}
+    get:
+      operationId: showPet
+      parameters:
+        - {name: X-Request-Id, in: header, schema: {type: string}}
+  /pets/{petId}/photo:
+    post:
+      operationId: uploadPhoto
+      parameters:
+        - {name: petId, in: path, required: true, schema: {type: string}}
+      requestBody:
+        content:
+          multipart/form-data:
+            schema:
+              type: object
+              required: [caption]
+              properties:
+                caption: {type: string}
+components:
+  schemas:
+    NewPet:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string}
+        tag: {type: string}
+`
+
+func generate(t *testing.T, spec string) (*Document, map[string]store.Tool) {
+	t.Helper()
+	doc, err := Parse([]byte(spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tools := map[string]store.Tool{}
+	for _, tool := range doc.GenerateTools() {
+		tools[tool.Name] = tool
+	}
+	return doc, tools
+}
+
+func TestGenerateSwagger2Petstore(t *testing.T) {
+	doc, tools := generate(t, petstoreSwagger)
+	if doc.Version != "2.0" || doc.BaseURL() != "http://pets.example.com/v1" {
+		t.Fatalf("version %s, base URL %q", doc.Version, doc.BaseURL())
+	}
+	list := tools["listPets"]
+	if list.Mapping.Method != "GET" || list.Mapping.Path != "/pets" || list.Mapping.Query["limit"] != "{{limit}}" {
+		t.Fatalf("listPets mapping %+v", list.Mapping)
+	}
+	show := tools["showPet"]
+	if show.Mapping.Path != "/pets/{{petId}}" || show.Mapping.Headers["X-Request-Id"] != "{{X-Request-Id}}" {
+		t.Fatalf("showPet mapping %+v", show.Mapping)
+	}
+	if !reflect.DeepEqual(show.InputSchema["required"], []interface{}{"petId"}) {
+		t.Fatalf("showPet required %v", show.InputSchema["required"])
+	}
+	create := tools["createPet"]
+	if create.Mapping.Method != "POST" || create.Mapping.BodyEncoding != "" || !reflect.DeepEqual(create.Mapping.Body, map[string]interface{}{"name": "{{name}}", "tag": "{{tag}}"}) {
+		t.Fatalf("createPet mapping %+v", create.Mapping)
+	}
+	if !reflect.DeepEqual(create.InputSchema["required"], []interface{}{"name"}) {
+		t.Fatalf("createPet required %v", create.InputSchema["required"])
+	}
+	upload := tools["uploadPhoto"]
+	if upload.Mapping.BodyEncoding != "multipart" || upload.Mapping.Body["caption"] != "{{caption}}" {
+		t.Fatalf("uploadPhoto mapping %+v", upload.Mapping)
+	}
+}
+
+func TestGenerateSwagger2MatchesOpenAPI3(t *testing.T) {
+	v2, tools2 := generate(t, petstoreSwagger)
+	v3, tools3 := generate(t, petstoreOpenAPI)
+	if v2.BaseURL() != v3.BaseURL() {
+		t.Fatalf("base URLs differ: %q vs %q", v2.BaseURL(), v3.BaseURL())
+	}
+	if len(tools2) != 4 || len(tools2) != len(tools3) {
+		t.Fatalf("generated %d and %d tools", len(tools2), len(tools3))
+	}
+	for name, tool2 := range tools2 {
+		tool3, ok := tools3[name]
+		if !ok {
+			t.Fatalf("%s missing from the OpenAPI 3 tools", name)
+		}
+		if !reflect.DeepEqual(tool2.Mapping, tool3.Mapping) {
+			t.Errorf("%s mapping differs:\n2.0: %+v\n3.0: %+v", name, tool2.Mapping, tool3.Mapping)
+		}
+		if !reflect.DeepEqual(tool2.InputSchema, tool3.InputSchema) {
+			t.Errorf("%s input schema differs:\n2.0: %v\n3.0: %v", name, tool2.InputSchema, tool3.InputSchema)
+		}
+	}
+}
+
+func TestGenerateFormDataAndNames(t *testing.T) {
+	spec := `
+swagger: "2.0"
+paths:
+  /login:
+    post:
+      parameters:
+        - {name: user, in: formData, type: string, required: true}
+  /v1/login:
+    post:
+      parameters:
+        - {name: user, in: formData, type: string}
+`
+	doc, err := Parse([]byte(spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tools := doc.GenerateTools()
+	if len(tools) != 2 {
+		t.Fatalf("generated %d tools", len(tools))
+	}
+	for _, tool := range tools {
+		if tool.Mapping.BodyEncoding != "form" || tool.Mapping.Body["user"] != "{{user}}" {
+			t.Errorf("%s mapping %+v", tool.Name, tool.Mapping)
+		}
+		if invalidNameRe.MatchString(tool.Name) {
+			t.Errorf("tool name %q has invalid characters", tool.Name)
+		}
+	}
+	if tools[0].Name == tools[1].Name {
+		t.Fatalf("duplicate tool names %q", tools[0].Name)
+	}
+	if doc.BaseURL() != "" {
+		t.Fatalf("base URL %q without host", doc.BaseURL())
+	}
+}
@@ -0,0 +1,224 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a parsed Swagger 2.0 or OpenAPI 3.x spec kept as generic JSON values.
+type Document struct {
+	// Version is "2.0", "3.0" or "3.1"
+	Version string
+	Raw     map[string]interface{}
+}
+
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Parse decodes a JSON or YAML spec and detects its version.
+func Parse(data []byte) (*Document, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("parse: %w", err)
+	}
+	raw, ok := normalize(v).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("document is not an object")
+	}
+	doc := &Document{Raw: raw}
+	switch {
+	case raw["swagger"] != nil:
+		if fmt.Sprint(raw["swagger"]) != "2.0" {
+			return nil, fmt.Errorf("unsupported swagger version %v", raw["swagger"])
+		}
+		doc.Version = "2.0"
+	case raw["openapi"] != nil:
+		ver := fmt.Sprint(raw["openapi"])
+		switch {
+		case strings.HasPrefix(ver, "3.0"):
+			doc.Version = "3.0"
+		case strings.HasPrefix(ver, "3.1"):
+			doc.Version = "3.1"
+		default:
+			return nil, fmt.Errorf("unsupported openapi version %s", ver)
+		}
+	default:
+		return nil, errors.New("missing swagger or openapi version field")
+	}
+	if _, ok := raw["paths"].(map[string]interface{}); !ok {
+		return nil, errors.New("missing paths object")
+	}
+	return doc, nil
+}
+
+// Operation is one path+method pair of the spec.
+type Operation struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	OperationID string `json:"operationId,omitempty"`
+	// item is the operation object; pathItem holds path-level parameters
+	item     map[string]interface{}
+	pathItem map[string]interface{}
+}
+
+// Operations lists the spec's operations sorted by path, then method.
+func (d *Document) Operations() []Operation {
+	paths, _ := d.Raw["paths"].(map[string]interface{})
+	keys := make([]string, 0, len(paths))
+	for p := range paths {
+		keys = append(keys, p)
+	}
+	sort.Strings(keys)
+	ops := []Operation{}
+	for _, p := range keys {
+		pi, _ := paths[p].(map[string]interface{})
+		for _, m := range httpMethods {
+			item, ok := pi[m].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _ := item["operationId"].(string)
+			ops = append(ops, Operation{Method: strings.ToUpper(m), Path: p, OperationID: id, item: item, pathItem: pi})
+		}
+	}
+	return ops
+}
+
+// Report summarizes whether a spec can be turned into tools.
+type Report struct {
+	Valid               bool        `json:"valid"`
+	Version             string      `json:"version,omitempty"`
+	Title               string      `json:"title,omitempty"`
+	OperationCount      int         `json:"operationCount"`
+	Operations          []Operation `json:"operations"`
+	MissingOperationIDs []string    `json:"missingOperationIds"`
+	Unsupported         []string    `json:"unsupported"`
+	Errors              []string    `json:"errors,omitempty"`
+}
+
+// Validate parses data and reports its operations and the features tool generation
+// cannot handle. Parse failures are reported rather than returned.
+func Validate(data []byte) Report {
+	rep := Report{Operations: []Operation{}, MissingOperationIDs: []string{}, Unsupported: []string{}}
+	doc, err := Parse(data)
+	if err != nil {
+		rep.Errors = []string{err.Error()}
+		return rep
+	}
+	rep.Valid = true
+	rep.Version = doc.Version
+	if info, ok := doc.Raw["info"].(map[string]interface{}); ok {
+		rep.Title, _ = info["title"].(string)
+	}
+	rep.Operations = doc.Operations()
+	rep.OperationCount = len(rep.Operations)
+	for _, op := range rep.Operations {
+		if op.OperationID == "" {
+			rep.MissingOperationIDs = append(rep.MissingOperationIDs, op.Method+" "+op.Path)
+		}
+	}
+	rep.Unsupported = unsupportedFeatures(doc)
+	return rep
+}
+
+// unsupportedFeatures lists constructs that the generator ignores or cannot map.
+func unsupportedFeatures(doc *Document) []string {
+	found := map[string]bool{}
+	if _, ok := doc.Raw["webhooks"]; ok {
+		found["webhooks"] = true
+	}
+	walk(doc.Raw, func(key string, v interface{}) {
+		if key == "$ref" {
+			if ref, ok := v.(string); ok && !strings.HasPrefix(ref, "#") {
+				found["external $ref: "+ref] = true
+			}
+		}
+	})
+	for _, op := range doc.Operations() {
+		where := op.Method + " " + op.Path
+		if _, ok := op.item["callbacks"]; ok {
+			found["callbacks ("+where+")"] = true
+		}
+		for _, p := range append(listOf(op.pathItem["parameters"]), listOf(op.item["parameters"])...) {
+			pm, _ := p.(map[string]interface{})
+			if pm["in"] == "cookie" {
+				found["cookie parameters ("+where+")"] = true
+			}
+		}
+		if rb, ok := op.item["requestBody"].(map[string]interface{}); ok {
+			content, _ := rb["content"].(map[string]interface{})
+			if len(content) > 0 && bodyEncodingFor(content) == "" {
+				found["request body media type ("+where+")"] = true
+			}
+		}
+	}
+	out := make([]string, 0, len(found))
+	for k := range found {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// bodyEncodingFor picks the store body encoding for the first supported media type.
+func bodyEncodingFor(content map[string]interface{}) string {
+	for _, mt := range []string{"application/json", "application/x-www-form-urlencoded", "multipart/form-data"} {
+		if _, ok := content[mt]; ok {
+			switch mt {
+			case "application/x-www-form-urlencoded":
+				return "form"
+			case "multipart/form-data":
+				return "multipart"
+			}
+			return "json"
+		}
+	}
+	return ""
+}
+
+func listOf(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+// walk calls fn for every key/value pair in nested objects.
+func walk(v interface{}, fn func(key string, v interface{})) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, x := range t {
+			fn(k, x)
+			walk(x, fn)
+		}
+	case []interface{}:
+		for _, x := range t {
+			walk(x, fn)
+		}
+	}
+}
+
+// normalize converts YAML's map[interface{}]interface{} (e.g. from numeric response
+// codes) into JSON-compatible map[string]interface{}.
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, x := range t {
+			t[k] = normalize(x)
+		}
+		return t
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, x := range t {
+			m[fmt.Sprint(k)] = normalize(x)
+		}
+		return m
+	case []interface{}:
+		for i, x := range t {
+			t[i] = normalize(x)
+		}
+		return t
+	}
+	return v
+}
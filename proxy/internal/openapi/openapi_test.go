@@ -0,0 +1,115 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const petstore = `{
+  "openapi": "3.0.3",
+  "info": {"title": "Petstore", "version": "1"},
+  "servers": [{"url": "https://pets.example.com/v1"}],
+  "paths": {
+    "/pets": {
+      "get": {"operationId": "listPets", "parameters": [{"name": "limit", "in": "query", "schema": {"type": "integer"}}]},
+      "post": {"operationId": "createPet", "requestBody": {"content": {"application/json": {"schema": {"type": "object"}}}}}
+    },
+    "/pets/{petId}": {
+      "parameters": [{"name": "petId", "in": "path", "required": true, "schema": {"type": "string"}}],
+      "get": {"operationId": "showPet"}
+    }
+  }
+}`
+
+func TestValidateValidSpec(t *testing.T) {
+	rep := Validate([]byte(petstore))
+	if !rep.Valid || rep.Version != "3.0" || rep.Title != "Petstore" || len(rep.Errors) != 0 {
+		t.Fatalf("report = %+v", rep)
+	}
+	if rep.OperationCount != 3 || len(rep.MissingOperationIDs) != 0 || len(rep.Unsupported) != 0 {
+		t.Fatalf("report = %+v", rep)
+	}
+	var got []string
+	for _, op := range rep.Operations {
+		got = append(got, op.Method+" "+op.Path+" "+op.OperationID)
+	}
+	want := []string{"GET /pets listPets", "POST /pets createPet", "GET /pets/{petId} showPet"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("operations %v, want %v", got, want)
+	}
+}
+
+func TestValidateVersions(t *testing.T) {
+	cases := map[string]string{
+		"swagger: \"2.0\"\ninfo: {title: t}\npaths: {}\n": "2.0",
+		"openapi: 3.0.0\npaths: {}\n":                     "3.0",
+		"openapi: 3.1.0\npaths:\n  /a:\n    get: {}\n":    "3.1",
+	}
+	for spec, want := range cases {
+		if rep := Validate([]byte(spec)); !rep.Valid || rep.Version != want {
+			t.Errorf("%q: report %+v, want version %s", spec, rep, want)
+		}
+	}
+}
+
+func TestValidateMissingOperationIDs(t *testing.T) {
+	spec := strings.NewReplacer(`"operationId": "listPets", `, ``, `"get": {"operationId": "showPet"}`, `"get": {}`).Replace(petstore)
+	rep := Validate([]byte(spec))
+	if !rep.Valid || rep.OperationCount != 3 {
+		t.Fatalf("report = %+v", rep)
+	}
+	if want := []string{"GET /pets", "GET /pets/{petId}"}; !reflect.DeepEqual(rep.MissingOperationIDs, want) {
+		t.Fatalf("missing %v, want %v", rep.MissingOperationIDs, want)
+	}
+}
+
+func TestValidateInvalidDocuments(t *testing.T) {
+	cases := map[string]string{
+		"not yaml":      "{{{",
+		"not an object": "[1, 2]",
+		"no version":    `{"paths": {}}`,
+		"swagger 1.2":   `{"swagger": "1.2", "paths": {}}`,
+		"openapi 4":     `{"openapi": "4.0.0", "paths": {}}`,
+		"no paths":      `{"openapi": "3.0.0"}`,
+	}
+	for name, spec := range cases {
+		rep := Validate([]byte(spec))
+		if rep.Valid || len(rep.Errors) == 0 || rep.OperationCount != 0 {
+			t.Errorf("%s: report %+v", name, rep)
+		}
+	}
+}
+
+func TestValidateUnsupportedFeatures(t *testing.T) {
+	spec := `
+openapi: 3.1.0
+webhooks:
+  newPet: {}
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      parameters:
+        - {name: session, in: cookie}
+        - $ref: "common.yaml#/parameters/limit"
+      callbacks:
+        onDone: {}
+    put:
+      operationId: uploadPets
+      requestBody:
+        content:
+          text/csv: {}
+`
+	rep := Validate([]byte(spec))
+	want := []string{
+		"callbacks (GET /pets)",
+		"cookie parameters (GET /pets)",
+		"external $ref: common.yaml#/parameters/limit",
+		"request body media type (PUT /pets)",
+		"webhooks",
+	}
+	if !rep.Valid || !reflect.DeepEqual(rep.Unsupported, want) {
+		t.Fatalf("unsupported %v, want %v", rep.Unsupported, want)
+	}
+}
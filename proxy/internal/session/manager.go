@@ -4,36 +4,188 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"sort"
 	"sync"
 	"time"
+
+	"gateway/proxy/internal/metrics"
+)
+
+var (
+	sessionsActive     = metrics.NewGaugeVec("mcp_sessions_active", "Live MCP sessions.")
+	sessionsCreated    = metrics.NewCounterVec("mcp_sessions_created_total", "MCP sessions created by initialize.")
+	sessionsExpired    = metrics.NewCounterVec("mcp_sessions_expired_total", "MCP sessions removed after their idle TTL.")
+	sessionsTerminated = metrics.NewCounterVec("mcp_sessions_terminated_total", "MCP sessions terminated by the client, an operator or tenant limit eviction.")
 )
 
 type Session struct {
-	ID           string
-	ServerSlug   string
-	TenantSlug   string
-	CreatedAt    time.Time
-	LastAccessed time.Time
-	Claims       map[string]interface{}
+	ID         string
+	ServerSlug string
+	TenantSlug string
+	// ProtocolVersion is the MCP version negotiated in initialize
+	ProtocolVersion string
+	CreatedAt       time.Time
+	LastAccessed    time.Time
+	Claims          map[string]interface{}
+	// Locale is the client's preferred language tag from initialize, if any
+	Locale string
+	// tools/call invocations waiting on elicited arguments, keyed by elicitation id
+	pending map[string]PendingCall
 }
 
+// pendingCallTTL is how long a parked tools/call waits for the client to supply the
+// missing arguments.
+const pendingCallTTL = 10 * time.Minute
+
+// PendingCall is a tools/call parked until the client supplies missing arguments. A
+// session holds at most one per tool.
+type PendingCall struct {
+	ToolName  string
+	Arguments map[string]interface{}
+	CreatedAt time.Time
+}
+
+// LimitPolicy decides what NewSession does when a tenant is at its session cap.
+type LimitPolicy string
+
+const (
+	// LimitReject refuses the new session
+	LimitReject LimitPolicy = "reject"
+	// LimitEvictLRU drops the tenant's least recently accessed session to make room
+	LimitEvictLRU LimitPolicy = "evict"
+)
+
+// ErrSessionLimit is returned by NewSession when the tenant cap is reached under LimitReject.
+var ErrSessionLimit = errors.New("session limit reached for tenant")
+
 type Manager struct {
 	mu       sync.RWMutex
 	sessions map[string]*Session
 	ttl      time.Duration
+	// per-tenant index so cap checks and eviction only touch that tenant's sessions
+	byTenant     map[string]map[string]*Session
+	maxPerTenant int
+	policy       LimitPolicy
+
+	stop chan struct{}
+	once sync.Once
 }
 
 func NewManager(ttl time.Duration) *Manager {
-	return &Manager{sessions: make(map[string]*Session), ttl: ttl}
+	return &Manager{sessions: make(map[string]*Session), ttl: ttl, byTenant: make(map[string]map[string]*Session), policy: LimitReject, stop: make(chan struct{})}
+}
+
+// StartSweeper removes expired sessions every interval until Close is called, so
+// sessions that are never used again do not linger.
+func (m *Manager) StartSweeper(interval time.Duration) {
+	if m.ttl <= 0 || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case now := <-ticker.C:
+				m.Sweep(now)
+			}
+		}
+	}()
+}
+
+// Close stops the sweeper.
+func (m *Manager) Close() {
+	m.once.Do(func() { close(m.stop) })
 }
 
-func (m *Manager) NewSession(serverSlug, tenantSlug string, claims map[string]interface{}) *Session {
+// Sweep removes sessions idle for longer than the TTL as of now and returns how many.
+func (m *Manager) Sweep(now time.Time) int {
+	if m.ttl <= 0 {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	removed := 0
+	for id, s := range m.sessions {
+		if now.Sub(s.LastAccessed) > m.ttl && m.deleteLocked(id) {
+			sessionsExpired.Inc()
+			removed++
+			continue
+		}
+		for eid, call := range s.pending {
+			if now.Sub(call.CreatedAt) > pendingCallTTL {
+				delete(s.pending, eid)
+			}
+		}
+	}
+	return removed
+}
+
+// SetTenantLimit caps concurrent sessions per tenant; max <= 0 means unlimited.
+func (m *Manager) SetTenantLimit(max int, policy LimitPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxPerTenant = max
+	m.policy = policy
+}
+
+func (m *Manager) NewSession(serverSlug, tenantSlug, protocolVersion, locale string, claims map[string]interface{}) (*Session, error) {
 	id := generateSessionID()
-	s := &Session{ID: id, ServerSlug: serverSlug, TenantSlug: tenantSlug, CreatedAt: time.Now(), LastAccessed: time.Now(), Claims: claims}
+	s := &Session{ID: id, ServerSlug: serverSlug, TenantSlug: tenantSlug, ProtocolVersion: protocolVersion, Locale: locale, CreatedAt: time.Now(), LastAccessed: time.Now(), Claims: claims}
 	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.maxPerTenant > 0 {
+		for len(m.byTenant[tenantSlug]) >= m.maxPerTenant {
+			if m.policy != LimitEvictLRU {
+				return nil, ErrSessionLimit
+			}
+			if m.deleteLocked(m.oldestLocked(tenantSlug)) {
+				sessionsTerminated.Inc()
+			}
+		}
+	}
 	m.sessions[id] = s
-	m.mu.Unlock()
-	return s
+	sessionsCreated.Inc()
+	sessionsActive.Set(float64(len(m.sessions)))
+	if m.byTenant[tenantSlug] == nil {
+		m.byTenant[tenantSlug] = make(map[string]*Session)
+	}
+	m.byTenant[tenantSlug][id] = s
+	return s, nil
+}
+
+// oldestLocked returns the id of the tenant's least recently accessed session.
+func (m *Manager) oldestLocked(tenantSlug string) string {
+	var oldest *Session
+	for _, s := range m.byTenant[tenantSlug] {
+		if oldest == nil || s.LastAccessed.Before(oldest.LastAccessed) {
+			oldest = s
+		}
+	}
+	if oldest == nil {
+		return ""
+	}
+	return oldest.ID
+}
+
+// deleteLocked removes a session and reports whether it existed. The active gauge is
+// set under the lock, so it always matches the map.
+func (m *Manager) deleteLocked(id string) bool {
+	s, ok := m.sessions[id]
+	if !ok {
+		return false
+	}
+	delete(m.sessions, id)
+	if ts := m.byTenant[s.TenantSlug]; ts != nil {
+		delete(ts, id)
+		if len(ts) == 0 {
+			delete(m.byTenant, s.TenantSlug)
+		}
+	}
+	sessionsActive.Set(float64(len(m.sessions)))
+	return true
 }
 
 func (m *Manager) Get(id string) (*Session, error) {
@@ -43,22 +195,101 @@ func (m *Manager) Get(id string) (*Session, error) {
 	if !ok {
 		return nil, errors.New("session not found")
 	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.ttl > 0 && time.Since(s.LastAccessed) > m.ttl {
-		m.Delete(id)
+		if m.deleteLocked(id) {
+			sessionsExpired.Inc()
+		}
 		return nil, errors.New("session expired")
 	}
-	m.mu.Lock()
 	s.LastAccessed = time.Now()
-	m.mu.Unlock()
 	return s, nil
 }
 
+// Delete terminates a session; unknown ids are ignored.
 func (m *Manager) Delete(id string) {
 	m.mu.Lock()
-	delete(m.sessions, id)
+	if m.deleteLocked(id) {
+		sessionsTerminated.Inc()
+	}
 	m.mu.Unlock()
 }
 
+// Info is the operator-visible view of a session; claims are deliberately omitted.
+type Info struct {
+	ID              string    `json:"id"`
+	ServerSlug      string    `json:"server"`
+	TenantSlug      string    `json:"tenant"`
+	Subject         string    `json:"subject,omitempty"`
+	ProtocolVersion string    `json:"protocolVersion,omitempty"`
+	CreatedAt       time.Time `json:"createdAt"`
+	LastAccessed    time.Time `json:"lastAccessed"`
+}
+
+// List returns live sessions, optionally filtered by tenant and/or server, oldest first.
+// Expired sessions that have not been reaped yet are skipped.
+func (m *Manager) List(tenantSlug, serverSlug string) []Info {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := []Info{}
+	for _, s := range m.sessions {
+		if (tenantSlug != "" && s.TenantSlug != tenantSlug) || (serverSlug != "" && s.ServerSlug != serverSlug) {
+			continue
+		}
+		if m.ttl > 0 && time.Since(s.LastAccessed) > m.ttl {
+			continue
+		}
+		sub, _ := s.Claims["sub"].(string)
+		out = append(out, Info{ID: s.ID, ServerSlug: s.ServerSlug, TenantSlug: s.TenantSlug, Subject: sub, ProtocolVersion: s.ProtocolVersion, CreatedAt: s.CreatedAt, LastAccessed: s.LastAccessed})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// AddPendingCall parks a tools/call on the session and returns its elicitation id. It
+// replaces a call already parked for the same tool, whose id stops working.
+func (m *Manager) AddPendingCall(sessionID string, call PendingCall) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[sessionID]
+	if !ok {
+		return "", errors.New("session not found")
+	}
+	if s.pending == nil {
+		s.pending = make(map[string]PendingCall)
+	}
+	for eid, parked := range s.pending {
+		if parked.ToolName == call.ToolName {
+			delete(s.pending, eid)
+		}
+	}
+	id := generateSessionID()
+	call.CreatedAt = time.Now()
+	s.pending[id] = call
+	return id, nil
+}
+
+// TakePendingCall removes and returns a parked call; each elicitation id is single-use.
+// Calls parked for longer than pendingCallTTL are not returned.
+func (m *Manager) TakePendingCall(sessionID, elicitationID string) (PendingCall, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[sessionID]
+	if !ok || s.pending == nil {
+		return PendingCall{}, false
+	}
+	call, ok := s.pending[elicitationID]
+	if !ok {
+		return PendingCall{}, false
+	}
+	delete(s.pending, elicitationID)
+	if time.Since(call.CreatedAt) > pendingCallTTL {
+		return PendingCall{}, false
+	}
+	return call, true
+}
+
 func generateSessionID() string {
 	var b [16]byte
 	_, _ = rand.Read(b[:])
@@ -0,0 +1,124 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newSession(t *testing.T, m *Manager, tenant string) *Session {
+	t.Helper()
+	s, err := m.NewSession("orders", tenant, "2025-06-18", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestTenantLimitRejects(t *testing.T) {
+	m := NewManager(time.Hour)
+	m.SetTenantLimit(2, LimitReject)
+	first := newSession(t, m, "acme")
+	newSession(t, m, "acme")
+
+	if _, err := m.NewSession("orders", "acme", "2025-06-18", "", nil); !errors.Is(err, ErrSessionLimit) {
+		t.Fatalf("err = %v, want ErrSessionLimit", err)
+	}
+	// Other tenants have their own budget
+	newSession(t, m, "globex")
+
+	// Deleting a session frees capacity
+	m.Delete(first.ID)
+	newSession(t, m, "acme")
+	if _, err := m.NewSession("orders", "acme", "2025-06-18", "", nil); !errors.Is(err, ErrSessionLimit) {
+		t.Fatalf("after refill err = %v, want ErrSessionLimit", err)
+	}
+}
+
+func TestTenantLimitEvictsLeastRecentlyAccessed(t *testing.T) {
+	m := NewManager(time.Hour)
+	m.SetTenantLimit(2, LimitEvictLRU)
+	older := newSession(t, m, "acme")
+	newer := newSession(t, m, "acme")
+	other := newSession(t, m, "globex")
+	older.LastAccessed = time.Now().Add(-2 * time.Minute)
+	newer.LastAccessed = time.Now().Add(-time.Minute)
+	// Using the older session makes the newer one the least recently accessed
+	if _, err := m.Get(older.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	added := newSession(t, m, "acme")
+	if _, err := m.Get(newer.ID); err == nil {
+		t.Fatal("least recently accessed session survived eviction")
+	}
+	for _, s := range []*Session{older, added, other} {
+		if _, err := m.Get(s.ID); err != nil {
+			t.Fatalf("session %s: %v", s.ID, err)
+		}
+	}
+	if n := len(m.List("acme", "")); n != 2 {
+		t.Fatalf("acme has %d sessions, want 2", n)
+	}
+}
+
+func TestNoTenantLimitByDefault(t *testing.T) {
+	m := NewManager(time.Hour)
+	for i := 0; i < 50; i++ {
+		newSession(t, m, "acme")
+	}
+	if n := len(m.List("acme", "")); n != 50 {
+		t.Fatalf("%d sessions, want 50", n)
+	}
+}
+
+func TestExpiredSessionsFreeCapacity(t *testing.T) {
+	m := NewManager(time.Minute)
+	m.SetTenantLimit(1, LimitReject)
+	s := newSession(t, m, "acme")
+	s.LastAccessed = time.Now().Add(-2 * time.Minute)
+	if n := m.Sweep(time.Now()); n != 1 {
+		t.Fatalf("swept %d, want 1", n)
+	}
+	newSession(t, m, "acme")
+}
+
+func TestPendingCallOnePerTool(t *testing.T) {
+	m := NewManager(time.Hour)
+	s := newSession(t, m, "acme")
+	first, err := m.AddPendingCall(s.ID, PendingCall{ToolName: "create_order", Arguments: map[string]interface{}{"sku": "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := m.AddPendingCall(s.ID, PendingCall{ToolName: "cancel_order"})
+	second, _ := m.AddPendingCall(s.ID, PendingCall{ToolName: "create_order", Arguments: map[string]interface{}{"sku": "b"}})
+
+	if _, ok := m.TakePendingCall(s.ID, first); ok {
+		t.Fatal("replaced call still taken")
+	}
+	if call, ok := m.TakePendingCall(s.ID, second); !ok || call.Arguments["sku"] != "b" {
+		t.Fatalf("latest call %+v, ok %v", call, ok)
+	}
+	if _, ok := m.TakePendingCall(s.ID, other); !ok {
+		t.Fatal("another tool's call was dropped")
+	}
+}
+
+func TestPendingCallExpires(t *testing.T) {
+	m := NewManager(time.Hour)
+	s := newSession(t, m, "acme")
+	stale, _ := m.AddPendingCall(s.ID, PendingCall{ToolName: "create_order"})
+	swept, _ := m.AddPendingCall(s.ID, PendingCall{ToolName: "cancel_order"})
+	for id, call := range s.pending {
+		call.CreatedAt = time.Now().Add(-pendingCallTTL - time.Second)
+		s.pending[id] = call
+	}
+
+	if _, ok := m.TakePendingCall(s.ID, stale); ok {
+		t.Fatal("expired call taken")
+	}
+	m.Sweep(time.Now())
+	if _, ok := s.pending[swept]; ok {
+		t.Fatal("sweep kept an expired call")
+	}
+}
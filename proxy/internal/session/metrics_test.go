@@ -0,0 +1,120 @@
+package session
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestActiveGaugeTracksSessions(t *testing.T) {
+	m := NewManager(time.Hour)
+	created, terminated := sessionsCreated.Value(), sessionsTerminated.Value()
+
+	const n = 50
+	ids := make(chan string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := m.NewSession("orders", "acme", "2025-06-18", "", nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ids <- s.ID
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	if got := sessionsActive.Value(); got != n {
+		t.Fatalf("active = %v after %d creates", got, n)
+	}
+
+	// Delete every other session concurrently with new creates
+	i := 0
+	for id := range ids {
+		if i%2 == 0 {
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				m.Delete(id)
+				m.Delete(id) // unknown ids change nothing
+			}(id)
+		}
+		i++
+	}
+	for j := 0; j < 10; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.NewSession("orders", "globex", "2025-06-18", "", nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, want := sessionsActive.Value(), float64(n-n/2+10); got != want {
+		t.Fatalf("active = %v, want %v", got, want)
+	}
+	if got := sessionsCreated.Value() - created; got != n+10 {
+		t.Fatalf("created += %v, want %d", got, n+10)
+	}
+	if got := sessionsTerminated.Value() - terminated; got != n/2 {
+		t.Fatalf("terminated += %v, want %d", got, n/2)
+	}
+}
+
+func TestSweepCountsExpired(t *testing.T) {
+	m := NewManager(time.Minute)
+	expired := sessionsExpired.Value()
+	stale := newSession(t, m, "acme")
+	newSession(t, m, "acme")
+	stale.LastAccessed = time.Now().Add(-2 * time.Minute)
+
+	if n := m.Sweep(time.Now()); n != 1 {
+		t.Fatalf("swept %d, want 1", n)
+	}
+	if got := sessionsExpired.Value() - expired; got != 1 {
+		t.Fatalf("expired += %v, want 1", got)
+	}
+	if got := sessionsActive.Value(); got != 1 {
+		t.Fatalf("active = %v, want 1", got)
+	}
+	if _, err := m.Get(stale.ID); err == nil {
+		t.Fatal("swept session still found")
+	}
+}
+
+func TestSweeperRunsInBackground(t *testing.T) {
+	m := NewManager(20 * time.Millisecond)
+	defer m.Close()
+	expired := sessionsExpired.Value()
+	newSession(t, m, "acme")
+	m.StartSweeper(10 * time.Millisecond)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for sessionsActive.Value() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("sweeper did not remove the idle session")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := sessionsExpired.Value() - expired; got != 1 {
+		t.Fatalf("expired += %v, want 1", got)
+	}
+}
+
+func TestEvictionCountsAsTerminated(t *testing.T) {
+	m := NewManager(time.Hour)
+	m.SetTenantLimit(1, LimitEvictLRU)
+	terminated := sessionsTerminated.Value()
+	newSession(t, m, "acme")
+	newSession(t, m, "acme")
+	if got := sessionsTerminated.Value() - terminated; got != 1 {
+		t.Fatalf("terminated += %v, want 1", got)
+	}
+	if got := sessionsActive.Value(); got != 1 {
+		t.Fatalf("active = %v, want 1", got)
+	}
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// AdminToken is a control-plane credential. Only the SHA-256 hash of the secret is
+// stored; revoked tokens are kept so a revoked ADMIN_TOKEN entry stays revoked.
+type AdminToken struct {
+	ID               string `json:"id"`
+	Label            string `json:"label"`
+	TokenHash        string `json:"-"`
+	Revoked          bool   `json:"revoked"`
+	CreatedUnixMilli int64  `json:"createdUnixMilli"`
+}
+
+var ErrAdminTokenNotFound = errors.New("admin token not found")
+
+func (s *MemoryStore) CreateAdminToken(t AdminToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.CreatedUnixMilli == 0 {
+		t.CreatedUnixMilli = time.Now().UnixMilli()
+	}
+	s.adminTokens[t.ID] = t
+	return nil
+}
+
+// ListAdminTokens returns every token, revoked ones included, ordered by creation time.
+func (s *MemoryStore) ListAdminTokens() ([]AdminToken, error) {
+	s.mu.RLock()
+	out := make([]AdminToken, 0, len(s.adminTokens))
+	for _, t := range s.adminTokens {
+		out = append(out, t)
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedUnixMilli < out[j].CreatedUnixMilli })
+	return out, nil
+}
+
+func (s *MemoryStore) RevokeAdminToken(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.adminTokens[id]
+	if !ok {
+		return ErrAdminTokenNotFound
+	}
+	t.Revoked = true
+	s.adminTokens[id] = t
+	return nil
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPostgresAdminTokens(t *testing.T) {
+	p, mock := newMockStore(t)
+	created := time.UnixMilli(1700000000000)
+	mock.ExpectExec(`insert into admin_tokens \(id, label, token_hash, revoked\)`).
+		WithArgs("a1", "ci", "hash-a1", false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`select id, coalesce\(label,''\), token_hash, revoked, created_at\s+from admin_tokens`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "label", "token_hash", "revoked", "created_at"}).
+			AddRow("e1", "env-1", "hash-e1", true, created).
+			AddRow("a1", "ci", "hash-a1", false, created))
+	mock.ExpectExec(`update admin_tokens set revoked=true where id=\$1`).WithArgs("missing").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := p.CreateAdminToken(AdminToken{ID: "a1", Label: "ci", TokenHash: "hash-a1"}); err != nil {
+		t.Fatal(err)
+	}
+	// Revoked tokens are listed too, so a revoked ADMIN_TOKEN entry is not re-added
+	tokens, err := p.ListAdminTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 || !tokens[0].Revoked || tokens[0].TokenHash != "hash-e1" || tokens[1].Revoked || tokens[1].CreatedUnixMilli != created.UnixMilli() {
+		t.Fatalf("listed %+v", tokens)
+	}
+	if err := p.RevokeAdminToken("missing"); !errors.Is(err, ErrAdminTokenNotFound) {
+		t.Fatalf("err = %v, want ErrAdminTokenNotFound", err)
+	}
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGetToolByNameResolvesAliases(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s backend, slug func(string) string) {
+		server := seedServer(t, s, slug)
+		tool := getTool("get_order", "/orders/{id}")
+		tool.Aliases = []string{"fetch_order", "order_get"}
+		if err := s.UpsertToolsForServer(server, []Tool{tool, getTool("list_orders", "/orders")}); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"get_order", "fetch_order", "order_get"} {
+			got, err := s.GetToolByName(server, name)
+			if err != nil || got.Name != "get_order" {
+				t.Fatalf("GetToolByName(%q) = %s, err %v", name, got.Name, err)
+			}
+		}
+		if err := s.SetToolsEnabled(server, []string{"get_order"}, false); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetToolByName(server, "fetch_order"); !errors.Is(err, ErrToolNotFound) {
+			t.Fatalf("alias of a disabled tool: err = %v, want ErrToolNotFound", err)
+		}
+	})
+}
+
+func TestUpsertRejectsAliasConflicts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s backend, slug func(string) string) {
+		server := seedServer(t, s, slug)
+		if err := s.UpsertToolsForServer(server, []Tool{getTool("get_order", "/orders/{id}")}); err != nil {
+			t.Fatal(err)
+		}
+		withAlias := func(name, path string, aliases ...string) Tool {
+			tool := getTool(name, path)
+			tool.Aliases = aliases
+			return tool
+		}
+		cases := []struct {
+			name  string
+			tools []Tool
+			want  string
+		}{
+			{"alias of another tool's name", []Tool{withAlias("get_order", "/o"), withAlias("list_orders", "/orders", "get_order")}, "get_order"},
+			{"alias shared by two tools", []Tool{withAlias("a", "/a", "shared"), withAlias("b", "/b", "shared")}, "shared"},
+			{"alias repeating its own name", []Tool{withAlias("a", "/a", "a")}, "a"},
+		}
+		for _, tc := range cases {
+			err := s.UpsertToolsForServer(server, tc.tools)
+			if !errors.Is(err, ErrToolNameConflict) || !strings.HasSuffix(err.Error(), ": "+tc.want) {
+				t.Errorf("%s: err = %v, want ErrToolNameConflict naming %s", tc.name, err, tc.want)
+			}
+		}
+		// A rejected upsert leaves the server's tools as they were
+		tools, err := s.ListToolDefinitions(server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(toolNames(tools), ","); got != "get_order" {
+			t.Fatalf("tools after rejected upserts: %s", got)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestEffectiveAnnotationsMethodDefaults(t *testing.T) {
+	cases := []struct {
+		method                            string
+		readOnly, destructive, idempotent bool
+	}{
+		{http.MethodGet, true, false, true},
+		{"get", true, false, true},
+		{http.MethodHead, true, false, true},
+		{http.MethodPost, false, true, false},
+		{http.MethodPatch, false, true, false},
+		{http.MethodPut, false, true, true},
+		{http.MethodDelete, false, true, true},
+	}
+	for _, tc := range cases {
+		a := Tool{Name: "t", Mapping: RequestTemplate{Method: tc.method}}.EffectiveAnnotations()
+		if *a.ReadOnlyHint != tc.readOnly || *a.DestructiveHint != tc.destructive || *a.IdempotentHint != tc.idempotent || !*a.OpenWorldHint {
+			t.Errorf("%s: readOnly %v destructive %v idempotent %v openWorld %v", tc.method, *a.ReadOnlyHint, *a.DestructiveHint, *a.IdempotentHint, *a.OpenWorldHint)
+		}
+	}
+}
+
+func TestEffectiveAnnotationsExplicitHints(t *testing.T) {
+	no := false
+	// A POST search endpoint that changes nothing
+	tool := Tool{Name: "search", Mapping: RequestTemplate{Method: http.MethodPost}, Annotations: &ToolAnnotations{Title: "Search", DestructiveHint: &no}}
+	a := tool.EffectiveAnnotations()
+	if a.Title != "Search" || *a.DestructiveHint || *a.ReadOnlyHint || *a.IdempotentHint {
+		t.Fatalf("annotations %+v", a)
+	}
+	if *tool.Annotations.DestructiveHint || tool.Annotations.ReadOnlyHint != nil {
+		t.Fatal("EffectiveAnnotations modified the tool's own annotations")
+	}
+}
+
+func TestAnnotationsPersist(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s backend, slug func(string) string) {
+		server := seedServer(t, s, slug)
+		yes := true
+		tool := getTool("get_order", "/orders/{id}")
+		tool.Annotations = &ToolAnnotations{Title: "Get order", OpenWorldHint: &yes}
+		if err := s.UpsertToolsForServer(server, []Tool{tool, getTool("list_orders", "/orders")}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.GetToolByName(server, "get_order")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Annotations == nil || got.Annotations.Title != "Get order" || got.Annotations.OpenWorldHint == nil || got.Annotations.ReadOnlyHint != nil {
+			t.Fatalf("annotations %+v", got.Annotations)
+		}
+		if other, err := s.GetToolByName(server, "list_orders"); err != nil || other.Annotations != nil {
+			t.Fatalf("unannotated tool: %+v, err %v", other.Annotations, err)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// APIKey is a long-lived credential scoped to a tenant for clients that cannot
+// complete an OAuth flow. Only the SHA-256 hash of the secret is stored.
+type APIKey struct {
+	ID         string `json:"id"`
+	TenantSlug string `json:"tenantSlug"`
+	Label      string `json:"label"`
+	KeyHash    string `json:"-"`
+	// Optional server allowlist; empty means every server of the tenant
+	Servers          []string `json:"servers"`
+	Scopes           []string `json:"scopes"`
+	Revoked          bool     `json:"revoked"`
+	CreatedUnixMilli int64    `json:"createdUnixMilli"`
+}
+
+// AllowsServer reports whether the key may be used against serverSlug.
+func (k APIKey) AllowsServer(serverSlug string) bool {
+	if len(k.Servers) == 0 {
+		return true
+	}
+	for _, s := range k.Servers {
+		if s == serverSlug {
+			return true
+		}
+	}
+	return false
+}
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+func (s *MemoryStore) CreateAPIKey(k APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if k.CreatedUnixMilli == 0 {
+		k.CreatedUnixMilli = time.Now().UnixMilli()
+	}
+	s.apiKeys[k.ID] = k
+	return nil
+}
+
+func (s *MemoryStore) GetAPIKeyByHash(hash string) (APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.apiKeys {
+		if k.KeyHash == hash {
+			return k, nil
+		}
+	}
+	return APIKey{}, ErrAPIKeyNotFound
+}
+
+func (s *MemoryStore) RevokeAPIKey(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.apiKeys[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	k.Revoked = true
+	s.apiKeys[id] = k
+	return nil
+}
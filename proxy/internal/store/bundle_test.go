@@ -0,0 +1,51 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUpsertServerBundle(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s backend, slug func(string) string) {
+		server := seedServer(t, s, slug)
+		srv, _ := s.GetServer(server)
+		srv.Name = "Orders v2"
+		if err := s.UpsertServerBundle(srv, []Tool{getTool("get_order", "/orders/{id}"), getTool("list_orders", "/orders")}); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := s.GetServer(server)
+		tools, _ := s.ListToolDefinitions(server)
+		if got.Name != "Orders v2" || strings.Join(toolNames(tools), ",") != "get_order,list_orders" {
+			t.Fatalf("server %q with tools %v", got.Name, toolNames(tools))
+		}
+
+		// A rejected tool leaves both the server and its tools as they were
+		srv.Name = "Orders v3"
+		bad := getTool("cancel_order", "/orders/{id}")
+		bad.Aliases = []string{"get_order"}
+		err := s.UpsertServerBundle(srv, []Tool{getTool("get_order", "/v3/orders/{id}"), bad})
+		if !errors.Is(err, ErrToolNameConflict) {
+			t.Fatalf("err = %v, want ErrToolNameConflict", err)
+		}
+		got, _ = s.GetServer(server)
+		tools, _ = s.ListToolDefinitions(server)
+		if got.Name != "Orders v2" || strings.Join(toolNames(tools), ",") != "get_order,list_orders" {
+			t.Fatalf("after a rejected bundle: server %q with tools %v", got.Name, toolNames(tools))
+		}
+		if tool, _ := s.GetToolByName(server, "get_order"); tool.Mapping.Path != "/orders/{id}" {
+			t.Fatalf("get_order path %q after a rejected bundle", tool.Mapping.Path)
+		}
+
+		// Repeating a bundle is harmless
+		for i := 0; i < 2; i++ {
+			if err := s.UpsertServerBundle(srv, []Tool{getTool("get_order", "/v3/orders/{id}")}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tools, _ = s.ListToolDefinitions(server)
+		if got, _ := s.GetServer(server); got.Name != "Orders v3" || strings.Join(toolNames(tools), ",") != "get_order" {
+			t.Fatalf("after repeated bundles: server %q with tools %v", got.Name, toolNames(tools))
+		}
+	})
+}
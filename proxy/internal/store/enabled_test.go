@@ -0,0 +1,154 @@
+package store
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDisabledToolsAreHidden(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s backend, slug func(string) string) {
+		server := seedServer(t, s, slug)
+		off := false
+		hidden := getTool("delete_order", "/orders/{id}")
+		hidden.Enabled = &off
+		if err := s.UpsertToolsForServer(server, []Tool{getTool("get_order", "/orders/{id}"), hidden}); err != nil {
+			t.Fatal(err)
+		}
+
+		tools, err := s.ListToolsByServer(server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names := toolNames(tools); !reflect.DeepEqual(names, []string{"get_order"}) {
+			t.Fatalf("listed %v, want only get_order", names)
+		}
+		if _, err := s.GetToolByName(server, "delete_order"); !errors.Is(err, ErrToolNotFound) {
+			t.Fatalf("GetToolByName(disabled) err = %v, want ErrToolNotFound", err)
+		}
+		page, total, err := s.ListToolsByServerPaged(server, 10, 0, "")
+		if err != nil || total != 1 || len(page) != 1 {
+			t.Fatalf("paged: %v of %d, err %v", toolNames(page), total, err)
+		}
+		// Definitions still include it, so it can be exported and re-enabled
+		defs, err := s.ListToolDefinitions(server)
+		if err != nil || len(defs) != 2 {
+			t.Fatalf("definitions %v, err %v", toolNames(defs), err)
+		}
+	})
+}
+
+func TestPostgresDisabledToolsAreHidden(t *testing.T) {
+	p, mock := newMockStore(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`select id::text from servers where slug=\$1`).WithArgs("orders").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("srv-1"))
+	for _, tc := range []struct {
+		name    string
+		enabled bool
+	}{{"get_order", true}, {"delete_order", false}} {
+		// The enabled flag is written as given rather than forced on
+		mock.ExpectQuery(`insert into tools \(.*enabled.*\)[\s\S]*enabled=excluded\.enabled`).
+			WithArgs(anyArgs(16, map[int]driver.Value{0: "srv-1", 1: tc.name, 7: tc.enabled})...).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("id-" + tc.name))
+		mock.ExpectExec(`insert into request_mappings`).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectQuery(`select name, coalesce\(aliases,'\[\]'::jsonb\) from tools where server_id=\$1`).WithArgs("srv-1").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "aliases"}).AddRow("get_order", "[]").AddRow("delete_order", "[]"))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`from tools_with_mappings\s+where server_slug=\$1 and enabled=true\s+order by name`).WithArgs("orders").
+		WillReturnRows(mockRows(toolRowValues("get_order", "/orders/{id}", true)))
+	mock.ExpectQuery(`from tools_with_mappings\s+where server_slug=\$1\s+order by name`).WithArgs("orders").
+		WillReturnRows(mockRows(toolRowValues("delete_order", "/orders/{id}", false), toolRowValues("get_order", "/orders/{id}", true)))
+
+	off := false
+	hidden := getTool("delete_order", "/orders/{id}")
+	hidden.Enabled = &off
+	if err := p.UpsertToolsForServer("orders", []Tool{getTool("get_order", "/orders/{id}"), hidden}); err != nil {
+		t.Fatal(err)
+	}
+	tools, err := p.ListToolsByServer("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := toolNames(tools); !reflect.DeepEqual(names, []string{"get_order"}) {
+		t.Fatalf("listed %v, want only get_order", names)
+	}
+	defs, err := p.ListToolDefinitions("orders")
+	if err != nil || len(defs) != 2 {
+		t.Fatalf("definitions %v, err %v", toolNames(defs), err)
+	}
+	if defs[0].IsEnabled() || !defs[1].IsEnabled() {
+		t.Fatalf("enabled flags not read back: %v %v", defs[0].IsEnabled(), defs[1].IsEnabled())
+	}
+}
+
+func TestSetToolsEnabled(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s backend, slug func(string) string) {
+		server := seedServer(t, s, slug)
+		create := Tool{Name: "create_order", Description: "Create an order", Mapping: RequestTemplate{Method: "POST", Path: "/orders", Body: map[string]interface{}{"sku": "{{sku}}"}}}
+		if err := s.UpsertToolsForServer(server, []Tool{getTool("get_order", "/orders/{id}"), getTool("list_orders", "/orders"), create}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.SetToolsEnabled(server, []string{"list_orders", "create_order"}, false); err != nil {
+			t.Fatal(err)
+		}
+		tools, err := s.ListToolsByServer(server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names := toolNames(tools); !reflect.DeepEqual(names, []string{"get_order"}) {
+			t.Fatalf("listed %v after disabling, want only get_order", names)
+		}
+		// Only the flag changed
+		defs, err := s.ListToolDefinitions(server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range defs {
+			if d.Name == "create_order" && (d.IsEnabled() || d.Description != create.Description || d.Mapping.Body["sku"] != "{{sku}}") {
+				t.Fatalf("create_order after disabling: %+v", d)
+			}
+		}
+
+		if err := s.SetToolsEnabled(server, []string{"create_order"}, true); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetToolByName(server, "create_order"); err != nil {
+			t.Fatalf("re-enabled tool: %v", err)
+		}
+
+		// An unknown name fails the whole call
+		if err := s.SetToolsEnabled(server, []string{"get_order", "missing"}, false); !errors.Is(err, ErrToolNotFound) {
+			t.Fatalf("err = %v, want ErrToolNotFound", err)
+		}
+		if _, err := s.GetToolByName(server, "get_order"); err != nil {
+			t.Fatalf("get_order was disabled by a failed call: %v", err)
+		}
+	})
+}
+
+func TestPostgresSetToolsEnabled(t *testing.T) {
+	p, mock := newMockStore(t)
+	update := `update tools set enabled=\$3\s+where server_id=\(select id from servers where slug=\$1\)\s+and name in \(select jsonb_array_elements_text\(\$2::jsonb\)\)\s+returning name`
+	mock.ExpectBegin()
+	mock.ExpectQuery(update).WithArgs("orders", `["list_orders","create_order"]`, false).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("list_orders").AddRow("create_order"))
+	mock.ExpectCommit()
+	// An unknown name rolls the whole update back
+	mock.ExpectBegin()
+	mock.ExpectQuery(update).WithArgs("orders", `["get_order","missing"]`, false).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("get_order"))
+	mock.ExpectRollback()
+
+	if err := p.SetToolsEnabled("orders", []string{"list_orders", "create_order"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetToolsEnabled("orders", []string{"get_order", "missing"}, false); !errors.Is(err, ErrToolNotFound) {
+		t.Fatalf("err = %v, want ErrToolNotFound", err)
+	}
+}
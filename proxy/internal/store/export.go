@@ -0,0 +1,66 @@
+package store
+
+import "sort"
+
+// TenantExport is a self-contained snapshot of a tenant used for backup and
+// migration; ImportTenant consumes the same shape.
+type TenantExport struct {
+	Tenant  Tenant         `json:"tenant"`
+	Servers []ServerExport `json:"servers"`
+}
+
+type ServerExport struct {
+	Server Server `json:"server"`
+	Tools  []Tool `json:"tools"`
+}
+
+// ListServers returns every server ordered by slug.
+func (s *MemoryStore) ListServers() ([]Server, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Server, 0, len(s.servers))
+	for _, srv := range s.servers {
+		out = append(out, srv)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Slug < out[j].Slug })
+	return out, nil
+}
+
+// ListServersByTag returns the servers carrying tag, or every server when tag is empty,
+// ordered by slug.
+func (s *MemoryStore) ListServersByTag(tag string) ([]Server, error) {
+	all, _ := s.ListServers()
+	out := []Server{}
+	for _, srv := range all {
+		if tag == "" || srv.HasTag(tag) {
+			out = append(out, srv)
+		}
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) ListServersByTenant(tenantSlug string) ([]Server, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := []Server{}
+	for _, srv := range s.servers {
+		if srv.TenantSlug == tenantSlug {
+			out = append(out, srv)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Slug < out[j].Slug })
+	return out, nil
+}
+
+// ImportTenant applies the whole export under one lock so readers never observe a partial import.
+func (s *MemoryStore) ImportTenant(exp TenantExport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tenants[exp.Tenant.Slug] = exp.Tenant
+	for _, se := range exp.Servers {
+		se.Server.TenantSlug = exp.Tenant.Slug
+		s.servers[se.Server.Slug] = se.Server
+		s.toolsByServer[se.Server.Slug] = se.Tools
+	}
+	return nil
+}
@@ -1,5 +1,7 @@
 package store
 
+import "sort"
+
 // AuthorizationServerRef mirrors handlers' ref to avoid import cycle
 type AuthorizationServerRef struct {
 	Issuer      string `json:"issuer"`
@@ -37,5 +39,6 @@ func (s *MemoryStore) AllAuthorizationServerRefs() []AuthorizationServerRef {
 	for _, v := range seen {
 		out = append(out, v)
 	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Issuer < out[j].Issuer })
 	return out
 }
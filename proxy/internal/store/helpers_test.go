@@ -0,0 +1,147 @@
+package store
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/lib/pq"
+)
+
+// backend is the store surface the tests below exercise on both implementations.
+type backend interface {
+	Store
+	UpsertTenant(Tenant) error
+	UpsertServer(Server) error
+	UpsertToolsForServer(serverSlug string, tools []Tool) error
+	UpsertServerBundle(srv Server, tools []Tool) error
+	SetToolsEnabled(serverSlug string, names []string, enabled bool) error
+	ListToolDefinitions(serverSlug string) ([]Tool, error)
+	ListServers() ([]Server, error)
+	ListServersByTag(tag string) ([]Server, error)
+	ListServersByTenant(tenantSlug string) ([]Server, error)
+}
+
+// forEachBackend runs fn against a MemoryStore and, when TEST_DATABASE_URL names a
+// scratch Postgres database, against a PostgresStore as well; without it the postgres run
+// is skipped, and the sqlmock tests (TestPostgres*) cover the Postgres queries. Each run
+// gets its own slug suffix so runs against the same database do not collide.
+func forEachBackend(t *testing.T, fn func(t *testing.T, s backend, slug func(string) string)) {
+	t.Run("memory", func(t *testing.T) {
+		fn(t, NewMemoryStore("https://api.example.com"), func(s string) string { return s })
+	})
+	t.Run("postgres", func(t *testing.T) {
+		dsn := os.Getenv("TEST_DATABASE_URL")
+		if dsn == "" {
+			t.Skip("TEST_DATABASE_URL not set; point it at a scratch Postgres database to run against PostgresStore")
+		}
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		schema, err := os.ReadFile("postgres_schema.sql")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := EnsureSchema(db, string(schema)); err != nil {
+			t.Fatal(err)
+		}
+		var b [4]byte
+		_, _ = rand.Read(b[:])
+		suffix := "-" + hex.EncodeToString(b[:])
+		fn(t, NewPostgresStore(db, "https://api.example.com"), func(s string) string { return s + suffix })
+	})
+}
+
+// seedServer stores tenant acme and its server orders under slug and returns the server slug.
+func seedServer(t *testing.T, s backend, slug func(string) string) string {
+	t.Helper()
+	tenant := Tenant{Slug: slug("acme"), Name: "Acme", Enabled: true}
+	if err := s.UpsertTenant(tenant); err != nil {
+		t.Fatal(err)
+	}
+	srv := Server{Slug: slug("orders"), TenantSlug: tenant.Slug, Name: "orders", Enabled: true, Audience: "https://api.example.com"}
+	if err := s.UpsertServer(srv); err != nil {
+		t.Fatal(err)
+	}
+	return srv.Slug
+}
+
+func getTool(name, path string) Tool {
+	return Tool{Name: name, Mapping: RequestTemplate{Method: "GET", Path: path}}
+}
+
+func toolNames(tools []Tool) []string {
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = tool.Name
+	}
+	return names
+}
+
+// newMockStore returns a PostgresStore on a sqlmock connection, for checking the SQL a
+// method sends and how it scans rows without a database. Expected queries are regular
+// expressions; expectations left unmet fail the test.
+func newMockStore(t *testing.T) (*PostgresStore, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return NewPostgresStore(db, "https://api.example.com"), mock
+}
+
+// mockRows builds result rows; columns are matched by position, so their names do not matter.
+func mockRows(rows ...[]driver.Value) *sqlmock.Rows {
+	cols := make([]string, len(rows[0]))
+	for i := range cols {
+		cols[i] = "c" + strconv.Itoa(i)
+	}
+	out := sqlmock.NewRows(cols)
+	for _, r := range rows {
+		out.AddRow(r...)
+	}
+	return out
+}
+
+// tenantRowValues returns tenantColumns for an enabled tenant.
+func tenantRowValues(slug, issuersJSON string) []driver.Value {
+	return []driver.Value{slug, "Acme", true, "[]", issuersJSON, "[]"}
+}
+
+// serverRowValues returns serverColumns for an enabled server whose other JSON columns
+// hold their defaults.
+func serverRowValues(slug, tenantSlug, issuersJSON string) []driver.Value {
+	return []driver.Value{slug, tenantSlug, slug, "https://api.example.com", true, "https://upstream.example.com", "", "", "", nil,
+		issuersJSON, "[]", "failover", "[]", "{}", "", "http", "[]", "{}", "[]", "{}", nil, "[]", "[]", nil, "[]", "", "[]"}
+}
+
+// toolRowValues returns toolColumns for a GET tool.
+func toolRowValues(name, path string, enabled bool) []driver.Value {
+	return []driver.Value{"id-" + name, name, "", "", "[]", "{}", "{}", "GET", path, "{}", "{}", "{}", enabled, 0, "json", "{}", false, "", false, "rest", "",
+		nil, "{}", "{}", false, "[]", "[]", "{}", "[]", "[]", "{}"}
+}
+
+// anyArgs returns n sqlmock.AnyArg matchers with the given positions replaced.
+func anyArgs(n int, at map[int]driver.Value) []driver.Value {
+	args := make([]driver.Value, n)
+	for i := range args {
+		args[i] = sqlmock.AnyArg()
+	}
+	for i, v := range at {
+		args[i] = v
+	}
+	return args
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAllowedIssuersRoundTrip(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s backend, slug func(string) string) {
+		tenant := Tenant{Slug: slug("acme"), Name: "Acme", Enabled: true, AllowedIssuers: []string{"https://idp.example.com", "https://login.example.com"}}
+		if err := s.UpsertTenant(tenant); err != nil {
+			t.Fatal(err)
+		}
+		srv := Server{Slug: slug("orders"), TenantSlug: tenant.Slug, Name: "orders", Enabled: true, Audience: "https://api.example.com", AllowedIssuers: []string{"https://orders-idp.example.com"}}
+		if err := s.UpsertServer(srv); err != nil {
+			t.Fatal(err)
+		}
+		plain := Server{Slug: slug("billing"), TenantSlug: tenant.Slug, Name: "billing", Enabled: true, Audience: "https://api.example.com"}
+		if err := s.UpsertServer(plain); err != nil {
+			t.Fatal(err)
+		}
+
+		gotTenant, err := s.GetTenant(tenant.Slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(gotTenant.AllowedIssuers, tenant.AllowedIssuers) {
+			t.Fatalf("tenant issuers = %v, want %v", gotTenant.AllowedIssuers, tenant.AllowedIssuers)
+		}
+		gotServer, gotServerTenant, err := s.GetServerWithTenant(srv.Slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(gotServer.AllowedIssuers, srv.AllowedIssuers) {
+			t.Fatalf("server issuers = %v, want %v", gotServer.AllowedIssuers, srv.AllowedIssuers)
+		}
+		if !reflect.DeepEqual(gotServerTenant.AllowedIssuers, tenant.AllowedIssuers) {
+			t.Fatalf("server tenant issuers = %v, want %v", gotServerTenant.AllowedIssuers, tenant.AllowedIssuers)
+		}
+		gotPlain, err := s.GetServer(plain.Slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(gotPlain.AllowedIssuers) != 0 {
+			t.Fatalf("server without override has issuers %v", gotPlain.AllowedIssuers)
+		}
+
+		// Updates replace the list rather than merging
+		tenant.AllowedIssuers = []string{"https://login.example.com"}
+		if err := s.UpsertTenant(tenant); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := s.GetTenant(tenant.Slug); !reflect.DeepEqual(got.AllowedIssuers, tenant.AllowedIssuers) {
+			t.Fatalf("updated tenant issuers = %v", got.AllowedIssuers)
+		}
+	})
+}
+
+func TestPostgresAllowedIssuersUpsert(t *testing.T) {
+	p, mock := newMockStore(t)
+	mock.ExpectExec(`insert into tenants \(slug, name, enabled, egress_allowlist, allowed_issuers, scope_claims\)`).
+		WithArgs("acme", "Acme", true, "[]", `["https://idp.example.com"]`, "[]").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// A nil list is stored as [] so reads never see JSON null
+	mock.ExpectExec(`insert into tenants`).
+		WithArgs("beta", "Beta", true, "[]", "[]", "[]").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`insert into servers \(.*allowed_issuers.*\)[\s\S]*allowed_issuers=excluded\.allowed_issuers`).
+		WithArgs(anyArgs(28, map[int]driver.Value{1: "orders", 10: `["https://orders-idp.example.com"]`})...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := p.UpsertTenant(Tenant{Slug: "acme", Name: "Acme", Enabled: true, AllowedIssuers: []string{"https://idp.example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.UpsertTenant(Tenant{Slug: "beta", Name: "Beta", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.UpsertServer(Server{Slug: "orders", TenantSlug: "acme", Name: "orders", Enabled: true, AllowedIssuers: []string{"https://orders-idp.example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPostgresAllowedIssuersScan(t *testing.T) {
+	p, mock := newMockStore(t)
+	mock.ExpectQuery(`coalesce\(t\.allowed_issuers,'\[\]'::jsonb\)[\s\S]*from tenants t where t\.slug=\$1`).
+		WithArgs("acme").
+		WillReturnRows(mockRows(tenantRowValues("acme", `["https://idp.example.com","https://login.example.com"]`)))
+	mock.ExpectQuery(`coalesce\(s\.allowed_issuers,'\[\]'::jsonb\)[\s\S]*coalesce\(t\.allowed_issuers,'\[\]'::jsonb\)[\s\S]*where s\.slug=\$1`).
+		WithArgs("orders").
+		WillReturnRows(mockRows(append(serverRowValues("orders", "acme", `["https://orders-idp.example.com"]`), tenantRowValues("acme", `["https://idp.example.com"]`)...)))
+	mock.ExpectQuery(`from servers s`).
+		WithArgs("billing").
+		WillReturnRows(mockRows(serverRowValues("billing", "acme", "[]")))
+
+	tenant, err := p.GetTenant("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"https://idp.example.com", "https://login.example.com"}; !reflect.DeepEqual(tenant.AllowedIssuers, want) {
+		t.Fatalf("tenant issuers = %v, want %v", tenant.AllowedIssuers, want)
+	}
+	srv, srvTenant, err := p.GetServerWithTenant("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"https://orders-idp.example.com"}; !reflect.DeepEqual(srv.AllowedIssuers, want) {
+		t.Fatalf("server issuers = %v, want %v", srv.AllowedIssuers, want)
+	}
+	if want := []string{"https://idp.example.com"}; !reflect.DeepEqual(srvTenant.AllowedIssuers, want) {
+		t.Fatalf("server tenant issuers = %v, want %v", srvTenant.AllowedIssuers, want)
+	}
+	plain, err := p.GetServer("billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plain.AllowedIssuers) != 0 {
+		t.Fatalf("server without override has issuers %v", plain.AllowedIssuers)
+	}
+}
+
+func TestPostgresAuthorizationServerRefs(t *testing.T) {
+	p, mock := newMockStore(t)
+	mock.ExpectQuery(`select distinct iss from \([\s\S]*allowed_issuers[\s\S]*from tenants[\s\S]*allowed_issuers[\s\S]*from servers`).
+		WillReturnRows(sqlmock.NewRows([]string{"iss"}).AddRow("https://idp.example.com").AddRow("https://orders-idp.example.com"))
+
+	want := []AuthorizationServerRef{
+		{Issuer: "https://idp.example.com", MetadataURL: "https://idp.example.com/.well-known/openid-configuration"},
+		{Issuer: "https://orders-idp.example.com", MetadataURL: "https://orders-idp.example.com/.well-known/openid-configuration"},
+	}
+	if got := p.AllAuthorizationServerRefs(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("refs = %v, want %v", got, want)
+	}
+}
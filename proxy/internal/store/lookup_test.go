@@ -0,0 +1,56 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetToolByNameWithDistinctID(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s backend, slug func(string) string) {
+		server := seedServer(t, s, slug)
+		// Postgres assigns its own UUID; the memory store keeps the given id
+		tool := getTool("get_order", "/orders/{id}")
+		tool.ID = "3f6c2a9e-0000-4000-8000-000000000001"
+		if err := s.UpsertToolsForServer(server, []Tool{tool, getTool("list_orders", "/orders")}); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.GetToolByName(server, "get_order")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != "get_order" || got.ID == "" || got.ID == got.Name || got.Mapping.Path != "/orders/{id}" {
+			t.Fatalf("got %+v", got)
+		}
+		for _, name := range []string{got.ID, "missing"} {
+			if _, err := s.GetToolByName(server, name); !errors.Is(err, ErrToolNotFound) {
+				t.Fatalf("GetToolByName(%q) err = %v, want ErrToolNotFound", name, err)
+			}
+		}
+		if _, err := s.GetToolByName(slug("nope"), "get_order"); !errors.Is(err, ErrToolNotFound) {
+			t.Fatalf("unknown server: err = %v, want ErrToolNotFound", err)
+		}
+	})
+}
+
+func TestPostgresGetToolByName(t *testing.T) {
+	p, mock := newMockStore(t)
+	byName := `from tools_with_mappings\s+where server_slug=\$1 and enabled=true and \(name=\$2 or aliases \? \$2\)`
+	row := toolRowValues("get_order", "/orders/{id}", true)
+	row[0] = "3f6c2a9e-0000-4000-8000-000000000001"
+	mock.ExpectQuery(byName).WithArgs("orders", "get_order").WillReturnRows(mockRows(row))
+	mock.ExpectQuery(byName).WithArgs("orders", "missing").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	got, err := p.GetToolByName("orders", "get_order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "get_order" || got.ID != "3f6c2a9e-0000-4000-8000-000000000001" || got.Mapping.Path != "/orders/{id}" {
+		t.Fatalf("got %+v", got)
+	}
+	if _, err := p.GetToolByName("orders", "missing"); !errors.Is(err, ErrToolNotFound) {
+		t.Fatalf("missing tool: err = %v, want ErrToolNotFound", err)
+	}
+}
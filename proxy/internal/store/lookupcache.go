@@ -0,0 +1,123 @@
+package store
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// lookupCache is a bounded read-through cache of configuration reads. Writes through
+// the owning store invalidate the affected keys. Each invalidation bumps a generation, and
+// a read that started before the bump does not store its result. A write racing a slow
+// read therefore cannot leave the pre-write value cached. Writes made by other processes
+// are only picked up once entries expire. Cached values are shared between callers, which
+// treat them as read-only just like MemoryStore results.
+type lookupCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	gen        uint64
+	entries    map[string]lookupEntry
+}
+
+type lookupEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// Key prefixes; tool keys are followed by the server slug and a NUL so one server's
+// entries can be dropped together.
+const (
+	keyTenant = "tenant\x00"
+	keyServer = "server\x00"
+	keyPair   = "pair\x00"
+	keyTools  = "tools\x00"
+)
+
+func newLookupCache(ttl time.Duration, maxEntries int) *lookupCache {
+	return &lookupCache{ttl: ttl, maxEntries: maxEntries, entries: make(map[string]lookupEntry)}
+}
+
+// get returns the cached value for key, or calls load and caches its result. Errors are
+// not cached. A nil cache always loads.
+func (c *lookupCache) get(key string, load func() (interface{}, error)) (interface{}, error) {
+	if c == nil {
+		return load()
+	}
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		if time.Now().Before(e.expires) {
+			c.mu.Unlock()
+			return e.value, nil
+		}
+		delete(c.entries, key)
+	}
+	gen := c.gen
+	c.mu.Unlock()
+
+	v, err := load()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen == gen {
+		c.makeRoomLocked()
+		c.entries[key] = lookupEntry{value: v, expires: time.Now().Add(c.ttl)}
+	}
+	return v, nil
+}
+
+// makeRoomLocked drops expired entries, then arbitrary ones, until a new entry fits.
+func (c *lookupCache) makeRoomLocked() {
+	if c.maxEntries <= 0 || len(c.entries) < c.maxEntries {
+		return
+	}
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	for k := range c.entries {
+		if len(c.entries) < c.maxEntries {
+			break
+		}
+		delete(c.entries, k)
+	}
+}
+
+// invalidate drops the given keys and every key starting with one of prefixes.
+func (c *lookupCache) invalidate(keys []string, prefixes ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for _, k := range keys {
+		delete(c.entries, k)
+	}
+	if len(prefixes) == 0 {
+		return
+	}
+	for k := range c.entries {
+		for _, p := range prefixes {
+			if strings.HasPrefix(k, p) {
+				delete(c.entries, k)
+				break
+			}
+		}
+	}
+}
+
+// clear drops every entry.
+func (c *lookupCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.entries = make(map[string]lookupEntry)
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// counted returns a loader yielding value and the number of times it ran.
+func counted(value interface{}) (func() (interface{}, error), *int) {
+	n := new(int)
+	return func() (interface{}, error) {
+		*n++
+		return value, nil
+	}, n
+}
+
+func TestLookupCacheHits(t *testing.T) {
+	c := newLookupCache(time.Minute, 0)
+	load, loads := counted("orders")
+	for i := 0; i < 3; i++ {
+		if v, err := c.get(keyServer+"orders", load); err != nil || v != "orders" {
+			t.Fatalf("get = %v, %v", v, err)
+		}
+	}
+	if *loads != 1 {
+		t.Fatalf("%d loads, want 1", *loads)
+	}
+
+	// Errors are not cached
+	fails := 0
+	fail := func() (interface{}, error) { fails++; return nil, errors.New("down") }
+	for i := 0; i < 2; i++ {
+		if _, err := c.get(keyServer+"billing", fail); err == nil {
+			t.Fatal("expected the load error")
+		}
+	}
+	if fails != 2 {
+		t.Fatalf("%d failing loads, want 2", fails)
+	}
+
+	// A nil cache always loads
+	var off *lookupCache
+	load, loads = counted("x")
+	_, _ = off.get("k", load)
+	_, _ = off.get("k", load)
+	off.invalidate([]string{"k"})
+	if *loads != 2 {
+		t.Fatalf("nil cache: %d loads, want 2", *loads)
+	}
+}
+
+func TestLookupCacheInvalidate(t *testing.T) {
+	c := newLookupCache(time.Minute, 0)
+	loadServer, serverLoads := counted("server")
+	loadOrders, ordersLoads := counted("tools")
+	loadOther, otherLoads := counted("tools")
+	get := func() {
+		_, _ = c.get(keyServer+"orders", loadServer)
+		_, _ = c.get(toolsKey("orders", "all"), loadOrders)
+		_, _ = c.get(toolsKey("orders", "name", "get_order"), loadOrders)
+		_, _ = c.get(toolsKey("orders-eu", "all"), loadOther)
+	}
+	get()
+	// Dropping one server's tool reads leaves a server whose slug extends it alone
+	c.invalidate([]string{keyServer + "orders"}, toolsKey("orders"))
+	get()
+	if *serverLoads != 2 || *ordersLoads != 4 || *otherLoads != 1 {
+		t.Fatalf("loads: server %d, orders tools %d, orders-eu tools %d; want 2, 4, 1", *serverLoads, *ordersLoads, *otherLoads)
+	}
+	c.clear()
+	get()
+	if *serverLoads != 3 || *otherLoads != 2 {
+		t.Fatalf("after clear: server %d, orders-eu tools %d loads", *serverLoads, *otherLoads)
+	}
+}
+
+func TestLookupCacheWriteDuringLoad(t *testing.T) {
+	c := newLookupCache(time.Minute, 0)
+	// A write lands while the read is in flight: the read's (stale) result is not kept
+	stale := func() (interface{}, error) {
+		c.invalidate([]string{keyTenant + "acme"})
+		return "before", nil
+	}
+	if v, _ := c.get(keyTenant+"acme", stale); v != "before" {
+		t.Fatalf("get = %v", v)
+	}
+	load, loads := counted("after")
+	if v, _ := c.get(keyTenant+"acme", load); v != "after" || *loads != 1 {
+		t.Fatalf("get = %v after %d loads, want a fresh read", v, *loads)
+	}
+}
+
+func TestLookupCacheTTL(t *testing.T) {
+	c := newLookupCache(20*time.Millisecond, 0)
+	load, loads := counted("acme")
+	_, _ = c.get(keyTenant+"acme", load)
+	_, _ = c.get(keyTenant+"acme", load)
+	time.Sleep(40 * time.Millisecond)
+	_, _ = c.get(keyTenant+"acme", load)
+	if *loads != 2 {
+		t.Fatalf("%d loads, want a refetch after the TTL", *loads)
+	}
+}
+
+func TestLookupCacheBounded(t *testing.T) {
+	c := newLookupCache(time.Minute, 3)
+	for i := 0; i < 10; i++ {
+		load, _ := counted(i)
+		_, _ = c.get(fmt.Sprintf("%s%d", keyServer, i), load)
+		if len(c.entries) > 3 {
+			t.Fatalf("%d entries, want at most 3", len(c.entries))
+		}
+	}
+}
+
+func TestLookupCacheConcurrent(t *testing.T) {
+	c := newLookupCache(time.Minute, 8)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				key := fmt.Sprintf("%s%d", keyServer, j%12)
+				v, err := c.get(key, func() (interface{}, error) { return key, nil })
+				if err != nil || v != key {
+					t.Errorf("get(%q) = %v, %v", key, v, err)
+					return
+				}
+				if j%10 == i {
+					c.invalidate([]string{key}, keyTools)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestCachedReadsSeeWrites(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s backend, slug func(string) string) {
+		if p, ok := s.(*PostgresStore); ok {
+			p.SetLookupCache(time.Minute, 100)
+		}
+		server := seedServer(t, s, slug)
+		if err := s.UpsertToolsForServer(server, []Tool{getTool("get_order", "/orders/{id}")}); err != nil {
+			t.Fatal(err)
+		}
+		// Warm every cached read, then change what each one returns
+		_, _ = s.GetTenant(slug("acme"))
+		_, _, _ = s.GetServerWithTenant(server)
+		_, _ = s.ListToolsByServer(server)
+		_, _ = s.GetToolByName(server, "get_order")
+
+		tenant, _ := s.GetTenant(slug("acme"))
+		tenant.Name = "Acme Corp"
+		if err := s.UpsertTenant(tenant); err != nil {
+			t.Fatal(err)
+		}
+		srv, _ := s.GetServer(server)
+		srv.Name = "Orders v2"
+		if err := s.UpsertServer(srv); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.UpsertToolsForServer(server, []Tool{getTool("get_order", "/v2/orders/{id}"), getTool("list_orders", "/orders")}); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, _ := s.GetTenant(slug("acme")); got.Name != "Acme Corp" {
+			t.Errorf("tenant name %q after upsert", got.Name)
+		}
+		if gotSrv, gotTenant, _ := s.GetServerWithTenant(server); gotSrv.Name != "Orders v2" || gotTenant.Name != "Acme Corp" {
+			t.Errorf("server %q of tenant %q after upserts", gotSrv.Name, gotTenant.Name)
+		}
+		if tools, _ := s.ListToolsByServer(server); len(tools) != 2 {
+			t.Errorf("%d tools after upsert, want 2", len(tools))
+		}
+		if tool, _ := s.GetToolByName(server, "get_order"); tool.Mapping.Path != "/v2/orders/{id}" {
+			t.Errorf("get_order path %q after upsert", tool.Mapping.Path)
+		}
+		if err := s.SetToolsEnabled(server, []string{"get_order"}, false); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetToolByName(server, "get_order"); !errors.Is(err, ErrToolNotFound) {
+			t.Errorf("disabled tool: err = %v, want ErrToolNotFound", err)
+		}
+	})
+}
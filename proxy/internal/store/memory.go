@@ -2,49 +2,383 @@ package store
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
 	"sync"
 )
 
 type Tenant struct {
-	Slug             string
-	Name             string
-	AllowedIssuers   []string
-	EgressAllowlist  []string
-	Enabled          bool
-	CreatedUnixMilli int64
+	Slug           string   `json:"slug"`
+	Name           string   `json:"name"`
+	AllowedIssuers []string `json:"allowedIssuers,omitempty"`
+	// Optional token claims scopes are read from, e.g. ["scp"] or ["permissions"]. Each may
+	// be a space-delimited string or an array. Empty means "scope" and "scopes".
+	ScopeClaims      []string `json:"scopeClaims,omitempty"`
+	EgressAllowlist  []string `json:"egressAllowlist"`
+	Enabled          bool     `json:"enabled"`
+	CreatedUnixMilli int64    `json:"createdUnixMilli,omitempty"`
 }
 
 type Server struct {
-	Slug       string
-	TenantSlug string
-	Name       string
-	Audience   string
+	Slug       string `json:"slug"`
+	TenantSlug string `json:"tenantSlug"`
+	Name       string `json:"name"`
+	// Optional labels for grouping and finding servers, e.g. "team:payments", "prod"
+	Tags []string `json:"tags,omitempty"`
+	// Audience is the primary resource audience, advertised in protected resource metadata
+	Audience string `json:"audience"`
+	// Optional further audiences accepted in tokens, e.g. while migrating between audience URIs
+	Audiences []string `json:"audiences,omitempty"`
 	// Optional override; if empty use tenant AllowedIssuers
-	AllowedIssuers  []string
-	Enabled         bool
-	UpstreamBaseURL string
-	ServerTitle     string
-	ServerVersion   string
-	Instructions    string
+	AllowedIssuers []string `json:"allowedIssuers,omitempty"`
+	// Optional override; if empty use tenant ScopeClaims
+	ScopeClaims     []string `json:"scopeClaims,omitempty"`
+	Enabled         bool     `json:"enabled"`
+	UpstreamBaseURL string   `json:"upstreamBaseURL"`
+	// Optional ordered failover list; takes precedence over UpstreamBaseURL when set
+	UpstreamBaseURLs []string `json:"upstreamBaseURLs,omitempty"`
+	// Optional; "failover" (default), "round-robin" or "weighted" across UpstreamBaseURLs
+	LoadBalancing string `json:"loadBalancing,omitempty"`
+	// Optional weights aligned with UpstreamBaseURLs for the weighted strategy
+	UpstreamWeights []int  `json:"upstreamWeights,omitempty"`
+	ServerTitle     string `json:"serverTitle,omitempty"`
+	ServerVersion   string `json:"serverVersion,omitempty"`
+	Instructions    string `json:"instructions,omitempty"`
+	// Optional translations of Instructions keyed by language tag (e.g. "fr", "pt-BR")
+	LocalizedInstructions map[string]string `json:"localizedInstructions,omitempty"`
+	// Optional; nil means tools-only
+	Capabilities *ServerCapabilities `json:"capabilities,omitempty"`
+	// Optional scopes required by HTTP method on top of each tool's RequiredScopes, e.g.
+	// {"*": ["write:*"]}. Holding any one listed scope satisfies the policy; a trailing "*"
+	// matches by prefix. The "*" key covers every method except GET, HEAD and OPTIONS.
+	MethodScopes map[string][]string `json:"methodScopes,omitempty"`
+	// Optional; "follow" (default), "follow-same-host" or "none". Tools may override it.
+	RedirectPolicy string `json:"redirectPolicy,omitempty"`
+	// Optional; "http" (default) or "stdio". Stdio servers forward tools/call to a local
+	// MCP server process started from StdioCommand; tool mappings are ignored.
+	Backend      string   `json:"backend,omitempty"`
+	StdioCommand []string `json:"stdioCommand,omitempty"`
+	// Optional JSON-RPC methods beyond the MCP ones that are passed through to the upstream:
+	// stdio servers get them on the child process, HTTP servers as a JSON-RPC POST to
+	// CustomMethodsPath on the upstream base URL. Unlisted methods stay method-not-found.
+	CustomMethods     []string `json:"customMethods,omitempty"`
+	CustomMethodsPath string   `json:"customMethodsPath,omitempty"`
+	// Optional claim name -> upstream header name, e.g. {"sub": "X-User-Id"}. String and
+	// number claims of the caller's token are forwarded; missing claims are skipped.
+	ClaimHeaderMappings map[string]string `json:"claimHeaderMappings,omitempty"`
+	// Optional active health probing of the upstreams; nil disables it
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"`
+	// Optional upstream response headers (e.g. "Link", "ETag") returned to clients in the
+	// tools/call result's _meta.responseHeaders. Cookie and auth headers are never passed.
+	ResponseHeaders []string `json:"responseHeaders,omitempty"`
+	// Optional upstream timeouts; nil keeps the gateway defaults
+	Timeouts *UpstreamTimeouts `json:"timeouts,omitempty"`
+}
+
+// UpstreamTimeouts splits a server's upstream time budget by stage, in milliseconds. Zero
+// keeps the gateway default for that stage: 10s to connect, 10s for the TLS handshake, no
+// separate response header limit, and 20s for the whole tool call.
+type UpstreamTimeouts struct {
+	// ConnectMs bounds establishing the TCP connection
+	ConnectMs int `json:"connectMs,omitempty"`
+	// TLSHandshakeMs bounds the TLS handshake
+	TLSHandshakeMs int `json:"tlsHandshakeMs,omitempty"`
+	// ResponseHeaderMs bounds the wait for response headers once the request is sent
+	ResponseHeaderMs int `json:"responseHeaderMs,omitempty"`
+	// TotalMs bounds the whole call, including failover and reading the body
+	TotalMs int `json:"totalMs,omitempty"`
+}
+
+// HealthCheck configures periodic probes of a server's upstreams. The server is down when
+// a probe round gets no 2xx/3xx answer from any upstream, and up again after one does.
+type HealthCheck struct {
+	// Path is requested with GET on every upstream base URL, e.g. "/healthz"
+	Path string `json:"path"`
+	// IntervalSeconds between probe rounds; defaults to 10
+	IntervalSeconds int `json:"intervalSeconds,omitempty"`
+	// FailFast rejects tool calls immediately while the server is down
+	FailFast bool `json:"failFast,omitempty"`
+}
+
+// HasTag reports whether the server carries tag.
+func (s Server) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// PrimaryAudience returns Audience, or the first of Audiences when Audience is empty.
+func (s Server) PrimaryAudience() string {
+	if s.Audience != "" || len(s.Audiences) == 0 {
+		return s.Audience
+	}
+	return s.Audiences[0]
+}
+
+// DefaultScopeClaims are read when neither the server nor its tenant sets ScopeClaims.
+var DefaultScopeClaims = []string{"scope", "scopes"}
+
+// ScopeClaimNames returns the token claims scopes are read from: the server's ScopeClaims,
+// else the tenant's, else DefaultScopeClaims.
+func (s Server) ScopeClaimNames(t Tenant) []string {
+	if len(s.ScopeClaims) > 0 {
+		return s.ScopeClaims
+	}
+	if len(t.ScopeClaims) > 0 {
+		return t.ScopeClaims
+	}
+	return DefaultScopeClaims
+}
+
+// AcceptsAudience reports whether a token for aud may be used with the server.
+func (s Server) AcceptsAudience(aud string) bool {
+	if aud == "" {
+		return false
+	}
+	if aud == s.Audience {
+		return true
+	}
+	for _, a := range s.Audiences {
+		if a == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// MethodScopesFor returns the policy scopes for an upstream HTTP method, if any.
+func (s Server) MethodScopesFor(method string) []string {
+	method = strings.ToUpper(method)
+	if scopes, ok := s.MethodScopes[method]; ok {
+		return scopes
+	}
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+	return s.MethodScopes["*"]
+}
+
+// UpstreamBases returns the upstream base URLs to try, in order.
+func (s Server) UpstreamBases() []string {
+	if len(s.UpstreamBaseURLs) > 0 {
+		return s.UpstreamBaseURLs
+	}
+	if s.UpstreamBaseURL != "" {
+		return []string{s.UpstreamBaseURL}
+	}
+	return nil
+}
+
+// ServerCapabilities selects which MCP capabilities a server advertises on initialize.
+type ServerCapabilities struct {
+	Tools              bool `json:"tools"`
+	Prompts            bool `json:"prompts"`
+	Resources          bool `json:"resources"`
+	ResourcesSubscribe bool `json:"resourcesSubscribe"`
+	Logging            bool `json:"logging"`
+	Completions        bool `json:"completions"`
+	Elicitation        bool `json:"elicitation"`
+}
+
+// DefaultServerCapabilities is used when a server has no explicit capabilities configured.
+func DefaultServerCapabilities() ServerCapabilities {
+	return ServerCapabilities{Tools: true}
+}
+
+// EffectiveCapabilities returns the configured capabilities or the tools-only default.
+func (s Server) EffectiveCapabilities() ServerCapabilities {
+	if s.Capabilities == nil {
+		return DefaultServerCapabilities()
+	}
+	return *s.Capabilities
 }
 
 type Tool struct {
-	ID             string                 `json:"id"`
-	Name           string                 `json:"name"`
-	Title          string                 `json:"title,omitempty"`
-	Description    string                 `json:"description"`
-	RequiredScopes []string               `json:"requiredScopes"`
+	ID             string   `json:"id"`
+	Name           string   `json:"name"`
+	Title          string   `json:"title,omitempty"`
+	Description    string   `json:"description"`
+	RequiredScopes []string `json:"requiredScopes"`
+	// Optional claim constraints evaluated alongside scopes: a string must equal the claim (or be
+	// contained in an array claim); an array matches if any of its values does
+	RequiredClaims map[string]interface{} `json:"requiredClaims,omitempty"`
 	Mapping        RequestTemplate        `json:"mapping"`
 	InputSchema    map[string]interface{} `json:"inputSchema,omitempty"`
 	OutputSchema   map[string]interface{} `json:"outputSchema,omitempty"`
+	// Optional; nil means enabled so existing definitions keep working
+	Enabled *bool `json:"enabled,omitempty"`
+	// Elicit asks the client for missing required arguments instead of failing the call
+	Elicit bool `json:"elicit,omitempty"`
+	// SkipMethodScopes opts the tool out of the server's MethodScopes policy
+	SkipMethodScopes bool `json:"skipMethodScopes,omitempty"`
+	// Optional MCP behavior hints; unset fields are derived from the mapping's HTTP method
+	Annotations *ToolAnnotations `json:"annotations,omitempty"`
+	// Optional translations of Title and Description keyed by language tag
+	LocalizedTitles       map[string]string `json:"localizedTitles,omitempty"`
+	LocalizedDescriptions map[string]string `json:"localizedDescriptions,omitempty"`
+	// Optional argument names whose values are redacted in logs, audit entries and error
+	// data; input schema properties marked "x-sensitive": true are redacted as well
+	SensitiveArgs []string `json:"sensitiveArgs,omitempty"`
+	// Optional argument values used when the caller omits them, e.g. {"pageSize": 50}. They
+	// take precedence over input schema defaults and are converted to the schema's types.
+	Defaults map[string]interface{} `json:"defaults,omitempty"`
+	// Optional further names tools/call accepts, e.g. the tool's former names after a
+	// rename; tools/list only advertises Name. Unique across the server's names and aliases.
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// Redacted replaces sensitive argument values.
+const Redacted = "***"
+
+// IsSensitiveArg reports whether the value of argument name must not be logged.
+func (t Tool) IsSensitiveArg(name string) bool {
+	for _, s := range t.SensitiveArgs {
+		if s == name {
+			return true
+		}
+	}
+	props, _ := t.InputSchema["properties"].(map[string]interface{})
+	prop, _ := props[name].(map[string]interface{})
+	sensitive, _ := prop["x-sensitive"].(bool)
+	return sensitive
+}
+
+// RedactArgs returns a copy of args with sensitive values replaced by Redacted.
+func (t Tool) RedactArgs(args map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if t.IsSensitiveArg(k) {
+			v = Redacted
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// IsEnabled reports whether the tool may be listed and called.
+func (t Tool) IsEnabled() bool {
+	return t.Enabled == nil || *t.Enabled
+}
+
+// ToolAnnotations are the MCP tool hints clients use to decide e.g. on auto-approval.
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    *bool  `json:"readOnlyHint,omitempty"`
+	DestructiveHint *bool  `json:"destructiveHint,omitempty"`
+	IdempotentHint  *bool  `json:"idempotentHint,omitempty"`
+	OpenWorldHint   *bool  `json:"openWorldHint,omitempty"`
+}
+
+// EffectiveAnnotations fills unset hints from the HTTP method: safe methods are read-only
+// and idempotent, PUT and DELETE are idempotent but destructive, POST and PATCH are
+// neither. Upstreams are external, so openWorldHint defaults to true.
+func (t Tool) EffectiveAnnotations() ToolAnnotations {
+	var a ToolAnnotations
+	if t.Annotations != nil {
+		a = *t.Annotations
+	}
+	readOnly, destructive, idempotent := false, true, false
+	switch strings.ToUpper(t.Mapping.Method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		readOnly, destructive, idempotent = true, false, true
+	case http.MethodPut, http.MethodDelete:
+		idempotent = true
+	}
+	if t.Mapping.Type == "graphql" {
+		readOnly, destructive, idempotent = false, true, false
+	}
+	if a.ReadOnlyHint == nil {
+		a.ReadOnlyHint = &readOnly
+	}
+	if a.DestructiveHint == nil {
+		a.DestructiveHint = &destructive
+	}
+	if a.IdempotentHint == nil {
+		a.IdempotentHint = &idempotent
+	}
+	if a.OpenWorldHint == nil {
+		openWorld := true
+		a.OpenWorldHint = &openWorld
+	}
+	return a
+}
+
+// ErrToolNotFound is returned when a named tool does not exist on the server.
+var ErrToolNotFound = errors.New("tool not found")
+
+// ErrToolNameConflict is returned when a tool name or alias is used twice on a server.
+var ErrToolNameConflict = errors.New("tool name or alias used more than once")
+
+// toolNameConflicts returns the names and aliases that more than one tool of a server
+// claims, or one tool claims twice.
+func toolNameConflicts(tools []Tool) []string {
+	owner := map[string]int{}
+	conflicts := []string{}
+	for i, t := range tools {
+		for _, name := range append([]string{t.Name}, t.Aliases...) {
+			if prev, ok := owner[name]; ok {
+				if prev != -1 {
+					conflicts = append(conflicts, name)
+					owner[name] = -1
+				}
+				continue
+			}
+			owner[name] = i
+		}
+	}
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// checkToolNames wraps ErrToolNameConflict with the conflicting names, if any.
+func checkToolNames(tools []Tool) error {
+	if conflicts := toolNameConflicts(tools); len(conflicts) > 0 {
+		return fmt.Errorf("%w: %s", ErrToolNameConflict, strings.Join(conflicts, ", "))
+	}
+	return nil
 }
 
 type RequestTemplate struct {
-	Method  string                 `json:"method"`
-	Path    string                 `json:"path"`
-	Query   map[string]string      `json:"query,omitempty"`
-	Headers map[string]string      `json:"headers,omitempty"`
-	Body    map[string]interface{} `json:"body,omitempty"`
+	// Optional; "rest" (default) or "graphql". GraphQL mappings POST GraphQLQuery to Path
+	// with the tool arguments as variables; Method, Query and Body are ignored.
+	Type         string                 `json:"type,omitempty"`
+	GraphQLQuery string                 `json:"graphqlQuery,omitempty"`
+	Method       string                 `json:"method"`
+	Path         string                 `json:"path"`
+	Query        map[string]string      `json:"query,omitempty"`
+	Headers      map[string]string      `json:"headers,omitempty"`
+	Body         map[string]interface{} `json:"body,omitempty"`
+	// Optional; one of "json" (default), "form" or "multipart"
+	BodyEncoding string `json:"bodyEncoding,omitempty"`
+	// Optional; when > 0 successful GET responses are cached for this many seconds
+	CacheTTLSeconds int `json:"cacheTTLSeconds,omitempty"`
+	// Optional override of the server's RedirectPolicy
+	RedirectPolicy string `json:"redirectPolicy,omitempty"`
+	// CompressRequest gzips request bodies of 1 KiB or more (Content-Encoding: gzip)
+	CompressRequest bool `json:"compressRequest,omitempty"`
+	// Stream relays the upstream response to the client as it arrives instead of buffering
+	// it; ignored for GraphQL tools and tools with an OutputSchema or a ResponseMapping
+	Stream bool `json:"stream,omitempty"`
+	// Optional response headers passed through in addition to the server's ResponseHeaders
+	ResponseHeaders []string `json:"responseHeaders,omitempty"`
+	// Optional expressions evaluated in order before templating, each setting one argument
+	Transforms []ArgTransform `json:"transforms,omitempty"`
+	// Optional projection of successful JSON responses: output field name to a dotted path
+	// into the upstream body, e.g. {"id": "data.order.id", "skus": "data.items.*.sku"}
+	ResponseMapping map[string]string `json:"responseMapping,omitempty"`
+}
+
+// ArgTransform sets argument Arg to the value of Expr, an expression over the arguments
+// such as `first + " " + last` or `pageSize ?? 50`. A null value removes the argument.
+type ArgTransform struct {
+	Arg  string `json:"arg"`
+	Expr string `json:"expr"`
 }
 
 type MemoryStore struct {
@@ -53,6 +387,8 @@ type MemoryStore struct {
 	tenants          map[string]Tenant
 	servers          map[string]Server
 	toolsByServer    map[string][]Tool
+	apiKeys          map[string]APIKey
+	adminTokens      map[string]AdminToken
 }
 
 func NewMemoryStore(resourceAudience string) *MemoryStore {
@@ -61,6 +397,8 @@ func NewMemoryStore(resourceAudience string) *MemoryStore {
 		tenants:          make(map[string]Tenant),
 		servers:          make(map[string]Server),
 		toolsByServer:    make(map[string][]Tool),
+		apiKeys:          make(map[string]APIKey),
+		adminTokens:      make(map[string]AdminToken),
 	}
 }
 
@@ -78,7 +416,7 @@ func (s *MemoryStore) GetTenant(slug string) (Tenant, error) {
 	defer s.mu.RUnlock()
 	t, ok := s.tenants[slug]
 	if !ok {
-		return Tenant{}, errors.New("tenant not found")
+		return Tenant{}, ErrTenantNotFound
 	}
 	return t, nil
 }
@@ -100,13 +438,43 @@ func (s *MemoryStore) GetServer(slug string) (Server, error) {
 	return srv, nil
 }
 
+func (s *MemoryStore) GetServerWithTenant(slug string) (Server, Tenant, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	srv, ok := s.servers[slug]
+	if !ok {
+		return Server{}, Tenant{}, errors.New("server not found")
+	}
+	t, ok := s.tenants[srv.TenantSlug]
+	if !ok {
+		return Server{}, Tenant{}, fmt.Errorf("server %s: %w: %s", slug, ErrTenantNotFound, srv.TenantSlug)
+	}
+	return srv, t, nil
+}
+
 func (s *MemoryStore) UpsertToolsForServer(serverSlug string, tools []Tool) error {
+	if err := checkToolNames(tools); err != nil {
+		return err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.toolsByServer[serverSlug] = tools
 	return nil
 }
 
+// UpsertServerBundle stores srv and replaces its tools under one lock, after checking the
+// tools, so readers never see one without the other.
+func (s *MemoryStore) UpsertServerBundle(srv Server, tools []Tool) error {
+	if err := checkToolNames(tools); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.servers[srv.Slug] = srv
+	s.toolsByServer[srv.Slug] = tools
+	return nil
+}
+
 func (s *MemoryStore) ListToolsByServer(serverSlug string) ([]Tool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -114,7 +482,100 @@ func (s *MemoryStore) ListToolsByServer(serverSlug string) ([]Tool, error) {
 	if !ok {
 		return []Tool{}, nil
 	}
-	return tools, nil
+	out := make([]Tool, 0, len(tools))
+	for _, t := range tools {
+		if t.IsEnabled() {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// ListToolDefinitions returns every tool of a server, including disabled ones, for the control plane.
+func (s *MemoryStore) ListToolDefinitions(serverSlug string) ([]Tool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Tool, len(s.toolsByServer[serverSlug]))
+	copy(out, s.toolsByServer[serverSlug])
+	return out, nil
+}
+
+func (s *MemoryStore) ListToolsByServerPaged(serverSlug string, limit, offset int, nameFilter string) ([]Tool, int, error) {
+	all, err := s.ListToolsByServer(serverSlug)
+	if err != nil {
+		return nil, 0, err
+	}
+	matched := make([]Tool, 0, len(all))
+	for _, t := range all {
+		if strings.HasPrefix(t.Name, nameFilter) {
+			matched = append(matched, t)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Name < matched[j].Name })
+	total := len(matched)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= total {
+		return []Tool{}, total, nil
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return matched[offset:end], total, nil
+}
+
+// SetToolsEnabled flips the enabled flag of the named tools, leaving their definitions
+// untouched. Nothing changes if any name is unknown.
+func (s *MemoryStore) SetToolsEnabled(serverSlug string, names []string, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tools := s.toolsByServer[serverSlug]
+	index := make(map[string]int, len(tools))
+	for i, t := range tools {
+		index[t.Name] = i
+	}
+	for _, name := range names {
+		if _, ok := index[name]; !ok {
+			return fmt.Errorf("%w: %s", ErrToolNotFound, name)
+		}
+	}
+	// Copy so slices handed out by earlier reads are not mutated
+	updated := make([]Tool, len(tools))
+	copy(updated, tools)
+	for _, name := range names {
+		e := enabled
+		updated[index[name]].Enabled = &e
+	}
+	s.toolsByServer[serverSlug] = updated
+	return nil
+}
+
+// GetToolByName returns the enabled tool named name, which is how tools/list advertises
+// it, or one of its aliases.
+func (s *MemoryStore) GetToolByName(serverSlug, name string) (Tool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, t := range s.toolsByServer[serverSlug] {
+		if t.IsEnabled() && t.HasName(name) {
+			return t, nil
+		}
+	}
+	return Tool{}, ErrToolNotFound
+}
+
+// HasName reports whether name is the tool's name or one of its aliases.
+func (t Tool) HasName(name string) bool {
+	if t.Name == name {
+		return true
+	}
+	for _, a := range t.Aliases {
+		if a == name {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *MemoryStore) GetTool(serverSlug, toolID string) (Tool, bool) {
@@ -125,7 +586,7 @@ func (s *MemoryStore) GetTool(serverSlug, toolID string) (Tool, bool) {
 		return Tool{}, false
 	}
 	for _, t := range tools {
-		if t.ID == toolID {
+		if t.ID == toolID && t.IsEnabled() {
 			return t, true
 		}
 	}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestListToolsByServerPaged(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s backend, slug func(string) string) {
+		server := seedServer(t, s, slug)
+		var tools []Tool
+		for i := 0; i < 12; i++ {
+			tools = append(tools, getTool(fmt.Sprintf("order_%02d", i), "/orders"))
+		}
+		tools = append(tools, getTool("refund", "/refunds"), getTool("customer", "/customers"))
+		off := false
+		disabled := getTool("order_zz", "/orders")
+		disabled.Enabled = &off
+		if err := s.UpsertToolsForServer(server, append(tools, disabled)); err != nil {
+			t.Fatal(err)
+		}
+
+		page, total, err := s.ListToolsByServerPaged(server, 5, 0, "")
+		if err != nil || total != 14 {
+			t.Fatalf("total %d, err %v, want 14 enabled tools", total, err)
+		}
+		if want := []string{"customer", "order_00", "order_01", "order_02", "order_03"}; !reflect.DeepEqual(toolNames(page), want) {
+			t.Fatalf("first page %v, want %v", toolNames(page), want)
+		}
+		page, _, _ = s.ListToolsByServerPaged(server, 5, 10, "")
+		if want := []string{"order_09", "order_10", "order_11", "refund"}; !reflect.DeepEqual(toolNames(page), want) {
+			t.Fatalf("last page %v, want %v", toolNames(page), want)
+		}
+		page, total, _ = s.ListToolsByServerPaged(server, 5, 20, "")
+		if len(page) != 0 || total != 14 {
+			t.Fatalf("page past the end: %v of %d", toolNames(page), total)
+		}
+
+		page, total, _ = s.ListToolsByServerPaged(server, 5, 5, "order_")
+		if total != 12 || !reflect.DeepEqual(toolNames(page), []string{"order_05", "order_06", "order_07", "order_08", "order_09"}) {
+			t.Fatalf("filtered page %v of %d, want order_05..09 of 12", toolNames(page), total)
+		}
+		if page, total, _ = s.ListToolsByServerPaged(server, 5, 0, "nothing"); len(page) != 0 || total != 0 {
+			t.Fatalf("unmatched filter: %v of %d", toolNames(page), total)
+		}
+	})
+}
@@ -4,38 +4,203 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type PostgresStore struct {
 	db               *sql.DB
 	resourceAudience string
+	// Optional cache of tenant, server and tool reads; nil reads the database every time
+	cache *lookupCache
 }
 
 func NewPostgresStore(db *sql.DB, resourceAudience string) *PostgresStore {
 	return &PostgresStore{db: db, resourceAudience: resourceAudience}
 }
 
+// SetLookupCache caches tenant, server and enabled-tool reads for ttl, holding at most
+// maxEntries (<= 0 means unbounded). Writes through this store invalidate the affected
+// entries; writes from other gateway replicas are seen once entries expire. ttl <= 0
+// disables the cache. Call it before the store is shared.
+func (p *PostgresStore) SetLookupCache(ttl time.Duration, maxEntries int) {
+	if ttl <= 0 {
+		p.cache = nil
+		return
+	}
+	p.cache = newLookupCache(ttl, maxEntries)
+}
+
 func (p *PostgresStore) ResourceAudience() string { return p.resourceAudience }
 
+const tenantColumns = `t.slug, coalesce(t.name,''), coalesce(t.enabled,true), coalesce(t.egress_allowlist,'[]'::jsonb), coalesce(t.allowed_issuers,'[]'::jsonb), coalesce(t.scope_claims,'[]'::jsonb)`
+
+// tenantRow receives tenantColumns.
+type tenantRow struct {
+	t                                       Tenant
+	allowJSON, issuersJSON, scopeClaimsJSON []byte
+}
+
+func (r *tenantRow) dest() []interface{} {
+	return []interface{}{&r.t.Slug, &r.t.Name, &r.t.Enabled, &r.allowJSON, &r.issuersJSON, &r.scopeClaimsJSON}
+}
+
+func (r *tenantRow) tenant() Tenant {
+	t := r.t
+	_ = jsonUnmarshal(r.scopeClaimsJSON, &t.ScopeClaims)
+	t.EgressAllowlist = []string{}
+	_ = jsonUnmarshal(r.allowJSON, &t.EgressAllowlist)
+	t.AllowedIssuers = []string{}
+	_ = jsonUnmarshal(r.issuersJSON, &t.AllowedIssuers)
+	return t
+}
+
 func (p *PostgresStore) GetTenant(slug string) (Tenant, error) {
-	var t Tenant
-	var allowJSON []byte
+	v, err := p.cache.get(keyTenant+slug, func() (interface{}, error) { return p.getTenant(slug) })
+	if err != nil {
+		return Tenant{}, err
+	}
+	return v.(Tenant), nil
+}
+
+func (p *PostgresStore) getTenant(slug string) (Tenant, error) {
+	var tr tenantRow
 	row := p.db.QueryRowContext(context.Background(), `
-        select slug, coalesce(name,''), coalesce(enabled,true), coalesce(egress_allowlist,'[]'::jsonb)
-        from tenants where slug=$1
+        select `+tenantColumns+`
+        from tenants t where t.slug=$1
     `, slug)
-	if err := row.Scan(&t.Slug, &t.Name, &t.Enabled, &allowJSON); err != nil {
+	if err := row.Scan(tr.dest()...); err != nil {
 		return Tenant{}, err
 	}
-	t.EgressAllowlist = []string{}
-	_ = jsonUnmarshal(allowJSON, &t.EgressAllowlist)
-	return t, nil
+	return tr.tenant(), nil
 }
 
 func (p *PostgresStore) GetServer(slug string) (Server, error) {
-	var s Server
+	v, err := p.cache.get(keyServer+slug, func() (interface{}, error) { return p.getServer(slug) })
+	if err != nil {
+		return Server{}, err
+	}
+	return v.(Server), nil
+}
+
+func (p *PostgresStore) getServer(slug string) (Server, error) {
+	row := p.db.QueryRowContext(context.Background(), `
+        select `+serverColumns+`
+        from servers s
+        join tenants t on t.id = s.tenant_id
+        where s.slug=$1
+    `, slug)
+	return scanServer(row)
+}
+
+// GetServerWithTenant reads the server and its tenant in one query. The tenant foreign
+// key makes a missing tenant impossible here, so no row means the server does not exist.
+func (p *PostgresStore) GetServerWithTenant(slug string) (Server, Tenant, error) {
+	type pair struct {
+		srv Server
+		t   Tenant
+	}
+	v, err := p.cache.get(keyPair+slug, func() (interface{}, error) {
+		srv, t, err := p.getServerWithTenant(slug)
+		return pair{srv, t}, err
+	})
+	if err != nil {
+		return Server{}, Tenant{}, err
+	}
+	return v.(pair).srv, v.(pair).t, nil
+}
+
+func (p *PostgresStore) getServerWithTenant(slug string) (Server, Tenant, error) {
+	var tr tenantRow
 	row := p.db.QueryRowContext(context.Background(), `
-        select s.slug,
+        select `+serverColumns+`, `+tenantColumns+`
+        from servers s
+        join tenants t on t.id = s.tenant_id
+        where s.slug=$1
+    `, slug)
+	srv, err := scanServer(withTrailing(row, tr.dest()...))
+	if err != nil {
+		return Server{}, Tenant{}, err
+	}
+	return srv, tr.tenant(), nil
+}
+
+// ListServers returns every server ordered by slug.
+func (p *PostgresStore) ListServers() ([]Server, error) {
+	rows, err := p.db.QueryContext(context.Background(), `
+        select `+serverColumns+`
+        from servers s
+        join tenants t on t.id = s.tenant_id
+        order by s.slug
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Server{}
+	for rows.Next() {
+		srv, err := scanServer(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, srv)
+	}
+	return out, rows.Err()
+}
+
+// ListServersByTag returns the servers carrying tag, or every server when tag is empty,
+// ordered by slug.
+func (p *PostgresStore) ListServersByTag(tag string) ([]Server, error) {
+	rows, err := p.db.QueryContext(context.Background(), `
+        select `+serverColumns+`
+        from servers s
+        join tenants t on t.id = s.tenant_id
+        where $1 = '' or s.tags ? $1
+        order by s.slug
+    `, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Server{}
+	for rows.Next() {
+		srv, err := scanServer(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, srv)
+	}
+	return out, rows.Err()
+}
+
+// ListServersByTenant returns all servers of a tenant ordered by slug.
+func (p *PostgresStore) ListServersByTenant(tenantSlug string) ([]Server, error) {
+	rows, err := p.db.QueryContext(context.Background(), `
+        select `+serverColumns+`
+        from servers s
+        join tenants t on t.id = s.tenant_id
+        where t.slug=$1
+        order by s.slug
+    `, tenantSlug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Server{}
+	for rows.Next() {
+		srv, err := scanServer(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, srv)
+	}
+	return out, rows.Err()
+}
+
+const serverColumns = `s.slug,
                t.slug as tenant_slug,
                s.name,
                s.audience,
@@ -43,21 +208,101 @@ func (p *PostgresStore) GetServer(slug string) (Server, error) {
                s.upstream_base_url,
                coalesce(s.server_title,''),
                coalesce(s.server_version,''),
-               coalesce(s.instructions,'')
-        from servers s
-        join tenants t on t.id = s.tenant_id
-        where s.slug=$1
-    `, slug)
-	if err := row.Scan(&s.Slug, &s.TenantSlug, &s.Name, &s.Audience, &s.Enabled, &s.UpstreamBaseURL, &s.ServerTitle, &s.ServerVersion, &s.Instructions); err != nil {
+               coalesce(s.instructions,''),
+               s.capabilities,
+               coalesce(s.allowed_issuers,'[]'::jsonb),
+               coalesce(s.upstream_base_urls,'[]'::jsonb),
+               coalesce(s.load_balancing,''),
+               coalesce(s.upstream_weights,'[]'::jsonb),
+               coalesce(s.method_scopes,'{}'::jsonb),
+               coalesce(s.redirect_policy,''),
+               coalesce(s.backend,'http'),
+               coalesce(s.stdio_command,'[]'::jsonb),
+               coalesce(s.localized_instructions,'{}'::jsonb),
+               coalesce(s.audiences,'[]'::jsonb),
+               coalesce(s.claim_header_mappings,'{}'::jsonb),
+               s.health_check,
+               coalesce(s.response_headers,'[]'::jsonb),
+               coalesce(s.scope_claims,'[]'::jsonb),
+               s.timeouts,
+               coalesce(s.custom_methods,'[]'::jsonb),
+               coalesce(s.custom_methods_path,''),
+               coalesce(s.tags,'[]'::jsonb)`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// trailingScanner scans extra columns that follow the ones its caller asks for.
+type trailingScanner struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+func (t trailingScanner) Scan(dest ...interface{}) error {
+	return t.row.Scan(append(dest, t.extra...)...)
+}
+
+func withTrailing(row rowScanner, extra ...interface{}) rowScanner {
+	return trailingScanner{row: row, extra: extra}
+}
+
+func scanServer(row rowScanner) (Server, error) {
+	var s Server
+	var capsJSON, issuersJSON, upstreamsJSON, weightsJSON, methodScopesJSON, stdioJSON, instructionsJSON, audiencesJSON, claimHeadersJSON, healthJSON, responseHeadersJSON, scopeClaimsJSON, timeoutsJSON, customMethodsJSON, tagsJSON []byte
+	if err := row.Scan(&s.Slug, &s.TenantSlug, &s.Name, &s.Audience, &s.Enabled, &s.UpstreamBaseURL, &s.ServerTitle, &s.ServerVersion, &s.Instructions, &capsJSON, &issuersJSON, &upstreamsJSON, &s.LoadBalancing, &weightsJSON, &methodScopesJSON, &s.RedirectPolicy, &s.Backend, &stdioJSON, &instructionsJSON, &audiencesJSON, &claimHeadersJSON, &healthJSON, &responseHeadersJSON, &scopeClaimsJSON, &timeoutsJSON, &customMethodsJSON, &s.CustomMethodsPath, &tagsJSON); err != nil {
 		return Server{}, err
 	}
+	_ = jsonUnmarshal(instructionsJSON, &s.LocalizedInstructions)
+	_ = jsonUnmarshal(audiencesJSON, &s.Audiences)
+	_ = jsonUnmarshal(claimHeadersJSON, &s.ClaimHeaderMappings)
+	_ = jsonUnmarshal(responseHeadersJSON, &s.ResponseHeaders)
+	_ = jsonUnmarshal(scopeClaimsJSON, &s.ScopeClaims)
+	_ = jsonUnmarshal(customMethodsJSON, &s.CustomMethods)
+	_ = jsonUnmarshal(tagsJSON, &s.Tags)
+	_ = jsonUnmarshal(stdioJSON, &s.StdioCommand)
+	_ = jsonUnmarshal(methodScopesJSON, &s.MethodScopes)
+	_ = jsonUnmarshal(weightsJSON, &s.UpstreamWeights)
+	_ = jsonUnmarshal(issuersJSON, &s.AllowedIssuers)
+	_ = jsonUnmarshal(upstreamsJSON, &s.UpstreamBaseURLs)
+	if len(capsJSON) > 0 && string(capsJSON) != "null" {
+		var caps ServerCapabilities
+		if err := jsonUnmarshal(capsJSON, &caps); err == nil {
+			s.Capabilities = &caps
+		}
+	}
+	if len(healthJSON) > 0 && string(healthJSON) != "null" {
+		var hc HealthCheck
+		if err := jsonUnmarshal(healthJSON, &hc); err == nil {
+			s.HealthCheck = &hc
+		}
+	}
+	if len(timeoutsJSON) > 0 && string(timeoutsJSON) != "null" {
+		var to UpstreamTimeouts
+		if err := jsonUnmarshal(timeoutsJSON, &to); err == nil {
+			s.Timeouts = &to
+		}
+	}
 	return s, nil
 }
 
 func (p *PostgresStore) ListToolsByServer(serverSlug string) ([]Tool, error) {
+	v, err := p.cache.get(toolsKey(serverSlug, "all"), func() (interface{}, error) { return p.listToolsByServer(serverSlug) })
+	if err != nil {
+		return nil, err
+	}
+	return v.([]Tool), nil
+}
+
+// toolsKey is the cache key of one tool read of a server; see invalidateServer.
+func toolsKey(serverSlug string, parts ...string) string {
+	return keyTools + serverSlug + "\x00" + strings.Join(parts, "\x00")
+}
+
+func (p *PostgresStore) listToolsByServer(serverSlug string) ([]Tool, error) {
 	rows, err := p.db.QueryContext(context.Background(), `
-        select id, name, coalesce(title,''), coalesce(description,''), coalesce(required_scopes,'{}')::jsonb, coalesce(input_schema,'{}')::jsonb, coalesce(output_schema,'{}')::jsonb,
-               method, path, coalesce(query,'{}')::jsonb, coalesce(headers,'{}')::jsonb, coalesce(body,'{}')::jsonb
+        select `+toolColumns+`
         from tools_with_mappings
         where server_slug=$1 and enabled=true
         order by name
@@ -66,13 +311,133 @@ func (p *PostgresStore) ListToolsByServer(serverSlug string) ([]Tool, error) {
 		return nil, err
 	}
 	defer rows.Close()
+	return scanTools(rows)
+}
+
+// GetToolByName returns the enabled tool named name, or having it as an alias; tool ids
+// are UUIDs and differ from the names tools/list advertises.
+func (p *PostgresStore) GetToolByName(serverSlug, name string) (Tool, error) {
+	v, err := p.cache.get(toolsKey(serverSlug, "name", name), func() (interface{}, error) { return p.getToolByName(serverSlug, name) })
+	if err != nil {
+		return Tool{}, err
+	}
+	return v.(Tool), nil
+}
+
+func (p *PostgresStore) getToolByName(serverSlug, name string) (Tool, error) {
+	rows, err := p.db.QueryContext(context.Background(), `
+        select `+toolColumns+`
+        from tools_with_mappings
+        where server_slug=$1 and enabled=true and (name=$2 or aliases ? $2)
+        order by name=$2 desc
+        limit 1
+    `, serverSlug, name)
+	if err != nil {
+		return Tool{}, err
+	}
+	defer rows.Close()
+	tools, err := scanTools(rows)
+	if err != nil {
+		return Tool{}, err
+	}
+	if len(tools) == 0 {
+		return Tool{}, ErrToolNotFound
+	}
+	return tools[0], nil
+}
+
+// ListToolDefinitions returns every tool of a server, including disabled ones, for the control plane.
+func (p *PostgresStore) ListToolDefinitions(serverSlug string) ([]Tool, error) {
+	rows, err := p.db.QueryContext(context.Background(), `
+        select `+toolColumns+`
+        from tools_with_mappings
+        where server_slug=$1
+        order by name
+    `, serverSlug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanTools(rows)
+}
+
+func (p *PostgresStore) ListToolsByServerPaged(serverSlug string, limit, offset int, nameFilter string) ([]Tool, int, error) {
+	type page struct {
+		tools []Tool
+		total int
+	}
+	v, err := p.cache.get(toolsKey(serverSlug, "page", strconv.Itoa(limit), strconv.Itoa(offset), nameFilter), func() (interface{}, error) {
+		tools, total, err := p.listToolsByServerPaged(serverSlug, limit, offset, nameFilter)
+		return page{tools, total}, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return v.(page).tools, v.(page).total, nil
+}
+
+func (p *PostgresStore) listToolsByServerPaged(serverSlug string, limit, offset int, nameFilter string) ([]Tool, int, error) {
+	var total int
+	if err := p.db.QueryRowContext(context.Background(), `
+        select count(*) from tools_with_mappings
+        where server_slug=$1 and enabled=true and starts_with(name, $2)
+    `, serverSlug, nameFilter).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	// limit <= 0 means no limit; postgres treats LIMIT NULL as unbounded
+	var lim interface{}
+	if limit > 0 {
+		lim = limit
+	}
+	rows, err := p.db.QueryContext(context.Background(), `
+        select `+toolColumns+`
+        from tools_with_mappings
+        where server_slug=$1 and enabled=true and starts_with(name, $2)
+        order by name
+        limit $3 offset $4
+    `, serverSlug, nameFilter, lim, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	tools, err := scanTools(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tools, total, nil
+}
+
+const toolColumns = `id, name, coalesce(title,''), coalesce(description,''), coalesce(required_scopes,'{}')::jsonb, coalesce(input_schema,'{}')::jsonb, coalesce(output_schema,'{}')::jsonb,
+               method, path, coalesce(query,'{}')::jsonb, coalesce(headers,'{}')::jsonb, coalesce(body,'{}')::jsonb, enabled, coalesce(cache_ttl_seconds,0), coalesce(body_encoding,'json'), coalesce(required_claims,'{}'::jsonb), coalesce(skip_method_scopes,false), coalesce(redirect_policy,''), coalesce(compress_request,false), coalesce(mapping_type,'rest'), coalesce(graphql_query,''), annotations, coalesce(localized_titles,'{}'::jsonb), coalesce(localized_descriptions,'{}'::jsonb), coalesce(stream,false), coalesce(sensitive_args,'[]'::jsonb), coalesce(response_headers,'[]'::jsonb), coalesce(defaults,'{}'::jsonb), coalesce(transforms,'[]'::jsonb), coalesce(aliases,'[]'::jsonb), coalesce(response_mapping,'{}'::jsonb)`
+
+func scanTools(rows *sql.Rows) ([]Tool, error) {
 	out := []Tool{}
 	for rows.Next() {
 		var t Tool
-		var scopesJSON, inJSON, outJSON, qJSON, hJSON, bJSON []byte
-		if err := rows.Scan(&t.ID, &t.Name, &t.Title, &t.Description, &scopesJSON, &inJSON, &outJSON, &t.Mapping.Method, &t.Mapping.Path, &qJSON, &hJSON, &bJSON); err != nil {
+		var scopesJSON, inJSON, outJSON, qJSON, hJSON, bJSON, claimsJSON, annotationsJSON, titlesJSON, descriptionsJSON, sensitiveJSON, responseHeadersJSON, defaultsJSON, transformsJSON, aliasesJSON, responseMappingJSON []byte
+		var enabled bool
+		if err := rows.Scan(&t.ID, &t.Name, &t.Title, &t.Description, &scopesJSON, &inJSON, &outJSON, &t.Mapping.Method, &t.Mapping.Path, &qJSON, &hJSON, &bJSON, &enabled, &t.Mapping.CacheTTLSeconds, &t.Mapping.BodyEncoding, &claimsJSON, &t.SkipMethodScopes, &t.Mapping.RedirectPolicy, &t.Mapping.CompressRequest, &t.Mapping.Type, &t.Mapping.GraphQLQuery, &annotationsJSON, &titlesJSON, &descriptionsJSON, &t.Mapping.Stream, &sensitiveJSON, &responseHeadersJSON, &defaultsJSON, &transformsJSON, &aliasesJSON, &responseMappingJSON); err != nil {
 			return nil, err
 		}
+		_ = jsonUnmarshal(titlesJSON, &t.LocalizedTitles)
+		_ = jsonUnmarshal(descriptionsJSON, &t.LocalizedDescriptions)
+		_ = jsonUnmarshal(sensitiveJSON, &t.SensitiveArgs)
+		_ = jsonUnmarshal(responseHeadersJSON, &t.Mapping.ResponseHeaders)
+		_ = jsonUnmarshal(defaultsJSON, &t.Defaults)
+		_ = jsonUnmarshal(transformsJSON, &t.Mapping.Transforms)
+		_ = jsonUnmarshal(aliasesJSON, &t.Aliases)
+		_ = jsonUnmarshal(responseMappingJSON, &t.Mapping.ResponseMapping)
+		if len(annotationsJSON) > 0 && string(annotationsJSON) != "null" {
+			var a ToolAnnotations
+			if err := jsonUnmarshal(annotationsJSON, &a); err == nil {
+				t.Annotations = &a
+			}
+		}
+		_ = jsonUnmarshal(claimsJSON, &t.RequiredClaims)
+		t.Enabled = &enabled
 		// Decode JSON columns into maps
 		t.RequiredScopes = []string{}
 		if len(scopesJSON) > 0 && string(scopesJSON) != "null" {
@@ -98,6 +463,31 @@ func (p *PostgresStore) ListToolsByServer(serverSlug string) ([]Tool, error) {
 }
 
 // helpers
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// nonNil keeps nil slices from being stored as JSON null.
+func nonNil(v []string) []string {
+	if v == nil {
+		return []string{}
+	}
+	return v
+}
+
+// nonNilMap keeps nil maps from being stored as JSON null.
+func nonNilMap(v map[string]string) map[string]string {
+	if v == nil {
+		return map[string]string{}
+	}
+	return v
+}
+
 func jsonUnmarshal(b []byte, v interface{}) error {
 	if len(b) == 0 || string(b) == "null" {
 		return nil
@@ -109,20 +499,79 @@ var _ Store = (*PostgresStore)(nil)
 
 // --- Write methods for control plane ---
 
+// dbtx is satisfied by both *sql.DB and *sql.Tx so write helpers can run inside a transaction.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
 func (p *PostgresStore) UpsertTenant(t Tenant) error {
-	allowJSON, _ := json.Marshal(t.EgressAllowlist)
-	_, err := p.db.ExecContext(context.Background(), `
-        insert into tenants (slug, name, enabled, egress_allowlist)
-        values ($1,$2,$3,$4::jsonb)
-        on conflict (slug) do update set name=excluded.name, enabled=excluded.enabled, egress_allowlist=excluded.egress_allowlist
-    `, t.Slug, t.Name, t.Enabled, string(allowJSON))
+	defer p.cache.invalidate([]string{keyTenant + t.Slug}, keyPair)
+	return upsertTenant(context.Background(), p.db, t)
+}
+
+func upsertTenant(ctx context.Context, q dbtx, t Tenant) error {
+	allowJSON, _ := json.Marshal(nonNil(t.EgressAllowlist))
+	issuersJSON, _ := json.Marshal(nonNil(t.AllowedIssuers))
+	scopeClaimsJSON, _ := json.Marshal(nonNil(t.ScopeClaims))
+	_, err := q.ExecContext(ctx, `
+        insert into tenants (slug, name, enabled, egress_allowlist, allowed_issuers, scope_claims)
+        values ($1,$2,$3,$4::jsonb,$5::jsonb,$6::jsonb)
+        on conflict (slug) do update set name=excluded.name, enabled=excluded.enabled, egress_allowlist=excluded.egress_allowlist, allowed_issuers=excluded.allowed_issuers, scope_claims=excluded.scope_claims
+    `, t.Slug, t.Name, t.Enabled, string(allowJSON), string(issuersJSON), string(scopeClaimsJSON))
 	return err
 }
 
 func (p *PostgresStore) UpsertServer(s Server) error {
-	_, err := p.db.ExecContext(context.Background(), `
-        insert into servers (tenant_id, slug, name, audience, enabled, upstream_base_url, server_title, server_version, instructions)
-        values ((select id from tenants where slug=$1), $2,$3,$4,$5,$6,$7,$8,$9)
+	defer p.invalidateServer(s.Slug)
+	return upsertServer(context.Background(), p.db, s)
+}
+
+// invalidateServer drops the cached server, its tenant pairing and all its tool reads.
+func (p *PostgresStore) invalidateServer(slug string) {
+	p.cache.invalidate([]string{keyServer + slug, keyPair + slug}, toolsKey(slug))
+}
+
+func upsertServer(ctx context.Context, q dbtx, s Server) error {
+	var capsJSON interface{}
+	if s.Capabilities != nil {
+		b, _ := json.Marshal(s.Capabilities)
+		capsJSON = string(b)
+	}
+	var healthJSON interface{}
+	if s.HealthCheck != nil {
+		b, _ := json.Marshal(s.HealthCheck)
+		healthJSON = string(b)
+	}
+	var timeoutsJSON interface{}
+	if s.Timeouts != nil {
+		b, _ := json.Marshal(s.Timeouts)
+		timeoutsJSON = string(b)
+	}
+	issuersJSON, _ := json.Marshal(nonNil(s.AllowedIssuers))
+	upstreamsJSON, _ := json.Marshal(nonNil(s.UpstreamBaseURLs))
+	weights := s.UpstreamWeights
+	if weights == nil {
+		weights = []int{}
+	}
+	weightsJSON, _ := json.Marshal(weights)
+	methodScopes := s.MethodScopes
+	if methodScopes == nil {
+		methodScopes = map[string][]string{}
+	}
+	methodScopesJSON, _ := json.Marshal(methodScopes)
+	stdioJSON, _ := json.Marshal(nonNil(s.StdioCommand))
+	instructionsJSON, _ := json.Marshal(nonNilMap(s.LocalizedInstructions))
+	audiencesJSON, _ := json.Marshal(nonNil(s.Audiences))
+	claimHeadersJSON, _ := json.Marshal(nonNilMap(s.ClaimHeaderMappings))
+	responseHeadersJSON, _ := json.Marshal(nonNil(s.ResponseHeaders))
+	scopeClaimsJSON, _ := json.Marshal(nonNil(s.ScopeClaims))
+	customMethodsJSON, _ := json.Marshal(nonNil(s.CustomMethods))
+	tagsJSON, _ := json.Marshal(nonNil(s.Tags))
+	_, err := q.ExecContext(ctx, `
+        insert into servers (tenant_id, slug, name, audience, enabled, upstream_base_url, server_title, server_version, instructions, capabilities, allowed_issuers, upstream_base_urls, load_balancing, upstream_weights, method_scopes, redirect_policy, backend, stdio_command, localized_instructions, audiences, claim_header_mappings, health_check, response_headers, scope_claims, timeouts, custom_methods, custom_methods_path, tags)
+        values ((select id from tenants where slug=$1), $2,$3,$4,$5,$6,$7,$8,$9,$10::jsonb,$11::jsonb,$12::jsonb,$13,$14::jsonb,$15::jsonb,$16,$17,$18::jsonb,$19::jsonb,$20::jsonb,$21::jsonb,$22::jsonb,$23::jsonb,$24::jsonb,$25::jsonb,$26::jsonb,$27,$28::jsonb)
         on conflict (slug) do update set
           name=excluded.name,
           audience=excluded.audience,
@@ -131,12 +580,51 @@ func (p *PostgresStore) UpsertServer(s Server) error {
           server_title=excluded.server_title,
           server_version=excluded.server_version,
           instructions=excluded.instructions,
+          capabilities=excluded.capabilities,
+          allowed_issuers=excluded.allowed_issuers,
+          upstream_base_urls=excluded.upstream_base_urls,
+          load_balancing=excluded.load_balancing,
+          upstream_weights=excluded.upstream_weights,
+          method_scopes=excluded.method_scopes,
+          redirect_policy=excluded.redirect_policy,
+          backend=excluded.backend,
+          stdio_command=excluded.stdio_command,
+          localized_instructions=excluded.localized_instructions,
+          audiences=excluded.audiences,
+          claim_header_mappings=excluded.claim_header_mappings,
+          health_check=excluded.health_check,
+          response_headers=excluded.response_headers,
+          scope_claims=excluded.scope_claims,
+          timeouts=excluded.timeouts,
+          custom_methods=excluded.custom_methods,
+          custom_methods_path=excluded.custom_methods_path,
+          tags=excluded.tags,
           updated_at=now()
-    `, s.TenantSlug, s.Slug, s.Name, s.Audience, s.Enabled, s.UpstreamBaseURL, s.ServerTitle, s.ServerVersion, s.Instructions)
+    `, s.TenantSlug, s.Slug, s.Name, s.PrimaryAudience(), s.Enabled, s.UpstreamBaseURL, s.ServerTitle, s.ServerVersion, s.Instructions, capsJSON, string(issuersJSON), string(upstreamsJSON), firstNonEmpty(s.LoadBalancing, "failover"), string(weightsJSON), string(methodScopesJSON), s.RedirectPolicy, firstNonEmpty(s.Backend, "http"), string(stdioJSON), string(instructionsJSON), string(audiencesJSON), string(claimHeadersJSON), healthJSON, string(responseHeadersJSON), string(scopeClaimsJSON), timeoutsJSON, string(customMethodsJSON), s.CustomMethodsPath, string(tagsJSON))
 	return err
 }
 
+// UpsertServerBundle stores a server and its tools in one transaction; a rejected tool
+// rolls back the server change too.
+func (p *PostgresStore) UpsertServerBundle(s Server, tools []Tool) error {
+	defer p.invalidateServer(s.Slug)
+	ctx := context.Background()
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := upsertServer(ctx, tx, s); err != nil {
+		return err
+	}
+	if err := upsertTools(ctx, tx, s.Slug, tools); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func (p *PostgresStore) UpdateServerOpenAPI(serverSlug string, specJSON []byte, sourceURL string) error {
+	defer p.invalidateServer(serverSlug)
 	_, err := p.db.ExecContext(context.Background(), `
         update servers set openapi_json=$2, openapi_source_url=$3, updated_at=now() where slug=$1
     `, serverSlug, specJSON, sourceURL)
@@ -144,13 +632,21 @@ func (p *PostgresStore) UpdateServerOpenAPI(serverSlug string, specJSON []byte,
 }
 
 func (p *PostgresStore) UpsertToolsForServer(serverSlug string, tools []Tool) error {
+	defer p.invalidateServer(serverSlug)
 	tx, err := p.db.BeginTx(context.Background(), nil)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = tx.Rollback() }()
+	if err := upsertTools(context.Background(), tx, serverSlug, tools); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func upsertTools(ctx context.Context, tx dbtx, serverSlug string, tools []Tool) error {
 	var serverID string
-	if err := tx.QueryRowContext(context.Background(), `select id::text from servers where slug=$1`, serverSlug).Scan(&serverID); err != nil {
+	if err := tx.QueryRowContext(ctx, `select id::text from servers where slug=$1`, serverSlug).Scan(&serverID); err != nil {
 		return err
 	}
 	for _, t := range tools {
@@ -159,35 +655,285 @@ func (p *PostgresStore) UpsertToolsForServer(serverSlug string, tools []Tool) er
 		scopesJSON, _ := json.Marshal(t.RequiredScopes)
 		inJSON, _ := json.Marshal(t.InputSchema)
 		outJSON, _ := json.Marshal(t.OutputSchema)
-		if err := tx.QueryRowContext(context.Background(), `
-            insert into tools (server_id, name, title, description, required_scopes, input_schema, output_schema, enabled)
-            values ($1,$2,$3,$4,$5::jsonb,$6::jsonb,$7::jsonb,true)
+		claims := t.RequiredClaims
+		if claims == nil {
+			claims = map[string]interface{}{}
+		}
+		claimsJSON, _ := json.Marshal(claims)
+		var annotationsJSON interface{}
+		if t.Annotations != nil {
+			b, _ := json.Marshal(t.Annotations)
+			annotationsJSON = string(b)
+		}
+		titlesJSON, _ := json.Marshal(nonNilMap(t.LocalizedTitles))
+		descriptionsJSON, _ := json.Marshal(nonNilMap(t.LocalizedDescriptions))
+		sensitiveJSON, _ := json.Marshal(nonNil(t.SensitiveArgs))
+		defaults := t.Defaults
+		if defaults == nil {
+			defaults = map[string]interface{}{}
+		}
+		defaultsJSON, _ := json.Marshal(defaults)
+		aliasesJSON, _ := json.Marshal(nonNil(t.Aliases))
+		if err := tx.QueryRowContext(ctx, `
+            insert into tools (server_id, name, title, description, required_scopes, input_schema, output_schema, enabled, required_claims, skip_method_scopes, annotations, localized_titles, localized_descriptions, sensitive_args, defaults, aliases)
+            values ($1,$2,$3,$4,$5::jsonb,$6::jsonb,$7::jsonb,$8,$9::jsonb,$10,$11::jsonb,$12::jsonb,$13::jsonb,$14::jsonb,$15::jsonb,$16::jsonb)
             on conflict (server_id, name) do update set
               title=excluded.title,
               description=excluded.description,
               required_scopes=excluded.required_scopes,
               input_schema=excluded.input_schema,
               output_schema=excluded.output_schema,
-              enabled=true
+              enabled=excluded.enabled,
+              required_claims=excluded.required_claims,
+              skip_method_scopes=excluded.skip_method_scopes,
+              annotations=excluded.annotations,
+              localized_titles=excluded.localized_titles,
+              localized_descriptions=excluded.localized_descriptions,
+              sensitive_args=excluded.sensitive_args,
+              defaults=excluded.defaults,
+              aliases=excluded.aliases
             returning id::text
-        `, serverID, t.Name, t.Title, t.Description, string(scopesJSON), string(inJSON), string(outJSON)).Scan(&toolID); err != nil {
+        `, serverID, t.Name, t.Title, t.Description, string(scopesJSON), string(inJSON), string(outJSON), t.IsEnabled(), string(claimsJSON), t.SkipMethodScopes, annotationsJSON, string(titlesJSON), string(descriptionsJSON), string(sensitiveJSON), string(defaultsJSON), string(aliasesJSON)).Scan(&toolID); err != nil {
 			return err
 		}
 		qJSON, _ := json.Marshal(t.Mapping.Query)
 		hJSON, _ := json.Marshal(t.Mapping.Headers)
 		bJSON, _ := json.Marshal(t.Mapping.Body)
-		if _, err := tx.ExecContext(context.Background(), `
-            insert into request_mappings (tool_id, method, path, query, headers, body)
-            values ($1,$2,$3,$4::jsonb,$5::jsonb,$6::jsonb)
+		responseHeadersJSON, _ := json.Marshal(nonNil(t.Mapping.ResponseHeaders))
+		transforms := t.Mapping.Transforms
+		if transforms == nil {
+			transforms = []ArgTransform{}
+		}
+		transformsJSON, _ := json.Marshal(transforms)
+		responseMapping := t.Mapping.ResponseMapping
+		if responseMapping == nil {
+			responseMapping = map[string]string{}
+		}
+		responseMappingJSON, _ := json.Marshal(responseMapping)
+		if _, err := tx.ExecContext(ctx, `
+            insert into request_mappings (tool_id, method, path, query, headers, body, cache_ttl_seconds, body_encoding, redirect_policy, compress_request, mapping_type, graphql_query, stream, response_headers, transforms, response_mapping)
+            values ($1,$2,$3,$4::jsonb,$5::jsonb,$6::jsonb,$7,$8,$9,$10,$11,$12,$13,$14::jsonb,$15::jsonb,$16::jsonb)
             on conflict (tool_id) do update set
               method=excluded.method,
               path=excluded.path,
               query=excluded.query,
               headers=excluded.headers,
-              body=excluded.body
-        `, toolID, t.Mapping.Method, t.Mapping.Path, string(qJSON), string(hJSON), string(bJSON)); err != nil {
+              body=excluded.body,
+              cache_ttl_seconds=excluded.cache_ttl_seconds,
+              body_encoding=excluded.body_encoding,
+              redirect_policy=excluded.redirect_policy,
+              compress_request=excluded.compress_request,
+              mapping_type=excluded.mapping_type,
+              graphql_query=excluded.graphql_query,
+              stream=excluded.stream,
+              response_headers=excluded.response_headers,
+              transforms=excluded.transforms,
+              response_mapping=excluded.response_mapping
+        `, toolID, t.Mapping.Method, t.Mapping.Path, string(qJSON), string(hJSON), string(bJSON), t.Mapping.CacheTTLSeconds, firstNonEmpty(t.Mapping.BodyEncoding, "json"), t.Mapping.RedirectPolicy, t.Mapping.CompressRequest, firstNonEmpty(t.Mapping.Type, "rest"), t.Mapping.GraphQLQuery, t.Mapping.Stream, string(responseHeadersJSON), string(transformsJSON), string(responseMappingJSON)); err != nil {
 			return err
 		}
 	}
+	// Check names and aliases across the server's tools, including ones not in this upsert
+	rows, err := tx.QueryContext(ctx, `select name, coalesce(aliases,'[]'::jsonb) from tools where server_id=$1`, serverID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	all := []Tool{}
+	for rows.Next() {
+		var t Tool
+		var aliasesJSON []byte
+		if err := rows.Scan(&t.Name, &aliasesJSON); err != nil {
+			return err
+		}
+		_ = jsonUnmarshal(aliasesJSON, &t.Aliases)
+		all = append(all, t)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return checkToolNames(all)
+}
+
+// SetToolsEnabled flips the enabled flag of the named tools, leaving their definitions
+// untouched. Nothing changes if any name is unknown.
+func (p *PostgresStore) SetToolsEnabled(serverSlug string, names []string, enabled bool) error {
+	defer p.invalidateServer(serverSlug)
+	ctx := context.Background()
+	unique := map[string]bool{}
+	for _, n := range names {
+		unique[n] = true
+	}
+	namesJSON, _ := json.Marshal(nonNil(names))
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	rows, err := tx.QueryContext(ctx, `
+        update tools set enabled=$3
+        where server_id=(select id from servers where slug=$1)
+          and name in (select jsonb_array_elements_text($2::jsonb))
+        returning name
+    `, serverSlug, string(namesJSON), enabled)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		delete(unique, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, n := range names {
+		if unique[n] {
+			return fmt.Errorf("%w: %s", ErrToolNotFound, n)
+		}
+	}
 	return tx.Commit()
 }
+
+// ImportTenant recreates a tenant with its servers and tools in a single transaction.
+func (p *PostgresStore) ImportTenant(exp TenantExport) error {
+	defer p.cache.clear()
+	ctx := context.Background()
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := upsertTenant(ctx, tx, exp.Tenant); err != nil {
+		return err
+	}
+	for _, se := range exp.Servers {
+		se.Server.TenantSlug = exp.Tenant.Slug
+		if err := upsertServer(ctx, tx, se.Server); err != nil {
+			return err
+		}
+		if err := upsertTools(ctx, tx, se.Server.Slug, se.Tools); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// --- API keys ---
+
+func (p *PostgresStore) CreateAPIKey(k APIKey) error {
+	serversJSON, _ := json.Marshal(k.Servers)
+	scopesJSON, _ := json.Marshal(k.Scopes)
+	_, err := p.db.ExecContext(context.Background(), `
+        insert into api_keys (id, tenant_id, label, key_hash, servers, scopes)
+        values ($1::uuid, (select id from tenants where slug=$2), $3, $4, $5::jsonb, $6::jsonb)
+    `, k.ID, k.TenantSlug, k.Label, k.KeyHash, string(serversJSON), string(scopesJSON))
+	return err
+}
+
+func (p *PostgresStore) GetAPIKeyByHash(hash string) (APIKey, error) {
+	var k APIKey
+	var serversJSON, scopesJSON []byte
+	var created time.Time
+	row := p.db.QueryRowContext(context.Background(), `
+        select k.id::text, t.slug, coalesce(k.label,''), k.key_hash, k.servers, k.scopes, k.revoked, k.created_at
+        from api_keys k
+        join tenants t on t.id = k.tenant_id
+        where k.key_hash=$1
+    `, hash)
+	if err := row.Scan(&k.ID, &k.TenantSlug, &k.Label, &k.KeyHash, &serversJSON, &scopesJSON, &k.Revoked, &created); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return APIKey{}, ErrAPIKeyNotFound
+		}
+		return APIKey{}, err
+	}
+	_ = jsonUnmarshal(serversJSON, &k.Servers)
+	_ = jsonUnmarshal(scopesJSON, &k.Scopes)
+	k.CreatedUnixMilli = created.UnixMilli()
+	return k, nil
+}
+
+func (p *PostgresStore) RevokeAPIKey(id string) error {
+	res, err := p.db.ExecContext(context.Background(), `update api_keys set revoked=true where id::text=$1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// --- Admin tokens ---
+
+func (p *PostgresStore) CreateAdminToken(t AdminToken) error {
+	_, err := p.db.ExecContext(context.Background(), `
+        insert into admin_tokens (id, label, token_hash, revoked)
+        values ($1, $2, $3, $4)
+    `, t.ID, t.Label, t.TokenHash, t.Revoked)
+	return err
+}
+
+// ListAdminTokens returns every token, revoked ones included, ordered by creation time.
+func (p *PostgresStore) ListAdminTokens() ([]AdminToken, error) {
+	rows, err := p.db.QueryContext(context.Background(), `
+        select id, coalesce(label,''), token_hash, revoked, created_at
+        from admin_tokens
+        order by created_at, id
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AdminToken
+	for rows.Next() {
+		var t AdminToken
+		var created time.Time
+		if err := rows.Scan(&t.ID, &t.Label, &t.TokenHash, &t.Revoked, &created); err != nil {
+			return nil, err
+		}
+		t.CreatedUnixMilli = created.UnixMilli()
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (p *PostgresStore) RevokeAdminToken(id string) error {
+	res, err := p.db.ExecContext(context.Background(), `update admin_tokens set revoked=true where id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAdminTokenNotFound
+	}
+	return nil
+}
+
+// AllAuthorizationServerRefs returns the deduped issuers across all tenants and servers.
+func (p *PostgresStore) AllAuthorizationServerRefs() []AuthorizationServerRef {
+	rows, err := p.db.QueryContext(context.Background(), `
+        select distinct iss from (
+          select jsonb_array_elements_text(allowed_issuers) as iss from tenants
+          union
+          select jsonb_array_elements_text(allowed_issuers) as iss from servers
+        ) i
+        order by iss
+    `)
+	if err != nil {
+		return []AuthorizationServerRef{}
+	}
+	defer rows.Close()
+	out := []AuthorizationServerRef{}
+	for rows.Next() {
+		var iss string
+		if err := rows.Scan(&iss); err != nil {
+			return out
+		}
+		out = append(out, AuthorizationServerRef{Issuer: iss, MetadataURL: iss + "/.well-known/openid-configuration"})
+	}
+	return out
+}
@@ -24,6 +24,58 @@ create table if not exists servers (
   updated_at timestamptz not null default now()
 );
 
+-- JWT issuers accepted per tenant, with optional per-server override
+alter table tenants add column if not exists allowed_issuers jsonb not null default '[]'::jsonb;
+alter table servers add column if not exists allowed_issuers jsonb not null default '[]'::jsonb;
+
+-- Token claims holding scopes, with optional per-server override (empty means scope/scopes)
+alter table tenants add column if not exists scope_claims jsonb not null default '[]'::jsonb;
+alter table servers add column if not exists scope_claims jsonb not null default '[]'::jsonb;
+
+-- Ordered upstream failover list (empty means use upstream_base_url)
+alter table servers add column if not exists upstream_base_urls jsonb not null default '[]'::jsonb;
+alter table servers add column if not exists load_balancing text not null default 'failover';
+alter table servers add column if not exists upstream_weights jsonb not null default '[]'::jsonb;
+
+-- Optional HTTP-method scope policy layered on per-tool required scopes
+alter table servers add column if not exists method_scopes jsonb not null default '{}'::jsonb;
+
+-- Upstream redirect handling: follow (default), follow-same-host or none
+alter table servers add column if not exists redirect_policy text not null default '';
+
+-- Backend type: http (default) or stdio, with the child process command line
+alter table servers add column if not exists backend text not null default 'http';
+alter table servers add column if not exists stdio_command jsonb not null default '[]'::jsonb;
+
+-- Optional translations of instructions keyed by language tag
+alter table servers add column if not exists localized_instructions jsonb not null default '{}'::jsonb;
+
+-- Further token audiences accepted besides the primary audience column
+alter table servers add column if not exists audiences jsonb not null default '[]'::jsonb;
+
+-- Token claims forwarded to upstreams as headers (claim name -> header name)
+alter table servers add column if not exists claim_header_mappings jsonb not null default '{}'::jsonb;
+
+-- Optional active upstream health probing (null disables it)
+alter table servers add column if not exists health_check jsonb;
+
+-- Upstream response headers passed through as tools/call result metadata
+alter table servers add column if not exists response_headers jsonb not null default '[]'::jsonb;
+
+-- Optional per-server upstream timeouts (null keeps the gateway defaults)
+alter table servers add column if not exists timeouts jsonb;
+
+-- Custom JSON-RPC methods passed through to the upstream, and the HTTP path they go to
+alter table servers add column if not exists custom_methods jsonb not null default '[]'::jsonb;
+alter table servers add column if not exists custom_methods_path text not null default '';
+
+-- Labels for grouping and finding servers
+alter table servers add column if not exists tags jsonb not null default '[]'::jsonb;
+create index if not exists servers_tags_idx on servers using gin (tags);
+
+-- Optional per-server capability flags (null means tools-only)
+alter table servers add column if not exists capabilities jsonb;
+
 create table if not exists tools (
   id uuid primary key default gen_random_uuid(),
   server_id uuid not null references servers(id) on delete cascade,
@@ -46,7 +98,77 @@ create table if not exists request_mappings (
   body jsonb default '{}'::jsonb
 );
 
+-- Optional claim constraints per tool
+alter table tools add column if not exists required_claims jsonb not null default '{}'::jsonb;
+alter table tools add column if not exists skip_method_scopes boolean not null default false;
+
+-- Optional MCP tool annotations (null derives hints from the HTTP method)
+alter table tools add column if not exists annotations jsonb;
+
+-- Optional translations of tool title and description keyed by language tag
+alter table tools add column if not exists localized_titles jsonb not null default '{}'::jsonb;
+alter table tools add column if not exists localized_descriptions jsonb not null default '{}'::jsonb;
+
+-- Argument names redacted in logs and error data
+alter table tools add column if not exists sensitive_args jsonb not null default '[]'::jsonb;
+
+-- Argument values used when the caller omits them
+alter table tools add column if not exists defaults jsonb not null default '{}'::jsonb;
+
+-- Former tool names still accepted by tools/call
+alter table tools add column if not exists aliases jsonb not null default '[]'::jsonb;
+
+-- Optional response cache TTL for GET mappings
+alter table request_mappings add column if not exists cache_ttl_seconds integer not null default 0;
+
+-- Upstream body encoding: json (default), form or multipart
+alter table request_mappings add column if not exists body_encoding text not null default 'json';
+
+-- Optional per-tool override of the server redirect policy (empty inherits)
+alter table request_mappings add column if not exists redirect_policy text not null default '';
+
+-- Gzip large request bodies for upstreams that accept Content-Encoding: gzip
+alter table request_mappings add column if not exists compress_request boolean not null default false;
+
+-- Relay upstream responses as they arrive instead of buffering them
+alter table request_mappings add column if not exists stream boolean not null default false;
+
+-- Response headers passed through on top of the server's list
+alter table request_mappings add column if not exists response_headers jsonb not null default '[]'::jsonb;
+
+-- Argument transformation expressions applied before templating
+alter table request_mappings add column if not exists transforms jsonb not null default '[]'::jsonb;
+
+-- Projection of successful JSON responses: output field to a dotted path into the body
+alter table request_mappings add column if not exists response_mapping jsonb not null default '{}'::jsonb;
+
+-- Mapping type: rest (default) or graphql with the query document stored alongside
+alter table request_mappings add column if not exists mapping_type text not null default 'rest';
+alter table request_mappings add column if not exists graphql_query text not null default '';
+
+-- Tenant-scoped API keys (only the SHA-256 hash of the secret is stored)
+create table if not exists api_keys (
+  id uuid primary key default gen_random_uuid(),
+  tenant_id uuid not null references tenants(id) on delete cascade,
+  label text,
+  key_hash text unique not null,
+  servers jsonb not null default '[]'::jsonb,
+  scopes jsonb not null default '[]'::jsonb,
+  revoked boolean not null default false,
+  created_at timestamptz not null default now()
+);
+
+-- Control-plane tokens; revoked rows are kept so a revoked ADMIN_TOKEN entry is not re-added
+create table if not exists admin_tokens (
+  id text primary key,
+  label text,
+  token_hash text unique not null,
+  revoked boolean not null default false,
+  created_at timestamptz not null default now()
+);
+
 -- Convenience view to fetch tools with mapping and server slug
+-- (new columns must be appended at the end: create or replace view cannot reorder them)
 create or replace view tools_with_mappings as
 select
   t.id,
@@ -62,7 +184,25 @@ select
   m.path,
   m.query,
   m.headers,
-  m.body
+  m.body,
+  m.cache_ttl_seconds,
+  m.body_encoding,
+  t.required_claims,
+  t.skip_method_scopes,
+  m.redirect_policy,
+  m.compress_request,
+  m.mapping_type,
+  m.graphql_query,
+  t.annotations,
+  t.localized_titles,
+  t.localized_descriptions,
+  m.stream,
+  t.sensitive_args,
+  m.response_headers,
+  t.defaults,
+  m.transforms,
+  t.aliases,
+  m.response_mapping
 from tools t
 join request_mappings m on m.tool_id = t.id
 join servers s on s.id = t.server_id;
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetServerWithTenant(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s backend, slug func(string) string) {
+		server := seedServer(t, s, slug)
+		tenant, err := s.GetTenant(slug("acme"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tenant.AllowedIssuers = []string{"https://idp.example.com"}
+		tenant.EgressAllowlist = []string{"api.example.com"}
+		if err := s.UpsertTenant(tenant); err != nil {
+			t.Fatal(err)
+		}
+
+		srv, got, err := s.GetServerWithTenant(server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantSrv, _ := s.GetServer(server)
+		wantTenant, _ := s.GetTenant(slug("acme"))
+		if !reflect.DeepEqual(srv, wantSrv) {
+			t.Fatalf("server %+v, want %+v", srv, wantSrv)
+		}
+		if !reflect.DeepEqual(got, wantTenant) {
+			t.Fatalf("tenant %+v, want %+v", got, wantTenant)
+		}
+		if got.Slug != srv.TenantSlug {
+			t.Fatalf("tenant %s for a server of %s", got.Slug, srv.TenantSlug)
+		}
+
+		if _, _, err := s.GetServerWithTenant(slug("nope")); err == nil || errors.Is(err, ErrTenantNotFound) {
+			t.Fatalf("unknown server: err = %v", err)
+		}
+	})
+}
+
+func TestGetServerWithTenantMissingTenant(t *testing.T) {
+	// Postgres cannot hold a server without its tenant; the memory store can
+	s := NewMemoryStore("https://api.example.com")
+	if err := s.UpsertServer(Server{Slug: "orders", TenantSlug: "ghost", Name: "orders", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := s.GetServerWithTenant("orders")
+	if !errors.Is(err, ErrTenantNotFound) || !strings.Contains(err.Error(), "orders") || !strings.Contains(err.Error(), "ghost") {
+		t.Fatalf("err = %v, want ErrTenantNotFound naming the server and tenant", err)
+	}
+}
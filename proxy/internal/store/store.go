@@ -1,5 +1,7 @@
 package store
 
+import "errors"
+
 // Store defines the minimal interface used by handlers so we can plug
 // different backends (memory, postgres, etc.).
 type Store interface {
@@ -7,6 +9,20 @@ type Store interface {
 
 	GetTenant(slug string) (Tenant, error)
 	GetServer(slug string) (Server, error)
+	// GetServerWithTenant returns a server and its tenant in one lookup. A server whose
+	// tenant is missing yields an error matching ErrTenantNotFound.
+	GetServerWithTenant(slug string) (Server, Tenant, error)
 
 	ListToolsByServer(serverSlug string) ([]Tool, error)
+	// GetToolByName returns the enabled tool advertised under name, or ErrToolNotFound.
+	GetToolByName(serverSlug, name string) (Tool, error)
+	// ListToolsByServerPaged returns one page of enabled tools ordered by name, optionally
+	// restricted to names starting with nameFilter, plus the total number of matches.
+	ListToolsByServerPaged(serverSlug string, limit, offset int, nameFilter string) ([]Tool, int, error)
 }
+
+// ErrTenantNotFound is returned when a server's tenant does not exist.
+var ErrTenantNotFound = errors.New("tenant not found")
+
+// ErrManagedByFile is returned for control-plane writes to a server owned by definition files.
+var ErrManagedByFile = errors.New("server is managed by definition files")
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestServerTags(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s backend, slug func(string) string) {
+		server := seedServer(t, s, slug)
+		team, prod := slug("team-payments"), slug("prod")
+		srv, _ := s.GetServer(server)
+		srv.Tags = []string{team, prod}
+		if err := s.UpsertServer(srv); err != nil {
+			t.Fatal(err)
+		}
+		billing := Server{Slug: slug("billing"), TenantSlug: slug("acme"), Name: "billing", Enabled: true, Audience: "https://api.example.com", Tags: []string{team}}
+		if err := s.UpsertServer(billing); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := s.GetServer(server); strings.Join(got.Tags, ",") != team+","+prod {
+			t.Fatalf("tags %v", got.Tags)
+		}
+
+		slugs := func(tag string) string {
+			t.Helper()
+			servers, err := s.ListServersByTag(tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := make([]string, len(servers))
+			for i, srv := range servers {
+				out[i] = srv.Slug
+			}
+			return strings.Join(out, ",")
+		}
+		if got := slugs(team); got != billing.Slug+","+server {
+			t.Fatalf("tagged %s: %s", team, got)
+		}
+		if got := slugs(prod); got != server {
+			t.Fatalf("tagged %s: %s", prod, got)
+		}
+		if got := slugs(slug("unused")); got != "" {
+			t.Fatalf("unused tag: %s", got)
+		}
+		if all := slugs(""); !strings.Contains(all, server) || !strings.Contains(all, billing.Slug) {
+			t.Fatalf("no tag: %s, want every server", all)
+		}
+
+		// An upsert replaces the tags
+		srv.Tags = []string{prod}
+		if err := s.UpsertServer(srv); err != nil {
+			t.Fatal(err)
+		}
+		if got := slugs(team); got != billing.Slug {
+			t.Fatalf("after retagging, tagged %s: %s", team, got)
+		}
+		srv.Tags = nil
+		if err := s.UpsertServer(srv); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := s.GetServer(server); len(got.Tags) != 0 || slugs(prod) != "" {
+			t.Fatalf("after clearing: tags %v, tagged %s: %s", got.Tags, prod, slugs(prod))
+		}
+	})
+}
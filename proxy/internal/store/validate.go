@@ -0,0 +1,106 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ConfigProblem is a misconfiguration found by Validate. Server and Tool are empty when
+// the problem is not specific to one.
+type ConfigProblem struct {
+	Server  string `json:"server,omitempty"`
+	Tool    string `json:"tool,omitempty"`
+	Problem string `json:"problem"`
+}
+
+func (p ConfigProblem) String() string {
+	switch {
+	case p.Tool != "":
+		return fmt.Sprintf("server %s tool %s: %s", p.Server, p.Tool, p.Problem)
+	case p.Server != "":
+		return fmt.Sprintf("server %s: %s", p.Server, p.Problem)
+	}
+	return p.Problem
+}
+
+// validationSource is the subset of store methods Validate reads.
+type validationSource interface {
+	ListServers() ([]Server, error)
+	GetTenant(slug string) (Tenant, error)
+	ListToolDefinitions(serverSlug string) ([]Tool, error)
+}
+
+// validate reports servers whose tenant is missing or that lack an audience or backend
+// settings, and tools whose mapping cannot produce a request.
+func validate(src validationSource) ([]ConfigProblem, error) {
+	servers, err := src.ListServers()
+	if err != nil {
+		return nil, err
+	}
+	problems := []ConfigProblem{}
+	for _, srv := range servers {
+		add := func(tool, format string, args ...interface{}) {
+			problems = append(problems, ConfigProblem{Server: srv.Slug, Tool: tool, Problem: fmt.Sprintf(format, args...)})
+		}
+		if _, err := src.GetTenant(srv.TenantSlug); err != nil {
+			add("", "tenant %q does not exist", srv.TenantSlug)
+		}
+		if srv.PrimaryAudience() == "" {
+			add("", "no audience set")
+		}
+		if srv.Backend == "stdio" {
+			if len(srv.StdioCommand) == 0 {
+				add("", "stdio backend without stdioCommand")
+			}
+			continue
+		}
+		if len(srv.UpstreamBases()) == 0 {
+			add("", "no upstream base URL set")
+		}
+		tools, err := src.ListToolDefinitions(srv.Slug)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tools {
+			switch {
+			case t.Name == "":
+				add("", "tool without a name")
+			case t.Mapping.Type == "graphql":
+				if t.Mapping.GraphQLQuery == "" || t.Mapping.Path == "" {
+					add(t.Name, "graphql mapping needs path and graphqlQuery")
+				}
+			case t.Mapping.Method == "" || t.Mapping.Path == "":
+				add(t.Name, "mapping needs method and path")
+			}
+		}
+	}
+	return problems, nil
+}
+
+// Validate checks the stored configuration for references to missing tenants or servers
+// and for required settings left empty.
+func (s *MemoryStore) Validate() ([]ConfigProblem, error) {
+	problems, err := validate(s)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var orphans []string
+	for slug, tools := range s.toolsByServer {
+		if _, ok := s.servers[slug]; !ok && len(tools) > 0 {
+			orphans = append(orphans, slug)
+		}
+	}
+	sort.Strings(orphans)
+	for _, slug := range orphans {
+		problems = append(problems, ConfigProblem{Server: slug, Problem: fmt.Sprintf("%d tools reference a server that does not exist", len(s.toolsByServer[slug]))})
+	}
+	return problems, nil
+}
+
+// Validate checks the stored configuration for required settings left empty. Foreign keys
+// already rule out servers without a tenant and tools without a server.
+func (p *PostgresStore) Validate() ([]ConfigProblem, error) {
+	return validate(p)
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func validStore(t *testing.T) *MemoryStore {
+	t.Helper()
+	s := NewMemoryStore("https://api.example.com")
+	if err := s.UpsertTenant(Tenant{Slug: "acme", Name: "Acme", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertServerBundle(Server{Slug: "orders", TenantSlug: "acme", Enabled: true, Audience: "https://api.example.com", UpstreamBaseURL: "https://orders.example.com"},
+		[]Tool{getTool("get_order", "/orders/{{id}}")}); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestValidateCleanConfiguration(t *testing.T) {
+	problems, err := validStore(t).Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("problems %v, want none", problems)
+	}
+}
+
+func TestValidateDanglingServer(t *testing.T) {
+	s := validStore(t)
+	if err := s.UpsertServer(Server{Slug: "billing", TenantSlug: "globex", Audience: "https://api.example.com", UpstreamBaseURL: "https://billing.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	problems, err := s.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || problems[0].Server != "billing" || !strings.Contains(problems[0].Problem, `tenant "globex" does not exist`) {
+		t.Fatalf("problems %v, want billing's missing tenant", problems)
+	}
+}
+
+func TestValidateToolOnMissingServer(t *testing.T) {
+	s := validStore(t)
+	if err := s.UpsertToolsForServer("billing", []Tool{getTool("list_invoices", "/invoices")}); err != nil {
+		t.Fatal(err)
+	}
+	problems, err := s.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || problems[0].Server != "billing" || !strings.Contains(problems[0].String(), "reference a server that does not exist") {
+		t.Fatalf("problems %v, want billing's orphaned tools", problems)
+	}
+}
+
+func TestValidateRequiredSettings(t *testing.T) {
+	s := validStore(t)
+	if err := s.UpsertServerBundle(Server{Slug: "billing", TenantSlug: "acme"}, []Tool{{Name: "broken"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertServer(Server{Slug: "local", TenantSlug: "acme", Audience: "https://api.example.com", Backend: "stdio"}); err != nil {
+		t.Fatal(err)
+	}
+	problems, err := s.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, len(problems))
+	for i, p := range problems {
+		got[i] = p.String()
+	}
+	for _, want := range []string{
+		"server billing: no audience set",
+		"server billing: no upstream base URL set",
+		"server billing tool broken: mapping needs method and path",
+		"server local: stdio backend without stdioCommand",
+	} {
+		if !strings.Contains(strings.Join(got, "\n"), want) {
+			t.Errorf("problems %q, missing %q", got, want)
+		}
+	}
+	if len(problems) != 4 {
+		t.Fatalf("%d problems, want 4: %q", len(problems), got)
+	}
+}